@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// annotateCmd attaches notes to a context of the managed kubeconfig
+var annotateCmd = &cobra.Command{
+	Use:   "annotate <context>",
+	Short: "Attach free-form notes to a context of the managed kubeconfig",
+	Long: `Attach free-form notes, such as the owning team or an on-call contact, to a
+context of the managed kubeconfig. The notes are stored in the context's
+"kubeconfig-wrangler" extension, which kubectl ignores, are shown by "describe"
+and "list", and are kept when "generate" rewrites the file.
+
+Without --note or --clear, the notes of the context are printed.
+
+Examples:
+  # Record the owner of a context
+  kubeconfig-wrangler annotate prod-payments --note "owned by team-x" --kubeconfig ~/.kube/rancher-config
+
+  # Replace the notes
+  kubeconfig-wrangler annotate prod-payments --clear --note "owned by team-y, #team-y-oncall"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAnnotate,
+}
+
+var (
+	annotateNotes     []string
+	annotateClear     bool
+	managedKubeconfig string
+)
+
+func init() {
+	rootCmd.AddCommand(annotateCmd)
+	annotateCmd.Flags().StringArrayVar(&annotateNotes, "note", nil, "Note to add to the context (repeatable)")
+	annotateCmd.Flags().BoolVar(&annotateClear, "clear", false, "Remove the existing notes first")
+	addManagedKubeconfigFlag(annotateCmd)
+}
+
+// addManagedKubeconfigFlag registers the flag naming the kubeconfig written by "generate"
+func addManagedKubeconfigFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&managedKubeconfig, "kubeconfig", "", "Managed kubeconfig written by generate (env: RANCHER_KUBECONFIG_OUTPUT)")
+}
+
+// managedKubeconfigPath returns the --kubeconfig flag or RANCHER_KUBECONFIG_OUTPUT
+func managedKubeconfigPath() string {
+	if managedKubeconfig != "" {
+		return managedKubeconfig
+	}
+	return loadConfig().OutputPath
+}
+
+// loadManagedContext loads the managed kubeconfig and looks up one of its contexts
+func loadManagedContext(name string) (string, *api.Config, *api.Context, error) {
+	path := managedKubeconfigPath()
+	if path == "" {
+		return "", nil, nil, fmt.Errorf("configuration error: --kubeconfig or RANCHER_KUBECONFIG_OUTPUT is required")
+	}
+	managed, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	context, ok := managed.Contexts[name]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("context %s not found in %s", name, path)
+	}
+	return path, managed, context, nil
+}
+
+func runAnnotate(cmd *cobra.Command, args []string) error {
+	path, managed, context, err := loadManagedContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(annotateNotes) == 0 && !annotateClear {
+		for _, note := range kubeconfig.ContextNotes(context) {
+			fmt.Println(note)
+		}
+		return nil
+	}
+
+	if annotateClear {
+		if err := kubeconfig.ClearNotes(context); err != nil {
+			return err
+		}
+	}
+	for _, note := range annotateNotes {
+		if err := kubeconfig.AddNote(context, note); err != nil {
+			return err
+		}
+	}
+
+	data, err := kubeconfig.NewGenerator("").Serialize(managed)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Context %s now has %d note(s)\n", args[0], len(kubeconfig.ContextNotes(context)))
+	return nil
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+var (
+	apiData    string
+	apiInclude bool
+	apiRaw     bool
+)
+
+// apiCmd represents the api command
+var apiCmd = &cobra.Command{
+	Use:   "api [method] <path>",
+	Short: "Send a raw request to the Rancher API",
+	Long: `Send an authenticated request to the configured Rancher server and print
+the response, pretty-printing JSON.
+
+The request goes through the same TLS, authentication, impersonation and
+retry handling as every other command, which makes it the quickest way to
+see what Rancher actually returns when a field the tool expects is missing.
+
+The method defaults to GET. The path is relative to the Rancher URL;
+absolute URLs are refused so the credentials never go elsewhere. A status of
+400 or above is printed and then reported as an error.
+
+Examples:
+  # Show the first ten clusters as Rancher returns them
+  kubeconfig-wrangler api GET '/v3/clusters?limit=10'
+
+  # Show one cluster with the response headers
+  kubeconfig-wrangler api -i /v3/clusters/c-m-abc123
+
+  # Send a request body from a file, or from stdin with @-
+  kubeconfig-wrangler api POST '/v3/clusters/c-m-abc123?action=generateKubeconfig' --data @body.json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runAPI,
+}
+
+func init() {
+	addRancherFlags(apiCmd)
+	apiCmd.Flags().StringVarP(&apiData, "data", "d", "", "Request body, or @file to read it from a file (@- for stdin)")
+	apiCmd.Flags().BoolVarP(&apiInclude, "include", "i", false, "Print the response status and headers before the body")
+	apiCmd.Flags().BoolVar(&apiRaw, "raw", false, "Print the response body as received, without pretty-printing")
+	rootCmd.AddCommand(apiCmd)
+}
+
+func runAPI(cmd *cobra.Command, args []string) error {
+	method, path := "GET", args[0]
+	if len(args) == 2 {
+		method, path = strings.ToUpper(args[0]), args[1]
+	}
+
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	plan := rancher.NewPlan("api", cfg)
+	call := rancher.PlannedCall{Method: method, Path: path, Count: "once", Purpose: "passthrough request"}
+	if method != "GET" && method != "HEAD" {
+		call.Creates = "whatever the request itself creates"
+	}
+	plan.Add(call)
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	body, err := readAPIData(apiData)
+	if err != nil {
+		return err
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	resp, err := client.Raw(method, path, reader)
+	if err != nil {
+		return err
+	}
+
+	if apiInclude {
+		fmt.Println(resp.Status)
+		names := make([]string, 0, len(resp.Header))
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range resp.Header[name] {
+				fmt.Printf("%s: %s\n", name, value)
+			}
+		}
+		fmt.Println()
+	}
+
+	output := resp.Body
+	if !apiRaw {
+		var indented bytes.Buffer
+		if json.Indent(&indented, resp.Body, "", "  ") == nil {
+			output = indented.Bytes()
+		}
+	}
+	os.Stdout.Write(output)
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		fmt.Println()
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("rancher answered %s", resp.Status)
+	}
+	return nil
+}
+
+// readAPIData returns the request body given with --data, reading it from a
+// file or stdin when prefixed with @, or nil when no body was given
+func readAPIData(data string) ([]byte, error) {
+	switch {
+	case data == "":
+		return nil, nil
+	case data == "@-":
+		body, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body from stdin: %w", err)
+		}
+		return body, nil
+	case strings.HasPrefix(data, "@"):
+		body, err := os.ReadFile(data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		return body, nil
+	default:
+		return []byte(data), nil
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// clustersCmd groups commands that operate on a single Rancher cluster
+var clustersCmd = &cobra.Command{
+	Use:   "clusters",
+	Short: "Inspect individual Rancher clusters",
+}
+
+// registrationTokenCmd prints the agent import command of a cluster
+var registrationTokenCmd = &cobra.Command{
+	Use:   "registration-token <cluster>",
+	Short: "Show the registration (import) command of a cluster",
+	Long: `Show the command that installs or re-installs the Rancher agents on a
+cluster, taken from the cluster's registration token. This is what you need
+when re-importing a cluster whose agents were removed or lost contact.
+
+The cluster may be given by name or ID.
+
+Examples:
+  # Show the import commands of a cluster
+  kubeconfig-wrangler clusters registration-token prod-eu
+
+  # Print only the kubectl command, for scripting
+  kubeconfig-wrangler clusters registration-token c-m-abc123 --command-only
+
+  # Request a registration token if the cluster has none yet
+  kubeconfig-wrangler clusters registration-token prod-eu --create`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistrationToken,
+}
+
+var (
+	registrationCreate      bool
+	registrationCommandOnly bool
+	registrationInsecure    bool
+)
+
+func init() {
+	rootCmd.AddCommand(clustersCmd)
+	clustersCmd.AddCommand(registrationTokenCmd)
+	addRancherFlags(registrationTokenCmd)
+	registrationTokenCmd.Flags().BoolVar(&registrationCreate, "create", false, "Request a new registration token if the cluster has none")
+	registrationTokenCmd.Flags().BoolVar(&registrationCommandOnly, "command-only", false, "Print only the kubectl import command")
+	registrationTokenCmd.Flags().BoolVar(&registrationInsecure, "insecure", false, "With --command-only, print the command that skips TLS verification of the Rancher server (for self-signed certificates)")
+}
+
+func runRegistrationToken(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	plan := rancher.NewPlan("clusters registration-token", cfg)
+	plan.ListClusters()
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/clusterregistrationtokens?clusterId=<id>", Count: "once", Purpose: "find the cluster's registration token"})
+	if registrationCreate {
+		plan.Add(rancher.PlannedCall{
+			Method:  "POST",
+			Path:    "/v3/clusterregistrationtokens",
+			Count:   "once, if the cluster has none",
+			Purpose: "request a registration token",
+			Creates: "a cluster registration token",
+		})
+	}
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+
+	cluster, err := client.FindCluster(args[0])
+	if err != nil {
+		return err
+	}
+
+	token, err := client.GetRegistrationToken(cluster.ID, registrationCreate)
+	if err != nil {
+		return fmt.Errorf("failed to get registration token: %w", err)
+	}
+	if token.Command == "" && token.ManifestURL == "" {
+		return fmt.Errorf("rancher has not filled in the registration token %s yet; run the command again in a few seconds", token.ID)
+	}
+
+	if registrationCommandOnly {
+		if registrationInsecure {
+			fmt.Println(token.InsecureCommand)
+		} else {
+			fmt.Println(token.Command)
+		}
+		return nil
+	}
+
+	fmt.Printf("Cluster:        %s (%s)\n", cluster.Name, cluster.ID)
+	fmt.Printf("Token:          %s\n", token.ID)
+	if token.ManifestURL != "" {
+		fmt.Printf("Manifest URL:   %s\n", token.ManifestURL)
+	}
+	if token.Command != "" {
+		fmt.Printf("\nImport the cluster (run against the downstream cluster):\n  %s\n", token.Command)
+	}
+	if token.InsecureCommand != "" {
+		fmt.Printf("\nIf the Rancher certificate is not trusted by the cluster:\n  %s\n", token.InsecureCommand)
+	}
+	if token.NodeCommand != "" {
+		fmt.Printf("\nRegister a custom node:\n  %s\n", token.NodeCommand)
+	}
+	if token.WindowsNodeCommand != "" {
+		fmt.Printf("\nRegister a Windows node:\n  %s\n", token.WindowsNodeCommand)
+	}
+	return nil
+}
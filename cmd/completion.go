@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+const (
+	// completionCacheTTL is how long the cluster names fetched for shell
+	// completion are reused before Rancher is asked again
+	completionCacheTTL = 5 * time.Minute
+
+	// completionFailureTTL is how long completion stops asking a Rancher
+	// server that could not list its clusters
+	completionFailureTTL = 30 * time.Second
+
+	// completionTimeout bounds each request made while completing
+	completionTimeout = 2 * time.Second
+)
+
+// completionCache is the cached outcome of listing the clusters for completion
+type completionCache struct {
+	Names  []string `json:"names,omitempty"`
+	Failed bool     `json:"failed,omitempty"`
+}
+
+// completing is set while computing shell completions, which must never wait
+// for input on the terminal the user is typing in
+var completing bool
+
+// errCompleting is returned instead of asking for input, or running a
+// credential command, while completing
+var errCompleting = errors.New("cannot ask for input or run commands during shell completion")
+
+// completeClusterNames completes the names of the clusters of the configured
+// Rancher server, for flags taking a comma-separated list of them. Failures
+// complete nothing rather than disturbing the shell.
+func completeClusterNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Shell completion does not run the root command's hooks
+	completing = true
+	if readEnvFile() != nil || readConfigFile() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil || cfg.Validate() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := cachedClusterNames(cfg)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// Complete the last element of a list such as prod-a,prod-b,pr
+	done, partial := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		done, partial = toComplete[:i+1], toComplete[i+1:]
+	}
+	given := strings.Split(done, ",")
+	var completions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, partial) && !slices.Contains(given, name) {
+			completions = append(completions, done+name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// cachedClusterNames returns the names of the clusters of cfg's Rancher
+// server, listing them at most once per completionCacheTTL, or once per
+// completionFailureTTL while that fails. The cache is kept in the cache
+// directory, by server and identity. Listing neither waits for Rancher nor
+// retries, and gives up after completionTimeout.
+func cachedClusterNames(cfg *config.Config) ([]string, error) {
+	dir, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(cfg.RancherURL + "\x00" + cfg.AccessKey + cfg.Username))
+	path := filepath.Join(dir, "completion", hex.EncodeToString(sum[:8])+".json")
+
+	if info, err := os.Stat(path); err == nil {
+		data, err := os.ReadFile(path)
+		var cache completionCache
+		if err == nil && json.Unmarshal(data, &cache) == nil {
+			age := time.Since(info.ModTime())
+			switch {
+			case cache.Failed && age < completionFailureTTL:
+				return nil, errors.New("listing the clusters failed moments ago")
+			case !cache.Failed && age < completionCacheTTL:
+				return cache.Names, nil
+			}
+		}
+	}
+
+	cfg.WaitForRancher, cfg.RetryMaxWait = 0, -1
+	var cache completionCache
+	client, err := rancher.NewClient(cfg, rancher.WithReporter(events.Discard), rancher.WithTimeout(completionTimeout))
+	var clusters []rancher.Cluster
+	if err == nil {
+		clusters, err = client.ListClusters()
+	}
+	if err != nil {
+		cache.Failed = true
+	}
+	for _, cluster := range clusters {
+		cache.Names = append(cache.Names, cluster.Name)
+	}
+	slices.Sort(cache.Names)
+
+	// A cache that cannot be written only makes the next completion slower
+	if data, err := json.Marshal(cache); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			_ = os.WriteFile(path, data, 0600)
+		}
+	}
+	return cache.Names, err
+}
+
+// completeManagedContexts completes the contexts generated by this tool in the
+// kubeconfig "use" works on
+func completeManagedContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	completing = true
+	if readEnvFile() != nil || readConfigFile() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	target, err := clientcmd.LoadFromFile(useKubeconfigPath())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, name := range kubeconfig.ManagedContexts(target) {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
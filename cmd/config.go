@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/encrypt"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// configCmd groups the commands managing the configuration file
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+}
+
+// configInitCmd asks for the basic settings and writes the configuration file
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the configuration file interactively",
+	Long: `Ask for the Rancher URL, how to authenticate, the cluster name prefix and
+the output path, check that Rancher accepts the credentials, and write the
+configuration file read by every other command.
+
+The token is stored in the OS keychain, unless you choose to write it into
+the configuration file. With password authentication, an API token is created
+like "login" does, so the password itself is never stored.
+
+The file is written to --config, RANCHER_CONFIG or the default location. With
+--profile, the settings are written as that profile, next to those already in
+the file.
+
+Examples:
+  # Create the default configuration
+  kubeconfig-wrangler config init
+
+  # Add a profile for a second Rancher installation
+  kubeconfig-wrangler config init --profile lab-rancher`,
+	Args: cobra.NoArgs,
+	// A broken configuration file must not keep it from being replaced
+	Annotations: map[string]string{skipConfigFileAnnotation: "true"},
+	RunE:        runConfigInit,
+}
+
+// configViewCmd prints the effective configuration
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Show the effective configuration",
+	Long: `Print the configuration the other commands would use, in the format of the
+configuration file: the settings of the file and of the selected profile,
+overridden by the environment variables and then by the global flags.
+
+Secret keys and passwords are replaced with REDACTED; of a token, only the
+access key is shown. Credentials read from a credential source, command or
+.netrc, or stored by "login", are not looked up.
+
+Examples:
+  # Show the configuration of the default profile
+  kubeconfig-wrangler config view
+
+  # Check what a profile and an environment override amount to
+  RANCHER_CLUSTER_PREFIX=ci- kubeconfig-wrangler config view --profile lab-rancher`,
+	Args: cobra.NoArgs,
+	RunE: runConfigView,
+}
+
+// configEncryptCmd encrypts the secrets of the configuration file
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the tokens and passwords of the configuration file",
+	Long: `Encrypt the tokens, secret keys and passwords of the configuration file, in
+the top-level settings and every profile, with age. The rest of the file stays
+readable; secrets already encrypted are left alone. Comments in the file are
+not kept.
+
+With --recipient, the secrets are encrypted for age public keys, and
+decrypted with the secret keys in $RANCHER_CONFIG_IDENTITY, by default the
+file SOPS uses ($SOPS_AGE_KEY_FILE, or sops/age/keys.txt in the user
+configuration directory). Otherwise they are encrypted with a passphrase,
+read from $RANCHER_CONFIG_PASSPHRASE or asked for, which is then needed on
+every run: set RANCHER_CONFIG_PASSPHRASE where no terminal is available.
+
+Examples:
+  # Encrypt for an age key, e.g. on a headless server
+  age-keygen -o ~/.config/sops/age/keys.txt
+  kubeconfig-wrangler config encrypt --recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+
+  # Encrypt with a passphrase
+  kubeconfig-wrangler config encrypt`,
+	Args: cobra.NoArgs,
+	// The secrets already encrypted need not be decrypted
+	Annotations: map[string]string{skipConfigFileAnnotation: "true"},
+	RunE:        runConfigEncrypt,
+}
+
+var (
+	configInitForce         bool
+	configEncryptRecipients []string
+)
+
+func init() {
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Replace the existing settings without asking")
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configEncryptCmd)
+	configEncryptCmd.Flags().StringArrayVar(&configEncryptRecipients, "recipient", nil, "Encrypt for this age public key (age1...), or the keys listed in this file, instead of a passphrase (repeatable)")
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	name := activeProfileName()
+	named := name != "default"
+
+	file := &config.File{}
+	if existing, err := config.LoadFile(path); err == nil {
+		file = existing
+	} else if !errors.Is(err, os.ErrNotExist) && !configInitForce {
+		return fmt.Errorf("configuration error: %w; use --force to replace it", err)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	_, exists := file.Profiles[name]
+	if !configInitForce && ((named && exists) || (!named && file.URL != "")) {
+		what := "The configuration in " + path
+		if named {
+			what = fmt.Sprintf("Profile %q in %s", name, path)
+		}
+		ok, err := promptYesNo(in, what+" exists. Replace it?", false)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	settings := config.Settings{}
+	if settings.URL, err = promptLine(in, "Rancher URL", ""); err != nil {
+		return err
+	}
+	if settings.URL == "" {
+		return fmt.Errorf("configuration error: rancher URL is required")
+	}
+	settings.URL = strings.TrimSuffix(settings.URL, "/")
+
+	cfg := loadConfig()
+	cfg.RancherURL = settings.URL
+	cfg.Token, cfg.AccessKey, cfg.SecretKey, cfg.Username, cfg.Password = "", "", "", "", ""
+	cfg.AuthMethod = ""
+
+	method, err := promptChoice(in, "Authentication: API token or username and password", []string{"token", "password"}, "token")
+	if err != nil {
+		return err
+	}
+	var apiToken string
+	if method == "token" {
+		if apiToken, err = promptSecret(in, "API token (access_key:secret_key)"); err != nil {
+			return err
+		}
+		cfg.Token = apiToken
+	} else {
+		if cfg.Username, err = promptLine(in, "Username", ""); err != nil {
+			return err
+		}
+		if cfg.Password, err = promptSecret(in, "Password"); err != nil {
+			return err
+		}
+		if cfg.AuthProvider, err = promptChoice(in, "Auth provider", rancher.LoginProviders(), "local"); err != nil {
+			return err
+		}
+		settings.Auth.Provider = cfg.AuthProvider
+		cfg.AuthMethod = config.AuthMethodPassword
+		if apiToken, err = createLoginToken(cfg, "", 0); err != nil {
+			return err
+		}
+		cfg.Token, cfg.Username, cfg.Password, cfg.AuthMethod = apiToken, "", "", ""
+	}
+
+	// Verify the credentials before anything is written
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return err
+	}
+	user, err := client.GetCurrentUser()
+	if err != nil {
+		return fmt.Errorf("failed to verify the credentials: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Connected to %s as %s\n", cfg.RancherURL, user.Username)
+
+	inKeyring, err := promptYesNo(in, "Store the token in the OS keychain instead of the configuration file?", true)
+	if err != nil {
+		return err
+	}
+	if inKeyring {
+		if _, err := storeLoginToken(cfg, name, apiToken, true); err != nil {
+			return err
+		}
+	} else {
+		settings.Auth = config.FileAuth{Token: apiToken}
+	}
+
+	if settings.Prefix, err = promptLine(in, "Cluster name prefix", "rancher-"); err != nil {
+		return err
+	}
+	defaultOutput := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		defaultOutput = filepath.Join(home, ".kube", "rancher.yaml")
+	}
+	if settings.Output.Path, err = promptLine(in, "Output kubeconfig", defaultOutput); err != nil {
+		return err
+	}
+
+	if named {
+		if file.Profiles == nil {
+			file.Profiles = make(map[string]config.Settings)
+		}
+		file.Profiles[name] = settings
+	} else {
+		file.Settings = settings
+	}
+	if err := writeConfigFile(path, file); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Configuration written to %s\n", path)
+	return nil
+}
+
+func runConfigView(cmd *cobra.Command, args []string) error {
+	settings := config.SettingsOf(loadConfig())
+	settings.Redact()
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func runConfigEncrypt(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	file, err := config.LoadFile(path)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	passphrase := ""
+	if len(configEncryptRecipients) == 0 {
+		if passphrase, err = newConfigPassphrase(); err != nil {
+			return err
+		}
+	}
+	count := 0
+	err = file.EncryptSecrets(func(value string) (string, error) {
+		if encrypt.IsEncryptedValue(value) {
+			return value, nil
+		}
+		count++
+		return encrypt.EncryptValue(value, configEncryptRecipients, passphrase)
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Fprintf(os.Stderr, "No plaintext secrets in %s\n", path)
+		return nil
+	}
+	if err := writeConfigFile(path, file); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Encrypted %d secret(s) in %s\n", count, path)
+	return nil
+}
+
+// newConfigPassphrase returns $RANCHER_CONFIG_PASSPHRASE, or asks for a new
+// passphrase twice
+func newConfigPassphrase() (string, error) {
+	if passphrase, err := config.SecretEnv("RANCHER_CONFIG_PASSPHRASE"); err != nil || passphrase != "" {
+		return passphrase, err
+	}
+	passphrase, err := readConfigPassphrase("New passphrase")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("configuration error: the passphrase must not be empty")
+	}
+	again, err := readConfigPassphrase("Repeat the passphrase")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", fmt.Errorf("configuration error: the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readConfigPassphrase asks for a passphrase on the terminal
+func readConfigPassphrase(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase; set RANCHER_CONFIG_PASSPHRASE")
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// configDecrypter decrypts the secrets of the configuration file with the
+// age keys in $RANCHER_CONFIG_IDENTITY or the SOPS keys file, or with the
+// passphrase in $RANCHER_CONFIG_PASSPHRASE or asked for
+func configDecrypter() *encrypt.ValueDecrypter {
+	identity := os.Getenv("RANCHER_CONFIG_IDENTITY")
+	if identity == "" {
+		identity = encrypt.DefaultIdentityFile()
+	}
+	return &encrypt.ValueDecrypter{
+		IdentityFile: identity,
+		Passphrase: func() (string, error) {
+			if passphrase, err := config.SecretEnv("RANCHER_CONFIG_PASSPHRASE"); err != nil || passphrase != "" {
+				return passphrase, err
+			}
+			if completing {
+				return "", errCompleting
+			}
+			return readConfigPassphrase("Passphrase of the configuration file")
+		},
+	}
+}
+
+// writeConfigFile writes the configuration file, readable by the current user only
+func writeConfigFile(path string, file *config.File) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	return nil
+}
+
+// promptLine asks for a line of input, returning def when it is left empty
+func promptLine(in *bufio.Reader, label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", label)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(label), err)
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line, nil
+	}
+	return def, nil
+}
+
+// promptSecret asks for a secret without echoing it on a terminal
+func promptSecret(in *bufio.Reader, label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return promptLine(in, label, "")
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(label), err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// promptChoice asks for one of choices until a valid one is given
+func promptChoice(in *bufio.Reader, label string, choices []string, def string) (string, error) {
+	for {
+		answer, err := promptLine(in, fmt.Sprintf("%s (%s)", label, strings.Join(choices, "/")), def)
+		if err != nil {
+			return "", err
+		}
+		for _, choice := range choices {
+			if strings.EqualFold(answer, choice) {
+				return choice, nil
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Please answer one of: %s\n", strings.Join(choices, ", "))
+	}
+}
+
+// promptYesNo asks a yes/no question
+func promptYesNo(in *bufio.Reader, question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := promptLine(in, question+" ("+hint+")", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,579 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/probe"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// dashboardCmd shows a live status board of every cluster
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Show a live, color-coded status of all clusters",
+	Long: `Show every cluster of the Rancher server with its state, Kubernetes
+version, the expiry of the token its context uses and the result of the last
+validation, refreshing the list every --interval.
+
+The token is read from the managed kubeconfig (--kubeconfig); clusters without
+a context there show the expiry of your own Rancher token.
+
+Keys:
+  up/down, k/j  select a cluster
+  v             validate the selected cluster's endpoints
+  g             regenerate the selected cluster's contexts in the managed kubeconfig
+  r             refresh now
+  q             quit
+
+Examples:
+  # Watch the fleet, refreshing every 10 seconds
+  kubeconfig-wrangler dashboard --kubeconfig ~/.kube/rancher-config --interval 10s`,
+	RunE: runDashboard,
+}
+
+var dashboardInterval time.Duration
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+	addRancherFlags(dashboardCmd)
+	addManagedKubeconfigFlag(dashboardCmd)
+	dashboardCmd.Flags().DurationVar(&dashboardInterval, "interval", 30*time.Second, "How often to refresh the cluster list")
+}
+
+// ANSI escape sequences used to draw the dashboard
+const (
+	ansiReset       = "\x1b[0m"
+	ansiBold        = "\x1b[1m"
+	ansiReverse     = "\x1b[7m"
+	ansiRed         = "\x1b[31m"
+	ansiGreen       = "\x1b[32m"
+	ansiYellow      = "\x1b[33m"
+	ansiDim         = "\x1b[2m"
+	ansiClear       = "\x1b[H\x1b[2J"
+	ansiAltScreen   = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen  = "\x1b[?25h\x1b[?1049l"
+	dashboardHeader = 3
+	dashboardFooter = 3
+)
+
+// validation is the outcome of the last validation of a cluster
+type validation struct {
+	at      time.Time
+	healthy bool
+	summary string
+}
+
+// dashboard is the state of the board. It is only touched by the loop in
+// run; background work hands its results back as functions on updates.
+type dashboard struct {
+	cfg     *config.Config
+	client  *rancher.Client
+	updates chan func(*dashboard)
+	reports chan events.Event
+
+	clusters    []rancher.Cluster
+	tokens      map[string]*rancher.Token
+	tokenOf     map[string]string
+	validations map[string]validation
+	refreshed   time.Time
+	selected    int
+	offset      int
+	busy        int
+	status      string
+	statusColor string
+	color       bool
+}
+
+func runDashboard(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	if dashboardInterval <= 0 {
+		return fmt.Errorf("configuration error: --interval must be positive")
+	}
+
+	plan := rancher.NewPlan("dashboard", cfg)
+	plan.ListClusters()
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/tokens/<name>", Count: "per token, every --interval", Purpose: "read the expiry of the tokens used by the contexts"})
+	plan.Note("the cluster list is fetched again every %s until you quit", dashboardInterval)
+	plan.Note("validating or regenerating a cluster fetches its kubeconfig, which creates a token")
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("dashboard needs an interactive terminal")
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to configure terminal: %w", err)
+	}
+	defer term.Restore(stdin, state)
+	fmt.Print(ansiAltScreen)
+	defer fmt.Print(ansiMainScreen)
+
+	d := &dashboard{
+		cfg:         cfg,
+		client:      client,
+		updates:     make(chan func(*dashboard)),
+		reports:     make(chan events.Event, 16),
+		tokens:      make(map[string]*rancher.Token),
+		tokenOf:     make(map[string]string),
+		validations: make(map[string]validation),
+		color:       os.Getenv("NO_COLOR") == "",
+	}
+	// Writing warnings to stderr would tear the board apart; show them in
+	// the status line instead, dropping them while it is busy
+	client.SetReporter(events.ReporterFunc(func(event events.Event) {
+		select {
+		case d.reports <- event:
+		default:
+		}
+	}))
+	d.run(readKeys())
+	return nil
+}
+
+// readKeys reads key presses from the terminal, translating arrow keys to k/j
+func readKeys() <-chan string {
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			switch key := string(buf[:n]); key {
+			case "\x1b[A", "\x1bOA":
+				keys <- "k"
+			case "\x1b[B", "\x1bOB":
+				keys <- "j"
+			default:
+				keys <- key
+			}
+		}
+	}()
+	return keys
+}
+
+// run draws the board and handles keys, refreshes and finished work until quit
+func (d *dashboard) run(keys <-chan string) {
+	refresh := time.NewTicker(dashboardInterval)
+	defer refresh.Stop()
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+
+	d.refresh()
+	for {
+		d.draw()
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			switch key {
+			case "q", "Q", "\x03", "\x04":
+				return
+			case "k":
+				d.move(-1)
+			case "j":
+				d.move(1)
+			case "r":
+				d.refresh()
+			case "v":
+				d.validate()
+			case "g":
+				d.regenerate()
+			}
+		case update := <-d.updates:
+			update(d)
+			d.busy--
+		case event := <-d.reports:
+			switch event.Kind {
+			case events.Warning:
+				d.setStatus(event.Text(), ansiYellow)
+			case events.Error:
+				d.setStatus(event.Text(), ansiRed)
+			default:
+				d.setStatus(event.Text(), "")
+			}
+		case <-refresh.C:
+			d.refresh()
+		case <-redraw.C:
+		}
+	}
+}
+
+// background runs work outside the loop and applies its result on the loop
+func (d *dashboard) background(status string, work func() func(*dashboard)) {
+	d.busy++
+	if status != "" {
+		d.setStatus(status, "")
+	}
+	go func() { d.updates <- work() }()
+}
+
+func (d *dashboard) setStatus(status, color string) {
+	d.status, d.statusColor = status, color
+}
+
+func (d *dashboard) move(delta int) {
+	if len(d.clusters) == 0 {
+		return
+	}
+	d.selected = min(max(d.selected+delta, 0), len(d.clusters)-1)
+}
+
+// current returns the selected cluster, if any
+func (d *dashboard) current() (rancher.Cluster, bool) {
+	if d.selected < 0 || d.selected >= len(d.clusters) {
+		return rancher.Cluster{}, false
+	}
+	return d.clusters[d.selected], true
+}
+
+// refresh lists the clusters again and reads the expiry of the tokens their
+// contexts use
+func (d *dashboard) refresh() {
+	d.background("", func() func(*dashboard) {
+		clusters, err := d.client.ListClusters()
+		if err != nil {
+			return func(d *dashboard) { d.setStatus(fmt.Sprintf("Refresh failed: %v", err), ansiRed) }
+		}
+		sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+
+		tokenOf := make(map[string]string)
+		if managed := d.loadManaged(); managed != nil {
+			for id, contexts := range kubeconfig.OwnedContexts(managed, d.cfg.RancherURL) {
+				if user, ok := managed.AuthInfos[managed.Contexts[contexts[0]].AuthInfo]; ok && user.Token != "" {
+					tokenOf[id], _, _ = strings.Cut(user.Token, ":")
+				}
+			}
+		}
+		own := d.client.TokenName()
+		tokens := make(map[string]*rancher.Token)
+		for _, cluster := range clusters {
+			name, ok := tokenOf[cluster.ID]
+			if !ok {
+				name = own
+				tokenOf[cluster.ID] = own
+			}
+			if _, done := tokens[name]; done || name == "" {
+				continue
+			}
+			// Tokens that cannot be read show as unknown
+			tokens[name], _ = d.client.GetToken(name)
+		}
+
+		return func(d *dashboard) {
+			d.clusters, d.tokens, d.tokenOf = clusters, tokens, tokenOf
+			d.refreshed = time.Now()
+			d.move(0)
+		}
+	})
+}
+
+// loadManaged reads the managed kubeconfig, or returns nil when there is none
+func (d *dashboard) loadManaged() *api.Config {
+	path := managedKubeconfigPath()
+	if path == "" {
+		return nil
+	}
+	managed, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil
+	}
+	return managed
+}
+
+// validate probes the endpoints of the selected cluster: those of its contexts
+// in the managed kubeconfig, or else those of a freshly fetched kubeconfig
+func (d *dashboard) validate() {
+	cluster, ok := d.current()
+	if !ok {
+		return
+	}
+	d.background(fmt.Sprintf("Validating %s...", cluster.Name), func() func(*dashboard) {
+		result := validation{at: time.Now()}
+		config, contexts, err := d.contextsOf(cluster)
+		if err != nil {
+			result.summary = err.Error()
+		} else {
+			results := probe.Contexts(config, contexts, probe.DefaultTimeout)
+			if best := probe.Best(results); best >= 0 {
+				result.healthy = true
+				result.summary = fmt.Sprintf("%s %s", results[best].Status, results[best].Latency.Round(time.Millisecond))
+			} else if len(results) > 0 {
+				result.summary = string(results[0].Status)
+			} else {
+				result.summary = "no endpoints"
+			}
+		}
+		return func(d *dashboard) {
+			d.validations[cluster.ID] = result
+			if result.healthy {
+				d.setStatus(fmt.Sprintf("%s is healthy", cluster.Name), ansiGreen)
+			} else {
+				d.setStatus(fmt.Sprintf("%s has no healthy endpoint: %s", cluster.Name, result.summary), ansiRed)
+			}
+		}
+	})
+}
+
+// contextsOf returns a kubeconfig holding the contexts of cluster and their names
+func (d *dashboard) contextsOf(cluster rancher.Cluster) (*api.Config, []string, error) {
+	if managed := d.loadManaged(); managed != nil {
+		if contexts := kubeconfig.OwnedContexts(managed, d.cfg.RancherURL)[cluster.ID]; len(contexts) > 0 {
+			return managed, contexts, nil
+		}
+	}
+	data, err := d.client.GetClusterKubeconfig(&cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := kubeconfig.NewGenerator("").ParseKubeconfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxy, direct := kubeconfig.ClassifyContexts(config)
+	return config, append(proxy, direct...), nil
+}
+
+// regenerate fetches a new kubeconfig for the selected cluster and updates its
+// contexts in the managed kubeconfig, leaving every other entry untouched
+func (d *dashboard) regenerate() {
+	cluster, ok := d.current()
+	if !ok {
+		return
+	}
+	path := managedKubeconfigPath()
+	if path == "" {
+		d.setStatus("Regenerating needs --kubeconfig or RANCHER_KUBECONFIG_OUTPUT", ansiRed)
+		return
+	}
+	d.background(fmt.Sprintf("Regenerating %s...", cluster.Name), func() func(*dashboard) {
+		report, err := d.regenerateInto(path, cluster)
+		if err != nil {
+			return func(d *dashboard) { d.setStatus(fmt.Sprintf("Regenerating %s failed: %v", cluster.Name, err), ansiRed) }
+		}
+		return func(d *dashboard) {
+			d.setStatus(fmt.Sprintf("Regenerated %s in %s: %d added, %d updated", cluster.Name, path, len(report.Added), len(report.Updated)), ansiGreen)
+			delete(d.validations, cluster.ID)
+			d.refresh()
+		}
+	})
+}
+
+func (d *dashboard) regenerateInto(path string, cluster rancher.Cluster) (*kubeconfig.UpdateReport, error) {
+	data, err := d.client.GetClusterKubeconfig(&cluster)
+	if err != nil {
+		return nil, err
+	}
+	generator, err := newGenerator(d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	generator.SetClusterInfo(cluster.Name, kubeconfig.NameData{
+		ClusterID: cluster.ID,
+		Provider:  cluster.Provider,
+		Instance:  d.cfg.Name,
+		Labels:    cluster.Labels,
+	})
+	generated, err := generator.MergeConfigs(map[string]string{cluster.Name: data})
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := clientcmd.LoadFromFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		target = api.NewConfig()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if _, err := kubeconfig.CarryOverNotes(generated, target); err != nil {
+		return nil, err
+	}
+	if _, err := kubeconfig.CarryOverGeneratedAt(generated, target); err != nil {
+		return nil, err
+	}
+	report, err := kubeconfig.UpdateManaged(target, generated)
+	if err != nil {
+		return nil, err
+	}
+	out, err := generator.Serialize(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// paint wraps text in an ANSI color unless colors are disabled
+func (d *dashboard) paint(color, text string) string {
+	if !d.color || color == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// draw renders the whole board. The terminal is in raw mode, so lines end in \r\n.
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 120, 40
+	}
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	title := fmt.Sprintf("Rancher %s - %d cluster(s)", d.cfg.RancherURL, len(d.clusters))
+	switch {
+	case d.refreshed.IsZero():
+		title += " - loading..."
+	default:
+		title += fmt.Sprintf(" - refreshed %s ago, every %s", time.Since(d.refreshed).Round(time.Second), dashboardInterval)
+	}
+	if d.busy > 0 {
+		title += " *"
+	}
+	b.WriteString(d.paint(ansiBold, clip(title, width)) + "\r\n\r\n")
+
+	header := []string{"NAME", "STATE", "VERSION", "NODES", "TOKEN EXPIRY", "VALIDATION"}
+	rows := make([][]string, len(d.clusters))
+	colors := make([][]string, len(d.clusters))
+	for i, cluster := range d.clusters {
+		expiry, expiryColor := d.tokenExpiry(cluster)
+		checked, checkedColor := d.validationOf(cluster)
+		rows[i] = []string{cluster.Name, cluster.State, orDash(cluster.Version.GitVersion), fmt.Sprint(cluster.NodeCount), expiry, checked}
+		colors[i] = []string{"", stateColor(cluster.State), "", "", expiryColor, checkedColor}
+	}
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	line := func(cells, cellColors []string) string {
+		var l strings.Builder
+		for i, cell := range cells {
+			padded := fmt.Sprintf("%-*s  ", widths[i], cell)
+			if cellColors != nil {
+				padded = d.paint(cellColors[i], padded)
+			}
+			l.WriteString(padded)
+		}
+		return l.String()
+	}
+	b.WriteString("  " + d.paint(ansiBold, clip(line(header, nil), width-2)) + "\r\n")
+
+	visible := max(height-dashboardHeader-dashboardFooter-1, 1)
+	if d.selected < d.offset {
+		d.offset = d.selected
+	} else if d.selected >= d.offset+visible {
+		d.offset = d.selected - visible + 1
+	}
+	for i := d.offset; i < len(rows) && i < d.offset+visible; i++ {
+		if i == d.selected {
+			b.WriteString(d.paint(ansiReverse, "> "+clip(line(rows[i], nil), width-2)) + "\r\n")
+			continue
+		}
+		b.WriteString("  " + line(rows[i], colors[i]) + "\r\n")
+	}
+
+	b.WriteString("\r\n" + d.paint(d.statusColor, clip(d.status, width)) + "\r\n")
+	b.WriteString(d.paint(ansiDim, clip("up/down select  v validate  g regenerate  r refresh  q quit", width)))
+	fmt.Print(b.String())
+}
+
+// tokenExpiry renders when the token used by the cluster's context expires,
+// in red when it has or will within a day and in yellow within a week
+func (d *dashboard) tokenExpiry(cluster rancher.Cluster) (string, string) {
+	name := d.tokenOf[cluster.ID]
+	if name == "" {
+		return "-", ""
+	}
+	token := d.tokens[name]
+	switch {
+	case token == nil:
+		return "unknown", ansiYellow
+	case token.Expired:
+		return "expired", ansiRed
+	case token.ExpiresAt == "":
+		return "never", ansiGreen
+	}
+	expires, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil {
+		return token.ExpiresAt, ""
+	}
+	left := time.Until(expires)
+	switch {
+	case left <= 0:
+		return "expired", ansiRed
+	case left < 24*time.Hour:
+		return "in " + left.Round(time.Minute).String(), ansiRed
+	case left < 7*24*time.Hour:
+		return fmt.Sprintf("in %dd", int(left.Hours()/24)), ansiYellow
+	}
+	return fmt.Sprintf("in %dd", int(left.Hours()/24)), ansiGreen
+}
+
+// validationOf renders the last validation result of a cluster
+func (d *dashboard) validationOf(cluster rancher.Cluster) (string, string) {
+	result, ok := d.validations[cluster.ID]
+	if !ok {
+		return "-", ""
+	}
+	text := fmt.Sprintf("%s (%s)", result.summary, result.at.Format(time.TimeOnly))
+	if result.healthy {
+		return text, ansiGreen
+	}
+	return text, ansiRed
+}
+
+// stateColor colors a cluster state: green when active, red when failed and
+// yellow while it is changing
+func stateColor(state string) string {
+	switch state {
+	case "active":
+		return ansiGreen
+	case "error", "unavailable", "disconnected":
+		return ansiRed
+	}
+	return ansiYellow
+}
+
+// clip cuts text to the terminal width so lines never wrap
+func clip(text string, width int) string {
+	if width > 0 && len(text) > width {
+		return text[:width]
+	}
+	return text
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/encrypt"
+)
+
+// decryptCmd reverses generate --encrypt-recipient
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [file]",
+	Short: "Decrypt a kubeconfig encrypted by generate --encrypt-recipient",
+	Long: `Decrypt a kubeconfig, or Secret manifest, that "generate --encrypt-recipient"
+encrypted with age or SOPS. The format is detected from the file. Without a
+file, or with "-", the encrypted data is read from stdin.
+
+The age secret keys are read from --identity, by default the file SOPS uses:
+$SOPS_AGE_KEY_FILE, or sops/age/keys.txt in the user configuration directory
+(~/.config on Linux). SOPS files are decrypted by running the sops command.
+
+Examples:
+  # Decrypt a kubeconfig checked out from git
+  kubeconfig-wrangler decrypt kubeconfigs/prod.yaml.age --output ~/.kube/prod
+
+  # Use it once without writing it to disk
+  kubectl --kubeconfig <(kubeconfig-wrangler decrypt kubeconfigs/prod.yaml.age) get nodes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDecrypt,
+}
+
+var (
+	decryptIdentity string
+	decryptOutput   string
+)
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+	decryptCmd.Flags().StringVarP(&decryptIdentity, "identity", "i", "", "File with the age secret keys (default: $SOPS_AGE_KEY_FILE or the SOPS keys file)")
+	decryptCmd.Flags().StringVarP(&decryptOutput, "output", "o", "", "File to write the decrypted kubeconfig to (default: stdout)")
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted kubeconfig: %w", err)
+	}
+
+	identity := decryptIdentity
+	if identity == "" {
+		identity = encrypt.DefaultIdentityFile()
+	}
+	plaintext, err := encrypt.Decrypt(data, identity)
+	if err != nil {
+		return err
+	}
+
+	if decryptOutput == "" {
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+	if err := os.WriteFile(decryptOutput, plaintext, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", decryptOutput, err)
+	}
+	fmt.Fprintf(os.Stderr, "Kubeconfig written to %s\n", decryptOutput)
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// describeCmd shows a context of the managed kubeconfig
+var describeCmd = &cobra.Command{
+	Use:   "describe <context>",
+	Short: "Show a context of the managed kubeconfig with its provenance and notes",
+	Long: `Show the cluster, server, user and namespace of a context of the managed
+kubeconfig, where it came from when it was imported with "normalize", and the
+notes attached to it with "annotate".
+
+Examples:
+  kubeconfig-wrangler describe prod-payments --kubeconfig ~/.kube/rancher-config`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDescribe,
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+	addManagedKubeconfigFlag(describeCmd)
+}
+
+func runDescribe(cmd *cobra.Command, args []string) error {
+	_, managed, context, err := loadManagedContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Context:        %s\n", args[0])
+	fmt.Printf("Cluster:        %s\n", context.Cluster)
+	if cluster, ok := managed.Clusters[context.Cluster]; ok {
+		fmt.Printf("Server:         %s\n", cluster.Server)
+	}
+	fmt.Printf("User:           %s\n", orDash(context.AuthInfo))
+	fmt.Printf("Namespace:      %s\n", orDash(context.Namespace))
+
+	provenance, _, err := kubeconfig.GetProvenance(context)
+	if err != nil {
+		return err
+	}
+	if provenance.ClusterID != "" {
+		fmt.Printf("Rancher:        %s (cluster %s)\n", provenance.RancherURL, provenance.ClusterID)
+	}
+	if provenance.Source != "" {
+		fmt.Printf("Source:         %s\n", provenance.Source)
+	}
+	if provenance.File != "" {
+		fmt.Printf("Imported from:  %s\n", provenance.File)
+	}
+	if !provenance.ImportedAt.IsZero() {
+		fmt.Printf("Imported at:    %s\n", provenance.ImportedAt.Local().Format(time.RFC3339))
+	}
+	if !provenance.GeneratedAt.IsZero() {
+		fmt.Printf("Generated at:   %s (%s)\n", provenance.GeneratedAt.Local().Format(time.RFC3339), orDash(provenance.ToolVersion))
+	}
+
+	if len(provenance.Notes) == 0 {
+		fmt.Printf("Notes:          -\n")
+		return nil
+	}
+	fmt.Printf("Notes:\n")
+	for _, note := range provenance.Notes {
+		fmt.Printf("  - %s\n", note)
+	}
+	return nil
+}
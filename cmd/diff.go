@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// diffDriftStatus is the exit status of "diff" when the kubeconfig differs,
+// telling drift apart from failures (status 1)
+const diffDriftStatus = 2
+
+// diffCmd compares what generate would write with the current kubeconfig
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how the generated kubeconfig differs from the current one",
+	Long: `Generate the kubeconfig like "generate" does, without writing it, and compare
+it with the managed kubeconfig: contexts that would be added or removed, and
+what would change in the others. Tokens, keys and other credentials are never
+printed, only whether they changed.
+
+Only the contexts generated from the same Rancher servers are compared, so the
+command also works on a kubeconfig updated with "generate --merge-into". Give
+the cluster filters and the policy the file was generated with, as for
+generate.
+
+The command exits with status 0 when the kubeconfig is up to date, 2 when
+there is a difference and 1 when it failed, so it can be used as a drift
+check in CI. Rancher usually issues new tokens on every run; use
+--ignore-credentials to only report changes of the clusters themselves.
+
+Examples:
+  # What would the next generate change?
+  kubeconfig-wrangler diff --kubeconfig ~/.kube/rancher
+
+  # Fail a CI job when a cluster was added, removed or moved
+  kubeconfig-wrangler diff --kubeconfig kubeconfig.yaml --ignore-credentials`,
+	RunE: runDiff,
+}
+
+var (
+	diffPrefix            string
+	diffSuffix            string
+	diffSeparator         string
+	diffEndpointMode      string
+	diffIgnoreCredentials bool
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	addRancherFlags(diffCmd)
+	addManagedKubeconfigFlag(diffCmd)
+	addNamingFlags(diffCmd, &diffSuffix, &diffSeparator)
+	addClusterFilterFlags(diffCmd)
+	addPolicyFlags(diffCmd)
+	diffCmd.Flags().StringVarP(&diffPrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
+	diffCmd.Flags().StringVar(&diffEndpointMode, "endpoint-mode", "all", "Contexts to keep for clusters with an authorized cluster endpoint, as for generate")
+	diffCmd.Flags().BoolVar(&diffIgnoreCredentials, "ignore-credentials", false, "Do not count contexts whose credentials alone changed as a difference")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	path := managedKubeconfigPath()
+	if path == "" {
+		return fmt.Errorf("configuration error: --kubeconfig or RANCHER_KUBECONFIG_OUTPUT is required")
+	}
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("prefix") {
+		cfg.ClusterPrefix = diffPrefix
+	}
+	applyNamingFlags(cmd, cfg, diffSuffix, diffSeparator)
+	applyClusterFilterFlags(cmd, cfg)
+	mode, err := kubeconfig.ParseEndpointMode(diffEndpointMode)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	pol, err := loadPolicy()
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	instances, err := loadInstances(cfg)
+	if err != nil {
+		return err
+	}
+
+	if explain {
+		return explainGenerate("diff", instances, mode, pol)
+	}
+
+	current, err := clientcmd.LoadFromFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		current = api.NewConfig()
+	} else if err != nil {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	generated, _, err := generateAll(instances, mode, pol)
+	if err != nil {
+		return err
+	}
+
+	diff := kubeconfig.DiffManaged(current, generated)
+	printDiff(diff)
+	if diff.Empty(diffIgnoreCredentials) {
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", path)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s differs from the kubeconfig generated from Rancher\n", path)
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	return &exitStatusError{status: diffDriftStatus}
+}
+
+// printDiff prints one line per added (+), removed (-) and changed (~) context
+func printDiff(diff *kubeconfig.ConfigDiff) {
+	for _, name := range diff.Added {
+		fmt.Printf("+ %s\n", name)
+	}
+	for _, name := range diff.Removed {
+		fmt.Printf("- %s\n", name)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %s\n", change.Name)
+		for _, what := range change.Changes {
+			fmt.Printf("    %s\n", what)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// docsCmd generates reference documentation from the command tree, so the
+// binary carries its own manual
+var docsCmd = &cobra.Command{
+	Use:    "docs",
+	Short:  "Generate reference documentation",
+	Hidden: true,
+}
+
+// docsManCmd writes a man page per command
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages for every command",
+	Long: `Generate a man page for every command, including its examples, into --dir.
+Point MANPATH at the parent directory or copy the pages to a man1 directory:
+
+  kubeconfig-wrangler docs man --dir ~/.local/share/man/man1
+  man kubeconfig-wrangler-generate`,
+	Args: cobra.NoArgs,
+	RunE: runDocsMan,
+}
+
+// docsMarkdownCmd writes a Markdown page per command
+var docsMarkdownCmd = &cobra.Command{
+	Use:   "markdown",
+	Short: "Generate Markdown reference pages for every command",
+	Args:  cobra.NoArgs,
+	RunE:  runDocsMarkdown,
+}
+
+var (
+	docsManDir      string
+	docsMarkdownDir string
+)
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.AddCommand(docsManCmd, docsMarkdownCmd)
+	docsManCmd.Flags().StringVar(&docsManDir, "dir", "man", "Directory to write the man pages to")
+	docsMarkdownCmd.Flags().StringVar(&docsMarkdownDir, "dir", "docs", "Directory to write the Markdown pages to")
+}
+
+func runDocsMan(cmd *cobra.Command, args []string) error {
+	if err := os.MkdirAll(docsManDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", docsManDir, err)
+	}
+	rootCmd.DisableAutoGenTag = true
+	header := &doc.GenManHeader{
+		Title:   "KUBECONFIG-WRANGLER",
+		Section: "1",
+		Source:  "kubeconfig-wrangler " + Version,
+		Manual:  "kubeconfig-wrangler Manual",
+	}
+	if err := doc.GenManTree(rootCmd, header, docsManDir); err != nil {
+		return fmt.Errorf("failed to generate man pages: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Man pages written to %s\n", docsManDir)
+	return nil
+}
+
+func runDocsMarkdown(cmd *cobra.Command, args []string) error {
+	if err := os.MkdirAll(docsMarkdownDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", docsMarkdownDir, err)
+	}
+	rootCmd.DisableAutoGenTag = true
+	if err := doc.GenMarkdownTree(rootCmd, docsMarkdownDir); err != nil {
+		return fmt.Errorf("failed to generate Markdown pages: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Markdown pages written to %s\n", docsMarkdownDir)
+	return nil
+}
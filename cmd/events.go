@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/policy"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// eventSettleDelay batches bursts of cluster events (e.g. a cluster passing through
+// several provisioning states) into a single regeneration
+const eventSettleDelay = 5 * time.Second
+
+// secretReloadInterval is how often the secret files are read again, e.g. to
+// pick up a token rotated in a mounted Kubernetes Secret
+const secretReloadInterval = 30 * time.Second
+
+// regenerateOnClusterEvents subscribes to cluster events of every instance and
+// regenerates the kubeconfig after relevant changes, until interrupted. When
+// secrets are read from files, a change to them reconnects with the new ones;
+// otherwise credentials rejected by an instance end the watch with an error.
+func regenerateOnClusterEvents(cfg *config.Config, instances []*config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	changed := make(chan struct{}, 1)
+	failed := make(chan error, len(instances))
+	stopWatching, err := watchClusterEvents(ctx, instances, changed, failed)
+	if err != nil {
+		return err
+	}
+	defer func() { stopWatching() }()
+
+	var reload <-chan time.Time
+	if slices.ContainsFunc(append([]*config.Config{cfg}, instances...), (*config.Config).HasSecretFiles) {
+		ticker := time.NewTicker(secretReloadInterval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	fmt.Fprintln(os.Stderr, "Watching for cluster changes (Ctrl+C to stop)...")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case err := <-failed:
+			if reload == nil {
+				return err
+			}
+			// The credentials may be rotated in their files; keep watching
+			// the other instances until then
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		case <-reload:
+			if !reloadSecretFiles(cfg, instances) {
+				continue
+			}
+			fmt.Fprintln(os.Stderr, "Credentials changed, reconnecting...")
+			stopWatching()
+			if stopWatching, err = watchClusterEvents(ctx, instances, changed, failed); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(eventSettleDelay):
+		}
+		// Drop events that arrived while settling; this regeneration covers them
+		select {
+		case <-changed:
+		default:
+		}
+
+		if _, err := generateAndWrite(cfg, instances, mode, pol); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: regeneration failed: %v\n", err)
+		}
+	}
+}
+
+// watchClusterEvents subscribes to the cluster events of every instance,
+// signaling changed on each event, until ctx is done or the returned function
+// is called. An instance whose watch ends with an error, such as rejected
+// credentials, sends it on failed.
+func watchClusterEvents(ctx context.Context, instances []*config.Config, changed chan<- struct{}, failed chan<- error) (context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	for _, instance := range instances {
+		client, err := newRancherClient(instance, rancher.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return func() {}, fmt.Errorf("failed to create Rancher client: %w", err)
+		}
+		go func(url string) {
+			err := client.WatchClusters(ctx, func(event rancher.ClusterEvent) {
+				if event.Type == rancher.ClusterStateChanged {
+					fmt.Fprintf(os.Stderr, "Cluster %s on %s: %s -> %s\n", event.Cluster.Name, url, event.OldState, event.Cluster.State)
+				} else {
+					fmt.Fprintf(os.Stderr, "Cluster %s on %s: %s\n", event.Cluster.Name, url, event.Type)
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			})
+			if err != nil {
+				select {
+				case failed <- fmt.Errorf("stopped watching cluster events of %s: %w", url, err):
+				default:
+				}
+			}
+		}(instance.RancherURL)
+	}
+	return cancel, nil
+}
+
+// reloadSecretFiles reads the secret files of every configuration again and
+// reports whether a secret changed. A file that cannot be read keeps the
+// previous secret, with a warning.
+func reloadSecretFiles(cfg *config.Config, instances []*config.Config) bool {
+	changed := false
+	seen := make(map[*config.Config]bool)
+	for _, c := range append([]*config.Config{cfg}, instances...) {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		ok, err := c.ReloadSecretFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		changed = changed || ok
+	}
+	return changed
+}
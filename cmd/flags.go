@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/credentials"
+	"github.com/kubeconfig-wrangler/pkg/events"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/profile"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+var (
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	disableHTTP2        bool
+	disableKeepAlives   bool
+	maxResponseSize     int64
+	retryMaxWait        time.Duration
+	waitForRancher      time.Duration
+	breakerThreshold    int
+	debugHTTP           bool
+
+	authProvider    string
+	fromKubeconfig  string
+	fromKubeContext string
+
+	credentialSource  string
+	credentialCommand string
+
+	explain bool
+
+	excludeSystemCAs bool
+)
+
+// addRancherFlags registers the Rancher connection and authentication flags
+// shared by every command that talks to a Rancher server
+func addRancherFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&rancherURL, "url", "u", "", "Rancher server URL (env: RANCHER_URL)")
+	cmd.Flags().StringVarP(&accessKey, "access-key", "a", "", "Rancher API access key (env: RANCHER_ACCESS_KEY)")
+	cmd.Flags().StringVarP(&secretKey, "secret-key", "s", "", "Rancher API secret key (env: RANCHER_SECRET_KEY)")
+	cmd.Flags().StringVarP(&token, "token", "t", "", "Rancher API token (access_key:secret_key) (env: RANCHER_TOKEN)")
+	cmd.Flags().StringVar(&username, "username", "", "Rancher username for password auth (env: RANCHER_USERNAME)")
+	cmd.Flags().StringVar(&password, "password", "", "Rancher password for password auth (env: RANCHER_PASSWORD)")
+	cmd.Flags().StringVar(&authProvider, "auth-provider", "", "Rancher auth provider for password auth: local, activedirectory, openldap or freeipa (env: RANCHER_AUTH_PROVIDER)")
+	cmd.Flags().StringVar(&credentialSource, "credential-source", "", "Secret store to read the token from when none is given, e.g. vault:secret/data/ci/rancher or aws-sm://rancher/prod/token (env: RANCHER_CREDENTIAL_SOURCE)")
+	cmd.Flags().StringVar(&credentialCommand, "credential-command", "", "Command printing the token when none is given, as words or a JSON array, e.g. \"op read op://infra/rancher/token\" (env: RANCHER_CREDENTIAL_COMMAND)")
+	cmd.Flags().BoolVar(&excludeSystemCAs, "exclude-system-cas", false, "Trust only the --ca-cert CAs, not the system CAs (env: RANCHER_EXCLUDE_SYSTEM_CAS)")
+	cmd.Flags().StringVar(&fromKubeconfig, "from-kubeconfig", "", "Take the Rancher URL and token from a Rancher-generated kubeconfig")
+	cmd.Flags().StringVar(&fromKubeContext, "context", "", "Context to read with --from-kubeconfig (default: current context)")
+	cmd.Flags().BoolVar(&explain, "explain", false, "Print the Rancher API calls the command would make, and what they create, without sending any")
+	cmd.Flags().BoolVar(&debugHTTP, "debug-http", false, "Log every Rancher API request and response to stderr, with credentials redacted (env: RANCHER_DEBUG_HTTP)")
+
+	// Transport tuning
+	cmd.Flags().IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 0, "Maximum idle keep-alive connections per host (env: RANCHER_MAX_IDLE_CONNS_PER_HOST)")
+	cmd.Flags().IntVar(&maxConnsPerHost, "max-conns-per-host", 0, "Maximum total connections per host, 0 for no limit (env: RANCHER_MAX_CONNS_PER_HOST)")
+	cmd.Flags().DurationVar(&idleConnTimeout, "idle-conn-timeout", 0, "How long idle connections are kept open (env: RANCHER_IDLE_CONN_TIMEOUT)")
+	cmd.Flags().BoolVar(&disableHTTP2, "disable-http2", false, "Disable HTTP/2 when talking to Rancher (env: RANCHER_DISABLE_HTTP2)")
+	cmd.Flags().BoolVar(&disableKeepAlives, "disable-keepalives", false, "Open a new connection for every request (env: RANCHER_DISABLE_KEEPALIVES)")
+	cmd.Flags().Int64Var(&maxResponseSize, "max-response-size", 0, "Maximum size in bytes of a Rancher API response, 0 for the 64 MiB default (env: RANCHER_MAX_RESPONSE_SIZE)")
+	cmd.Flags().DurationVar(&retryMaxWait, "retry-max-wait", 0, "Total time to wait and retry while Rancher answers 429/503, negative to disable (default 1m) (env: RANCHER_RETRY_MAX_WAIT)")
+	cmd.Flags().DurationVar(&waitForRancher, "wait-for-rancher", 0, "Wait up to this long for a Rancher that refuses connections or answers 503, e.g. during an upgrade, instead of failing (env: RANCHER_WAIT_FOR_RANCHER)")
+	cmd.Flags().IntVar(&breakerThreshold, "breaker-threshold", 0, "Consecutive failed requests after which the remaining ones fail fast, negative to disable (default 5) (env: RANCHER_BREAKER_THRESHOLD)")
+}
+
+// printPlan writes plan to stdout when --explain is set and reports whether it did,
+// in which case the command must stop before contacting Rancher
+func printPlan(plan *rancher.Plan) (bool, error) {
+	if !explain {
+		return false, nil
+	}
+	return true, plan.Write(os.Stdout)
+}
+
+// durationValue is a duration flag that also accepts days, e.g. "30d"
+type durationValue time.Duration
+
+func (d *durationValue) String() string {
+	if *d == 0 {
+		return "0"
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *durationValue) Set(value string) error {
+	parsed, err := config.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(parsed)
+	return nil
+}
+
+func (d *durationValue) Type() string {
+	return "duration"
+}
+
+// terminalReporter shows the progress and warnings of Rancher clients on stderr
+var terminalReporter = events.NewTerminal(os.Stderr)
+
+// newRancherClient creates a Rancher client reporting to the terminal
+func newRancherClient(cfg *config.Config, opts ...rancher.ClientOption) (*rancher.Client, error) {
+	return rancher.NewClient(cfg, append([]rancher.ClientOption{rancher.WithReporter(terminalReporter)}, opts...)...)
+}
+
+// loadRancherConfig builds the configuration from the environment, then from
+// --from-kubeconfig if given, and finally overrides it with any Rancher flags
+// explicitly set on the command line
+func loadRancherConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg := loadConfig()
+
+	if cmd.Flags().Changed("from-kubeconfig") || cmd.Flags().Changed("context") {
+		endpoint, err := kubeconfig.DiscoverRancher(fromKubeconfig, fromKubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover Rancher from kubeconfig: %w", err)
+		}
+		cfg.RancherURL = endpoint.URL
+		cfg.Token = endpoint.Token
+		cfg.AccessKey = ""
+		cfg.SecretKey = ""
+		if endpoint.InsecureSkipTLSVerify {
+			cfg.InsecureSkipTLSVerify = true
+		}
+		if endpoint.CACert != "" {
+			cfg.CACert = endpoint.CACert
+		}
+		if endpoint.CACertData != "" {
+			cfg.CACertData = endpoint.CACertData
+		}
+	}
+
+	if rancherURL != "" {
+		cfg.RancherURL = rancherURL
+	}
+	if accessKey != "" {
+		cfg.AccessKey = accessKey
+	}
+	if secretKey != "" {
+		cfg.SecretKey = secretKey
+	}
+	if token != "" {
+		cfg.Token = token
+	}
+	if username != "" {
+		cfg.Username = username
+	}
+	if password != "" {
+		cfg.Password = password
+	}
+	if authProvider != "" {
+		cfg.AuthProvider = authProvider
+	}
+	if cmd.Flags().Changed("insecure-skip-tls-verify") {
+		cfg.InsecureSkipTLSVerify = insecureSkipTLS
+	}
+	if caCert != "" {
+		cfg.CACert = caCert
+	}
+	if cmd.Flags().Changed("exclude-system-cas") {
+		cfg.ExcludeSystemCAs = excludeSystemCAs
+	}
+	if cmd.Flags().Changed("max-idle-conns-per-host") {
+		cfg.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	}
+	if cmd.Flags().Changed("max-conns-per-host") {
+		cfg.MaxConnsPerHost = maxConnsPerHost
+	}
+	if cmd.Flags().Changed("idle-conn-timeout") {
+		cfg.IdleConnTimeout = idleConnTimeout
+	}
+	if cmd.Flags().Changed("disable-http2") {
+		cfg.DisableHTTP2 = disableHTTP2
+	}
+	if cmd.Flags().Changed("disable-keepalives") {
+		cfg.DisableKeepAlives = disableKeepAlives
+	}
+	if cmd.Flags().Changed("max-response-size") {
+		cfg.MaxResponseSize = maxResponseSize
+	}
+	if cmd.Flags().Changed("debug-http") {
+		cfg.DebugHTTP = debugHTTP
+	}
+	if cmd.Flags().Changed("retry-max-wait") {
+		cfg.RetryMaxWait = retryMaxWait
+	}
+	if cmd.Flags().Changed("wait-for-rancher") {
+		cfg.WaitForRancher = waitForRancher
+	}
+	if cmd.Flags().Changed("breaker-threshold") {
+		cfg.BreakerThreshold = breakerThreshold
+	}
+
+	if credentialSource != "" {
+		cfg.CredentialSource = credentialSource
+	}
+	if credentialCommand != "" {
+		cfg.CredentialCommand = config.SplitCommand(credentialCommand)
+	}
+	ctx := context.Background()
+	if completing {
+		// Completion must answer at once and never run programs
+		if len(cfg.CredentialCommand) > 0 {
+			return nil, errCompleting
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, completionTimeout)
+		defer cancel()
+	}
+	if err := credentials.Apply(ctx, cfg); err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+
+	// Fall back to the credentials stored by "login"
+	if cfg.RancherURL == "" || (cfg.Token == "" && cfg.AccessKey == "" && cfg.Username == "") {
+		applyStoredLogin(cfg)
+	}
+
+	return cfg, nil
+}
+
+// loadInstances returns the Rancher instances configured with RANCHER_INSTANCES,
+// or else cfg alone, validated
+func loadInstances(cfg *config.Config) ([]*config.Config, error) {
+	instances := []*config.Config{cfg}
+	if names := config.InstanceNames(); len(names) > 0 {
+		instances = make([]*config.Config, 0, len(names))
+		for _, name := range names {
+			instances = append(instances, config.LoadInstance(cfg, name))
+		}
+	}
+	for _, instance := range instances {
+		if err := validateInstance(instance); err != nil {
+			if instance.Name != "" {
+				return nil, fmt.Errorf("configuration error for instance %s: %w", instance.Name, err)
+			}
+			return nil, fmt.Errorf("configuration error: %w", err)
+		}
+	}
+	return instances, nil
+}
+
+// validateInstance reads the token of a Rancher instance from its credential
+// source, if it has one, and validates its configuration
+func validateInstance(cfg *config.Config) error {
+	if err := credentials.Apply(context.Background(), cfg); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// applyStoredLogin fills the Rancher URL and token from the active profile saved by
+// "login", unless another Rancher URL is configured. Problems opening the store are
+// ignored: the caller reports the missing URL.
+func applyStoredLogin(cfg *config.Config) {
+	store, err := profile.NewStore()
+	if err != nil {
+		return
+	}
+	p := store.FindByName(activeProfileName())
+	if p == nil || !p.IsRancher() || p.Token == "" {
+		return
+	}
+	if cfg.RancherURL != "" && strings.TrimSuffix(cfg.RancherURL, "/") != strings.TrimSuffix(p.RancherURL, "/") {
+		return
+	}
+
+	cfg.RancherURL = p.RancherURL
+	if cfg.Token == "" && cfg.AccessKey == "" && cfg.Username == "" {
+		cfg.Token = p.Token
+	}
+	if p.SkipTLS {
+		cfg.InsecureSkipTLSVerify = true
+	}
+	if cfg.CACert == "" {
+		cfg.CACert = p.CACert
+	}
+}
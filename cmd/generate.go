@@ -1,14 +1,29 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/encrypt"
 	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/policy"
 	"github.com/kubeconfig-wrangler/pkg/rancher"
+	"github.com/kubeconfig-wrangler/pkg/sink"
 )
 
 var (
@@ -19,9 +34,80 @@ var (
 	username        string
 	password        string
 	clusterPrefix   string
+	clusterSuffix   string
+	clusterSep      string
+	nameTemplate    string
 	outputPath      string
+	splitDir        string
+	splitLabel      string
+	printEnv        string
+	currentContext  string
+	sanitizeNames   []string
+	maxNameLength   int
+	onConflict      string
+	flattenOutput   bool
+	minifyOutput    bool
+	redactOutput    bool
+	encryptTo       []string
+	encryptFormat   string
 	insecureSkipTLS bool
 	caCert          string
+
+	secretOutput       string
+	secretNameTemplate string
+	secretNamespace    string
+	secretDataKey      string
+	secretLabels       []string
+	secretAnnotations  []string
+	secretConvention   string
+
+	endpointMode   string
+	instanceNames  string
+	scopedTokens   bool
+	scopedTokenTTL time.Duration
+	asUser         string
+	execCreds      bool
+	execCommand    string
+
+	oidcIssuerURL    string
+	oidcClientID     string
+	oidcClientSecret string
+	oidcExtraScopes  []string
+	oidcAuthProvider bool
+
+	clusterProxyURL   string
+	clusterProxyRules []string
+
+	clusterCAs            []string
+	clusterTLSServerNames []string
+	clusterInsecure       []string
+
+	contextNamespaces []string
+	projectNamespaces bool
+
+	subscribeEvents bool
+	watchRefresh    bool
+	watchInterval   time.Duration
+	failOnError     bool
+	mergeInto       string
+
+	clusterStates    []string
+	includeAllStates bool
+	harvesterMode    string
+	olderThan        time.Duration
+	newerThan        time.Duration
+
+	ephemeralLabel       string
+	ephemeralNamePattern string
+	includeEphemeral     bool
+
+	includeClusters []string
+	onlyClusters    []string
+	excludeClusters []string
+
+	// progress receives the progress messages of generateInstance, which
+	// "sync" discards to only report changes
+	progress io.Writer = os.Stderr
 )
 
 // generateCmd represents the generate command
@@ -48,6 +134,71 @@ Examples:
   # Generate kubeconfig with cluster name prefix
   kubeconfig-wrangler generate --url https://rancher.example.com --token token-xxxxx:yyyyyyy --prefix "prod-"
 
+  # Name clusters team-cluster-region, e.g. payments-cluster1-eu
+  kubeconfig-wrangler generate --prefix payments --suffix eu --separator -
+
+  # Name clusters after a template with access to labels, provider and instance
+  kubeconfig-wrangler generate --name-template '{{.Labels.team}}-{{.ClusterName}}-{{.Provider}}'
+
+  # Keep only the fastest healthy endpoint of clusters with an authorized cluster endpoint
+  kubeconfig-wrangler generate --url https://rancher.example.com --token token-xxxxx:yyyyyyy --endpoint-mode auto
+
+  # Only clusters labelled env=prod, with explicit approval for those labelled restricted
+  kubeconfig-wrangler generate --approve prod-payments \
+    --policy '"env" in cluster.labels && cluster.labels.env == "prod" ? ("restricted" in cluster.labels ? "require-approval" : "include") : "exclude"'
+
+  # Also include clusters that are being upgraded
+  kubeconfig-wrangler generate --states active,updating
+
+  # Leave out ephemeral CI clusters created less than an hour ago
+  kubeconfig-wrangler generate --older-than 1h
+
+  # Leave out CI clusters by name or label
+  kubeconfig-wrangler generate --ephemeral-name-pattern '^ci-[0-9]+' --ephemeral-label ephemeral=true
+
+  # Fetch short-lived cluster tokens at kubectl time instead of embedding tokens
+  kubeconfig-wrangler generate --exec-credentials
+
+  # Log in through Keycloak with kubelogin, straight to the clusters' endpoints
+  kubeconfig-wrangler generate --endpoint-mode direct \
+    --oidc-issuer-url https://keycloak.example.com/realms/k8s --oidc-client-id kubernetes
+
+  # Make the first production cluster the current context
+  kubeconfig-wrangler generate --output ~/.kube/rancher-config --set-current-context 'prod-*'
+
+  # One kubeconfig per cluster for automation jobs
+  kubeconfig-wrangler generate --split-dir ./kubeconfigs/
+
+  # kubeconfig-prod.yaml, kubeconfig-staging.yaml, ... by the environment label
+  kubeconfig-wrangler generate --split-by-label environment
+
+  # A kubeconfig for the production team, without its sandboxes
+  kubeconfig-wrangler generate --include 'prod-*' --exclude '*-sandbox'
+
+  # Leave out Harvester HCI clusters
+  kubeconfig-wrangler generate --harvester exclude
+
+  # Give every cluster its own token, valid for that cluster only
+  kubeconfig-wrangler generate --scoped-tokens --scoped-token-ttl 720h
+
+  # As an admin, generate the kubeconfigs a specific user would get
+  kubeconfig-wrangler generate --as-user u-abc123 --output u-abc123.yaml
+
+  # In CI, fail instead of writing a kubeconfig with clusters missing
+  kubeconfig-wrangler generate --output kubeconfig.yaml --fail-on-error
+
+  # Keep the file up to date as clusters come and go
+  kubeconfig-wrangler generate --output ~/.kube/rancher-config --subscribe
+
+  # Or refresh it on a schedule, e.g. as a service (kill -HUP to refresh now)
+  kubeconfig-wrangler generate --output ~/.kube/rancher-config --watch --interval 15m
+
+  # Reuse the Rancher URL and token of an existing Rancher-generated kubeconfig
+  kubeconfig-wrangler generate --from-kubeconfig ~/.kube/config --context mycluster
+
+  # Merge the clusters of several Rancher servers (RANCHER_DEV_URL, RANCHER_DEV_TOKEN, ...)
+  kubeconfig-wrangler generate --instances dev,stage,prod
+
   # Generate kubeconfig to a specific file
   kubeconfig-wrangler generate --url https://rancher.example.com --username admin --password mypassword --output ~/.kube/rancher-config
 
@@ -61,94 +212,832 @@ Examples:
 }
 
 func init() {
-	generateCmd.Flags().StringVarP(&rancherURL, "url", "u", "", "Rancher server URL (env: RANCHER_URL)")
-	generateCmd.Flags().StringVarP(&accessKey, "access-key", "a", "", "Rancher API access key (env: RANCHER_ACCESS_KEY)")
-	generateCmd.Flags().StringVarP(&secretKey, "secret-key", "s", "", "Rancher API secret key (env: RANCHER_SECRET_KEY)")
-	generateCmd.Flags().StringVarP(&token, "token", "t", "", "Rancher API token (access_key:secret_key) (env: RANCHER_TOKEN)")
-	generateCmd.Flags().StringVar(&username, "username", "", "Rancher username for password auth (env: RANCHER_USERNAME)")
-	generateCmd.Flags().StringVar(&password, "password", "", "Rancher password for password auth (env: RANCHER_PASSWORD)")
-	generateCmd.Flags().StringVarP(&clusterPrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
-	generateCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output file path (default: stdout) (env: RANCHER_KUBECONFIG_OUTPUT)")
-	generateCmd.Flags().BoolVarP(&insecureSkipTLS, "insecure-skip-tls-verify", "k", false, "Skip TLS certificate verification (env: RANCHER_INSECURE_SKIP_TLS_VERIFY)")
-	generateCmd.Flags().StringVar(&caCert, "ca-cert", "", "Path to CA certificate file (env: RANCHER_CA_CERT)")
+	addRancherFlags(generateCmd)
+	addNamingFlags(generateCmd, &clusterSuffix, &clusterSep)
+	generateCmd.Flags().StringVar(&nameTemplate, "name-template", "", "Go template naming the clusters, e.g. '{{.Prefix}}{{.ClusterName}}-{{.Provider}}' (env: RANCHER_NAME_TEMPLATE)")
+	generateCmd.Flags().StringVar(&onConflict, "on-conflict", "", "What to do when two clusters get the same name: error, skip (keep the first by name), overwrite (keep the last) or rename (number the last, e.g. prod-2) (default: error) (env: RANCHER_CONFLICT_STRATEGY)")
+	generateCmd.Flags().StringVar(&currentContext, "set-current-context", "", "Make this context current: a context name, or a glob such as 'prod-*' picking the first match by name; by default --output and --merge-into keep the current context of the file (env: RANCHER_CURRENT_CONTEXT)")
+	generateCmd.Flags().BoolVar(&flattenOutput, "flatten", false, "Inline the certificate, key and token files the written kubeconfig references, like kubectl config view --flatten (env: RANCHER_FLATTEN)")
+	generateCmd.Flags().BoolVar(&minifyOutput, "minify", false, "Leave out clusters and users no context uses, and empty fields such as preferences (env: RANCHER_MINIFY)")
+	generateCmd.Flags().BoolVar(&redactOutput, "redact", false, "Replace certificate data, tokens and other secrets with placeholders, for a copy to attach to a ticket or review; the result cannot be used to connect")
+	generateCmd.Flags().StringArrayVar(&encryptTo, "encrypt-recipient", nil, "Encrypt the written kubeconfigs and Secret manifests for this age public key (age1...), or the keys listed in this file (repeatable) (env: RANCHER_ENCRYPT_RECIPIENTS)")
+	generateCmd.Flags().StringVar(&encryptFormat, "encrypt-format", "", "Encryption of --encrypt-recipient: age (the whole file, ASCII-armored) or sops (the values, with the sops command) (default: age) (env: RANCHER_ENCRYPT_FORMAT)")
+	generateCmd.Flags().StringVar(&splitDir, "split-dir", "", "Also write one kubeconfig per cluster, named after its context, into this directory; without --output or --merge-into nothing is printed (env: RANCHER_KUBECONFIG_SPLIT_DIR)")
+	generateCmd.Flags().StringVar(&printEnv, "print-env", "", "With --split-dir, print a command setting KUBECONFIG to the written files, in sh (the default), fish or powershell syntax")
+	generateCmd.Flags().Lookup("print-env").NoOptDefVal = "sh"
+	generateCmd.Flags().StringVar(&splitLabel, "split-by-label", "", "Also write one kubeconfig per value of this cluster label, e.g. environment, named like --output (default: kubeconfig.yaml) with the value appended; without --output or --merge-into nothing is printed (env: RANCHER_KUBECONFIG_SPLIT_LABEL)")
+
+	generateCmd.Flags().StringVar(&instanceNames, "instances", "", "Comma-separated Rancher instances to aggregate, each configured via RANCHER_<NAME>_* variables (env: RANCHER_INSTANCES)")
+	generateCmd.Flags().BoolVar(&scopedTokens, "scoped-tokens", false, "Embed a cluster-scoped API token per cluster instead of your own token, reused by later runs until half of its lifetime is left (env: RANCHER_SCOPED_TOKENS)")
+	generateCmd.Flags().DurationVar(&scopedTokenTTL, "scoped-token-ttl", 0, "Lifetime of the scoped tokens (default 720h) (env: RANCHER_SCOPED_TOKEN_TTL)")
+	generateCmd.Flags().BoolVar(&execCreds, "exec-credentials", false, "Have kubectl fetch a cluster token through \"kubeconfig-wrangler token\" instead of embedding one (env: RANCHER_EXEC_CREDENTIALS)")
+	generateCmd.Flags().StringVar(&execCommand, "exec-command", "", "Command kubectl runs for --exec-credentials, e.g. an absolute path (default: kubeconfig-wrangler) (env: RANCHER_EXEC_COMMAND)")
+	generateCmd.Flags().StringVar(&oidcIssuerURL, "oidc-issuer-url", "", "OIDC issuer the clusters trust, e.g. a Keycloak realm; users then log in with kubelogin instead of Rancher tokens (env: RANCHER_OIDC_ISSUER_URL)")
+	generateCmd.Flags().StringVar(&oidcClientID, "oidc-client-id", "", "OIDC client ID to log in with (env: RANCHER_OIDC_CLIENT_ID)")
+	generateCmd.Flags().StringVar(&oidcClientSecret, "oidc-client-secret", "", "OIDC client secret, for confidential clients (env: RANCHER_OIDC_CLIENT_SECRET)")
+	generateCmd.Flags().StringSliceVar(&oidcExtraScopes, "oidc-extra-scope", nil, "Additional OIDC scopes to request, e.g. groups (env: RANCHER_OIDC_EXTRA_SCOPES)")
+	generateCmd.Flags().BoolVar(&oidcAuthProvider, "oidc-auth-provider", false, "Write the legacy oidc auth-provider instead of a kubelogin exec plugin (env: RANCHER_OIDC_AUTH_PROVIDER)")
+	generateCmd.Flags().StringVar(&clusterProxyURL, "cluster-proxy-url", "", "HTTP or SOCKS proxy to reach the clusters' API servers through, e.g. socks5://bastion:1080, written as their proxy-url (env: RANCHER_CLUSTER_PROXY_URL)")
+	generateCmd.Flags().StringArrayVar(&clusterProxyRules, "cluster-proxy-rule", nil, "Proxy of the clusters selected by a name glob or label, as <glob or label:value>=<proxy URL>; the first matching rule wins over --cluster-proxy-url (env: RANCHER_CLUSTER_PROXY_RULES)")
+	generateCmd.Flags().StringArrayVar(&clusterCAs, "cluster-ca", nil, "Certificate authority of the direct endpoints of the clusters matching a glob, as <glob>=<PEM file>, e.g. for a re-encrypting load balancer (env: RANCHER_CLUSTER_CAS)")
+	generateCmd.Flags().StringArrayVar(&clusterTLSServerNames, "cluster-tls-server-name", nil, "TLS server name of the direct endpoints of the clusters matching a glob, as <glob>=<name> (env: RANCHER_CLUSTER_TLS_SERVER_NAMES)")
+	generateCmd.Flags().StringArrayVar(&clusterInsecure, "cluster-insecure", nil, "Skip TLS verification for the direct endpoints of the clusters matching this glob (env: RANCHER_CLUSTER_INSECURE)")
+	generateCmd.Flags().StringSliceVar(&contextNamespaces, "namespaces", nil, "Generate one context per namespace of this list for every cluster, named <context>-<namespace> (env: RANCHER_CONTEXT_NAMESPACES)")
+	generateCmd.Flags().BoolVar(&projectNamespaces, "project-namespaces", false, "Generate one context per namespace of each cluster's projects, leaving out the System project (env: RANCHER_PROJECT_NAMESPACES)")
+	generateCmd.Flags().StringVar(&asUser, "as-user", "", "Rancher user ID to impersonate, so the kubeconfigs carry that user's permissions (env: RANCHER_AS_USER)")
+	generateCmd.Flags().StringSliceVar(&clusterStates, "states", nil, "Cluster states to generate kubeconfigs for, e.g. active,updating (default: active) (env: RANCHER_CLUSTER_STATES)")
+	generateCmd.Flags().BoolVar(&includeAllStates, "include-all-states", false, "Generate kubeconfigs for clusters in any state, warning about those that are not active (env: RANCHER_INCLUDE_ALL_STATES)")
+	addAgeFlags(generateCmd)
+	generateCmd.Flags().StringVar(&ephemeralLabel, "ephemeral-label", "", "Label marking ephemeral clusters to leave out, as key or key=value (env: RANCHER_EPHEMERAL_LABEL)")
+	generateCmd.Flags().StringVar(&ephemeralNamePattern, "ephemeral-name-pattern", "", "Regular expression on cluster names marking ephemeral clusters to leave out (env: RANCHER_EPHEMERAL_NAME_PATTERN)")
+	generateCmd.Flags().BoolVar(&includeEphemeral, "include-ephemeral", false, "Keep the clusters classified as ephemeral (env: RANCHER_INCLUDE_EPHEMERAL)")
+	addClusterFilterFlags(generateCmd)
+	generateCmd.Flags().StringVar(&harvesterMode, "harvester", "", "Harvester HCI clusters: include, exclude or only (default: include) (env: RANCHER_HARVESTER)")
+	generateCmd.Flags().BoolVar(&failOnError, "fail-on-error", false, "Exit non-zero without writing anything when any cluster's kubeconfig cannot be fetched")
+	generateCmd.Flags().StringVar(&mergeInto, "merge-into", "", "Update the Rancher contexts of an existing kubeconfig, e.g. ~/.kube/config, leaving all other entries untouched")
+	generateCmd.Flags().BoolVar(&subscribeEvents, "subscribe", false, "Keep running and regenerate --output whenever a cluster is created, removed or changes state")
+	generateCmd.Flags().BoolVar(&watchRefresh, "watch", false, "Keep running and regenerate --output every --interval, or right away on SIGHUP")
+	generateCmd.Flags().DurationVar(&watchInterval, "interval", defaultWatchInterval, "Time between two refreshes with --watch")
+	addPolicyFlags(generateCmd)
+	generateCmd.Flags().StringVar(&endpointMode, "endpoint-mode", "all", "Contexts to keep for clusters with an authorized cluster endpoint: all (keep-all), proxy (keep-proxied), direct, keep-direct (one direct context, the FQDN one if any) or auto")
+
+	// Kubernetes Secret sink
+	generateCmd.Flags().StringVar(&secretOutput, "secret-output", "", "Also write the kubeconfig as Kubernetes Secret manifest(s) to this path (\"-\" for stdout)")
+	generateCmd.Flags().StringVar(&secretNameTemplate, "secret-name-template", "", "Go template for the Secret name (default: "+sink.DefaultSecretNameTemplate+")")
+	generateCmd.Flags().StringVar(&secretNamespace, "secret-namespace", "", "Namespace for the Secret manifest(s)")
+	generateCmd.Flags().StringVar(&secretDataKey, "secret-data-key", "", "Data key holding the kubeconfig (default: config, or value for flux)")
+	generateCmd.Flags().StringArrayVar(&secretLabels, "secret-label", nil, "Label to add to the Secret as key=value; the value may be a template (repeatable)")
+	generateCmd.Flags().StringArrayVar(&secretAnnotations, "secret-annotation", nil, "Annotation to add to the Secret as key=value; the value may be a template (repeatable)")
+	generateCmd.Flags().StringVar(&secretConvention, "secret-convention", "", "Secret layout convention: flux or argocd (default: plain Opaque Secret)")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
 	// Build configuration from flags and environment
-	cfg := config.LoadFromEnv()
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
 
-	// Override with command line flags if provided
-	if rancherURL != "" {
-		cfg.RancherURL = rancherURL
+	applyNamingFlags(cmd, cfg, clusterSuffix, clusterSep)
+	if cmd.Flags().Changed("name-template") {
+		cfg.NameTemplate = nameTemplate
+	}
+	if cmd.Flags().Changed("split-dir") {
+		cfg.SplitDir = splitDir
+	}
+	if cmd.Flags().Changed("split-by-label") {
+		cfg.SplitLabel = splitLabel
+	}
+	if cmd.Flags().Changed("on-conflict") {
+		cfg.ConflictStrategy = onConflict
+	}
+	if cmd.Flags().Changed("flatten") {
+		cfg.Flatten = flattenOutput
+	}
+	if cmd.Flags().Changed("minify") {
+		cfg.Minify = minifyOutput
+	}
+	if cmd.Flags().Changed("encrypt-recipient") {
+		cfg.EncryptRecipients = encryptTo
+	}
+	if cmd.Flags().Changed("encrypt-format") {
+		cfg.EncryptFormat = encryptFormat
+	}
+	if _, err := encrypt.ParseFormat(cfg.EncryptFormat); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	if cmd.Flags().Changed("set-current-context") {
+		cfg.CurrentContext = currentContext
+	}
+	if cmd.Flags().Changed("states") {
+		cfg.ClusterStates = clusterStates
+	}
+	if cmd.Flags().Changed("include-all-states") {
+		cfg.IncludeAllStates = includeAllStates
+	}
+	if cmd.Flags().Changed("scoped-tokens") {
+		cfg.ScopedTokens = scopedTokens
+	}
+	if cmd.Flags().Changed("scoped-token-ttl") {
+		cfg.ScopedTokenTTL = scopedTokenTTL
+	}
+	if cmd.Flags().Changed("as-user") {
+		cfg.AsUser = asUser
+	}
+	if cmd.Flags().Changed("exec-credentials") {
+		cfg.ExecCredentials = execCreds
+	}
+	if cmd.Flags().Changed("exec-command") {
+		cfg.ExecCommand = execCommand
 	}
-	if accessKey != "" {
-		cfg.AccessKey = accessKey
+	if cmd.Flags().Changed("oidc-issuer-url") {
+		cfg.OIDCIssuerURL = oidcIssuerURL
 	}
-	if secretKey != "" {
-		cfg.SecretKey = secretKey
+	if cmd.Flags().Changed("oidc-client-id") {
+		cfg.OIDCClientID = oidcClientID
 	}
-	if token != "" {
-		cfg.Token = token
+	if cmd.Flags().Changed("oidc-client-secret") {
+		cfg.OIDCClientSecret = oidcClientSecret
 	}
-	if username != "" {
-		cfg.Username = username
+	if cmd.Flags().Changed("oidc-extra-scope") {
+		cfg.OIDCExtraScopes = oidcExtraScopes
 	}
-	if password != "" {
-		cfg.Password = password
+	if cmd.Flags().Changed("oidc-auth-provider") {
+		cfg.OIDCAuthProvider = oidcAuthProvider
 	}
-	if clusterPrefix != "" {
-		cfg.ClusterPrefix = clusterPrefix
+	if cmd.Flags().Changed("cluster-proxy-url") {
+		cfg.ClusterProxyURL = clusterProxyURL
 	}
-	if outputPath != "" {
-		cfg.OutputPath = outputPath
+	if cmd.Flags().Changed("cluster-proxy-rule") {
+		cfg.ClusterProxyRules = clusterProxyRules
 	}
-	if cmd.Flags().Changed("insecure-skip-tls-verify") {
-		cfg.InsecureSkipTLSVerify = insecureSkipTLS
+	if cmd.Flags().Changed("cluster-ca") {
+		cfg.ClusterCAs = clusterCAs
 	}
-	if caCert != "" {
-		cfg.CACert = caCert
+	if cmd.Flags().Changed("cluster-tls-server-name") {
+		cfg.ClusterTLSServerNames = clusterTLSServerNames
 	}
+	if cmd.Flags().Changed("cluster-insecure") {
+		cfg.ClusterInsecure = clusterInsecure
+	}
+	if cmd.Flags().Changed("namespaces") {
+		cfg.ContextNamespaces = contextNamespaces
+	}
+	if cmd.Flags().Changed("project-namespaces") {
+		cfg.ProjectNamespaces = projectNamespaces
+	}
+	if len(cfg.ContextNamespaces) > 0 && cfg.ProjectNamespaces {
+		return fmt.Errorf("configuration error: --namespaces and --project-namespaces are mutually exclusive")
+	}
+	if cmd.Flags().Changed("harvester") {
+		cfg.Harvester = config.HarvesterMode(harvesterMode)
+	}
+	applyAgeFlags(cmd, cfg)
+	if cmd.Flags().Changed("ephemeral-label") {
+		cfg.EphemeralLabel = ephemeralLabel
+	}
+	if cmd.Flags().Changed("ephemeral-name-pattern") {
+		cfg.EphemeralNamePattern = ephemeralNamePattern
+	}
+	if cmd.Flags().Changed("include-ephemeral") {
+		cfg.IncludeEphemeral = includeEphemeral
+	}
+	applyClusterFilterFlags(cmd, cfg)
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
+	mode, err := kubeconfig.ParseEndpointMode(endpointMode)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	pol, err := loadPolicy()
+	if err != nil {
 		return fmt.Errorf("configuration error: %w", err)
 	}
 
+	// A single Rancher server unless several instances are configured
+	instances := []*config.Config{cfg}
+	names := config.InstanceNames()
+	if cmd.Flags().Changed("instances") {
+		names = config.SplitInstanceNames(instanceNames)
+	}
+	if len(names) > 0 {
+		if cmd.Flags().Changed("as-user") {
+			return fmt.Errorf("configuration error: --as-user cannot be used with several instances; set RANCHER_<NAME>_AS_USER per instance")
+		}
+		instances = make([]*config.Config, 0, len(names))
+		for _, name := range names {
+			instances = append(instances, config.LoadInstance(cfg, name))
+		}
+	}
+
+	// Validate configuration
+	for _, instance := range instances {
+		if err := validateInstance(instance); err != nil {
+			if instance.Name != "" {
+				return fmt.Errorf("configuration error for instance %s: %w", instance.Name, err)
+			}
+			return fmt.Errorf("configuration error: %w", err)
+		}
+	}
+
+	if mergeInto != "" {
+		if cmd.Flags().Changed("output") {
+			return fmt.Errorf("configuration error: --merge-into and --output cannot be combined")
+		}
+		if redactOutput {
+			return fmt.Errorf("configuration error: --redact cannot be used with --merge-into, which would replace the credentials of the kubeconfig being updated")
+		}
+		if len(cfg.EncryptRecipients) > 0 {
+			return fmt.Errorf("configuration error: --encrypt-recipient cannot be used with --merge-into, since kubectl cannot read an encrypted kubeconfig")
+		}
+		cfg.OutputPath = ""
+	}
+	if printEnv != "" {
+		if cfg.SplitDir == "" {
+			return fmt.Errorf("configuration error: --print-env requires --split-dir")
+		}
+		if len(cfg.EncryptRecipients) > 0 {
+			return fmt.Errorf("configuration error: --print-env cannot be used with --encrypt-recipient, since kubectl cannot read encrypted kubeconfigs")
+		}
+		if _, err := kubeconfigExport(printEnv, nil); err != nil {
+			return err
+		}
+	}
+	if subscribeEvents && cfg.OutputPath == "" && mergeInto == "" && cfg.SplitDir == "" && cfg.SplitLabel == "" {
+		return fmt.Errorf("configuration error: --subscribe requires --output, --merge-into, --split-dir or --split-by-label")
+	}
+	if watchRefresh {
+		switch {
+		case subscribeEvents:
+			return fmt.Errorf("configuration error: --watch and --subscribe cannot be combined")
+		case cfg.OutputPath == "" && mergeInto == "" && cfg.SplitDir == "" && cfg.SplitLabel == "":
+			return fmt.Errorf("configuration error: --watch requires --output, --merge-into, --split-dir or --split-by-label")
+		case watchInterval <= 0:
+			return fmt.Errorf("configuration error: --interval must be positive")
+		}
+	}
+
+	if explain {
+		return explainGenerate("generate", instances, mode, pol)
+	}
+
+	if watchRefresh {
+		return regenerateEvery(cfg, instances, mode, pol, watchInterval)
+	}
+	if _, err := generateAndWrite(cfg, instances, mode, pol); err != nil {
+		return err
+	}
+
+	if subscribeEvents {
+		return regenerateOnClusterEvents(cfg, instances, mode, pol)
+	}
+	return nil
+}
+
+// generateAndWrite generates the merged kubeconfig of all instances and writes it,
+// and any Secret manifests, to the configured outputs. It returns the number of
+// contexts generated.
+func generateAndWrite(cfg *config.Config, instances []*config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy) (int, error) {
+	mergedConfig, existing, err := generateAll(instances, mode, pol)
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep the notes attached with "annotate" and the context chosen with
+	// "kubectl config use-context" in the file being replaced
+	if cfg.OutputPath != "" {
+		if previous, err := clientcmd.LoadFromFile(cfg.OutputPath); err == nil {
+			if _, err := kubeconfig.CarryOverNotes(mergedConfig, previous); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to keep notes from %s: %v\n", cfg.OutputPath, err)
+			}
+			if _, err := kubeconfig.CarryOverGeneratedAt(mergedConfig, previous); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to keep generation times from %s: %v\n", cfg.OutputPath, err)
+			}
+			kubeconfig.CarryOverCurrentContext(mergedConfig, previous)
+		}
+	}
+	if cfg.CurrentContext != "" && mergeInto == "" {
+		if _, err := kubeconfig.SetCurrentContext(mergedConfig, cfg.CurrentContext); err != nil {
+			return 0, fmt.Errorf("failed to set the current context: %w", err)
+		}
+	}
+
+	generator := kubeconfig.NewGenerator(cfg.ClusterPrefix)
+	generator.SetFlatten(cfg.Flatten)
+	generator.SetMinify(cfg.Minify)
+	generator.SetRedact(redactOutput)
+	kubeconfigData, err := generator.Serialize(mergedConfig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+
+	if secretOutput != "" {
+		prefix := cfg.ClusterPrefix
+		if len(instances) > 1 {
+			prefix = ""
+		}
+		if err := writeSecretSink(cfg, prefix, kubeconfigData); err != nil {
+			return 0, err
+		}
+	}
+
+	if cfg.SplitDir != "" {
+		paths, err := writeSplitKubeconfigs(cfg, mergedConfig, generator)
+		if err != nil {
+			return 0, err
+		}
+		if printEnv != "" {
+			line, err := kubeconfigExport(printEnv, paths)
+			if err != nil {
+				return 0, err
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if cfg.SplitLabel != "" {
+		if err := writeGroupKubeconfigs(cfg, mergedConfig, generator); err != nil {
+			return 0, err
+		}
+	}
+
+	// Output the kubeconfig
+	if mergeInto != "" {
+		if err := mergeIntoKubeconfig(mergeInto, mergedConfig, existing, cfg.CurrentContext, generator); err != nil {
+			return 0, err
+		}
+		return len(mergedConfig.Contexts), nil
+	}
+	output, err := encryptOutput(cfg, kubeconfigData)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.OutputPath != "" {
+		changed, err := writeIfChanged(cfg.OutputPath, output)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write kubeconfig to %s: %w", cfg.OutputPath, err)
+		}
+		if changed {
+			fmt.Fprintf(os.Stderr, "Kubeconfig written to %s\n", cfg.OutputPath)
+		} else {
+			fmt.Fprintf(os.Stderr, "Kubeconfig at %s is unchanged\n", cfg.OutputPath)
+		}
+	} else if cfg.SplitDir == "" && cfg.SplitLabel == "" {
+		fmt.Print(string(output))
+	}
+
+	return len(mergedConfig.Contexts), nil
+}
+
+// encryptOutput encrypts data for cfg's recipients, if there are any. age
+// output differs on every run, so encrypted files are always rewritten.
+func encryptOutput(cfg *config.Config, data []byte) ([]byte, error) {
+	if len(cfg.EncryptRecipients) == 0 {
+		return data, nil
+	}
+	format, err := encrypt.ParseFormat(cfg.EncryptFormat)
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	encrypted, err := encrypt.Encrypt(format, data, cfg.EncryptRecipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+	}
+	return encrypted, nil
+}
+
+// writeIfChanged writes data to path unless the file already holds exactly
+// that, so an unchanged kubeconfig keeps its modification time and does not
+// wake up file watchers. It reports whether the file was written.
+func writeIfChanged(path string, data []byte) (bool, error) {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeSplitKubeconfigs writes one kubeconfig per cluster of the generated
+// kubeconfig into cfg's split directory, serialized by generator and encrypted
+// like the merged one, and returns their paths. Files of clusters that are
+// gone are left in place.
+func writeSplitKubeconfigs(cfg *config.Config, generated *api.Config, generator *kubeconfig.Generator) ([]string, error) {
+	dir := cfg.SplitDir
+	split, err := kubeconfig.SplitByCluster(generated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split kubeconfig: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	paths := make([]string, 0, len(split))
+	for name, config := range split {
+		data, err := generator.Serialize(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate kubeconfig for %s: %w", name, err)
+		}
+		if data, err = encryptOutput(cfg, data); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, kubeconfig.SplitFileName(name))
+		if _, err := writeIfChanged(path, data); err != nil {
+			return nil, fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	fmt.Fprintf(os.Stderr, "%d kubeconfig(s) written to %s\n", len(split), dir)
+	return paths, nil
+}
+
+// writeGroupKubeconfigs writes one kubeconfig per value of cfg's split label,
+// named after the output file, serialized by generator and encrypted like the
+// merged one. Clusters without the label are only in the merged kubeconfig.
+func writeGroupKubeconfigs(cfg *config.Config, generated *api.Config, generator *kubeconfig.Generator) error {
+	split, ungrouped, err := kubeconfig.SplitByGroup(generated)
+	if err != nil {
+		return fmt.Errorf("failed to split kubeconfig: %w", err)
+	}
+	base := cfg.OutputPath
+	if base == "" {
+		base = "kubeconfig.yaml"
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(split)) {
+		data, err := generator.Serialize(split[group])
+		if err != nil {
+			return fmt.Errorf("failed to generate kubeconfig for %s: %w", group, err)
+		}
+		if data, err = encryptOutput(cfg, data); err != nil {
+			return err
+		}
+		path := kubeconfig.GroupFileName(base, group)
+		if _, err := writeIfChanged(path, data); err != nil {
+			return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Kubeconfig for %s=%s written to %s\n", cfg.SplitLabel, group, path)
+	}
+	if len(ungrouped) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d context(s) without the %s label are in none of the split kubeconfigs: %s\n", len(ungrouped), cfg.SplitLabel, strings.Join(ungrouped, ", "))
+	}
+	return nil
+}
+
+// mergeIntoKubeconfig updates the Rancher contexts of the kubeconfig at path
+// with the generated ones and prunes those of deleted clusters, keeping every
+// other entry and the notes of updated contexts. existing holds the IDs of the
+// clusters Rancher still lists, by Rancher URL. The current context of the
+// file is kept unless currentContext names another one. generator serializes
+// the result.
+func mergeIntoKubeconfig(path string, generated *api.Config, existing map[string][]string, currentContext string, generator *kubeconfig.Generator) error {
+	target, err := clientcmd.LoadFromFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		target = api.NewConfig()
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if _, err := kubeconfig.CarryOverNotes(generated, target); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to keep notes from %s: %v\n", path, err)
+	}
+	if _, err := kubeconfig.CarryOverGeneratedAt(generated, target); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to keep generation times from %s: %v\n", path, err)
+	}
+	report, err := kubeconfig.UpdateManaged(target, generated)
+	if err != nil {
+		return fmt.Errorf("failed to merge into %s: %w", path, err)
+	}
+	removed := kubeconfig.Prune(target, existing)
+	if currentContext != "" {
+		if _, err := kubeconfig.SetCurrentContext(target, currentContext); err != nil {
+			return fmt.Errorf("failed to set the current context: %w", err)
+		}
+	}
+
+	data, err := generator.Serialize(target)
+	if err != nil {
+		return fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+	if _, err := writeIfChanged(path, data); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Merged into %s: %d added, %d updated, %d removed\n", path, len(report.Added), len(report.Updated), len(removed))
+	for _, name := range removed {
+		fmt.Fprintf(os.Stderr, "  removed %s (no longer in Rancher)\n", name)
+	}
+	return nil
+}
+
+// generateAll generates the kubeconfig of every instance and combines them. It
+// also returns the IDs of the clusters each Rancher server lists, by the
+// Rancher URL recorded in the generated contexts.
+func generateAll(instances []*config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy) (*api.Config, map[string][]string, error) {
+	generated := make([]*api.Config, 0, len(instances))
+	existing := make(map[string][]string)
+	for _, instance := range instances {
+		merged, clusterIDs, err := generateInstance(instance, mode, pol)
+		if err != nil {
+			if instance.Name != "" {
+				return nil, nil, fmt.Errorf("instance %s: %w", instance.Name, err)
+			}
+			return nil, nil, err
+		}
+		generated = append(generated, merged)
+		for _, server := range kubeconfig.RancherServers(merged) {
+			existing[server] = clusterIDs
+		}
+	}
+
+	if len(generated) == 1 {
+		return generated[0], existing, nil
+	}
+	combined, err := kubeconfig.Combine(generated...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge Rancher instances: %w", err)
+	}
+	return combined, existing, nil
+}
+
+// addClusterFilterFlags registers the flags selecting clusters by name or ID
+func addClusterFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&includeClusters, "include", nil, "Only generate clusters whose name matches one of these globs, or /regexp/, e.g. 'prod-*' (env: RANCHER_INCLUDE_CLUSTERS)")
+	cmd.Flags().StringSliceVar(&excludeClusters, "exclude", nil, "Leave out clusters whose name matches one of these globs, or /regexp/, e.g. '*-sandbox' (env: RANCHER_EXCLUDE_CLUSTERS)")
+	cmd.Flags().StringSliceVar(&onlyClusters, "clusters", nil, "Only generate these clusters, given by exact name or ID, e.g. prod-a,c-m-x7k2p (env: RANCHER_CLUSTERS)")
+	for _, name := range []string{"clusters", "include", "exclude"} {
+		_ = cmd.RegisterFlagCompletionFunc(name, completeClusterNames)
+	}
+}
+
+// applyClusterFilterFlags overrides cfg with the cluster filter flags set on
+// the command line
+func applyClusterFilterFlags(cmd *cobra.Command, cfg *config.Config) {
+	if cmd.Flags().Changed("include") {
+		cfg.IncludeClusters = includeClusters
+	}
+	if cmd.Flags().Changed("exclude") {
+		cfg.ExcludeClusters = excludeClusters
+	}
+	if cmd.Flags().Changed("clusters") {
+		cfg.Clusters = onlyClusters
+	}
+}
+
+// addNamingFlags registers the flags completing the cluster prefix with a
+// suffix and a separator
+func addNamingFlags(cmd *cobra.Command, suffix, separator *string) {
+	cmd.Flags().StringVar(suffix, "suffix", "", "Suffix to add to cluster names, e.g. a region (env: RANCHER_CLUSTER_SUFFIX)")
+	cmd.Flags().StringVar(separator, "separator", "", "Separator placed between prefix, cluster name and suffix (default: none, concatenated as given) (env: RANCHER_CLUSTER_SEPARATOR)")
+	cmd.Flags().StringSliceVar(&sanitizeNames, "sanitize-names", nil, "Sanitize the generated names: lowercase, replace-invalid (runs of characters other than letters, digits, '.', '_' and '-' become '-') or all (env: RANCHER_SANITIZE_NAMES)")
+	cmd.Flags().IntVar(&maxNameLength, "max-name-length", 0, "Shorten longer generated names, ending them with a hash of the full name (at least 16; default: no limit) (env: RANCHER_MAX_NAME_LENGTH)")
+}
+
+// applyNamingFlags overrides cfg with the naming flags set on the command line
+func applyNamingFlags(cmd *cobra.Command, cfg *config.Config, suffix, separator string) {
+	if cmd.Flags().Changed("suffix") {
+		cfg.ClusterSuffix = suffix
+	}
+	if cmd.Flags().Changed("separator") {
+		cfg.ClusterSeparator = separator
+	}
+	if cmd.Flags().Changed("sanitize-names") {
+		cfg.SanitizeNames = sanitizeNames
+	}
+	if cmd.Flags().Changed("max-name-length") {
+		cfg.MaxNameLength = maxNameLength
+	}
+}
+
+// newGenerator returns a generator naming clusters after cfg's prefix, suffix
+// and separator, or its name template
+func newGenerator(cfg *config.Config) (*kubeconfig.Generator, error) {
+	generator := kubeconfig.NewGenerator(cfg.ClusterPrefix)
+	generator.SetGeneratedAt(time.Now())
+	generator.SetSuffix(cfg.ClusterSuffix)
+	generator.SetSeparator(cfg.ClusterSeparator)
+	generator.SetGroupLabel(cfg.SplitLabel)
+	if err := generator.SetNameTemplate(cfg.NameTemplate); err != nil {
+		return nil, err
+	}
+	if len(cfg.SanitizeNames) > 0 || cfg.MaxNameLength != 0 {
+		sanitizer, err := kubeconfig.ParseNameSanitizer(cfg.SanitizeNames, cfg.MaxNameLength)
+		if err != nil {
+			return nil, fmt.Errorf("configuration error: %w", err)
+		}
+		generator.SetSanitizer(sanitizer)
+	}
+	strategy, err := kubeconfig.ParseConflictStrategy(cfg.ConflictStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	generator.SetConflictStrategy(strategy)
+	proxyRules, err := kubeconfig.ParseProxyRules(cfg.ClusterProxyRules, cfg.ClusterProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	generator.SetProxyRules(proxyRules)
+	tlsOverrides, err := kubeconfig.ParseTLSOverrides(cfg.ClusterCAs, cfg.ClusterTLSServerNames, cfg.ClusterInsecure)
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	generator.SetTLSOverrides(tlsOverrides)
+	if cfg.ExecCredentials {
+		generator.SetExecCommand(cfg.ExecCommandOrDefault())
+	}
+	if cfg.OIDCIssuerURL != "" {
+		generator.SetOIDC(&kubeconfig.OIDCConfig{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			ExtraScopes:  cfg.OIDCExtraScopes,
+			AuthProvider: cfg.OIDCAuthProvider,
+		})
+	}
+	return generator, nil
+}
+
+// generateInstance fetches the kubeconfigs of every active cluster of one Rancher
+// server and merges them using that server's cluster prefix. It also returns
+// the IDs of all the clusters the server lists.
+func generateInstance(cfg *config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy) (*api.Config, []string, error) {
 	// Create Rancher client
-	client, err := rancher.NewClient(cfg)
+	client, err := newRancherClient(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create Rancher client: %w", err)
+		return nil, nil, fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+	if pol != nil {
+		client.SetClusterFilter(policyFilter(client, pol, approvedNames))
 	}
 
 	// Get kubeconfigs for all clusters
-	fmt.Fprintln(os.Stderr, "Fetching clusters from Rancher...")
-	kubeconfigs, err := client.GetAllKubeconfigs()
+	fmt.Fprintf(progress, "Fetching clusters from %s (run %s)...\n", cfg.RancherURL, rancher.CorrelationID)
+	result, err := client.FetchKubeconfigs()
 	if err != nil {
-		return fmt.Errorf("failed to get kubeconfigs: %w", err)
+		return nil, nil, fmt.Errorf("failed to get kubeconfigs: %w", err)
+	}
+	warnSchemaDrift(client)
+
+	if len(result.Failures) > 0 {
+		printFailures(result.Failures)
+		if failOnError {
+			return nil, nil, fmt.Errorf("%d cluster(s) failed (--fail-on-error)", len(result.Failures))
+		}
 	}
+	kubeconfigs := result.Kubeconfigs
 
+	if len(kubeconfigs) == 0 && len(result.Failures) > 0 {
+		return nil, nil, fmt.Errorf("none of the %d eligible cluster(s) could be fetched", len(result.Failures))
+	}
 	if len(kubeconfigs) == 0 {
-		return fmt.Errorf("no active clusters found")
+		return nil, nil, fmt.Errorf("no clusters found in an accepted state (%s)", strings.Join(cfg.AcceptedClusterStates(), ", "))
 	}
 
-	fmt.Fprintf(os.Stderr, "Found %d active cluster(s)\n", len(kubeconfigs))
+	fmt.Fprintf(progress, "Found %d cluster(s)\n", len(kubeconfigs))
 
 	// Generate merged kubeconfig
-	generator := kubeconfig.NewGenerator(cfg.ClusterPrefix)
-	kubeconfigData, err := generator.Generate(kubeconfigs)
+	generator, err := newGenerator(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate kubeconfig: %w", err)
+		return nil, nil, err
+	}
+	for name, cluster := range result.Clusters {
+		generator.SetClusterInfo(name, kubeconfig.NameData{
+			ClusterID: cluster.ID,
+			Provider:  cluster.Provider,
+			Instance:  cfg.Name,
+			Labels:    cluster.Labels,
+		})
+	}
+	if len(cfg.ContextNamespaces) > 0 {
+		generator.SetAllNamespaces(cfg.ContextNamespaces)
 	}
+	if cfg.ProjectNamespaces {
+		for _, name := range slices.Sorted(maps.Keys(result.Clusters)) {
+			namespaces, err := client.ListProjectNamespaces(result.Clusters[name].ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to list the namespaces of cluster %s, generating a single context: %v\n", name, err)
+				continue
+			}
+			if len(namespaces) == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: cluster %s has no namespaces in its projects, generating a single context\n", name)
+			}
+			generator.SetNamespaces(name, namespaces)
+		}
+	}
+	generator.SetEndpointMode(mode, nil)
+	if mode == kubeconfig.EndpointModeAuto {
+		fmt.Fprintln(progress, "Probing cluster endpoints...")
+	}
+	merged, err := generator.MergeConfigs(kubeconfigs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+	for _, conflict := range generator.Conflicts() {
+		fmt.Fprintf(os.Stderr, "Resolved name conflict: %s\n", conflict)
+	}
+	return merged, result.ListedIDs, nil
+}
 
-	// Output the kubeconfig
-	if cfg.OutputPath != "" {
-		if err := os.WriteFile(cfg.OutputPath, kubeconfigData, 0600); err != nil {
-			return fmt.Errorf("failed to write kubeconfig to %s: %w", cfg.OutputPath, err)
+// printFailures prints a table of the clusters whose kubeconfig could not be fetched
+func printFailures(failures []rancher.ClusterFailure) {
+	fmt.Fprintf(os.Stderr, "\n%d cluster(s) failed:\n", len(failures))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  CLUSTER\tID\tERROR")
+	for _, failure := range failures {
+		fmt.Fprintf(w, "  %s\t%s\t%v\n", failure.Name, failure.ID, failure.Err)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+}
+
+// addAgeFlags registers the cluster age filters
+func addAgeFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "Only clusters created at least this long ago, e.g. 1h to leave out ephemeral CI clusters (env: RANCHER_OLDER_THAN)")
+	cmd.Flags().DurationVar(&newerThan, "newer-than", 0, "Only clusters created less than this long ago (env: RANCHER_NEWER_THAN)")
+}
+
+// applyAgeFlags overrides the configured age filters with the flags given
+func applyAgeFlags(cmd *cobra.Command, cfg *config.Config) {
+	if cmd.Flags().Changed("older-than") {
+		cfg.OlderThan = olderThan
+	}
+	if cmd.Flags().Changed("newer-than") {
+		cfg.NewerThan = newerThan
+	}
+}
+
+// explainGenerate prints the plan of generating the kubeconfigs of every instance
+func explainGenerate(command string, instances []*config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy) error {
+	for i, instance := range instances {
+		if i > 0 {
+			fmt.Println()
+		}
+		plan := rancher.NewPlan(command, instance)
+		if pol != nil {
+			plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/users?me=true", Count: "once", Purpose: "look up the current user for the policy"})
+		}
+		plan.GetAllKubeconfigs()
+		target := instance.OutputPath
+		if instance.SplitDir != "" {
+			plan.Note("one kubeconfig per cluster is also written to %s", instance.SplitDir)
+		}
+		if instance.ProjectNamespaces {
+			plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/projects?clusterId=<id>", Count: "per cluster", Purpose: "list the cluster's projects"})
+			plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/cluster/<id>/namespaces", Count: "per cluster", Purpose: "list the namespaces of the projects, one context each"})
 		}
-		fmt.Fprintf(os.Stderr, "Kubeconfig written to %s\n", cfg.OutputPath)
-	} else {
-		fmt.Print(string(kubeconfigData))
+		if instance.SplitLabel != "" {
+			plan.Note("one kubeconfig per value of the %s cluster label is also written", instance.SplitLabel)
+		}
+		if mergeInto != "" {
+			target = mergeInto
+			plan.Note("only the contexts of this Rancher server in %s are updated; its other entries are kept", mergeInto)
+		}
+		if subscribeEvents {
+			plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/subscribe", Count: "once, kept open", Purpose: "watch cluster events (websocket)"})
+			plan.Note("every cluster event repeats the calls above to regenerate %s", target)
+		}
+		if watchRefresh {
+			plan.Note("the calls above are repeated every %s, or on SIGHUP, to regenerate %s", watchInterval, target)
+		}
+		if mode == kubeconfig.EndpointModeAuto {
+			plan.Note("the endpoints of clusters with an authorized cluster endpoint are probed directly, not through Rancher")
+		}
+		if _, err := printPlan(plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSecretSink renders the generated kubeconfig as Kubernetes Secret manifests
+// according to the --secret-* flags and writes them, encrypted like the
+// kubeconfig, to --secret-output
+func writeSecretSink(cfg *config.Config, prefix string, kubeconfigData []byte) error {
+	labels, err := sink.ParseKeyValues(secretLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --secret-label: %w", err)
+	}
+	annotations, err := sink.ParseKeyValues(secretAnnotations)
+	if err != nil {
+		return fmt.Errorf("invalid --secret-annotation: %w", err)
 	}
 
+	opts := sink.SecretOptions{
+		NameTemplate: secretNameTemplate,
+		Namespace:    secretNamespace,
+		Key:          secretDataKey,
+		Labels:       labels,
+		Annotations:  annotations,
+		Convention:   sink.Convention(secretConvention),
+	}
+	data := sink.SecretTemplateData{
+		Profile: activeProfileName(),
+		Prefix:  prefix,
+	}
+
+	manifest, err := sink.RenderSecrets(opts, data, kubeconfigData)
+	if err != nil {
+		return fmt.Errorf("failed to render kubeconfig secret: %w", err)
+	}
+	if manifest, err = encryptOutput(cfg, manifest); err != nil {
+		return err
+	}
+
+	if secretOutput == "-" {
+		fmt.Print(string(manifest))
+		return nil
+	}
+	if err := os.WriteFile(secretOutput, manifest, 0600); err != nil {
+		return fmt.Errorf("failed to write secret manifest to %s: %w", secretOutput, err)
+	}
+	fmt.Fprintf(os.Stderr, "Secret manifest written to %s\n", secretOutput)
 	return nil
 }
+
+// warnSchemaDrift prints warnings about unexpected Rancher API responses
+func warnSchemaDrift(client *rancher.Client) {
+	for _, warning := range client.SchemaDrift().Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// importCmd adopts existing contexts of Rancher clusters as managed ones
+var importCmd = &cobra.Command{
+	Use:     "import",
+	Aliases: []string{"adopt"},
+	Short:   "Mark existing contexts of Rancher clusters as managed",
+	Long: `Scan a kubeconfig, by default the one kubectl uses, for contexts of the
+clusters Rancher lists and mark them as managed, as if "generate" had written
+them. "generate --merge-into" then updates them instead of refusing to replace
+them, and removes them once their cluster is deleted from Rancher.
+
+A context belongs to a cluster when its server goes through the Rancher proxy
+with the cluster's ID, or is the cluster's API endpoint or the FQDN of its
+authorized cluster endpoint. Other contexts are left as they are.
+
+Examples:
+  # See which contexts of ~/.kube/config would be adopted
+  kubeconfig-wrangler import --dry-run
+
+  # Adopt them, then keep them up to date
+  kubeconfig-wrangler import
+  kubeconfig-wrangler generate --merge-into ~/.kube/config`,
+	RunE: runImport,
+}
+
+var (
+	importKubeconfig string
+	importDryRun     bool
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	addRancherFlags(importCmd)
+	importCmd.Flags().StringVar(&importKubeconfig, "kubeconfig", "", "Kubeconfig to scan (default: the first file of $KUBECONFIG, or ~/.kube/config)")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only print the contexts that would be adopted")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	instances := []*config.Config{cfg}
+	if names := config.InstanceNames(); len(names) > 0 {
+		instances = make([]*config.Config, 0, len(names))
+		for _, name := range names {
+			instances = append(instances, config.LoadInstance(cfg, name))
+		}
+	}
+	for _, instance := range instances {
+		if err := validateInstance(instance); err != nil {
+			if instance.Name != "" {
+				return fmt.Errorf("configuration error for instance %s: %w", instance.Name, err)
+			}
+			return fmt.Errorf("configuration error: %w", err)
+		}
+	}
+
+	path := importKubeconfig
+	if path == "" {
+		path = clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+	}
+
+	if explain {
+		for i, instance := range instances {
+			if i > 0 {
+				fmt.Println()
+			}
+			plan := rancher.NewPlan("import", instance)
+			plan.ListClusters()
+			plan.Note("the matching contexts of %s are then marked as managed", path)
+			if _, err := printPlan(plan); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	target, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var adopted []kubeconfig.Adoption
+	for _, instance := range instances {
+		client, err := newRancherClient(instance)
+		if err != nil {
+			return fmt.Errorf("failed to create Rancher client: %w", err)
+		}
+		clusters, err := client.ListClusters()
+		if err != nil {
+			return fmt.Errorf("failed to list clusters of %s: %w", instance.RancherURL, err)
+		}
+		servers := make(map[string][]string, len(clusters))
+		for i := range clusters {
+			servers[clusters[i].ID] = clusters[i].Servers()
+		}
+		found, err := kubeconfig.Adopt(target, instance.RancherURL, servers)
+		if err != nil {
+			return fmt.Errorf("failed to adopt contexts of %s: %w", instance.RancherURL, err)
+		}
+		adopted = append(adopted, found...)
+	}
+
+	for _, adoption := range adopted {
+		fmt.Printf("%s: cluster %s (matched by %s)\n", adoption.Context, adoption.ClusterID, adoption.MatchedBy)
+	}
+	if len(adopted) == 0 {
+		fmt.Fprintf(os.Stderr, "No contexts of %s belong to a Rancher cluster that is not managed yet\n", path)
+		return nil
+	}
+	if importDryRun {
+		fmt.Fprintf(os.Stderr, "%d context(s) would be adopted (--dry-run)\n", len(adopted))
+		return nil
+	}
+
+	data, err := kubeconfig.NewGenerator("").Serialize(target)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "%d context(s) of %s are now managed\n", len(adopted), path)
+	return nil
+}
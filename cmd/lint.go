@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// lintCmd checks a kubeconfig for broken references and risky settings
+var lintCmd = &cobra.Command{
+	Use:   "lint [file]",
+	Short: "Check a kubeconfig for broken references and insecure settings",
+	Long: `Check a kubeconfig, generated or not, without contacting any server. Errors:
+contexts referencing missing clusters or users, files that cannot be read,
+invalid certificate authorities and expired client certificates. Warnings:
+servers used with different credentials, clusters without a certificate
+authority, client certificates expiring within 30 days, and insecure settings
+such as insecure-skip-tls-verify, plain HTTP servers and basic authentication.
+
+Without a file the managed kubeconfig (--kubeconfig or RANCHER_KUBECONFIG_OUTPUT)
+is checked; "-" reads stdin. The command exits with status 1 when there is an
+error, or with --strict any finding. --format json prints the findings as a
+JSON array for other tools.
+
+Examples:
+  # Check the kubeconfig kubectl uses
+  kubeconfig-wrangler lint ~/.kube/config
+
+  # Fail CI on any finding, and keep a report
+  kubeconfig-wrangler lint kubeconfig.yaml --strict --format json > lint.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLint,
+}
+
+var (
+	lintFormat string
+	lintStrict bool
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	addManagedKubeconfigFlag(lintCmd)
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "Output format: text or json")
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "Exit with status 1 on warnings too")
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if lintFormat != "text" && lintFormat != "json" {
+		return fmt.Errorf("configuration error: invalid --format %q: must be text or json", lintFormat)
+	}
+	path := managedKubeconfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return fmt.Errorf("configuration error: a file, --kubeconfig or RANCHER_KUBECONFIG_OUTPUT is required")
+	}
+
+	config, err := loadLintedKubeconfig(path)
+	if err != nil {
+		return err
+	}
+	findings := kubeconfig.Lint(config, time.Now())
+
+	errorCount := 0
+	for _, finding := range findings {
+		if finding.Severity == kubeconfig.LintError {
+			errorCount++
+		}
+	}
+	if lintFormat == "json" {
+		if findings == nil {
+			findings = []kubeconfig.LintFinding{}
+		}
+		out, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode findings: %w", err)
+		}
+		fmt.Println(string(out))
+	} else if len(findings) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SEVERITY\tRULE\tENTRY\tMESSAGE")
+		for _, finding := range findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", finding.Severity, finding.Rule, finding.Entry, finding.Message)
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %d error(s), %d warning(s)\n", path, errorCount, len(findings)-errorCount)
+	if errorCount > 0 || (lintStrict && len(findings) > 0) {
+		return fmt.Errorf("%s has %d finding(s)", path, len(findings))
+	}
+	return nil
+}
+
+// loadLintedKubeconfig reads the kubeconfig at path, or stdin for "-". Files
+// are loaded so that relative paths resolve against their directory.
+func loadLintedKubeconfig(path string) (*api.Config, error) {
+	if path != "-" {
+		config, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		return config, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stdin: %w", err)
+	}
+	return config, nil
+}
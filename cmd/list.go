@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
 
 	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
 	"github.com/kubeconfig-wrangler/pkg/rancher"
 )
 
@@ -16,7 +26,13 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all clusters from Rancher",
 	Long: `List all downstream Kubernetes clusters managed by the specified
-Rancher instance, showing their name, ID, state, and provider.
+Rancher instance, showing their name, ID, state, provider, Kubernetes
+version, node count and age, plus the notes attached with "annotate" to
+their contexts in the managed kubeconfig (--kubeconfig).
+
+--output selects the format: table (the default), wide (adding the creation
+time, API endpoint and labels), json or yaml (a list of objects whose field
+names are stable, for scripts) or name (one cluster name per line).
 
 Examples:
   # List all clusters using API token
@@ -25,6 +41,12 @@ Examples:
   # List all clusters using username/password
   kubeconfig-wrangler list --url https://rancher.example.com --username admin --password mypassword
 
+  # Production clusters not owned by the infra team
+  kubeconfig-wrangler list --selector env=prod,team!=infra
+
+  # Names of the active clusters, for a script
+  kubeconfig-wrangler list -o json | jq -r '.[] | select(.state == "active") | .name'
+
   # Using environment variables
   export RANCHER_URL=https://rancher.example.com
   export RANCHER_USERNAME=admin
@@ -33,45 +55,69 @@ Examples:
 	RunE: runList,
 }
 
+var (
+	listProjects          bool
+	includeSystemProjects bool
+	listOutput            string
+	listSelector          string
+)
+
+// listFormats are the formats of "list --output"
+var listFormats = []string{"table", "wide", "json", "yaml", "name"}
+
+// listItem is a cluster as printed by "list --output json" or yaml. The field
+// names are an interface scripts rely on: add fields, never rename them.
+type listItem struct {
+	Name               string            `json:"name"`
+	ID                 string            `json:"id"`
+	State              string            `json:"state"`
+	Provider           string            `json:"provider"`
+	Harvester          bool              `json:"harvester"`
+	Version            string            `json:"version"`
+	Nodes              int               `json:"nodes"`
+	Created            string            `json:"created,omitempty"`
+	APIEndpoint        string            `json:"apiEndpoint,omitempty"`
+	AuthorizedEndpoint string            `json:"authorizedEndpoint,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Notes              []string          `json:"notes,omitempty"`
+	Projects           []listProject     `json:"projects,omitempty"`
+}
+
+// listProject is a project of a listItem
+type listProject struct {
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
 func init() {
-	listCmd.Flags().StringVarP(&rancherURL, "url", "u", "", "Rancher server URL (env: RANCHER_URL)")
-	listCmd.Flags().StringVarP(&accessKey, "access-key", "a", "", "Rancher API access key (env: RANCHER_ACCESS_KEY)")
-	listCmd.Flags().StringVarP(&secretKey, "secret-key", "s", "", "Rancher API secret key (env: RANCHER_SECRET_KEY)")
-	listCmd.Flags().StringVarP(&token, "token", "t", "", "Rancher API token (access_key:secret_key) (env: RANCHER_TOKEN)")
-	listCmd.Flags().StringVar(&username, "username", "", "Rancher username for password auth (env: RANCHER_USERNAME)")
-	listCmd.Flags().StringVar(&password, "password", "", "Rancher password for password auth (env: RANCHER_PASSWORD)")
-	listCmd.Flags().BoolVarP(&insecureSkipTLS, "insecure-skip-tls-verify", "k", false, "Skip TLS certificate verification (env: RANCHER_INSECURE_SKIP_TLS_VERIFY)")
-	listCmd.Flags().StringVar(&caCert, "ca-cert", "", "Path to CA certificate file (env: RANCHER_CA_CERT)")
+	addRancherFlags(listCmd)
+	listCmd.Flags().BoolVar(&listProjects, "projects", false, "Also list the projects of each active cluster")
+	addManagedKubeconfigFlag(listCmd)
+	addAgeFlags(listCmd)
+	listCmd.Flags().BoolVar(&includeSystemProjects, "include-system-projects", false, "Include Rancher's System project when listing projects (env: RANCHER_INCLUDE_SYSTEM_PROJECTS)")
+	listCmd.Flags().StringVarP(&listSelector, "selector", "l", "", "Only list the clusters whose labels match this selector, as in kubectl, e.g. env=prod,team!=infra")
+	// Shadows the global --output, which names the generated kubeconfig
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "Output format: "+strings.Join(listFormats, ", "))
 }
 
 func runList(cmd *cobra.Command, args []string) error {
 	// Build configuration from flags and environment
-	cfg := config.LoadFromEnv()
-
-	// Override with command line flags if provided
-	if rancherURL != "" {
-		cfg.RancherURL = rancherURL
-	}
-	if accessKey != "" {
-		cfg.AccessKey = accessKey
-	}
-	if secretKey != "" {
-		cfg.SecretKey = secretKey
-	}
-	if token != "" {
-		cfg.Token = token
-	}
-	if username != "" {
-		cfg.Username = username
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
 	}
-	if password != "" {
-		cfg.Password = password
+
+	if cmd.Flags().Changed("include-system-projects") {
+		cfg.IncludeSystemProjects = includeSystemProjects
 	}
-	if cmd.Flags().Changed("insecure-skip-tls-verify") {
-		cfg.InsecureSkipTLSVerify = insecureSkipTLS
+	applyAgeFlags(cmd, cfg)
+	if !slices.Contains(listFormats, listOutput) {
+		return fmt.Errorf("configuration error: invalid --output %q: must be one of %s", listOutput, strings.Join(listFormats, ", "))
 	}
-	if caCert != "" {
-		cfg.CACert = caCert
+	selector, err := rancher.ParseClusterSelector(listSelector)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
 	}
 
 	// Validate configuration
@@ -79,8 +125,17 @@ func runList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("configuration error: %w", err)
 	}
 
+	plan := rancher.NewPlan("list", cfg)
+	plan.ListClusters()
+	if listProjects {
+		plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/projects?clusterId=<id>", Count: "per active cluster", Purpose: "list the cluster's projects"})
+	}
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
 	// Create Rancher client
-	client, err := rancher.NewClient(cfg)
+	client, err := newRancherClient(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create Rancher client: %w", err)
 	}
@@ -90,20 +145,210 @@ func runList(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to list clusters: %w", err)
 	}
+	warnSchemaDrift(client)
+	clusters = rancher.SelectClusters(filterByAge(cfg, clusters), selector)
+
+	switch listOutput {
+	case "json", "yaml":
+		return printListItems(client, clusters, managedClusterNotes())
+	case "name":
+		for _, cluster := range clusters {
+			fmt.Println(cluster.Name)
+		}
+		return nil
+	}
 
 	if len(clusters) == 0 {
 		fmt.Println("No clusters found")
 		return nil
 	}
 
+	// Notes attached with "annotate" get a column of their own when there are any
+	notes := managedClusterNotes()
+	wide := listOutput == "wide"
+
 	// Print clusters in a table format
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tID\tSTATE\tPROVIDER")
-	fmt.Fprintln(w, "----\t--\t-----\t--------")
+	header := []string{"NAME", "ID", "STATE", "PROVIDER", "VERSION", "NODES", "AGE"}
+	if wide {
+		header = append(header, "CREATED", "API ENDPOINT", "LABELS")
+	}
+	if len(notes) > 0 {
+		header = append(header, "NOTES")
+	}
+	underline := make([]string, len(header))
+	for i, title := range header {
+		underline[i] = strings.Repeat("-", len(title))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	fmt.Fprintln(w, strings.Join(underline, "\t"))
 	for _, cluster := range clusters {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cluster.Name, cluster.ID, cluster.State, cluster.Provider)
+		provider := cluster.Provider
+		if cluster.IsHarvester() {
+			provider = "harvester (HCI)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s", cluster.Name, cluster.ID, cluster.State,
+			orDash(provider), orDash(cluster.Version.GitVersion), cluster.NodeCount, clusterAge(cluster))
+		if wide {
+			fmt.Fprintf(w, "\t%s\t%s\t%s", orDash(clusterCreated(cluster)), orDash(cluster.APIEndpoint), orDash(formatLabels(cluster.Labels)))
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "\t%s", orDash(strings.Join(notes[cluster.ID], "; ")))
+		}
+		fmt.Fprintln(w)
 	}
 	w.Flush()
 
+	if listProjects {
+		return printProjects(client, clusters)
+	}
+
 	return nil
 }
+
+// printListItems prints the clusters as JSON or YAML, with their notes and,
+// with --projects, the projects of the active ones
+func printListItems(client *rancher.Client, clusters []rancher.Cluster, notes map[string][]string) error {
+	items := make([]listItem, 0, len(clusters))
+	for _, cluster := range clusters {
+		item := listItem{
+			Name:        cluster.Name,
+			ID:          cluster.ID,
+			State:       cluster.State,
+			Provider:    cluster.Provider,
+			Harvester:   cluster.IsHarvester(),
+			Version:     cluster.Version.GitVersion,
+			Nodes:       cluster.NodeCount,
+			Created:     clusterCreated(cluster),
+			APIEndpoint: cluster.APIEndpoint,
+			Labels:      cluster.Labels,
+			Notes:       notes[cluster.ID],
+		}
+		if cluster.LocalClusterAuthEndpoint.Enabled && cluster.LocalClusterAuthEndpoint.FQDN != "" {
+			item.AuthorizedEndpoint = "https://" + cluster.LocalClusterAuthEndpoint.FQDN
+		}
+		if listProjects && cluster.State == "active" {
+			projects, err := client.ListProjects(cluster.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to list projects for cluster %s: %v\n", cluster.Name, err)
+			}
+			for _, project := range projects {
+				item.Projects = append(item.Projects, listProject{Name: project.Name, ID: project.ID, State: project.State})
+			}
+		}
+		items = append(items, item)
+	}
+
+	var out []byte
+	var err error
+	if listOutput == "yaml" {
+		out, err = yaml.Marshal(items)
+	} else {
+		out, err = json.MarshalIndent(items, "", "  ")
+		out = append(out, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode clusters: %w", err)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// clusterCreated renders when a cluster was created as RFC 3339, or "" if unknown
+func clusterCreated(cluster rancher.Cluster) string {
+	created, ok := cluster.CreatedAt()
+	if !ok {
+		return ""
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// formatLabels renders labels as key=value pairs in key order, kubectl style
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// managedClusterNotes returns the notes of the managed kubeconfig by Rancher
+// cluster ID. A missing managed kubeconfig simply means there are no notes.
+func managedClusterNotes() map[string][]string {
+	path := managedKubeconfigPath()
+	if path == "" {
+		return nil
+	}
+	managed, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read notes from %s: %v\n", path, err)
+		}
+		return nil
+	}
+	return kubeconfig.ClusterNotes(managed)
+}
+
+// filterByAge drops the clusters outside the --older-than/--newer-than range,
+// keeping those whose creation time is unknown
+func filterByAge(cfg *config.Config, clusters []rancher.Cluster) []rancher.Cluster {
+	if cfg.OlderThan == 0 && cfg.NewerThan == 0 {
+		return clusters
+	}
+	kept := clusters[:0]
+	for _, cluster := range clusters {
+		created, ok := cluster.CreatedAt()
+		if !ok || cfg.AcceptsClusterAge(time.Since(created)) {
+			kept = append(kept, cluster)
+		}
+	}
+	return kept
+}
+
+// clusterAge renders how long ago a cluster was created, kubectl style
+func clusterAge(cluster rancher.Cluster) string {
+	created, ok := cluster.CreatedAt()
+	if !ok {
+		return "-"
+	}
+	age := time.Since(created)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	case age < 2*365*24*time.Hour:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+	return fmt.Sprintf("%dy", int(age.Hours()/24/365))
+}
+
+// orDash returns "-" for an empty value so table columns stay aligned
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// printProjects prints the projects of every active cluster
+func printProjects(client *rancher.Client, clusters []rancher.Cluster) error {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tPROJECT\tID\tSTATE")
+	fmt.Fprintln(w, "-------\t-------\t--\t-----")
+	for _, cluster := range clusters {
+		if cluster.State != "active" {
+			continue
+		}
+		projects, err := client.ListProjects(cluster.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to list projects for cluster %s: %v\n", cluster.Name, err)
+			continue
+		}
+		for _, project := range projects {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cluster.Name, project.Name, project.ID, project.State)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/profile"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+var (
+	loginTTL         time.Duration
+	loginDescription string
+	loginKeyring     bool
+)
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in to Rancher and store an API token",
+	Long: `Authenticate against Rancher with a username and password, create an
+API token and store it (encrypted) in the active profile, "default" unless
+selected with --profile, RANCHER_PROFILE or RKP_PROFILE when the command runs.
+
+Subsequent commands use the stored URL and token whenever no Rancher URL
+is given via flags or environment variables. With --keyring the token itself
+is kept in the OS keychain (macOS Keychain, Windows Credential Manager or the
+Secret Service, e.g. GNOME Keyring, on Linux) and read from there by every
+command, instead of in the profiles file. The stored profile is also
+visible in the web GUI.
+
+The username and password are prompted for when not given. Local users,
+Active Directory, OpenLDAP and FreeIPA are supported via --auth-provider.
+
+Examples:
+  # Log in as a local user
+  kubeconfig-wrangler login --url https://rancher.example.com
+
+  # Log in with Active Directory, creating a token valid for 30 days
+  kubeconfig-wrangler login --url https://rancher.example.com --auth-provider activedirectory --ttl 720h`,
+	RunE: runLogin,
+}
+
+func init() {
+	addRancherFlags(loginCmd)
+	loginCmd.Flags().DurationVar(&loginTTL, "ttl", 0, "Lifetime of the created API token (default: never expires, subject to the server maximum)")
+	loginCmd.Flags().StringVar(&loginDescription, "description", "", "Description of the created API token")
+	loginCmd.Flags().BoolVar(&loginKeyring, "keyring", os.Getenv("RANCHER_KEYRING") == "true", "Store the token in the OS keychain instead of the profiles file (env: RANCHER_KEYRING)")
+	rootCmd.AddCommand(loginCmd)
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	base, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	cfg := &config.Config{
+		RancherURL:            base.RancherURL,
+		Username:              base.Username,
+		Password:              base.Password,
+		AuthProvider:          base.AuthProvider,
+		AuthMethod:            config.AuthMethodPassword,
+		InsecureSkipTLSVerify: base.InsecureSkipTLSVerify,
+		CACert:                base.CACert,
+		CACertData:            base.CACertData,
+		ExcludeSystemCAs:      base.ExcludeSystemCAs,
+		MaxIdleConnsPerHost:   base.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       base.MaxConnsPerHost,
+		IdleConnTimeout:       base.IdleConnTimeout,
+		DisableHTTP2:          base.DisableHTTP2,
+		DisableKeepAlives:     base.DisableKeepAlives,
+	}
+
+	if cfg.RancherURL == "" {
+		return fmt.Errorf("configuration error: rancher URL is required")
+	}
+	if _, err := rancher.LoginProviderPath(cfg.AuthProvider); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	plan := rancher.NewPlan("login", cfg)
+	plan.Add(rancher.PlannedCall{
+		Method:  "POST",
+		Path:    "/v3/tokens",
+		Count:   "once",
+		Purpose: "create the API token saved in the profile",
+		Creates: "an API token",
+	})
+	plan.Add(rancher.PlannedCall{Method: "POST", Path: "/v3/tokens?action=logout", Count: "once", Purpose: "end the login session, deleting its session token"})
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	if err := promptCredentials(cfg); err != nil {
+		return err
+	}
+	apiToken, err := createLoginToken(cfg, loginDescription, loginTTL)
+	if err != nil {
+		return err
+	}
+
+	name := activeProfileName()
+	store, err := storeLoginToken(cfg, name, apiToken, loginKeyring)
+	if err != nil {
+		return err
+	}
+
+	tokenName, _, _ := strings.Cut(apiToken, ":")
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.RancherURL, cfg.Username)
+	if p := store.FindByName(name); p != nil && p.TokenInKeyring {
+		fmt.Fprintf(os.Stderr, "API token %s stored in the OS keychain for profile %q (%s)\n", tokenName, name, store.Path())
+	} else {
+		fmt.Fprintf(os.Stderr, "API token %s stored in profile %q (%s)\n", tokenName, name, store.Path())
+	}
+	return nil
+}
+
+// createLoginToken logs in with the username and password of cfg, creates an
+// API token and ends the login session again
+func createLoginToken(cfg *config.Config, description string, ttl time.Duration) (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", fmt.Errorf("configuration error: %w", err)
+	}
+
+	// NewClient performs the password login
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if description == "" {
+		hostname, _ := os.Hostname()
+		description = "kubeconfig-wrangler on " + hostname
+	}
+
+	apiToken, err := client.CreateAPIToken(description, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	if err := client.Logout(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to end the login session: %v\n", err)
+	}
+	return apiToken, nil
+}
+
+// storeLoginToken saves the Rancher URL and an API token in the named profile
+// of the profile store, keeping the token in the OS keychain if inKeyring is set
+func storeLoginToken(cfg *config.Config, name, apiToken string, inKeyring bool) (*profile.Store, error) {
+	store, err := profile.NewStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile store: %w", err)
+	}
+
+	req := &profile.ProfileCreateRequest{
+		Name:       name,
+		Type:       profile.ProfileTypeRancher,
+		RancherURL: cfg.RancherURL,
+		Token:      apiToken,
+		SkipTLS:    cfg.InsecureSkipTLSVerify,
+		CACert:     cfg.CACert,
+		CACertData: cfg.CACertData,
+
+		ExcludeSystemCAs: cfg.ExcludeSystemCAs,
+		TokenInKeyring:   inKeyring,
+	}
+	if existing := store.FindByName(name); existing != nil {
+		req.ClusterAliases = existing.ClusterAliases
+		if _, err := store.Update(existing.ID, req); err != nil {
+			return nil, fmt.Errorf("failed to store token: %w", err)
+		}
+	} else if _, err := store.Create(req); err != nil {
+		return nil, fmt.Errorf("failed to store token: %w", err)
+	}
+	return store, nil
+}
+
+// promptCredentials asks for the username and password on the terminal when they were not provided
+func promptCredentials(cfg *config.Config) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	if cfg.Username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+		cfg.Username = strings.TrimSpace(line)
+	}
+
+	if cfg.Password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		fd := int(os.Stdin.Fd())
+		if term.IsTerminal(fd) {
+			secret, err := term.ReadPassword(fd)
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return fmt.Errorf("failed to read password: %w", err)
+			}
+			cfg.Password = string(secret)
+		} else {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("failed to read password: %w", err)
+			}
+			cfg.Password = strings.TrimRight(line, "\r\n")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// normalizeCmd imports kubeconfigs downloaded from the Rancher UI
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize <file>...",
+	Short: "Import kubeconfigs downloaded from the Rancher UI",
+	Long: `Import kubeconfig files previously downloaded from the Rancher UI into
+the managed kubeconfig, so existing tokens keep working while you migrate to
+generated kubeconfigs.
+
+Each file is cleaned up (contexts pointing at missing clusters or users and
+unused entries are removed), renamed with the cluster prefix exactly as
+"generate" names its entries, and tagged with a "kubeconfig-wrangler"
+provenance extension recording the source file and Rancher cluster. The
+result is merged into --output, replacing entries of the same name, or
+printed to stdout.
+
+Examples:
+  # Merge two downloaded kubeconfigs into the managed file
+  kubeconfig-wrangler normalize ~/Downloads/prod.yaml ~/Downloads/stage.yaml --output ~/.kube/rancher-config
+
+  # Preview the normalized result with a prefix
+  kubeconfig-wrangler normalize ~/Downloads/prod.yaml --prefix rancher-`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runNormalize,
+}
+
+var (
+	normalizePrefix string
+	normalizeSuffix string
+	normalizeSep    string
+	normalizeOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+	normalizeCmd.Flags().StringVarP(&normalizePrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
+	addNamingFlags(normalizeCmd, &normalizeSuffix, &normalizeSep)
+	normalizeCmd.Flags().StringVarP(&normalizeOutput, "output", "o", "", "Managed kubeconfig to merge into (default: stdout) (env: RANCHER_KUBECONFIG_OUTPUT)")
+}
+
+func runNormalize(cmd *cobra.Command, args []string) error {
+	cfg := loadConfig()
+	if cmd.Flags().Changed("prefix") {
+		cfg.ClusterPrefix = normalizePrefix
+	}
+	applyNamingFlags(cmd, cfg, normalizeSuffix, normalizeSep)
+	if normalizeOutput != "" {
+		cfg.OutputPath = normalizeOutput
+	}
+
+	merged := api.NewConfig()
+	if cfg.OutputPath != "" {
+		existing, err := clientcmd.LoadFromFile(cfg.OutputPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", cfg.OutputPath, err)
+		}
+		if err == nil {
+			merged = existing
+		}
+	}
+
+	generator, err := newGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	for _, file := range args {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		source := file
+		if abs, err := filepath.Abs(file); err == nil {
+			source = abs
+		}
+
+		normalized, fixes, err := generator.Normalize(data, source)
+		for _, fix := range fixes {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, fix)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to normalize %s: %w", file, err)
+		}
+
+		for _, entry := range kubeconfig.MergeInto(merged, normalized) {
+			fmt.Fprintf(os.Stderr, "Warning: %s: replaced existing %s\n", file, entry)
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d context(s) from %s\n", len(normalized.Contexts), file)
+	}
+
+	data, err := generator.Serialize(merged)
+	if err != nil {
+		return err
+	}
+	if cfg.OutputPath == "" {
+		fmt.Print(string(data))
+		return nil
+	}
+	if err := os.WriteFile(cfg.OutputPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", cfg.OutputPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "Kubeconfig written to %s\n", cfg.OutputPath)
+	return nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// pingCmd represents the ping command
+var pingCmd = &cobra.Command{
+	Use:     "ping",
+	Aliases: []string{"doctor"},
+	Short:   "Check Rancher connectivity and credentials",
+	Long: `Check that the Rancher server is reachable and that the configured
+credentials are accepted, without generating anything.
+
+Reports reachability and latency, broken down into DNS resolution, TCP
+connect, TLS handshake and time to first byte, the server's TLS certificate,
+the authenticated user, the scope and expiry of the API token, and the
+Rancher server version.
+
+Examples:
+  # Check connectivity using API token
+  kubeconfig-wrangler ping --url https://rancher.example.com --token token-xxxxx:yyyyyyy
+
+  # Using environment variables
+  export RANCHER_URL=https://rancher.example.com
+  export RANCHER_TOKEN=token-xxxxx:yyyyyyy
+  kubeconfig-wrangler ping`,
+	RunE: runPing,
+}
+
+func init() {
+	addRancherFlags(pingCmd)
+	rootCmd.AddCommand(pingCmd)
+}
+
+func runPing(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	plan := rancher.NewPlan("ping", cfg)
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3", Count: "once", Purpose: "check reachability, TLS and credentials"})
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/users?me=true", Count: "once", Purpose: "identify the authenticated user"})
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/tokens/<name>", Count: "once, with token auth", Purpose: "show the token's scope and expiry"})
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/settings/server-version", Count: "once", Purpose: "read the Rancher version"})
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+
+	result, err := client.Ping()
+	fmt.Printf("Server:         %s\n", cfg.RancherURL)
+	if err != nil {
+		fmt.Printf("Reachable:      no\n")
+		return fmt.Errorf("rancher is not reachable: %w", err)
+	}
+	fmt.Printf("Reachable:      yes (%s)\n", result.Latency.Round(time.Millisecond))
+	if result.Timings != nil {
+		printTimings(result.Timings)
+	}
+
+	if result.TLS != nil {
+		verification := "verified"
+		if !result.TLS.Verified {
+			verification = "NOT verified (insecure-skip-tls-verify)"
+		}
+		fmt.Printf("TLS:            %s, %s\n", result.TLS.Version, verification)
+		fmt.Printf("  Subject:      %s\n", result.TLS.Subject)
+		fmt.Printf("  Issuer:       %s\n", result.TLS.Issuer)
+		fmt.Printf("  Expires:      %s\n", result.TLS.NotAfter.Format(time.RFC3339))
+	} else {
+		fmt.Printf("TLS:            none (plain HTTP)\n")
+	}
+
+	if result.ServerVersion != "" {
+		fmt.Printf("Version:        %s\n", result.ServerVersion)
+	}
+
+	if result.User != nil {
+		fmt.Printf("User:           %s (%s)\n", result.User.Username, result.User.ID)
+	}
+
+	if result.Token != nil {
+		expiry := "never"
+		if result.Token.ExpiresAt != "" {
+			expiry = result.Token.ExpiresAt
+		}
+		fmt.Printf("Token:          %s\n", result.Token.Name)
+		fmt.Printf("  Scope:        %s\n", result.Token.Scope())
+		fmt.Printf("  Expires:      %s\n", expiry)
+		if result.Token.Expired {
+			fmt.Printf("  Status:       EXPIRED\n")
+		}
+	}
+
+	for _, problem := range result.Errors {
+		fmt.Printf("Warning:        %s\n", problem)
+	}
+
+	if !result.Authenticated {
+		return fmt.Errorf("authentication failed")
+	}
+
+	return nil
+}
+
+// printTimings shows where the time of the reachability check went
+func printTimings(timings *rancher.Timings) {
+	phase := func(d time.Duration) string {
+		if d == 0 {
+			return "-"
+		}
+		return d.Round(100 * time.Microsecond).String()
+	}
+
+	dns := phase(timings.DNS)
+	if len(timings.Addrs) > 0 {
+		dns += " (" + strings.Join(timings.Addrs, ", ") + ")"
+	}
+	fmt.Printf("  DNS:          %s\n", dns)
+	fmt.Printf("  TCP connect:  %s\n", phase(timings.Connect))
+	fmt.Printf("  TLS:          %s\n", phase(timings.TLS))
+	fmt.Printf("  First byte:   %s\n", phase(timings.FirstByte))
+	if timings.Reused {
+		fmt.Printf("  Connection:   reused, no DNS, connect or TLS timings\n")
+	}
+}
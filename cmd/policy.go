@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/policy"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+var (
+	policyExpr    string
+	policyFile    string
+	approvedNames []string
+)
+
+// addPolicyFlags registers the flags of the CEL policy deciding which clusters
+// are generated
+func addPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&policyExpr, "policy", "", "CEL expression deciding per cluster: true/false or \"include\", \"exclude\", \"require-approval\"")
+	cmd.Flags().StringVar(&policyFile, "policy-file", "", "File containing the CEL policy expression")
+	cmd.Flags().StringSliceVar(&approvedNames, "approve", nil, "Clusters (name or ID) approved for inclusion when the policy requires approval")
+}
+
+// loadPolicy compiles the policy given by --policy or --policy-file, or returns nil when neither is set
+func loadPolicy() (*policy.Policy, error) {
+	switch {
+	case policyExpr != "" && policyFile != "":
+		return nil, fmt.Errorf("--policy and --policy-file are mutually exclusive")
+	case policyExpr != "":
+		return policy.Compile(policyExpr)
+	case policyFile != "":
+		return policy.Load(policyFile)
+	}
+	return nil, nil
+}
+
+// policyFilter turns a policy into a cluster filter for client. Clusters that
+// require approval are only included when named in approved; evaluation errors
+// exclude the cluster rather than failing the whole run.
+func policyFilter(client *rancher.Client, pol *policy.Policy, approved []string) rancher.ClusterFilter {
+	identity := policy.Identity{}
+	if user, err := client.GetCurrentUser(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not determine the requesting identity for the policy: %v\n", err)
+	} else {
+		identity.ID = user.ID
+		identity.Username = user.Username
+	}
+
+	isApproved := make(map[string]bool, len(approved))
+	for _, name := range approved {
+		isApproved[name] = true
+	}
+
+	return func(cluster rancher.Cluster) bool {
+		decision, err := pol.Evaluate(policy.Cluster{
+			ID:          cluster.ID,
+			Name:        cluster.Name,
+			State:       cluster.State,
+			Provider:    cluster.Provider,
+			Labels:      cluster.Labels,
+			Annotations: cluster.Annotations,
+		}, identity)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; excluding it\n", err)
+			return false
+		}
+
+		switch decision {
+		case policy.Include:
+			return true
+		case policy.RequireApproval:
+			if isApproved[cluster.Name] || isApproved[cluster.ID] {
+				return true
+			}
+			fmt.Fprintf(os.Stderr, "Cluster %s requires approval (use --approve %s)\n", cluster.Name, cluster.Name)
+			return false
+		default:
+			return false
+		}
+	}
+}
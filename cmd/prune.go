@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// pruneCmd removes the contexts of deleted clusters from a kubeconfig
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the contexts of clusters deleted from Rancher",
+	Long: `Remove from a kubeconfig the contexts generated by this tool whose Rancher
+cluster no longer exists, along with the clusters and users only they use.
+Every other entry of the file is left as it is, so it works on a kubeconfig
+updated with "generate --merge-into", such as ~/.kube/config.
+
+The clusters are listed from the configured Rancher servers (RANCHER_URL, or
+every instance of RANCHER_INSTANCES). Contexts generated from a server that is
+not configured are kept, since nothing is known about its clusters.
+
+Examples:
+  # Show what would be removed
+  kubeconfig-wrangler prune --kubeconfig ~/.kube/config --dry-run
+
+  # Remove the contexts of deleted clusters
+  kubeconfig-wrangler prune --kubeconfig ~/.kube/config`,
+	RunE: runPrune,
+}
+
+var pruneDryRun bool
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	addRancherFlags(pruneCmd)
+	addManagedKubeconfigFlag(pruneCmd)
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Print the contexts that would be removed without changing the file")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	path := managedKubeconfigPath()
+	if path == "" {
+		return fmt.Errorf("configuration error: --kubeconfig or RANCHER_KUBECONFIG_OUTPUT is required")
+	}
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	instances, err := loadInstances(cfg)
+	if err != nil {
+		return err
+	}
+
+	if explain {
+		for i, instance := range instances {
+			if i > 0 {
+				fmt.Println()
+			}
+			plan := rancher.NewPlan("prune", instance)
+			plan.ListClusters()
+			plan.Note("the contexts of clusters it no longer lists are removed from %s", path)
+			if _, err := printPlan(plan); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	target, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	existing := make(map[string][]string)
+	for _, server := range kubeconfig.RancherServers(target) {
+		instance := instanceForServer(instances, server)
+		if instance == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s is not configured, keeping its contexts\n", server)
+			continue
+		}
+		client, err := newRancherClient(instance)
+		if err != nil {
+			return fmt.Errorf("failed to create Rancher client: %w", err)
+		}
+		clusters, err := client.ListClusters()
+		if err != nil {
+			return fmt.Errorf("failed to list the clusters of %s: %w", server, err)
+		}
+		ids := make([]string, len(clusters))
+		for i, cluster := range clusters {
+			ids[i] = cluster.ID
+		}
+		existing[server] = ids
+	}
+
+	removed := kubeconfig.Prune(target, existing)
+	for _, name := range removed {
+		fmt.Printf("- %s\n", name)
+	}
+	switch {
+	case len(removed) == 0:
+		fmt.Fprintf(os.Stderr, "Nothing to prune in %s\n", path)
+		return nil
+	case pruneDryRun:
+		fmt.Fprintf(os.Stderr, "Would remove %d context(s) from %s (dry run)\n", len(removed), path)
+		return nil
+	}
+
+	data, err := kubeconfig.NewGenerator("").Serialize(target)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d context(s) from %s\n", len(removed), path)
+	return nil
+}
+
+// instanceForServer returns the configured instance of the Rancher server a
+// kubeconfig was generated from, or nil
+func instanceForServer(instances []*config.Config, server string) *config.Config {
+	for _, instance := range instances {
+		if strings.EqualFold(strings.TrimSuffix(instance.RancherURL, "/"), strings.TrimSuffix(server, "/")) {
+			return instance
+		}
+	}
+	return nil
+}
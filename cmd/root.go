@@ -2,15 +2,30 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
 )
 
 var (
 	// Version is set during build
 	Version = "dev"
+
+	noExec bool
+
+	configPath  string
+	profileName string
+	envFile     string
+	// configFile is the configuration file read before every command, nil if there is none
+	configFile *config.File
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -26,28 +41,193 @@ kubectl, helm, k9s, and other applications that support kubeconfig files.
 
 Cluster names can be prefixed with a configurable string to help identify
 which source they belong to.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if noExec {
+			noexec.Enable()
+		}
+		if err := readEnvFile(); err != nil {
+			return err
+		}
+		if cmd.Annotations[skipConfigFileAnnotation] != "" {
+			return nil
+		}
+		return readConfigFile()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var statusErr *exitStatusError
+		if errors.As(err, &statusErr) {
+			os.Exit(statusErr.status)
+		}
 		fmt.Fprintln(os.Stderr, err)
+		var apiErr *rancher.APIError
+		if errors.As(err, &apiErr) && apiErr.Hint() != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", apiErr.Hint())
+		}
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rancher.UserAgent = "kubeconfig-wrangler/" + Version
+	kubeconfig.ToolVersion = Version
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Configuration file (default: config.yaml in the user configuration directory) (env: RANCHER_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&envFile, "env-file", "", "Dotenv file setting environment variables not set in the shell (default: .env in the working directory, if any) (env: RANCHER_ENV_FILE)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Profile of the configuration file and of \"login\" to use (default \"default\") (env: RANCHER_PROFILE or RKP_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&caCert, "ca-cert", "", "Path to a CA certificate file or a directory of PEM files, trusted in addition to the system CAs (env: RANCHER_CA_CERT)")
+	rootCmd.PersistentFlags().BoolVarP(&insecureSkipTLS, "insecure-skip-tls-verify", "k", false, "Skip TLS certificate verification (env: RANCHER_INSECURE_SKIP_TLS_VERIFY)")
+	rootCmd.PersistentFlags().StringVarP(&clusterPrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
+	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Output file path (default: stdout) (env: RANCHER_KUBECONFIG_OUTPUT)")
+	rootCmd.PersistentFlags().BoolVar(&noExec, "no-exec", false, "Refuse every feature that would start another process, for hardened environments (env: RANCHER_NO_EXEC)")
+
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(eksCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// exitStatusError ends a command with an exit status telling a result apart,
+// like "sync --exit-code" reporting a rewritten kubeconfig. It is not printed.
+type exitStatusError struct {
+	status int
+}
+
+func (e *exitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.status)
+}
+
+// skipConfigFileAnnotation marks the commands that must run without reading
+// the configuration file
+const skipConfigFileAnnotation = "skip-config-file"
+
+// configFilePath returns the configuration file named by --config or
+// RANCHER_CONFIG, or else the default one, reporting whether it was named
+func configFilePath() (string, bool, error) {
+	if configPath != "" {
+		return configPath, true, nil
+	}
+	if path := os.Getenv("RANCHER_CONFIG"); path != "" {
+		return path, true, nil
+	}
+	path, err := config.DefaultFilePath()
+	return path, false, err
+}
+
+// readEnvFile loads the dotenv file named by --env-file or RANCHER_ENV_FILE,
+// or else the cluster selection and naming variables of .env in the working
+// directory if it exists. That .env may be another tool's, so it only warns
+// when it cannot be read.
+func readEnvFile() error {
+	path := envFile
+	if path == "" {
+		path = os.Getenv("RANCHER_ENV_FILE")
+	}
+	if path != "" {
+		if err := config.LoadEnvFile(path); err != nil {
+			return fmt.Errorf("configuration error: %w", err)
+		}
+		return nil
+	}
+
+	ignored, err := config.LoadWorkingDirEnvFile(config.EnvFileName)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", config.EnvFileName, err)
+	case len(ignored) > 0:
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s from %s, name it with --env-file to use them\n", strings.Join(ignored, ", "), config.EnvFileName)
+	}
+	return nil
+}
+
+// readConfigFile reads the file named by --config or RANCHER_CONFIG, or else
+// the default configuration file if it exists
+func readConfigFile() error {
+	path, required, err := configFilePath()
+	if err != nil {
+		return nil
+	}
+	file, err := config.LoadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	if profileName != "" || profileFromEnv() != "" {
+		if err := file.CheckProfile(activeProfileName()); err != nil {
+			return fmt.Errorf("configuration error: %w", err)
+		}
+	}
+	if err := file.DecryptSecrets(activeProfileName(), configDecrypter().Decrypt); err != nil {
+		return fmt.Errorf("configuration error: failed to decrypt %s: %w", path, err)
+	}
+	configFile = file
+	return nil
+}
+
+// loadConfig loads the configuration from the environment, taking the
+// settings whose variables are unset from the configuration file, and
+// overrides it with the global flags set on the command line
+func loadConfig() *config.Config {
+	cfg := config.LoadFromEnv()
+	if configFile != nil {
+		configFile.Apply(cfg, activeProfileName())
+	}
+
+	// A command defining a flag of the same name shadows the global one,
+	// which then stays unchanged
+	flags := rootCmd.PersistentFlags()
+	if flags.Changed("ca-cert") {
+		cfg.CACert = caCert
+	}
+	if flags.Changed("insecure-skip-tls-verify") {
+		cfg.InsecureSkipTLSVerify = insecureSkipTLS
+	}
+	if flags.Changed("prefix") {
+		cfg.ClusterPrefix = clusterPrefix
+	}
+	if flags.Changed("output") {
+		cfg.OutputPath = outputPath
+	}
+	return cfg
+}
+
+// activeProfileName returns the name of the configuration profile in use,
+// selected with --profile, RANCHER_PROFILE or RKP_PROFILE and exposed to
+// output templates as .Profile. It is read when a command runs, after the
+// flags are parsed and the .env file is loaded.
+func activeProfileName() string {
+	if profileName != "" {
+		return profileName
+	}
+	if name := profileFromEnv(); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// profileFromEnv returns the profile selected by RANCHER_PROFILE, or by its
+// alias RKP_PROFILE
+func profileFromEnv() string {
+	if name := os.Getenv("RANCHER_PROFILE"); name != "" {
+		return name
+	}
+	return os.Getenv("RKP_PROFILE")
+}
+
 // versionCmd prints the version
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number",
 	Run: func(cmd *cobra.Command, args []string) {
+		if noexec.BuiltIn() {
+			fmt.Printf("kubeconfig-wrangler %s (no-exec build)\n", Version)
+			return
+		}
 		fmt.Printf("kubeconfig-wrangler %s\n", Version)
 	},
 }
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/share"
+)
+
+// shareCmd generates a kubeconfig and serves it once over HTTPS
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Generate a kubeconfig and serve it once over a one-time HTTPS link",
+	Long: `Generate a kubeconfig like "generate" does and serve it once over HTTPS
+on a random port, behind a one-time URL that is printed together with a QR code.
+The link stops working after the first download or when --ttl passes, and the
+command exits.
+
+The certificate is self-signed and generated for this share only. The printed
+curl command pins its public key, so the download is still protected against
+interception.
+
+Examples:
+  # Share a kubeconfig for ten minutes
+  kubeconfig-wrangler share --ttl 10m
+
+  # Advertise a specific address, e.g. the one reachable from a VM
+  kubeconfig-wrangler share --host 192.168.56.1 --prefix lab-`,
+	RunE: runShare,
+}
+
+var (
+	shareTTL    time.Duration
+	shareAddr   string
+	shareHost   string
+	sharePrefix string
+	shareNoQR   bool
+)
+
+func init() {
+	rootCmd.AddCommand(shareCmd)
+	addRancherFlags(shareCmd)
+	shareCmd.Flags().DurationVar(&shareTTL, "ttl", share.DefaultTTL, "How long the link stays valid")
+	shareCmd.Flags().StringVar(&shareAddr, "addr", "0.0.0.0", "Address to listen on (a random port is used unless one is given)")
+	shareCmd.Flags().StringVar(&shareHost, "host", "", "Host name or IP address to put in the link (default: first non-loopback IPv4 address)")
+	shareCmd.Flags().StringVarP(&sharePrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
+	shareCmd.Flags().BoolVar(&shareNoQR, "no-qr", false, "Do not print a QR code of the link")
+}
+
+func runShare(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("prefix") {
+		cfg.ClusterPrefix = sharePrefix
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	if explain {
+		return explainGenerate("share", []*config.Config{cfg}, kubeconfig.EndpointModeAll, nil)
+	}
+
+	merged, _, err := generateAll([]*config.Config{cfg}, kubeconfig.EndpointModeAll, nil)
+	if err != nil {
+		return err
+	}
+	data, err := kubeconfig.NewGenerator(cfg.ClusterPrefix).Serialize(merged)
+	if err != nil {
+		return fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+
+	s, err := share.Start(data, share.Options{
+		Addr:     shareAddr,
+		Host:     shareHost,
+		TTL:      shareTTL,
+		FileName: "kubeconfig",
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fmt.Fprintf(os.Stderr, "\nOne-time link, valid until %s:\n\n  %s\n\n", s.ExpiresAt.Format(time.Kitchen), s.URL)
+	fmt.Fprintf(os.Stderr, "Download it with the certificate pinned:\n\n  %s\n\n", s.CurlCommand("kubeconfig"))
+	fmt.Fprintf(os.Stderr, "Certificate SHA-256 fingerprint: %s\n\n", s.Fingerprint)
+	if !shareNoQR {
+		if qr, err := qrcode.New(s.URL, qrcode.Medium); err == nil {
+			fmt.Fprintln(os.Stderr, qr.ToSmallString(false))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := s.Wait(ctx)
+	switch {
+	case errors.Is(err, share.ErrExpired):
+		return fmt.Errorf("the link expired after %s without being used", shareTTL)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Share cancelled")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Kubeconfig downloaded by %s; the link is no longer valid\n", client)
+	return nil
+}
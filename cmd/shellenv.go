@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// shellEnvCmd prints the KUBECONFIG of the files written by generate --split-dir
+var shellEnvCmd = &cobra.Command{
+	Use:   "shell-env [dir]",
+	Short: "Print a shell command setting KUBECONFIG to the per-cluster kubeconfigs",
+	Long: `Print a command that sets KUBECONFIG to every kubeconfig in a directory
+written by "generate --split-dir", so kubectl sees all the clusters while each
+keeps its own file. The directory defaults to RANCHER_KUBECONFIG_SPLIT_DIR.
+Paths are absolute, so the line works from any directory.
+
+Examples:
+  # In ~/.bashrc or ~/.zshrc
+  eval "$(kubeconfig-wrangler shell-env ~/.kube/rancher)"
+
+  # In ~/.config/fish/config.fish
+  kubeconfig-wrangler shell-env ~/.kube/rancher --shell fish | source
+
+  # In a PowerShell profile
+  kubeconfig-wrangler shell-env ~/.kube/rancher --shell powershell | Invoke-Expression`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShellEnv,
+}
+
+var shellEnvShell string
+
+func init() {
+	rootCmd.AddCommand(shellEnvCmd)
+	shellEnvCmd.Flags().StringVar(&shellEnvShell, "shell", "sh", "Syntax of the printed command: sh (also bash and zsh), fish or powershell")
+}
+
+func runShellEnv(cmd *cobra.Command, args []string) error {
+	dir := loadConfig().SplitDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if dir == "" {
+		return fmt.Errorf("configuration error: a directory or RANCHER_KUBECONFIG_SPLIT_DIR is required")
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no kubeconfigs found in %s", dir)
+	}
+	line, err := kubeconfigExport(shellEnvShell, paths)
+	if err != nil {
+		return err
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// kubeconfigExport returns the command setting KUBECONFIG to paths in shell's
+// syntax. The paths are made absolute and sorted.
+func kubeconfigExport(shell string, paths []string) (string, error) {
+	absolute := make([]string, 0, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		absolute = append(absolute, abs)
+	}
+	slices.Sort(absolute)
+	value := strings.Join(absolute, string(os.PathListSeparator))
+
+	switch shell {
+	case "sh", "bash", "zsh":
+		return "export KUBECONFIG=" + quoteSingle(value, `'\''`), nil
+	case "fish":
+		return "set -gx KUBECONFIG " + quoteSingle(strings.ReplaceAll(value, `\`, `\\`), `\'`), nil
+	case "powershell", "pwsh":
+		return "$env:KUBECONFIG = " + quoteSingle(value, "''"), nil
+	default:
+		return "", fmt.Errorf("configuration error: invalid shell %q: must be sh, bash, zsh, fish or powershell", shell)
+	}
+}
+
+// quoteSingle single-quotes value, writing every quote in it as escaped
+func quoteSingle(value, escaped string) string {
+	return "'" + strings.ReplaceAll(value, "'", escaped) + "'"
+}
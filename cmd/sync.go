@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// syncChangedStatus is the exit status of "sync --exit-code" when the
+// kubeconfig was rewritten, telling it apart from failures (status 1)
+const syncChangedStatus = 2
+
+// syncCmd rewrites the kubeconfig only when what generate produces changed
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Regenerate the kubeconfig and rewrite it only when it changed",
+	Long: `Generate the kubeconfig like "generate --output" does and compare a SHA-256
+hash of it with the file already written. The file is only rewritten, and the
+command only prints something, when the content changed: the added (+),
+removed (-) and changed (~) contexts, followed by a summary on stderr.
+
+Rancher usually issues new tokens on every run; with --ignore-credentials a
+kubeconfig whose credentials alone changed is left as it is. Give the cluster
+filters and the policy the file was generated with, as for generate.
+
+With --exit-code the command exits with status 2 when it rewrote the file, 0
+when the file was up to date and 1 on errors, so cron jobs and CI pipelines
+can act on drift.
+
+Examples:
+  # Keep a kubeconfig up to date from cron, quietly
+  kubeconfig-wrangler sync --output ~/.kube/rancher.yaml --ignore-credentials
+
+  # Fail a CI job when the committed kubeconfig is out of date
+  kubeconfig-wrangler sync --output kubeconfig.yaml --ignore-credentials --exit-code`,
+	RunE: runSync,
+}
+
+var (
+	syncSuffix            string
+	syncSeparator         string
+	syncEndpointMode      string
+	syncIgnoreCredentials bool
+	syncExitCode          bool
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	addRancherFlags(syncCmd)
+	addNamingFlags(syncCmd, &syncSuffix, &syncSeparator)
+	addClusterFilterFlags(syncCmd)
+	addPolicyFlags(syncCmd)
+	syncCmd.Flags().StringVar(&syncEndpointMode, "endpoint-mode", "all", "Contexts to keep for clusters with an authorized cluster endpoint, as for generate")
+	syncCmd.Flags().BoolVar(&syncIgnoreCredentials, "ignore-credentials", false, "Leave the kubeconfig as it is when only its credentials changed")
+	syncCmd.Flags().BoolVar(&syncExitCode, "exit-code", false, "Exit with status 2 when the kubeconfig was rewritten")
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cfg.OutputPath == "" {
+		return fmt.Errorf("configuration error: --output or RANCHER_KUBECONFIG_OUTPUT is required")
+	}
+	if len(cfg.EncryptRecipients) > 0 {
+		return fmt.Errorf("configuration error: sync cannot compare encrypted kubeconfigs, whose content differs on every run")
+	}
+	applyNamingFlags(cmd, cfg, syncSuffix, syncSeparator)
+	applyClusterFilterFlags(cmd, cfg)
+	mode, err := kubeconfig.ParseEndpointMode(syncEndpointMode)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	pol, err := loadPolicy()
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	instances, err := loadInstances(cfg)
+	if err != nil {
+		return err
+	}
+
+	if explain {
+		return explainGenerate("sync", instances, mode, pol)
+	}
+
+	path := cfg.OutputPath
+	currentData, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	current := api.NewConfig()
+	if len(currentData) > 0 {
+		if current, err = clientcmd.Load(currentData); err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+
+	progress = io.Discard
+	generated, _, err := generateAll(instances, mode, pol)
+	if err != nil {
+		return err
+	}
+
+	// Keep what generate keeps from the file it replaces, so that an
+	// unchanged fleet serializes to the same bytes
+	if _, err := kubeconfig.CarryOverNotes(generated, current); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to keep notes from %s: %v\n", path, err)
+	}
+	if _, err := kubeconfig.CarryOverGeneratedAt(generated, current); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to keep generation times from %s: %v\n", path, err)
+	}
+	kubeconfig.CarryOverCurrentContext(generated, current)
+	if cfg.CurrentContext != "" {
+		if _, err := kubeconfig.SetCurrentContext(generated, cfg.CurrentContext); err != nil {
+			return fmt.Errorf("failed to set the current context: %w", err)
+		}
+	}
+
+	generator := kubeconfig.NewGenerator(cfg.ClusterPrefix)
+	generator.SetFlatten(cfg.Flatten)
+	generator.SetMinify(cfg.Minify)
+	data, err := generator.Serialize(generated)
+	if err != nil {
+		return fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if sum == sha256.Sum256(currentData) {
+		return nil
+	}
+	diff := kubeconfig.DiffManaged(current, generated)
+	if syncIgnoreCredentials && len(currentData) > 0 && diff.Empty(true) {
+		return nil
+	}
+
+	if _, err := writeIfChanged(path, data); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+	}
+	printDiff(diff)
+	fmt.Fprintf(os.Stderr, "Synced %s (sha256 %s): %d added, %d removed, %d changed\n",
+		path, hex.EncodeToString(sum[:])[:12], len(diff.Added), len(diff.Removed), len(diff.Changed))
+
+	if syncExitCode {
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		return &exitStatusError{status: syncChangedStatus}
+	}
+	return nil
+}
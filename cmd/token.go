@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// tokenCmd is the exec credential plugin of kubeconfigs generated with --exec-credentials
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Print a cluster token as an ExecCredential for kubectl",
+	Long: `Print a token for one cluster as a client.authentication.k8s.io/v1
+ExecCredential. Kubeconfigs generated with --exec-credentials run this command
+from kubectl, so no long-lived token is stored in the kubeconfig.
+
+The token is cluster-scoped, valid for --ttl and cached on disk until shortly
+before it expires, so kubectl does not mint a new one for every command.
+Credentials come from the environment or from "login", like for every other
+command; with RANCHER_INSTANCES, the instance whose URL is --server is used.
+
+Examples:
+  # What kubectl runs for a generated context
+  kubeconfig-wrangler token --server https://rancher.example.com --cluster c-m-abc123`,
+	RunE: runToken,
+}
+
+var (
+	tokenServer  string
+	tokenCluster string
+	tokenTTL     time.Duration
+	tokenRefresh bool
+)
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	addRancherFlags(tokenCmd)
+	tokenCmd.Flags().StringVar(&tokenServer, "server", "", "Rancher server the cluster belongs to (default: the configured Rancher URL)")
+	tokenCmd.Flags().StringVar(&tokenCluster, "cluster", "", "Rancher ID of the cluster, e.g. c-m-abc123")
+	tokenCmd.Flags().DurationVar(&tokenTTL, "ttl", 12*time.Hour, "Lifetime of the minted cluster token")
+	tokenCmd.Flags().BoolVar(&tokenRefresh, "refresh", false, "Mint a new token even if a cached one is still valid")
+}
+
+func runToken(cmd *cobra.Command, args []string) error {
+	if tokenCluster == "" {
+		return fmt.Errorf("configuration error: --cluster is required")
+	}
+	if tokenTTL <= 0 {
+		return fmt.Errorf("configuration error: --ttl must be positive")
+	}
+	cfg, err := tokenConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	plan := rancher.NewPlan("token", cfg)
+	plan.Add(rancher.PlannedCall{Method: "POST", Path: "/v3/tokens", Count: "unless a cached token is valid", Purpose: "mint a cluster-scoped token", Creates: "a cluster-scoped API token"})
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/tokens/<name>", Count: "after minting", Purpose: "read when the token expires"})
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	cache := rancher.NewCredentialCache(filepath.Join(cacheDir, "tokens"))
+	key := []string{cfg.RancherURL, tokenCluster, cfg.AccessKey + cfg.Username}
+
+	cred, ok := cache.Get(key...)
+	if !ok || tokenRefresh {
+		client, err := newRancherClient(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create Rancher client: %w", err)
+		}
+		if cred, err = client.MintClusterCredential(tokenCluster, "kubeconfig-wrangler exec credential for "+tokenCluster, tokenTTL); err != nil {
+			return fmt.Errorf("failed to create a token for cluster %s: %w", tokenCluster, err)
+		}
+		if err := cache.Put(*cred, key...); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	expires := metav1.NewTime(cred.ExpiresAt)
+	out, err := json.Marshal(clientauthv1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{APIVersion: kubeconfig.ExecCredentialAPIVersion, Kind: "ExecCredential"},
+		Status:   &clientauthv1.ExecCredentialStatus{Token: cred.Token, ExpirationTimestamp: &expires},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode credential: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// tokenConfig returns the configuration of the Rancher server named by
+// --server: the default one, or one of the configured instances
+func tokenConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*config.Config{cfg}
+	for _, name := range config.InstanceNames() {
+		candidates = append(candidates, config.LoadInstance(cfg, name))
+	}
+	server := strings.TrimSuffix(tokenServer, "/")
+	for _, candidate := range candidates {
+		if server != "" && strings.TrimSuffix(candidate.RancherURL, "/") != server {
+			continue
+		}
+		if err := validateInstance(candidate); err != nil {
+			return nil, fmt.Errorf("configuration error: %w", err)
+		}
+		return candidate, nil
+	}
+	return nil, fmt.Errorf("configuration error: no credentials configured for %s; run \"kubeconfig-wrangler login --url %s\"", server, server)
+}
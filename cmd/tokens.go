@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+	"github.com/kubeconfig-wrangler/pkg/sink"
+)
+
+// tokensCmd groups commands that manage Rancher API tokens
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Manage Rancher API tokens",
+}
+
+// tokensRevokeCmd revokes the API tokens matching the given criteria
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke API tokens in bulk by age, label, owner or expiry",
+	Long: `Revoke every API token Rancher lists to you that matches all of the given
+criteria. At least one criterion is required, and the token this command
+authenticates with is never revoked.
+
+Use --dry-run to preview the tokens that would be revoked.
+
+Examples:
+  # Preview the tokens created by automation more than 30 days ago
+  kubeconfig-wrangler tokens revoke --older-than 30d --label created-by=rkp --dry-run
+
+  # Revoke them
+  kubeconfig-wrangler tokens revoke --older-than 30d --label created-by=rkp
+
+  # Clean up the expired tokens of one user
+  kubeconfig-wrangler tokens revoke --expired --user u-abc123`,
+	RunE: runTokensRevoke,
+}
+
+var (
+	revokeOlderThan durationValue
+	revokeLabels    []string
+	revokeUser      string
+	revokeExpired   bool
+	revokeDryRun    bool
+)
+
+func init() {
+	rootCmd.AddCommand(tokensCmd)
+	tokensCmd.AddCommand(tokensRevokeCmd)
+	addRancherFlags(tokensRevokeCmd)
+	tokensRevokeCmd.Flags().Var(&revokeOlderThan, "older-than", "Only tokens created at least this long ago, e.g. 30d or 12h")
+	tokensRevokeCmd.Flags().StringArrayVar(&revokeLabels, "label", nil, "Only tokens with this label, as key=value (repeatable)")
+	tokensRevokeCmd.Flags().StringVar(&revokeUser, "user", "", "Only tokens of this user ID, e.g. u-abc123")
+	tokensRevokeCmd.Flags().BoolVar(&revokeExpired, "expired", false, "Only tokens that have expired")
+	tokensRevokeCmd.Flags().BoolVar(&revokeDryRun, "dry-run", false, "List the tokens that would be revoked without revoking them")
+}
+
+func runTokensRevoke(cmd *cobra.Command, args []string) error {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	if revokeOlderThan < 0 {
+		return fmt.Errorf("configuration error: --older-than must not be negative")
+	}
+	labels, err := sink.ParseKeyValues(revokeLabels)
+	if err != nil {
+		return fmt.Errorf("configuration error: invalid --label: %w", err)
+	}
+	selector := rancher.TokenSelector{
+		OlderThan:   time.Duration(revokeOlderThan),
+		Labels:      labels,
+		UserID:      revokeUser,
+		ExpiredOnly: revokeExpired,
+	}
+	if selector.OlderThan == 0 && len(selector.Labels) == 0 && selector.UserID == "" && !selector.ExpiredOnly {
+		return fmt.Errorf("configuration error: give at least one of --older-than, --label, --user or --expired")
+	}
+
+	plan := rancher.NewPlan("tokens revoke", cfg)
+	plan.Add(rancher.PlannedCall{Method: "GET", Path: "/v3/tokens", Count: "once per page", Purpose: "list the API tokens"})
+	if !revokeDryRun {
+		plan.Add(rancher.PlannedCall{Method: "DELETE", Path: "/v3/tokens/<name>", Count: "per matching token", Purpose: "revoke the token"})
+		plan.Note("matching tokens are revoked for good; use --dry-run to preview them")
+	}
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+	tokens, err := client.ListTokens()
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	own := client.TokenName()
+	now := time.Now()
+	var selected []rancher.Token
+	for _, token := range tokens {
+		if token.Name == own || token.Current || !selector.Matches(&token, now) {
+			continue
+		}
+		selected = append(selected, token)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Name < selected[j].Name })
+
+	if len(selected) == 0 {
+		fmt.Fprintln(os.Stderr, "No tokens match")
+		return nil
+	}
+	printTokens(selected)
+	if revokeDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %d token(s) would be revoked\n", len(selected))
+		return nil
+	}
+
+	var failed []string
+	for _, token := range selected {
+		if err := client.DeleteToken(token.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to revoke %s: %v\n", token.Name, err)
+			failed = append(failed, token.Name)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Revoked %d token(s)\n", len(selected)-len(failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to revoke %d token(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// printTokens prints a table of tokens
+func printTokens(tokens []rancher.Token) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUSER\tSCOPE\tCREATED\tEXPIRED\tDESCRIPTION")
+	for _, token := range tokens {
+		created := "unknown"
+		if at, ok := token.CreatedAt(); ok {
+			created = at.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", token.Name, token.UserID, token.Scope(), created, token.Expired, token.Description)
+	}
+	w.Flush()
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// useCmd switches the current context among the generated ones
+var useCmd = &cobra.Command{
+	Use:   "use [cluster]",
+	Short: "Switch the current context to a generated cluster",
+	Long: `Set the current context of a kubeconfig to one of the contexts generated by
+this tool, like kubectx. The cluster is matched by its exact context name, or
+else by the only context name starting with it, containing it or holding its
+letters in order, ignoring case; an ambiguous name lists the candidates.
+Contexts the tool did not generate are never selected.
+
+Without a cluster, the generated contexts are listed and the current one is
+marked with an asterisk.
+
+The kubeconfig is --kubeconfig or RANCHER_KUBECONFIG_OUTPUT, or else the one
+kubectl uses ($KUBECONFIG or ~/.kube/config).
+
+Examples:
+  # Switch to rancher-prod-eu
+  kubeconfig-wrangler use prod-eu
+
+  # Letters in order are enough
+  kubeconfig-wrangler use rpeu --kubeconfig ~/.kube/config`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeManagedContexts,
+	RunE:              runUse,
+}
+
+func init() {
+	rootCmd.AddCommand(useCmd)
+	addManagedKubeconfigFlag(useCmd)
+}
+
+// useKubeconfigPath returns the managed kubeconfig, or else the one kubectl uses
+func useKubeconfigPath() string {
+	if path := managedKubeconfigPath(); path != "" {
+		return path
+	}
+	return clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+}
+
+func runUse(cmd *cobra.Command, args []string) error {
+	path := useKubeconfigPath()
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	managed := kubeconfig.ManagedContexts(config)
+	if len(managed) == 0 {
+		return fmt.Errorf("%s has no contexts generated by kubeconfig-wrangler", path)
+	}
+
+	if len(args) == 0 {
+		for _, name := range managed {
+			if name == config.CurrentContext {
+				fmt.Printf("* %s\n", name)
+			} else {
+				fmt.Printf("  %s\n", name)
+			}
+		}
+		return nil
+	}
+
+	name, err := kubeconfig.MatchContext(managed, args[0])
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	if config.CurrentContext != name {
+		config.CurrentContext = name
+		data, err := kubeconfig.NewGenerator("").Serialize(config)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write kubeconfig to %s: %w", path, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Switched to context %q\n", name)
+	return nil
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/probe"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check that the generated cluster endpoints are reachable",
+	Long: `Fetch the kubeconfig of every active cluster and call the Kubernetes
+API /version endpoint of each context with its credentials.
+
+For clusters with an authorized cluster endpoint (ACE), both the Rancher
+proxy endpoint and the direct endpoint(s) are tested, and the healthiest,
+fastest one is marked with "*". This is the endpoint that
+"generate --endpoint-mode auto" would keep.
+
+With --kubeconfig, the contexts of a kubeconfig file, e.g. one written by
+"generate", are checked instead, without contacting Rancher. This catches
+expired or revoked tokens before kubectl runs into them.
+
+Examples:
+  # Validate all clusters using API token
+  kubeconfig-wrangler validate --url https://rancher.example.com --token token-xxxxx:yyyyyyy
+
+  # Use a shorter timeout per endpoint
+  kubeconfig-wrangler validate --timeout 3s
+
+  # Check every context of the generated kubeconfig
+  kubeconfig-wrangler validate --kubeconfig ~/.kube/rancher-config
+
+  # Check a kubeconfig before installing it
+  kubeconfig-wrangler generate | kubeconfig-wrangler validate --kubeconfig -`,
+	RunE: runValidate,
+}
+
+var (
+	validateTimeout    time.Duration
+	validateKubeconfig string
+)
+
+func init() {
+	addRancherFlags(validateCmd)
+	validateCmd.Flags().DurationVar(&validateTimeout, "timeout", probe.DefaultTimeout, "Timeout for each endpoint check")
+	validateCmd.Flags().StringVar(&validateKubeconfig, "kubeconfig", "", "Check every context of this kubeconfig file (\"-\" for stdin) instead of fetching kubeconfigs from Rancher")
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	if validateKubeconfig != "" {
+		return validateKubeconfigFile(validateKubeconfig)
+	}
+
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	plan := rancher.NewPlan("validate", cfg)
+	plan.GetAllKubeconfigs()
+	plan.Note("every endpoint of the generated kubeconfigs is then checked directly, not through Rancher")
+	if ok, err := printPlan(plan); ok {
+		return err
+	}
+
+	client, err := newRancherClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Rancher client: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Fetching clusters from Rancher...")
+	fetched, err := client.FetchKubeconfigs()
+	if err != nil {
+		return fmt.Errorf("failed to get kubeconfigs: %w", err)
+	}
+	kubeconfigs := fetched.Kubeconfigs
+
+	if len(kubeconfigs) == 0 && len(fetched.Failures) == 0 {
+		return fmt.Errorf("no active clusters found")
+	}
+
+	clusterNames := make([]string, 0, len(kubeconfigs))
+	for name := range kubeconfigs {
+		clusterNames = append(clusterNames, name)
+	}
+	sort.Strings(clusterNames)
+
+	generator := kubeconfig.NewGenerator("")
+	unhealthy := len(fetched.Failures)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tENDPOINT\tSERVER\tSTATUS\tLATENCY\tVERSION\t")
+	fmt.Fprintln(w, "-------\t--------\t------\t------\t-------\t-------\t")
+	for _, failure := range fetched.Failures {
+		fmt.Fprintf(w, "%s\t-\t-\t%s\t-\t-\t\n", failure.Name, "no kubeconfig")
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", failure)
+	}
+	for _, clusterName := range clusterNames {
+		config, err := generator.ParseKubeconfig(kubeconfigs[clusterName])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", clusterName, err)
+			unhealthy++
+			continue
+		}
+
+		proxy, direct := kubeconfig.ClassifyContexts(config)
+		contexts := append(append([]string{}, proxy...), direct...)
+		results := probe.Contexts(config, contexts, validateTimeout)
+
+		best := -1
+		if len(proxy) > 0 && len(direct) > 0 {
+			best = probe.Best(results)
+		}
+		if probe.Best(results) == -1 {
+			unhealthy++
+		}
+
+		for i, result := range results {
+			kind := "direct"
+			if i < len(proxy) {
+				kind = "proxy"
+			}
+			if i == best {
+				kind += " *"
+			}
+			status := string(result.Status)
+			latency := "-"
+			if result.Latency > 0 {
+				latency = result.Latency.Round(time.Millisecond).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", clusterName, kind, result.Server, status, latency, result.Version)
+			if result.Error != "" && !result.Healthy() {
+				fmt.Fprintf(os.Stderr, "Warning: %s (%s): %s\n", clusterName, result.Context, result.Error)
+			}
+		}
+	}
+	w.Flush()
+
+	if unhealthy > 0 {
+		return fmt.Errorf("%d cluster(s) have no healthy endpoint", unhealthy)
+	}
+	return nil
+}
+
+// validateKubeconfigFile probes every context of the kubeconfig at path
+// concurrently and reports the outcome of each
+func validateKubeconfigFile(path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(config.Contexts) == 0 {
+		return fmt.Errorf("%s has no contexts", path)
+	}
+
+	contexts := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	results := probe.Contexts(config, contexts, validateTimeout)
+
+	counts := make(map[probe.Status]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tSERVER\tSTATUS\tLATENCY\tVERSION\t")
+	fmt.Fprintln(w, "-------\t------\t------\t-------\t-------\t")
+	for _, result := range results {
+		counts[result.Status]++
+		latency := "-"
+		if result.Latency > 0 {
+			latency = result.Latency.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", result.Context, result.Server, result.Status, latency, result.Version)
+		if result.Error != "" && !result.Healthy() {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", result.Context, result.Error)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintf(os.Stderr, "%d reachable, %d auth failed, %d unreachable\n",
+		counts[probe.StatusReachable], counts[probe.StatusAuthFailed], counts[probe.StatusUnreachable])
+	if unhealthy := len(results) - counts[probe.StatusReachable]; unhealthy > 0 {
+		return fmt.Errorf("%d context(s) are not usable", unhealthy)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+)
+
+// verifyCmd checks a generated kubeconfig against an expectations file
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the generated kubeconfig matches an expectations file",
+	Long: `Generate a kubeconfig like "generate" does, or read one with --kubeconfig,
+and check it against a YAML expectations file. The command fails, listing every
+violation, when a context is missing, an unlisted context appears, a server does
+not match its pattern, or a cluster skips TLS verification or uses plain HTTP.
+
+Expectations file:
+  server: ^https://rancher\.example\.com/   # pattern for every server (optional)
+  allowExtra: false                         # accept unlisted contexts
+  allowInsecure: false                      # accept insecure clusters
+  contexts:
+  - name: prod-eu
+  - name: prod-us
+    server: ^https://k8s\.us\.example\.com  # overrides the global pattern
+
+Examples:
+  # Fail CI when the Rancher fleet drifts from the declared one
+  kubeconfig-wrangler verify --expect expectations.yaml --prefix prod-
+
+  # Check a kubeconfig generated earlier in the pipeline
+  kubeconfig-wrangler verify --expect expectations.yaml --kubeconfig kubeconfig.yaml`,
+	RunE: runVerify,
+}
+
+var (
+	verifyExpect     string
+	verifyKubeconfig string
+	verifyPrefix     string
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	addRancherFlags(verifyCmd)
+	verifyCmd.Flags().StringVar(&verifyExpect, "expect", "", "Expectations file the kubeconfig must satisfy")
+	verifyCmd.Flags().StringVar(&verifyKubeconfig, "kubeconfig", "", "Verify this kubeconfig file instead of generating one from Rancher")
+	verifyCmd.Flags().StringVarP(&verifyPrefix, "prefix", "p", "", "Prefix to add to cluster names (env: RANCHER_CLUSTER_PREFIX)")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if verifyExpect == "" {
+		return fmt.Errorf("configuration error: --expect is required")
+	}
+	expectations, err := kubeconfig.LoadExpectations(verifyExpect)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	var generated *api.Config
+	if verifyKubeconfig != "" {
+		if generated, err = clientcmd.LoadFromFile(verifyKubeconfig); err != nil {
+			return fmt.Errorf("failed to read %s: %w", verifyKubeconfig, err)
+		}
+	} else if generated, err = generateForVerify(cmd); err != nil || generated == nil {
+		return err
+	}
+
+	violations := expectations.Verify(generated)
+	for _, violation := range violations {
+		fmt.Fprintf(os.Stderr, "  %s\n", violation)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("kubeconfig does not match %s: %d violation(s)", verifyExpect, len(violations))
+	}
+	fmt.Fprintf(os.Stderr, "Kubeconfig matches %s (%d contexts)\n", verifyExpect, len(generated.Contexts))
+	return nil
+}
+
+// generateForVerify generates the kubeconfig of the configured Rancher
+// instances, or prints the plan and returns nil with --explain
+func generateForVerify(cmd *cobra.Command) (*api.Config, error) {
+	cfg, err := loadRancherConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Flags().Changed("prefix") {
+		cfg.ClusterPrefix = verifyPrefix
+	}
+
+	instances := []*config.Config{cfg}
+	if names := config.InstanceNames(); len(names) > 0 {
+		instances = make([]*config.Config, 0, len(names))
+		for _, name := range names {
+			instances = append(instances, config.LoadInstance(cfg, name))
+		}
+	}
+	for _, instance := range instances {
+		if err := validateInstance(instance); err != nil {
+			if instance.Name != "" {
+				return nil, fmt.Errorf("configuration error for instance %s: %w", instance.Name, err)
+			}
+			return nil, fmt.Errorf("configuration error: %w", err)
+		}
+	}
+
+	if explain {
+		return nil, explainGenerate("verify", instances, kubeconfig.EndpointModeAll, nil)
+	}
+	generated, _, err := generateAll(instances, kubeconfig.EndpointModeAll, nil)
+	return generated, err
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
+	"github.com/kubeconfig-wrangler/pkg/policy"
+)
+
+// defaultWatchInterval is how often "generate --watch" refreshes the kubeconfig
+const defaultWatchInterval = 15 * time.Minute
+
+// regenerateEvery generates the kubeconfig right away and then every interval,
+// or immediately on SIGHUP, logging a summary of each refresh, until
+// interrupted. A failed refresh is retried at the next one.
+func regenerateEvery(cfg *config.Config, instances []*config.Config, mode kubeconfig.EndpointMode, pol *policy.Policy, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	fmt.Fprintf(os.Stderr, "Refreshing every %s (SIGHUP to refresh now, Ctrl+C to stop)...\n", interval)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		case <-hangup:
+			fmt.Fprintln(os.Stderr, "Received SIGHUP, refreshing now")
+		}
+
+		if reloadSecretFiles(cfg, instances) {
+			fmt.Fprintln(os.Stderr, "Credentials changed, using the new ones")
+		}
+		start := time.Now()
+		contexts, err := generateAndWrite(cfg, instances, mode, pol)
+		took := time.Since(start).Round(time.Millisecond)
+		next := time.Now().Add(interval).Format(time.TimeOnly)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: refresh failed after %s, retrying at %s: %v\n", took, next, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Refreshed %d context(s) in %s, next refresh at %s\n", contexts, took, next)
+		}
+		timer.Reset(interval)
+	}
+}
@@ -2,9 +2,21 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/noexec"
 )
 
 // AuthMethod represents the authentication method to use
@@ -19,6 +31,9 @@ const (
 
 // Config holds the application configuration
 type Config struct {
+	// Name identifies the Rancher instance when several are aggregated (empty for a single instance)
+	Name string
+
 	// RancherURL is the URL of the Rancher server (e.g., https://rancher.example.com)
 	RancherURL string
 
@@ -40,34 +55,272 @@ type Config struct {
 	// AuthMethod indicates which authentication method to use
 	AuthMethod AuthMethod
 
+	// CredentialSource is a secret store the token is read from when none is
+	// configured, e.g. vault:secret/data/ci/rancher (empty for none)
+	CredentialSource string
+
+	// CredentialCommand is a command printing the token, e.g. a password
+	// manager's CLI, run when no token is configured (empty for none)
+	CredentialCommand []string
+
+	// AuthProvider is the Rancher auth provider used for password login (local, activedirectory, openldap, freeipa; empty means local)
+	AuthProvider string
+
 	// ClusterPrefix is the prefix to add to cluster names in the kubeconfig
 	ClusterPrefix string
 
+	// ClusterSuffix is the suffix to add to cluster names in the kubeconfig
+	ClusterSuffix string
+
+	// ClusterSeparator joins the prefix, cluster name and suffix (empty concatenates them as given)
+	ClusterSeparator string
+
+	// NameTemplate is a Go template naming the clusters in the kubeconfig, replacing prefix and suffix joining
+	NameTemplate string
+
+	// SanitizeNames are the sanitization rules applied to the generated names:
+	// lowercase, replace-invalid or all (empty keeps names as they are)
+	SanitizeNames []string
+
+	// MaxNameLength shortens longer generated names, ending them with a hash (0 for no limit)
+	MaxNameLength int
+
+	// ConflictStrategy resolves clusters ending up with the same name: error,
+	// skip, overwrite or rename (empty fails the merge)
+	ConflictStrategy string
+
 	// OutputPath is the path where the kubeconfig file will be written (empty for stdout)
 	OutputPath string
 
+	// CurrentContext names the current context of the generated kubeconfig,
+	// exactly or as a glob matched against the context names in order
+	CurrentContext string
+
+	// SplitDir is a directory to also write one kubeconfig per cluster into (empty for none)
+	SplitDir string
+
+	// SplitLabel is a cluster label, e.g. environment, by whose value the
+	// clusters are also written to one kubeconfig each (empty for none)
+	SplitLabel string
+
+	// Flatten inlines the certificate, key and token files referenced by the written kubeconfigs
+	Flatten bool
+
+	// Minify leaves clusters and users no context uses, and empty fields, out of the written kubeconfigs
+	Minify bool
+
+	// EncryptRecipients are the age public keys, or files listing them, the
+	// written kubeconfigs are encrypted for (empty writes plaintext)
+	EncryptRecipients []string
+
+	// EncryptFormat is how the written kubeconfigs are encrypted: age or sops (empty for age)
+	EncryptFormat string
+
 	// InsecureSkipTLSVerify skips TLS certificate verification
 	InsecureSkipTLSVerify bool
 
-	// CACert is the path to a CA certificate file for TLS verification
+	// CACert is the path to a CA certificate file, or a directory of PEM files, trusted for TLS verification
 	CACert string
+
+	// CACertData is PEM-encoded CA certificates trusted in addition to CACert
+	CACertData string
+
+	// ExcludeSystemCAs trusts only the custom CAs instead of adding them to the system roots
+	ExcludeSystemCAs bool
+
+	// MaxIdleConnsPerHost is the maximum number of idle keep-alive connections kept per host (0 uses the client default)
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the total number of connections per host, including active ones (0 means no limit)
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection stays in the pool before being closed (0 uses the client default)
+	IdleConnTimeout time.Duration
+
+	// DisableHTTP2 disables HTTP/2 negotiation with the Rancher server
+	DisableHTTP2 bool
+
+	// DisableKeepAlives disables connection reuse, opening a new connection for every request
+	DisableKeepAlives bool
+
+	// MaxResponseSize limits the size in bytes of Rancher API responses (0 uses the client default)
+	MaxResponseSize int64
+
+	// RetryMaxWait is the total time spent waiting on 429/503 responses before giving up (0 uses the client default, negative disables retries)
+	RetryMaxWait time.Duration
+
+	// WaitForRancher is how long to wait for a Rancher that refuses connections or answers 502/503/504 to come back (0 fails at once)
+	WaitForRancher time.Duration
+
+	// BreakerThreshold is the number of consecutive failed Rancher requests after which
+	// further requests fail fast (0 uses the client default, negative disables the breaker)
+	BreakerThreshold int
+
+	// DebugHTTP logs every Rancher API request and response (credentials redacted) to stderr
+	DebugHTTP bool
+
+	// ScopedTokens mints a cluster-scoped API token per cluster and embeds it instead of the generating user's token
+	ScopedTokens bool
+
+	// ScopedTokenTTL is the lifetime of the scoped tokens (0 uses rancher.DefaultScopedTokenTTL)
+	ScopedTokenTTL time.Duration
+
+	// AsUser is the Rancher user ID (e.g. u-abc123) to impersonate, so that generated
+	// kubeconfigs carry that user's permissions instead of the caller's
+	AsUser string
+
+	// ExecCredentials writes an exec credential plugin fetching a token at kubectl
+	// time instead of embedding a token in the kubeconfig
+	ExecCredentials bool
+
+	// ExecCommand is the command the exec credential plugin runs (empty means DefaultExecCommand)
+	ExecCommand string
+
+	// OIDCIssuerURL is the OpenID Connect issuer the clusters trust; when set,
+	// generated users log in with OIDC instead of using Rancher tokens
+	OIDCIssuerURL string
+
+	// OIDCClientID is the OIDC client the users log in with
+	OIDCClientID string
+
+	// OIDCClientSecret is the secret of a confidential OIDC client (empty for a public client)
+	OIDCClientSecret string
+
+	// OIDCExtraScopes are requested in addition to openid, e.g. groups
+	OIDCExtraScopes []string
+
+	// OIDCAuthProvider writes the legacy oidc auth-provider instead of a kubelogin exec plugin
+	OIDCAuthProvider bool
+
+	// ClusterProxyURL is the proxy-url written into the generated cluster
+	// entries, for API servers only reachable through an HTTP or SOCKS proxy
+	ClusterProxyURL string
+
+	// ClusterProxyRules route the clusters they select through other proxies,
+	// as <cluster glob or label:value>=<proxy URL>; the first match wins
+	ClusterProxyRules []string
+
+	// ClusterCAs replace the certificate authority of the direct endpoints of
+	// the clusters matching a glob, as <cluster glob>=<PEM file>
+	ClusterCAs []string
+
+	// ClusterTLSServerNames set the TLS server name of the direct endpoints of
+	// the clusters matching a glob, as <cluster glob>=<name>
+	ClusterTLSServerNames []string
+
+	// ClusterInsecure are globs of the clusters whose direct endpoints are
+	// not verified
+	ClusterInsecure []string
+
+	// ClusterStates are the cluster states accepted for kubeconfig generation (empty means DefaultClusterStates)
+	ClusterStates []string
+
+	// IncludeAllStates accepts clusters in any state, warning about those that are not active
+	IncludeAllStates bool
+
+	// OlderThan keeps only clusters created at least this long ago (0 for no limit)
+	OlderThan time.Duration
+
+	// NewerThan keeps only clusters created less than this long ago (0 for no limit)
+	NewerThan time.Duration
+
+	// EphemeralLabel marks ephemeral clusters by label, given as key or key=value (empty disables)
+	EphemeralLabel string
+
+	// EphemeralNamePattern marks ephemeral clusters by a regular expression on their name (empty disables)
+	EphemeralNamePattern string
+
+	// IncludeEphemeral keeps the clusters classified as ephemeral
+	IncludeEphemeral bool
+
+	// IncludeClusters keeps only the clusters whose name matches one of these
+	// patterns, globs or regular expressions written as /regexp/ (empty keeps all)
+	IncludeClusters []string
+
+	// ExcludeClusters leaves out the clusters whose name matches one of these patterns
+	ExcludeClusters []string
+
+	// Clusters keeps only the clusters with one of these exact names or IDs (empty keeps all)
+	Clusters []string
+
+	// StateField is the dotted JSON path of the cluster state, for Rancher derivatives (empty for "state")
+	StateField string
+
+	// NameField is the dotted JSON path of the cluster name, for Rancher derivatives (empty for "name")
+	NameField string
+
+	// KubeconfigActionField is the dotted JSON path of the kubeconfig action URL (empty for "actions.generateKubeconfig")
+	KubeconfigActionField string
+
+	// IncludeSystemProjects includes Rancher's System project when expanding projects
+	IncludeSystemProjects bool
+
+	// ContextNamespaces expands every cluster into one context per namespace
+	ContextNamespaces []string
+
+	// ProjectNamespaces expands every cluster into one context per namespace
+	// of its projects, as listed by Rancher
+	ProjectNamespaces bool
+
+	// Harvester selects whether Harvester HCI clusters are generated (empty means HarvesterInclude)
+	Harvester HarvesterMode
+
+	// secretFiles are the secrets read from files, for ReloadSecretFiles
+	secretFiles []secretFile
+
+	// envErrors are the problems reading the environment, reported by Validate
+	envErrors []error
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	// Problems holds one error per problem, in the order they were found
+	Problems []error
+}
+
+// Error describes the only problem, or lists them all one per line
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems:", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem.Error())
+	}
+	return b.String()
 }
 
-// Validate checks if the configuration is valid
+// Unwrap returns the problems, for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks if the configuration is valid, reporting every problem
+// found as a *ValidationError rather than stopping at the first one
 func (c *Config) Validate() error {
-	if c.RancherURL == "" {
-		return errors.New("rancher URL is required")
+	problems := slices.Clone(c.envErrors)
+	add := func(err error) {
+		problems = append(problems, err)
 	}
 
-	// Ensure URL doesn't have trailing slash
-	c.RancherURL = strings.TrimSuffix(c.RancherURL, "/")
+	if c.RancherURL == "" {
+		add(errors.New("rancher URL is required"))
+	} else {
+		// Ensure URL doesn't have trailing slash
+		c.RancherURL = strings.TrimSuffix(c.RancherURL, "/")
+		if u, err := url.Parse(c.RancherURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add(fmt.Errorf("invalid rancher URL %q: must be an http or https URL", c.RancherURL))
+		}
+	}
 
 	// Determine authentication method based on provided credentials
 	hasToken := c.Token != "" || (c.AccessKey != "" && c.SecretKey != "")
 	hasPassword := c.Username != "" && c.Password != ""
 
 	if !hasToken && !hasPassword {
-		return errors.New("authentication required: provide either token/access_key+secret_key or username+password")
+		add(errors.New("authentication required: provide either token/access_key+secret_key or username+password"))
 	}
 
 	// If both are provided, prefer token auth unless explicitly set to password
@@ -80,49 +333,411 @@ func (c *Config) Validate() error {
 	}
 
 	// If using token auth, parse the token if needed
-	if c.AuthMethod == AuthMethodToken {
+	if hasToken && c.AuthMethod == AuthMethodToken {
+		parsed := true
 		if c.Token != "" {
-			parts := strings.SplitN(c.Token, ":", 2)
-			if len(parts) != 2 {
-				return errors.New("invalid token format, expected 'access_key:secret_key'")
+			access, secret, ok := strings.Cut(c.Token, ":")
+			if ok {
+				c.AccessKey, c.SecretKey = access, secret
+			} else {
+				add(errors.New("invalid token format, expected 'access_key:secret_key'"))
 			}
-			c.AccessKey = parts[0]
-			c.SecretKey = parts[1]
+			parsed = ok
 		}
-		if c.AccessKey == "" || c.SecretKey == "" {
-			return errors.New("token authentication requires access_key and secret_key")
+		if parsed && (c.AccessKey == "" || c.SecretKey == "") {
+			add(errors.New("token authentication requires access_key and secret_key"))
 		}
 	}
 
 	// If using password auth, validate credentials
-	if c.AuthMethod == AuthMethodPassword {
+	if c.AuthMethod == AuthMethodPassword && (hasToken || hasPassword) {
 		if c.Username == "" || c.Password == "" {
-			return errors.New("password authentication requires username and password")
+			add(errors.New("password authentication requires username and password"))
+		}
+	}
+
+	if c.CACert != "" {
+		if f, err := os.Open(c.CACert); err != nil {
+			add(fmt.Errorf("CA certificate %s is not readable: %w", c.CACert, err))
+		} else {
+			f.Close()
+		}
+	}
+
+	if c.OutputPath != "" {
+		if info, err := os.Stat(c.OutputPath); err == nil && info.IsDir() {
+			add(fmt.Errorf("output path %s is a directory", c.OutputPath))
+		} else if info, err := os.Stat(filepath.Dir(c.OutputPath)); err != nil {
+			add(fmt.Errorf("output directory %s does not exist", filepath.Dir(c.OutputPath)))
+		} else if !info.IsDir() {
+			add(fmt.Errorf("output directory %s is not a directory", filepath.Dir(c.OutputPath)))
+		}
+	}
+	if c.SplitDir != "" {
+		if info, err := os.Stat(c.SplitDir); err == nil && !info.IsDir() {
+			add(fmt.Errorf("split directory %s is not a directory", c.SplitDir))
+		}
+	}
+
+	if _, err := ParseHarvesterMode(string(c.Harvester)); err != nil {
+		add(err)
+	}
+
+	for _, path := range []string{c.StateField, c.NameField, c.KubeconfigActionField} {
+		if path != "" && slices.Contains(strings.Split(path, "."), "") {
+			add(fmt.Errorf("invalid field path %q: empty segment", path))
 		}
 	}
 
+	if c.NameTemplate != "" {
+		if _, err := template.New("name").Parse(c.NameTemplate); err != nil {
+			add(fmt.Errorf("invalid name template: %w", err))
+		}
+	}
+
+	if c.EphemeralNamePattern != "" {
+		if _, err := regexp.Compile(c.EphemeralNamePattern); err != nil {
+			add(fmt.Errorf("invalid ephemeral name pattern: %w", err))
+		}
+	}
+
+	if c.ExecCredentials && c.ScopedTokens {
+		add(errors.New("exec credentials already fetch cluster-scoped tokens; drop scoped tokens"))
+	}
+	if c.ExecCredentials && c.AsUser != "" {
+		add(errors.New("exec credentials cannot be combined with impersonation, as kubectl would fetch your own tokens"))
+	}
+
+	// Refuse up front what no-exec mode would only stop after the
+	// kubeconfigs were fetched and their tokens created
+	if noexec.Enabled() {
+		if len(c.CredentialCommand) > 0 {
+			add(fmt.Errorf("credential command %s cannot run in no-exec mode", c.CredentialCommand[0]))
+		}
+		if len(c.EncryptRecipients) > 0 && strings.EqualFold(c.EncryptFormat, "sops") {
+			add(errors.New("sops encryption runs the sops command, which no-exec mode forbids; use age encryption"))
+		}
+	}
+
+	if c.OIDCIssuerURL != "" {
+		if u, err := url.Parse(c.OIDCIssuerURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			add(fmt.Errorf("invalid OIDC issuer URL %q: must be an https URL", c.OIDCIssuerURL))
+		}
+		if c.OIDCClientID == "" {
+			add(errors.New("an OIDC client ID is required with an OIDC issuer"))
+		}
+		if c.ExecCredentials {
+			add(errors.New("OIDC login and exec credentials cannot be combined"))
+		}
+	}
+
+	for _, pattern := range append(slices.Clone(c.IncludeClusters), c.ExcludeClusters...) {
+		if _, err := MatchClusterName(pattern, ""); err != nil {
+			add(err)
+		}
+	}
+
+	if c.OlderThan < 0 || c.NewerThan < 0 {
+		add(errors.New("cluster age filters must not be negative"))
+	} else if c.OlderThan > 0 && c.NewerThan > 0 && c.OlderThan >= c.NewerThan {
+		add(fmt.Errorf("no cluster can be older than %s and newer than %s", c.OlderThan, c.NewerThan))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
 	return nil
 }
 
+// HarvesterMode selects how Harvester HCI clusters are treated during generation
+type HarvesterMode string
+
+const (
+	// HarvesterInclude generates Harvester clusters like any other cluster
+	HarvesterInclude HarvesterMode = "include"
+	// HarvesterExclude skips Harvester clusters
+	HarvesterExclude HarvesterMode = "exclude"
+	// HarvesterOnly generates Harvester clusters and nothing else
+	HarvesterOnly HarvesterMode = "only"
+)
+
+// ParseHarvesterMode validates a Harvester mode name; empty means HarvesterInclude
+func ParseHarvesterMode(value string) (HarvesterMode, error) {
+	switch mode := HarvesterMode(strings.ToLower(value)); mode {
+	case "":
+		return HarvesterInclude, nil
+	case HarvesterInclude, HarvesterExclude, HarvesterOnly:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid Harvester mode %q (must be include, exclude or only)", value)
+}
+
+// AcceptsHarvester reports whether a cluster is eligible for kubeconfig
+// generation given whether it is a Harvester cluster
+func (c *Config) AcceptsHarvester(isHarvester bool) bool {
+	mode, _ := ParseHarvesterMode(string(c.Harvester))
+	switch mode {
+	case HarvesterExclude:
+		return !isHarvester
+	case HarvesterOnly:
+		return isHarvester
+	}
+	return true
+}
+
+// AcceptsClusterAge reports whether a cluster created age ago passes the
+// OlderThan and NewerThan filters
+func (c *Config) AcceptsClusterAge(age time.Duration) bool {
+	if c.OlderThan > 0 && age < c.OlderThan {
+		return false
+	}
+	if c.NewerThan > 0 && age >= c.NewerThan {
+		return false
+	}
+	return true
+}
+
+// IsEphemeral reports whether a cluster matches the ephemeral label or name
+// pattern. Both are off by default, classifying no cluster as ephemeral.
+func (c *Config) IsEphemeral(name string, labels map[string]string) bool {
+	if c.EphemeralLabel != "" {
+		key, value, hasValue := strings.Cut(c.EphemeralLabel, "=")
+		if actual, ok := labels[key]; ok && (!hasValue || actual == value) {
+			return true
+		}
+	}
+	if c.EphemeralNamePattern != "" {
+		if matched, err := regexp.MatchString(c.EphemeralNamePattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectsCluster reports whether a cluster is among the Clusters selected by
+// name or ID, which all are when none is
+func (c *Config) SelectsCluster(id, name string) bool {
+	return len(c.Clusters) == 0 || slices.Contains(c.Clusters, name) || slices.Contains(c.Clusters, id)
+}
+
+// AcceptsClusterName reports whether a cluster name passes the include and
+// exclude patterns. Exclusion wins over inclusion.
+func (c *Config) AcceptsClusterName(name string) bool {
+	matchesAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if matched, err := MatchClusterName(pattern, name); err == nil && matched {
+				return true
+			}
+		}
+		return false
+	}
+	if len(c.IncludeClusters) > 0 && !matchesAny(c.IncludeClusters) {
+		return false
+	}
+	return !matchesAny(c.ExcludeClusters)
+}
+
+// MatchClusterName matches a cluster name against a glob such as "prod-*", or
+// against a regular expression when the pattern is written as /regexp/
+func MatchClusterName(pattern, name string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid cluster name pattern %q: %w", pattern, err)
+		}
+		return re.MatchString(name), nil
+	}
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		return false, fmt.Errorf("invalid cluster name pattern %q: %w", pattern, err)
+	}
+	return matched, nil
+}
+
+// DefaultExecCommand is the command exec credential plugins run when none is configured
+const DefaultExecCommand = "kubeconfig-wrangler"
+
+// ExecCommandOrDefault returns the configured exec credential command, or DefaultExecCommand
+func (c *Config) ExecCommandOrDefault() string {
+	if c.ExecCommand == "" {
+		return DefaultExecCommand
+	}
+	return c.ExecCommand
+}
+
+// DefaultClusterStates are the cluster states accepted when none are configured
+var DefaultClusterStates = []string{"active"}
+
+// AcceptedClusterStates returns the configured cluster states, or DefaultClusterStates
+func (c *Config) AcceptedClusterStates() []string {
+	if len(c.ClusterStates) == 0 {
+		return DefaultClusterStates
+	}
+	return c.ClusterStates
+}
+
+// AcceptsClusterState reports whether clusters in the given state are eligible for
+// kubeconfig generation. States are compared case-insensitively.
+func (c *Config) AcceptsClusterState(state string) bool {
+	if c.IncludeAllStates {
+		return true
+	}
+	for _, accepted := range c.AcceptedClusterStates() {
+		if strings.EqualFold(accepted, state) {
+			return true
+		}
+	}
+	return false
+}
+
 // UsePasswordAuth returns true if password authentication should be used
 func (c *Config) UsePasswordAuth() bool {
 	return c.AuthMethod == AuthMethodPassword
 }
 
-// LoadFromEnv loads configuration from environment variables
+// LoadFromEnv loads configuration from environment variables. The secrets
+// may be read from files instead, named by RANCHER_TOKEN_FILE and the like.
 func LoadFromEnv() *Config {
-	return &Config{
+	var problems []error
+	cfg := &Config{
 		RancherURL:            os.Getenv("RANCHER_URL"),
 		AccessKey:             os.Getenv("RANCHER_ACCESS_KEY"),
 		SecretKey:             os.Getenv("RANCHER_SECRET_KEY"),
 		Token:                 os.Getenv("RANCHER_TOKEN"),
 		Username:              os.Getenv("RANCHER_USERNAME"),
 		Password:              os.Getenv("RANCHER_PASSWORD"),
+		AuthProvider:          os.Getenv("RANCHER_AUTH_PROVIDER"),
+		CredentialSource:      os.Getenv("RANCHER_CREDENTIAL_SOURCE"),
+		CredentialCommand:     SplitCommand(os.Getenv("RANCHER_CREDENTIAL_COMMAND")),
 		ClusterPrefix:         os.Getenv("RANCHER_CLUSTER_PREFIX"),
+		ClusterSuffix:         os.Getenv("RANCHER_CLUSTER_SUFFIX"),
+		ClusterSeparator:      os.Getenv("RANCHER_CLUSTER_SEPARATOR"),
+		NameTemplate:          os.Getenv("RANCHER_NAME_TEMPLATE"),
+		SanitizeNames:         SplitList(os.Getenv("RANCHER_SANITIZE_NAMES")),
+		MaxNameLength:         envInt("RANCHER_MAX_NAME_LENGTH", &problems),
+		ConflictStrategy:      os.Getenv("RANCHER_CONFLICT_STRATEGY"),
 		OutputPath:            os.Getenv("RANCHER_KUBECONFIG_OUTPUT"),
+		SplitDir:              os.Getenv("RANCHER_KUBECONFIG_SPLIT_DIR"),
+		SplitLabel:            os.Getenv("RANCHER_KUBECONFIG_SPLIT_LABEL"),
+		CurrentContext:        os.Getenv("RANCHER_CURRENT_CONTEXT"),
+		Flatten:               os.Getenv("RANCHER_FLATTEN") == "true",
+		Minify:                os.Getenv("RANCHER_MINIFY") == "true",
+		EncryptRecipients:     SplitList(os.Getenv("RANCHER_ENCRYPT_RECIPIENTS")),
+		EncryptFormat:         os.Getenv("RANCHER_ENCRYPT_FORMAT"),
 		InsecureSkipTLSVerify: os.Getenv("RANCHER_INSECURE_SKIP_TLS_VERIFY") == "true",
 		CACert:                os.Getenv("RANCHER_CA_CERT"),
+		CACertData:            os.Getenv("RANCHER_CA_CERT_DATA"),
+		ExcludeSystemCAs:      os.Getenv("RANCHER_EXCLUDE_SYSTEM_CAS") == "true",
+		MaxIdleConnsPerHost:   envInt("RANCHER_MAX_IDLE_CONNS_PER_HOST", &problems),
+		MaxConnsPerHost:       envInt("RANCHER_MAX_CONNS_PER_HOST", &problems),
+		IdleConnTimeout:       envDuration("RANCHER_IDLE_CONN_TIMEOUT", &problems),
+		DisableHTTP2:          os.Getenv("RANCHER_DISABLE_HTTP2") == "true",
+		DisableKeepAlives:     os.Getenv("RANCHER_DISABLE_KEEPALIVES") == "true",
+		ClusterStates:         SplitList(os.Getenv("RANCHER_CLUSTER_STATES")),
+		IncludeAllStates:      os.Getenv("RANCHER_INCLUDE_ALL_STATES") == "true",
+		OlderThan:             envDuration("RANCHER_OLDER_THAN", &problems),
+		NewerThan:             envDuration("RANCHER_NEWER_THAN", &problems),
+		EphemeralLabel:        os.Getenv("RANCHER_EPHEMERAL_LABEL"),
+		EphemeralNamePattern:  os.Getenv("RANCHER_EPHEMERAL_NAME_PATTERN"),
+		IncludeEphemeral:      os.Getenv("RANCHER_INCLUDE_EPHEMERAL") == "true",
+		IncludeClusters:       SplitList(os.Getenv("RANCHER_INCLUDE_CLUSTERS")),
+		ExcludeClusters:       SplitList(os.Getenv("RANCHER_EXCLUDE_CLUSTERS")),
+		Clusters:              SplitList(os.Getenv("RANCHER_CLUSTERS")),
+		StateField:            os.Getenv("RANCHER_STATE_FIELD"),
+		NameField:             os.Getenv("RANCHER_NAME_FIELD"),
+		KubeconfigActionField: os.Getenv("RANCHER_KUBECONFIG_ACTION_FIELD"),
+		IncludeSystemProjects: os.Getenv("RANCHER_INCLUDE_SYSTEM_PROJECTS") == "true",
+		ContextNamespaces:     SplitList(os.Getenv("RANCHER_CONTEXT_NAMESPACES")),
+		ProjectNamespaces:     os.Getenv("RANCHER_PROJECT_NAMESPACES") == "true",
+		MaxResponseSize:       int64(envInt("RANCHER_MAX_RESPONSE_SIZE", &problems)),
+		RetryMaxWait:          envDuration("RANCHER_RETRY_MAX_WAIT", &problems),
+		WaitForRancher:        envDuration("RANCHER_WAIT_FOR_RANCHER", &problems),
+		BreakerThreshold:      envInt("RANCHER_BREAKER_THRESHOLD", &problems),
+		DebugHTTP:             os.Getenv("RANCHER_DEBUG_HTTP") == "true",
+		ScopedTokens:          os.Getenv("RANCHER_SCOPED_TOKENS") == "true",
+		ScopedTokenTTL:        envDuration("RANCHER_SCOPED_TOKEN_TTL", &problems),
+		AsUser:                os.Getenv("RANCHER_AS_USER"),
+		ExecCredentials:       os.Getenv("RANCHER_EXEC_CREDENTIALS") == "true",
+		ExecCommand:           os.Getenv("RANCHER_EXEC_COMMAND"),
+		OIDCIssuerURL:         os.Getenv("RANCHER_OIDC_ISSUER_URL"),
+		OIDCClientID:          os.Getenv("RANCHER_OIDC_CLIENT_ID"),
+		OIDCClientSecret:      os.Getenv("RANCHER_OIDC_CLIENT_SECRET"),
+		OIDCExtraScopes:       SplitList(os.Getenv("RANCHER_OIDC_EXTRA_SCOPES")),
+		OIDCAuthProvider:      os.Getenv("RANCHER_OIDC_AUTH_PROVIDER") == "true",
+		ClusterProxyURL:       os.Getenv("RANCHER_CLUSTER_PROXY_URL"),
+		ClusterProxyRules:     SplitList(os.Getenv("RANCHER_CLUSTER_PROXY_RULES")),
+		ClusterCAs:            SplitList(os.Getenv("RANCHER_CLUSTER_CAS")),
+		ClusterTLSServerNames: SplitList(os.Getenv("RANCHER_CLUSTER_TLS_SERVER_NAMES")),
+		ClusterInsecure:       SplitList(os.Getenv("RANCHER_CLUSTER_INSECURE")),
+		Harvester:             HarvesterMode(os.Getenv("RANCHER_HARVESTER")),
+	}
+	cfg.envErrors = problems
+	cfg.loadSecretFiles(func(setting string) string { return "RANCHER_" + setting })
+	return cfg
+}
+
+// SplitList parses a comma-separated list, trimming items and dropping blanks
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SplitCommand parses a command line given as a JSON array, for arguments
+// containing spaces, or as words separated by spaces
+func SplitCommand(value string) []string {
+	var command []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") && json.Unmarshal([]byte(value), &command) == nil {
+		return command
+	}
+	return strings.Fields(value)
+}
+
+// ParseDuration parses a duration like time.ParseDuration, also accepting a
+// whole number of days, e.g. "30d"
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// envInt reads an integer environment variable, returning 0 if it is unset.
+// An invalid value is added to problems, for Validate to report.
+func envInt(key string, problems *[]error) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*problems = append(*problems, fmt.Errorf("invalid %s %q: must be a whole number", key, value))
+		return 0
+	}
+	return n
+}
+
+// envDuration reads a duration environment variable (e.g. "90s"), returning 0
+// if it is unset. An invalid value is added to problems, for Validate to report.
+func envDuration(key string, problems *[]error) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		*problems = append(*problems, fmt.Errorf("invalid %s %q: must be a duration such as 90s or 5m", key, value))
+		return 0
 	}
+	return d
 }
 
 // GetBasicAuth returns the basic auth credentials for the Rancher API
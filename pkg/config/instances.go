@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// InstanceNames returns the Rancher instances listed in RANCHER_INSTANCES
+// (comma-separated), or nil when a single instance is configured
+func InstanceNames() []string {
+	return SplitInstanceNames(os.Getenv("RANCHER_INSTANCES"))
+}
+
+// SplitInstanceNames parses a comma-separated list of instance names, dropping blanks
+func SplitInstanceNames(value string) []string {
+	return SplitList(value)
+}
+
+// InstanceEnvKey returns the environment variable holding a setting for a named
+// instance, e.g. InstanceEnvKey("prod-eu", "URL") is RANCHER_PROD_EU_URL
+func InstanceEnvKey(name, setting string) string {
+	key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name))
+	return "RANCHER_" + key + "_" + setting
+}
+
+// LoadInstance builds the configuration of a named Rancher instance from its
+// RANCHER_<NAME>_* environment variables, reading secrets from the files
+// named by RANCHER_<NAME>_TOKEN_FILE and the like. Connection tuning is inherited from
+// base, but the URL and credentials never are, so that one instance's token
+// cannot leak to another. Neither is the impersonated user, whose ID differs
+// between Rancher servers. The cluster prefix defaults to "<name>-", or to the
+// bare name when a separator is configured.
+func LoadInstance(base *Config, name string) *Config {
+	cfg := &Config{
+		Name:                  name,
+		RancherURL:            os.Getenv(InstanceEnvKey(name, "URL")),
+		AccessKey:             os.Getenv(InstanceEnvKey(name, "ACCESS_KEY")),
+		SecretKey:             os.Getenv(InstanceEnvKey(name, "SECRET_KEY")),
+		Token:                 os.Getenv(InstanceEnvKey(name, "TOKEN")),
+		Username:              os.Getenv(InstanceEnvKey(name, "USERNAME")),
+		Password:              os.Getenv(InstanceEnvKey(name, "PASSWORD")),
+		AuthProvider:          os.Getenv(InstanceEnvKey(name, "AUTH_PROVIDER")),
+		CredentialSource:      os.Getenv(InstanceEnvKey(name, "CREDENTIAL_SOURCE")),
+		CredentialCommand:     SplitCommand(os.Getenv(InstanceEnvKey(name, "CREDENTIAL_COMMAND"))),
+		AsUser:                os.Getenv(InstanceEnvKey(name, "AS_USER")),
+		ClusterPrefix:         name + "-",
+		ClusterSuffix:         base.ClusterSuffix,
+		ClusterSeparator:      base.ClusterSeparator,
+		NameTemplate:          base.NameTemplate,
+		SanitizeNames:         base.SanitizeNames,
+		MaxNameLength:         base.MaxNameLength,
+		ConflictStrategy:      base.ConflictStrategy,
+		OutputPath:            base.OutputPath,
+		SplitDir:              base.SplitDir,
+		SplitLabel:            base.SplitLabel,
+		CurrentContext:        base.CurrentContext,
+		Flatten:               base.Flatten,
+		Minify:                base.Minify,
+		EncryptRecipients:     base.EncryptRecipients,
+		EncryptFormat:         base.EncryptFormat,
+		InsecureSkipTLSVerify: base.InsecureSkipTLSVerify,
+		CACert:                base.CACert,
+		CACertData:            base.CACertData,
+		ExcludeSystemCAs:      base.ExcludeSystemCAs,
+		MaxIdleConnsPerHost:   base.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       base.MaxConnsPerHost,
+		IdleConnTimeout:       base.IdleConnTimeout,
+		DisableHTTP2:          base.DisableHTTP2,
+		DisableKeepAlives:     base.DisableKeepAlives,
+		MaxResponseSize:       base.MaxResponseSize,
+		RetryMaxWait:          base.RetryMaxWait,
+		WaitForRancher:        base.WaitForRancher,
+		BreakerThreshold:      base.BreakerThreshold,
+		DebugHTTP:             base.DebugHTTP,
+		ClusterStates:         base.ClusterStates,
+		IncludeAllStates:      base.IncludeAllStates,
+		OlderThan:             base.OlderThan,
+		NewerThan:             base.NewerThan,
+		EphemeralLabel:        base.EphemeralLabel,
+		EphemeralNamePattern:  base.EphemeralNamePattern,
+		IncludeEphemeral:      base.IncludeEphemeral,
+		IncludeClusters:       base.IncludeClusters,
+		ExcludeClusters:       base.ExcludeClusters,
+		Clusters:              base.Clusters,
+		StateField:            base.StateField,
+		NameField:             base.NameField,
+		KubeconfigActionField: base.KubeconfigActionField,
+		IncludeSystemProjects: base.IncludeSystemProjects,
+		ContextNamespaces:     base.ContextNamespaces,
+		ProjectNamespaces:     base.ProjectNamespaces,
+		Harvester:             base.Harvester,
+		ScopedTokens:          base.ScopedTokens,
+		ScopedTokenTTL:        base.ScopedTokenTTL,
+		ExecCredentials:       base.ExecCredentials,
+		ExecCommand:           base.ExecCommand,
+		OIDCIssuerURL:         base.OIDCIssuerURL,
+		OIDCClientID:          base.OIDCClientID,
+		OIDCClientSecret:      base.OIDCClientSecret,
+		OIDCExtraScopes:       base.OIDCExtraScopes,
+		OIDCAuthProvider:      base.OIDCAuthProvider,
+		ClusterProxyURL:       base.ClusterProxyURL,
+		ClusterProxyRules:     base.ClusterProxyRules,
+		ClusterCAs:            base.ClusterCAs,
+		ClusterTLSServerNames: base.ClusterTLSServerNames,
+		ClusterInsecure:       base.ClusterInsecure,
+	}
+
+	if cfg.ClusterSeparator != "" {
+		cfg.ClusterPrefix = name
+	}
+	if prefix, ok := os.LookupEnv(InstanceEnvKey(name, "CLUSTER_PREFIX")); ok {
+		cfg.ClusterPrefix = prefix
+	}
+	if suffix, ok := os.LookupEnv(InstanceEnvKey(name, "CLUSTER_SUFFIX")); ok {
+		cfg.ClusterSuffix = suffix
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "INSECURE_SKIP_TLS_VERIFY")); ok {
+		cfg.InsecureSkipTLSVerify = value == "true"
+	}
+	if value := os.Getenv(InstanceEnvKey(name, "CA_CERT")); value != "" {
+		cfg.CACert = value
+	}
+	if value := os.Getenv(InstanceEnvKey(name, "CA_CERT_DATA")); value != "" {
+		cfg.CACertData = value
+	}
+	// Each Rancher server may front clusters trusting their own identity provider
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "OIDC_ISSUER_URL")); ok {
+		cfg.OIDCIssuerURL = value
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "OIDC_CLIENT_ID")); ok {
+		cfg.OIDCClientID = value
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "OIDC_CLIENT_SECRET")); ok {
+		cfg.OIDCClientSecret = value
+	} else {
+		// The client secret of base is reloaded from its file like base's
+		cfg.secretFiles = slices.DeleteFunc(slices.Clone(base.secretFiles), func(f secretFile) bool {
+			return f.setting != "OIDC_CLIENT_SECRET"
+		})
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "STATE_FIELD")); ok {
+		cfg.StateField = value
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "NAME_FIELD")); ok {
+		cfg.NameField = value
+	}
+	if value, ok := os.LookupEnv(InstanceEnvKey(name, "KUBECONFIG_ACTION_FIELD")); ok {
+		cfg.KubeconfigActionField = value
+	}
+	cfg.loadSecretFiles(func(setting string) string { return InstanceEnvKey(name, setting) })
+
+	return cfg
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AppName is the directory the tool keeps its files in, under the user's
+// configuration, cache and state directories
+const AppName = "kubeconfig-wrangler"
+
+// ConfigDir returns the directory of the configuration file and the profiles
+// saved by login: $RANCHER_CONFIG_DIR, else kubeconfig-wrangler in
+// $XDG_CONFIG_HOME or the platform's user configuration directory
+func ConfigDir() (string, error) {
+	return userDir("RANCHER_CONFIG_DIR", "XDG_CONFIG_HOME", os.UserConfigDir)
+}
+
+// CacheDir returns the directory of data that can be fetched again, such as
+// the tokens handed to kubectl: $RANCHER_CACHE_DIR, else kubeconfig-wrangler in
+// $XDG_CACHE_HOME or the platform's user cache directory
+func CacheDir() (string, error) {
+	return userDir("RANCHER_CACHE_DIR", "XDG_CACHE_HOME", os.UserCacheDir)
+}
+
+// StateDir returns the directory of the state kept between runs, such as the
+// tokens the tool created: $RANCHER_STATE_DIR, else kubeconfig-wrangler in
+// $XDG_STATE_HOME or ~/.local/state (the configuration directory on macOS, the
+// local application data on Windows)
+func StateDir() (string, error) {
+	return userDir("RANCHER_STATE_DIR", "XDG_STATE_HOME", userStateDir)
+}
+
+// userDir returns the directory named by the override variable, else the
+// application's directory under the XDG variable or the platform default
+func userDir(override, xdg string, platform func() (string, error)) (string, error) {
+	if dir := os.Getenv(override); dir != "" {
+		return dir, nil
+	}
+	if base := os.Getenv(xdg); filepath.IsAbs(base) {
+		return filepath.Join(base, AppName), nil
+	}
+	base, err := platform()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the user directory, set %s: %w", override, err)
+	}
+	return filepath.Join(base, AppName), nil
+}
+
+// userStateDir returns the platform's directory for user state, which only
+// the XDG specification distinguishes from configuration and cache
+func userStateDir() (string, error) {
+	switch runtime.GOOS {
+	case "darwin", "ios":
+		return os.UserConfigDir()
+	case "windows":
+		return os.UserCacheDir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
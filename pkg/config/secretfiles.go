@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// secretSettings are the settings holding secrets. Each may instead be read
+// from the file named by its *_FILE variable, e.g. RANCHER_TOKEN_FILE, as
+// mounted from a Kubernetes Secret or a Docker secret.
+var secretSettings = []string{"TOKEN", "ACCESS_KEY", "SECRET_KEY", "PASSWORD", "OIDC_CLIENT_SECRET"}
+
+// secretFile is a secret setting read from a file
+type secretFile struct {
+	setting string
+	path    string
+
+	// value is the secret last read, telling whether a flag has since replaced it
+	value string
+}
+
+// SecretEnv returns the value of the environment variable key, or else the
+// content of the file named by key_FILE, without its trailing newline
+func SecretEnv(key string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	value, err := readSecretFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	return value, nil
+}
+
+// readSecretFile reads a secret from a file, without its trailing newline
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// secretField returns the field of a secret setting
+func (c *Config) secretField(setting string) *string {
+	switch setting {
+	case "TOKEN":
+		return &c.Token
+	case "ACCESS_KEY":
+		return &c.AccessKey
+	case "SECRET_KEY":
+		return &c.SecretKey
+	case "PASSWORD":
+		return &c.Password
+	case "OIDC_CLIENT_SECRET":
+		return &c.OIDCClientSecret
+	}
+	panic("unknown secret setting " + setting)
+}
+
+// loadSecretFiles reads the secrets whose variable, named by key, is unset
+// from the file named by its *_FILE variable. Unreadable files are reported
+// by Validate.
+func (c *Config) loadSecretFiles(key func(setting string) string) {
+	for _, setting := range secretSettings {
+		name := key(setting)
+		path := os.Getenv(name + "_FILE")
+		if path == "" || os.Getenv(name) != "" {
+			continue
+		}
+		value, err := readSecretFile(path)
+		if err != nil {
+			c.envErrors = append(c.envErrors, fmt.Errorf("%s_FILE: %w", name, err))
+			continue
+		}
+		*c.secretField(setting) = value
+		c.secretFiles = slices.DeleteFunc(c.secretFiles, func(f secretFile) bool { return f.setting == setting })
+		c.secretFiles = append(c.secretFiles, secretFile{setting: setting, path: path, value: value})
+	}
+}
+
+// HasSecretFiles reports whether a secret was read from a file
+func (c *Config) HasSecretFiles() bool {
+	return len(c.secretFiles) > 0
+}
+
+// ReloadSecretFiles reads the secret files again, e.g. after Kubernetes
+// updated a mounted Secret, and reports whether a secret changed. Secrets
+// since replaced, e.g. by a flag, are left alone. A new token replaces the
+// access and secret keys taken from the previous one.
+func (c *Config) ReloadSecretFiles() (bool, error) {
+	changed := false
+	for i, f := range c.secretFiles {
+		field := c.secretField(f.setting)
+		if *field != f.value {
+			continue
+		}
+		value, err := readSecretFile(f.path)
+		if err != nil {
+			return changed, err
+		}
+		if value == f.value {
+			continue
+		}
+		*field, c.secretFiles[i].value, changed = value, value, true
+		if access, secret, ok := strings.Cut(value, ":"); ok && f.setting == "TOKEN" {
+			c.AccessKey, c.SecretKey = access, secret
+		}
+	}
+	return changed, nil
+}
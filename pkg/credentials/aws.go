@@ -0,0 +1,72 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// loadAWSConfig loads the AWS configuration the way the AWS CLI does, from
+// the environment, the shared configuration files or the role of the
+// CodeBuild project, Lambda function or instance. A secret given by ARN is
+// read in the region of the ARN.
+func loadAWSConfig(ctx context.Context, secret string) (aws.Config, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if parts := strings.SplitN(secret, ":", 6); len(parts) == 6 && parts[0] == "arn" && parts[3] != "" {
+		opts = append(opts, awsconfig.WithRegion(parts[3]))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// fetchSecretsManager reads the credentials from an AWS Secrets Manager
+// secret, given by name or ARN. The secret holds either the bare token or a
+// JSON object with the token and CA fields.
+func fetchSecretsManager(ctx context.Context, secret string, fields fieldNames) (*Credentials, error) {
+	cfg, err := loadAWSConfig(ctx, secret)
+	if err != nil {
+		return nil, err
+	}
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secret),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AWS Secrets Manager secret %s: %w", secret, err)
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("AWS Secrets Manager secret %s is binary; store the token as a string", secret)
+	}
+	return fields.fromValue(secret, *out.SecretString)
+}
+
+// fetchParameterStore reads the credentials from an AWS Systems Manager
+// parameter, decrypting a SecureString. Hierarchical names may leave out the
+// leading slash, so that aws-ssm://rancher/prod/token names /rancher/prod/token.
+func fetchParameterStore(ctx context.Context, name string, fields fieldNames) (*Credentials, error) {
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "arn:") {
+		name = "/" + name
+	}
+	cfg, err := loadAWSConfig(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AWS SSM parameter %s: %w", name, err)
+	}
+	if out.Parameter == nil || out.Parameter.Value == nil {
+		return nil, fmt.Errorf("AWS SSM parameter %s has no value", name)
+	}
+	return fields.fromValue(name, *out.Parameter.Value)
+}
@@ -0,0 +1,84 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// awsServer answers GetSecretValue and GetParameter with the values keyed by
+// secret ID or parameter name, and points the AWS SDK at itself
+func awsServer(t *testing.T, values map[string]string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			SecretId       string
+			Name           string
+			WithDecryption bool
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if value, ok := values[in.SecretId]; ok {
+				json.NewEncoder(w).Encode(map[string]any{"Name": in.SecretId, "SecretString": value})
+				return
+			}
+		case "AmazonSSM.GetParameter":
+			if value, ok := values[in.Name]; ok && in.WithDecryption {
+				json.NewEncoder(w).Encode(map[string]any{"Parameter": map[string]any{"Name": in.Name, "Value": value}})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+	t.Setenv("AWS_ENDPOINT_URL_SSM", server.URL)
+}
+
+func TestFetch_SecretsManager(t *testing.T) {
+	awsServer(t, map[string]string{
+		"rancher/prod/token": "token-abc:secret\n",
+		"rancher/prod/json":  `{"token":"token-def:secret","ca":"PEM"}`,
+	})
+
+	creds, err := Fetch(context.Background(), "aws-sm://rancher/prod/token")
+	if err != nil || creds.Token != "token-abc:secret" {
+		t.Errorf("Fetch() of a bare token = %+v, %v", creds, err)
+	}
+	creds, err = Fetch(context.Background(), "aws-sm://rancher/prod/json")
+	if err != nil || creds.Token != "token-def:secret" || creds.CACertData != "PEM" {
+		t.Errorf("Fetch() of a JSON secret = %+v, %v", creds, err)
+	}
+	if _, err := Fetch(context.Background(), "aws-sm://rancher/prod/missing"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
+
+func TestFetch_ParameterStore(t *testing.T) {
+	awsServer(t, map[string]string{"/rancher/prod/token": "token-abc:secret"})
+
+	for _, source := range []string{"aws-ssm://rancher/prod/token", "aws-ssm:/rancher/prod/token"} {
+		creds, err := Fetch(context.Background(), source)
+		if err != nil || creds.Token != "token-abc:secret" {
+			t.Errorf("Fetch(%q) = %+v, %v", source, creds, err)
+		}
+	}
+}
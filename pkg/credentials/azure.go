@@ -0,0 +1,178 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// azureKeyVaultScope is the OAuth scope of the Key Vault data plane
+	azureKeyVaultScope = "https://vault.azure.net/.default"
+
+	// azureKeyVaultAPIVersion is the version of the Key Vault REST API
+	azureKeyVaultAPIVersion = "7.4"
+
+	// azureIMDSEndpoint is the instance metadata endpoint handing out managed identity tokens
+	azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureKeyVaultURL returns the base URL of the vault at host
+var azureKeyVaultURL = func(host string) string {
+	return "https://" + host
+}
+
+// fetchAzureKeyVault reads the credentials from an Azure Key Vault secret,
+// given as <vault>/<secret>[/<version>]. The vault is its name, or its host
+// name outside the public cloud. The secret holds either the bare token or a
+// JSON object with the token and CA fields.
+func fetchAzureKeyVault(ctx context.Context, ref string, fields fieldNames) (*Credentials, error) {
+	parts := strings.Split(strings.Trim(ref, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid Azure Key Vault secret %q: expected <vault>/<secret>[/<version>]", ref)
+	}
+	host := parts[0]
+	if !strings.Contains(host, ".") {
+		host += ".vault.azure.net"
+	}
+	secretURL := azureKeyVaultURL(host) + "/secrets/" + url.PathEscape(parts[1])
+	if len(parts) == 3 {
+		secretURL += "/" + url.PathEscape(parts[2])
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	token, err := azureToken(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL+"?api-version="+azureKeyVaultAPIVersion, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(client, req, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read Azure Key Vault secret %s: %w", ref, err)
+	}
+	return fields.fromValue(ref, secret.Value)
+}
+
+// azureToken obtains an access token for Key Vault like the Azure SDKs'
+// default credential does: a service principal secret, workload identity,
+// then the managed identity of the VM, App Service or Function
+func azureToken(ctx context.Context, client *http.Client) (string, error) {
+	tenant, clientID := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID")
+	if tenant != "" && clientID != "" {
+		form := url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "scope": {azureKeyVaultScope}}
+		switch {
+		case os.Getenv("AZURE_CLIENT_SECRET") != "":
+			form.Set("client_secret", os.Getenv("AZURE_CLIENT_SECRET"))
+			return azureTokenRequest(ctx, client, tenant, form)
+		case os.Getenv("AZURE_FEDERATED_TOKEN_FILE") != "":
+			assertion, err := os.ReadFile(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+			if err != nil {
+				return "", fmt.Errorf("failed to read AZURE_FEDERATED_TOKEN_FILE: %w", err)
+			}
+			form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+			form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+			return azureTokenRequest(ctx, client, tenant, form)
+		}
+	}
+	return azureManagedIdentityToken(ctx, client, clientID)
+}
+
+// azureTokenRequest requests a token from Microsoft Entra ID for a service principal
+func azureTokenRequest(ctx context.Context, client *http.Client, tenant string, form url.Values) (string, error) {
+	authority := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authority == "" {
+		authority = "https://login.microsoftonline.com"
+	}
+	endpoint := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(client, req, &token); err != nil {
+		return "", fmt.Errorf("failed to get an Azure token for client %s: %w", form.Get("client_id"), err)
+	}
+	return token.AccessToken, nil
+}
+
+// azureManagedIdentityToken requests a token for the managed identity, from
+// the App Service endpoint if there is one, else from the instance metadata
+func azureManagedIdentityToken(ctx context.Context, client *http.Client, clientID string) (string, error) {
+	resource := strings.TrimSuffix(azureKeyVaultScope, "/.default")
+	query := url.Values{"resource": {resource}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	// Fail fast where no metadata endpoint answers
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var req *http.Request
+	var err error
+	if endpoint := os.Getenv("IDENTITY_ENDPOINT"); endpoint != "" {
+		query.Set("api-version", "2019-08-01")
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err == nil {
+			req.Header.Set("X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER"))
+		}
+	} else {
+		query.Set("api-version", "2018-02-01")
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, azureIMDSEndpoint+"?"+query.Encode(), nil)
+		if err == nil {
+			req.Header.Set("Metadata", "true")
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(client, req, &token); err != nil {
+		return "", fmt.Errorf("no Azure credentials: set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, or run with a managed identity (%w)", err)
+	}
+	return token.AccessToken, nil
+}
+
+// doJSON sends req and decodes the JSON response into out, failing on an
+// error status with the message of the response
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(data))
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		return fmt.Errorf("status %d: %s", resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
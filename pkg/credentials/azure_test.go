@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch_AzureKeyVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant-1/oauth2/v2.0/token":
+			if r.FormValue("client_secret") != "sp-secret" || r.FormValue("scope") != azureKeyVaultScope {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"kv-token","token_type":"Bearer"}`))
+		case "/secrets/rancher-token", "/secrets/rancher-token/v2":
+			if r.Header.Get("Authorization") != "Bearer kv-token" || r.URL.Query().Get("api-version") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"value":"token-abc:secret","id":"https://prod.vault.azure.net/secrets/rancher-token/v2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"SecretNotFound"}}`))
+		}
+	}))
+	defer server.Close()
+
+	var hosts []string
+	defer func(original func(string) string) { azureKeyVaultURL = original }(azureKeyVaultURL)
+	azureKeyVaultURL = func(host string) string {
+		hosts = append(hosts, host)
+		return server.URL
+	}
+	t.Setenv("AZURE_AUTHORITY_HOST", server.URL)
+	t.Setenv("AZURE_TENANT_ID", "tenant-1")
+	t.Setenv("AZURE_CLIENT_ID", "client-1")
+	t.Setenv("AZURE_CLIENT_SECRET", "sp-secret")
+
+	for _, source := range []string{"azure-kv://prod/rancher-token", "azure-kv://prod/rancher-token/v2"} {
+		creds, err := Fetch(context.Background(), source)
+		if err != nil || creds.Token != "token-abc:secret" {
+			t.Errorf("Fetch(%q) = %+v, %v", source, creds, err)
+		}
+	}
+	if len(hosts) == 0 || hosts[0] != "prod.vault.azure.net" {
+		t.Errorf("vault hosts = %v, want prod.vault.azure.net", hosts)
+	}
+
+	if _, err := Fetch(context.Background(), "azure-kv://prod/missing"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+	if _, err := Fetch(context.Background(), "azure-kv://prod"); err == nil {
+		t.Error("expected an error for a reference without a secret")
+	}
+	t.Setenv("AZURE_CLIENT_SECRET", "wrong")
+	if _, err := Fetch(context.Background(), "azure-kv://prod/rancher-token"); err == nil {
+		t.Error("expected an error for a rejected service principal")
+	}
+}
@@ -0,0 +1,37 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+)
+
+// runCommand runs a credential helper, e.g. ["op", "read", "op://infra/rancher/token"],
+// and reads the credentials from its standard output: the bare token or a
+// JSON object with token and ca fields. The helper shares the terminal, so
+// that it can ask to be unlocked.
+func runCommand(ctx context.Context, command []string) (*Credentials, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("credential command is empty")
+	}
+	if err := noexec.Check("running credential command " + command[0]); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	var stdout bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential command %s failed: %w", command[0], err)
+	}
+	creds, err := fieldNames{token: "token", ca: "ca"}.fromValue("printed by "+command[0], strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("credential command: %w", err)
+	}
+	return creds, nil
+}
@@ -0,0 +1,45 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+)
+
+func TestRunCommand(t *testing.T) {
+	creds, err := runCommand(context.Background(), []string{"echo", "token-abc:secret"})
+	if err != nil || creds.Token != "token-abc:secret" {
+		t.Errorf("runCommand(echo) = %+v, %v", creds, err)
+	}
+	creds, err = runCommand(context.Background(), []string{"echo", `{"token":"token-def:secret","ca":"PEM"}`})
+	if err != nil || creds.Token != "token-def:secret" || creds.CACertData != "PEM" {
+		t.Errorf("runCommand() printing JSON = %+v, %v", creds, err)
+	}
+
+	if _, err := runCommand(context.Background(), []string{"false"}); err == nil {
+		t.Error("expected an error for a failing command")
+	}
+	if _, err := runCommand(context.Background(), []string{"true"}); err == nil {
+		t.Error("expected an error for a command printing nothing")
+	}
+
+	t.Setenv("RANCHER_NO_EXEC", "true")
+	if _, err := runCommand(context.Background(), []string{"echo", "token"}); !errors.Is(err, noexec.ErrDisabled) {
+		t.Errorf("runCommand() in no-exec mode error = %v, want ErrDisabled", err)
+	}
+}
+
+func TestApply_CommandAndSource(t *testing.T) {
+	cfg := &config.Config{CredentialSource: "vault:secret/rancher", CredentialCommand: []string{"echo", "token"}}
+	if err := Apply(context.Background(), cfg); err == nil {
+		t.Error("expected an error when both a source and a command are set")
+	}
+
+	cfg = &config.Config{CredentialCommand: []string{"echo", "token-abc:secret"}}
+	if err := Apply(context.Background(), cfg); err != nil || cfg.Token != "token-abc:secret" {
+		t.Errorf("Apply() = %q, %v", cfg.Token, err)
+	}
+}
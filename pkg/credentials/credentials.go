@@ -0,0 +1,122 @@
+// Package credentials fetches the Rancher credentials from external secret
+// stores, so that the token need not be handed to the tool in plain text
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+// Credentials are the Rancher credentials read from a credential source
+type Credentials struct {
+	// Token is the Rancher API token (access_key:secret_key)
+	Token string
+
+	// CACertData is PEM-encoded CA certificates of the Rancher server (empty if none are stored)
+	CACertData string
+}
+
+// Fetch reads the credentials from source, given as <scheme>:<secret> or
+// <scheme>://<secret>, e.g. vault:secret/data/ci/rancher or
+// aws-sm://rancher/prod/token. Query parameters name the fields holding the
+// token and the CA, e.g. vault:secret/data/ci/rancher?token=api_token&ca=ca_pem.
+func Fetch(ctx context.Context, source string) (*Credentials, error) {
+	scheme, ref, ok := strings.Cut(source, ":")
+	ref = strings.TrimPrefix(ref, "//")
+	if !ok || ref == "" {
+		return nil, fmt.Errorf("invalid credential source %q: expected <scheme>:<secret>", source)
+	}
+	secret, fields := ref, fieldNames{token: "token", ca: "ca"}
+	if path, query, ok := strings.Cut(ref, "?"); ok {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential source %q: %w", source, err)
+		}
+		secret = path
+		if values.Has("token") {
+			fields.token = values.Get("token")
+		}
+		if values.Has("ca") {
+			fields.ca = values.Get("ca")
+		}
+	}
+
+	switch scheme {
+	case "vault":
+		return fetchVault(ctx, secret, fields)
+	case "aws-sm":
+		return fetchSecretsManager(ctx, secret, fields)
+	case "aws-ssm":
+		return fetchParameterStore(ctx, secret, fields)
+	case "azure-kv":
+		return fetchAzureKeyVault(ctx, secret, fields)
+	case "gcp-sm":
+		return fetchGCPSecretManager(ctx, secret, fields)
+	default:
+		return nil, fmt.Errorf("unsupported credential source %q: scheme must be vault, aws-sm, aws-ssm, azure-kv or gcp-sm", source)
+	}
+}
+
+// fieldNames are the fields of a secret holding the token and the CA
+type fieldNames struct {
+	token string
+	ca    string
+}
+
+// fromFields picks the credentials out of the fields of a secret
+func (f fieldNames) fromFields(secret string, data map[string]any) (*Credentials, error) {
+	token, _ := data[f.token].(string)
+	if token == "" {
+		return nil, fmt.Errorf("secret %s has no %q field", secret, f.token)
+	}
+	ca, _ := data[f.ca].(string)
+	return &Credentials{Token: strings.TrimSpace(token), CACertData: ca}, nil
+}
+
+// fromValue picks the credentials out of a secret stored as a string: a JSON
+// object is read like the fields of a secret, anything else is the token
+func (f fieldNames) fromValue(secret, value string) (*Credentials, error) {
+	var data map[string]any
+	if err := json.Unmarshal([]byte(value), &data); err == nil {
+		return f.fromFields(secret, data)
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return nil, fmt.Errorf("secret %s is empty", secret)
+	}
+	return &Credentials{Token: value}, nil
+}
+
+// Apply fills the credentials of cfg, unless some are configured, from its
+// credential source or credential command, or else from the .netrc entry of
+// the Rancher host. A CA read along with them is trusted in addition to
+// those configured.
+func Apply(ctx context.Context, cfg *config.Config) error {
+	if cfg.Token != "" || cfg.AccessKey != "" || cfg.Username != "" {
+		return nil
+	}
+	var creds *Credentials
+	var err error
+	switch {
+	case cfg.CredentialSource != "" && len(cfg.CredentialCommand) > 0:
+		return fmt.Errorf("set either a credential source or a credential command, not both")
+	case cfg.CredentialSource != "":
+		creds, err = Fetch(ctx, cfg.CredentialSource)
+	case len(cfg.CredentialCommand) > 0:
+		creds, err = runCommand(ctx, cfg.CredentialCommand)
+	default:
+		return applyNetrc(cfg)
+	}
+	if err != nil {
+		return err
+	}
+	cfg.Token = creds.Token
+	if creds.CACertData != "" {
+		cfg.CACertData = strings.TrimSpace(cfg.CACertData + "\n" + creds.CACertData)
+	}
+	return nil
+}
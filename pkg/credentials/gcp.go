@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// gcpSecretManagerURL is the base URL of the Secret Manager API
+var gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+
+// fetchGCPSecretManager reads the credentials from a Google Secret Manager
+// secret version, given by resource name, projects/<project>/secrets/<secret>
+// [/versions/<version>], or as <project>/<secret>[/<version>]. The latest
+// version is read unless another is named. The secret holds either the bare
+// token or a JSON object with the token and CA fields.
+func fetchGCPSecretManager(ctx context.Context, ref string, fields fieldNames) (*Credentials, error) {
+	name, err := gcpSecretVersionName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Application Default Credentials: GOOGLE_APPLICATION_CREDENTIALS, the
+	// gcloud login or the service account of the Cloud Build, Run or GCE host
+	tokens, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, fmt.Errorf("no Google credentials: %w", err)
+	}
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a Google access token: %w", err)
+	}
+	return readGCPSecret(ctx, name, token, fields)
+}
+
+// readGCPSecret accesses the secret version name with token
+func readGCPSecret(ctx context.Context, name string, token *oauth2.Token, fields fieldNames) (*Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerURL+name+":access", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	token.SetAuthHeader(req)
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(&http.Client{Timeout: 30 * time.Second}, req, &version); err != nil {
+		return nil, fmt.Errorf("failed to read Google secret %s: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Google secret %s: %w", name, err)
+	}
+	return fields.fromValue(name, string(data))
+}
+
+// gcpSecretVersionName returns the resource name of the secret version ref refers to
+func gcpSecretVersionName(ref string) (string, error) {
+	parts := strings.Split(strings.Trim(ref, "/"), "/")
+	if parts[0] == "projects" {
+		switch {
+		case len(parts) == 4 && parts[2] == "secrets":
+			parts = []string{parts[1], parts[3]}
+		case len(parts) == 6 && parts[2] == "secrets" && parts[4] == "versions":
+			parts = []string{parts[1], parts[3], parts[5]}
+		default:
+			return "", fmt.Errorf("invalid Google secret %q: expected projects/<project>/secrets/<secret>[/versions/<version>]", ref)
+		}
+	}
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid Google secret %q: expected <project>/<secret>[/<version>]", ref)
+	}
+	version := "latest"
+	if len(parts) == 3 && parts[2] != "" {
+		version = parts[2]
+	}
+	return "projects/" + parts[0] + "/secrets/" + parts[1] + "/versions/" + version, nil
+}
@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGCPSecretVersionName(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"my-project/rancher-token", "projects/my-project/secrets/rancher-token/versions/latest", false},
+		{"my-project/rancher-token/3", "projects/my-project/secrets/rancher-token/versions/3", false},
+		{"projects/my-project/secrets/rancher-token", "projects/my-project/secrets/rancher-token/versions/latest", false},
+		{"projects/my-project/secrets/rancher-token/versions/7", "projects/my-project/secrets/rancher-token/versions/7", false},
+		{"my-project", "", true},
+		{"projects/my-project/rancher-token", "", true},
+	}
+	for _, tt := range tests {
+		got, err := gcpSecretVersionName(tt.ref)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("gcpSecretVersionName(%q) = %q, %v; want %q, error %t", tt.ref, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadGCPSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/p/secrets/rancher/versions/latest:access" || r.Header.Get("Authorization") != "Bearer gcp-token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		payload := base64.StdEncoding.EncodeToString([]byte(`{"token":"token-abc:secret","ca":"PEM"}`))
+		w.Write([]byte(`{"name":"projects/p/secrets/rancher/versions/1","payload":{"data":"` + payload + `"}}`))
+	}))
+	defer server.Close()
+	defer func(original string) { gcpSecretManagerURL = original }(gcpSecretManagerURL)
+	gcpSecretManagerURL = server.URL + "/"
+
+	token := &oauth2.Token{AccessToken: "gcp-token", TokenType: "Bearer"}
+	fields := fieldNames{token: "token", ca: "ca"}
+	creds, err := readGCPSecret(context.Background(), "projects/p/secrets/rancher/versions/latest", token, fields)
+	if err != nil || creds.Token != "token-abc:secret" || creds.CACertData != "PEM" {
+		t.Errorf("readGCPSecret() = %+v, %v", creds, err)
+	}
+	if _, err := readGCPSecret(context.Background(), "projects/p/secrets/other/versions/latest", token, fields); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
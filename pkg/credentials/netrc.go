@@ -0,0 +1,131 @@
+package credentials
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+// NetrcEntry is the login and password of one machine in a .netrc file
+type NetrcEntry struct {
+	Login    string
+	Password string
+}
+
+// netrcPath returns the .netrc file to read: $NETRC, else ~/.netrc (~/_netrc
+// on Windows, if it exists)
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(filepath.Join(home, "_netrc")); err == nil {
+			return filepath.Join(home, "_netrc")
+		}
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// LookupNetrc returns the entry of host in the .netrc file at path, or its
+// default entry, like curl does. A missing file has no entries.
+func LookupNetrc(path, host string) (*NetrcEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read netrc: %w", err)
+	}
+	defer f.Close()
+
+	var found, fallback *NetrcEntry
+	var current *NetrcEntry
+	var inMacro bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// A macro definition runs until the next blank line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				current = nil
+				if i+1 < len(fields) {
+					i++
+					if strings.EqualFold(fields[i], host) && found == nil {
+						found = &NetrcEntry{}
+						current = found
+					}
+				}
+			case "default":
+				current = nil
+				if fallback == nil {
+					fallback = &NetrcEntry{}
+					current = fallback
+				}
+			case "login", "password", "account":
+				if i+1 >= len(fields) {
+					continue
+				}
+				i++
+				if current == nil {
+					continue
+				}
+				if fields[i-1] == "login" {
+					current.Login = fields[i]
+				} else if fields[i-1] == "password" {
+					current.Password = fields[i]
+				}
+			case "macdef":
+				current = nil
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read netrc: %w", err)
+	}
+	if found != nil {
+		return found, nil
+	}
+	return fallback, nil
+}
+
+// applyNetrc fills the credentials of cfg from the .netrc entry of the
+// Rancher host. A login starting with "token-" is an API access key and its
+// password the secret key; any other login is a username and password.
+func applyNetrc(cfg *config.Config) error {
+	u, err := url.Parse(cfg.RancherURL)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	path := netrcPath()
+	if path == "" {
+		return nil
+	}
+	entry, err := LookupNetrc(path, u.Hostname())
+	if err != nil || entry == nil || entry.Login == "" || entry.Password == "" {
+		return err
+	}
+	if strings.HasPrefix(entry.Login, "token-") {
+		cfg.AccessKey, cfg.SecretKey = entry.Login, entry.Password
+	} else {
+		cfg.Username, cfg.Password = entry.Login, entry.Password
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+const sampleNetrc = `machine github.com login octocat password gh-secret
+
+macdef init
+machine rancher.example.com login bogus password bogus
+
+machine rancher.example.com
+  login token-abc12
+  password key-secret
+machine lab.example.com login admin password lab-pw
+default login anonymous password guest
+`
+
+func writeNetrc(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(path, []byte(sampleNetrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookupNetrc(t *testing.T) {
+	path := writeNetrc(t)
+	tests := []struct {
+		host            string
+		login, password string
+	}{
+		{"rancher.example.com", "token-abc12", "key-secret"},
+		{"RANCHER.example.com", "token-abc12", "key-secret"},
+		{"lab.example.com", "admin", "lab-pw"},
+		{"other.example.com", "anonymous", "guest"},
+	}
+	for _, tt := range tests {
+		entry, err := LookupNetrc(path, tt.host)
+		if err != nil || entry == nil || entry.Login != tt.login || entry.Password != tt.password {
+			t.Errorf("LookupNetrc(%q) = %+v, %v; want %s/%s", tt.host, entry, err, tt.login, tt.password)
+		}
+	}
+
+	entry, err := LookupNetrc(filepath.Join(t.TempDir(), "missing"), "rancher.example.com")
+	if err != nil || entry != nil {
+		t.Errorf("LookupNetrc() of a missing file = %+v, %v", entry, err)
+	}
+}
+
+func TestApply_Netrc(t *testing.T) {
+	t.Setenv("NETRC", writeNetrc(t))
+
+	cfg := &config.Config{RancherURL: "https://rancher.example.com:8443"}
+	if err := Apply(context.Background(), cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if cfg.AccessKey != "token-abc12" || cfg.SecretKey != "key-secret" {
+		t.Errorf("access and secret key = %q, %q", cfg.AccessKey, cfg.SecretKey)
+	}
+
+	cfg = &config.Config{RancherURL: "https://lab.example.com"}
+	if err := Apply(context.Background(), cfg); err != nil || cfg.Username != "admin" || cfg.Password != "lab-pw" {
+		t.Errorf("username and password = %q, %q, %v", cfg.Username, cfg.Password, err)
+	}
+
+	cfg = &config.Config{RancherURL: "https://rancher.example.com", Token: "token-given:secret"}
+	if err := Apply(context.Background(), cfg); err != nil || cfg.AccessKey != "" {
+		t.Errorf("Apply() with a token filled the access key %q, %v", cfg.AccessKey, err)
+	}
+}
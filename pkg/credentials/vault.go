@@ -0,0 +1,186 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultServiceAccountTokenPath is where Kubernetes mounts the token of the pod's service account
+const defaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultClient reads secrets from HashiCorp Vault, configured by the
+// environment variables of the vault CLI
+type vaultClient struct {
+	addr       string
+	namespace  string
+	token      string
+	httpClient *http.Client
+}
+
+// vaultResponse is the envelope of Vault API responses
+type vaultResponse struct {
+	Data map[string]any `json:"data"`
+	Auth *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// fetchVault reads the credentials from the Vault KV secret at path, the API
+// path of the secret, e.g. secret/data/ci/rancher for a KV version 2 engine
+// mounted at secret/
+func fetchVault(ctx context.Context, path string, fields fieldNames) (*Credentials, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := client.do(ctx, http.MethodGet, strings.Trim(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret %s: %w", path, err)
+	}
+
+	data := resp.Data
+	// KV version 2 nests the fields of the secret next to its metadata
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	return fields.fromFields(path, data)
+}
+
+// newVaultClient configures a client from VAULT_ADDR, VAULT_NAMESPACE,
+// VAULT_CACERT and VAULT_SKIP_VERIFY
+func newVaultClient() (*vaultClient, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is required to read credentials from Vault")
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: os.Getenv("VAULT_SKIP_VERIFY") == "true"}
+	if path := os.Getenv("VAULT_CACERT"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_CACERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &vaultClient{
+		addr:       addr,
+		namespace:  os.Getenv("VAULT_NAMESPACE"),
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// authenticate obtains a Vault token: VAULT_TOKEN, else a Kubernetes auth
+// login when RANCHER_VAULT_K8S_ROLE is set, else the token the vault CLI
+// saved in ~/.vault-token
+func (c *vaultClient) authenticate(ctx context.Context) error {
+	if c.token = os.Getenv("VAULT_TOKEN"); c.token != "" {
+		return nil
+	}
+	if role := os.Getenv("RANCHER_VAULT_K8S_ROLE"); role != "" {
+		return c.kubernetesLogin(ctx, role)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			c.token = strings.TrimSpace(string(data))
+		}
+	}
+	if c.token == "" {
+		return fmt.Errorf("no Vault token: set VAULT_TOKEN, log in with \"vault login\" or set RANCHER_VAULT_K8S_ROLE for Kubernetes auth")
+	}
+	return nil
+}
+
+// kubernetesLogin logs in with the service account token of the pod, for
+// jobs running in a cluster whose service accounts Vault trusts
+func (c *vaultClient) kubernetesLogin(ctx context.Context, role string) error {
+	mount := os.Getenv("RANCHER_VAULT_K8S_MOUNT")
+	if mount == "" {
+		mount = "kubernetes"
+	}
+	tokenPath := os.Getenv("RANCHER_VAULT_K8S_TOKEN_PATH")
+	if tokenPath == "" {
+		tokenPath = defaultServiceAccountTokenPath
+	}
+	jwt, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the service account token for Vault Kubernetes auth: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{"role": role, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return fmt.Errorf("failed to encode Vault login: %w", err)
+	}
+	resp, err := c.do(ctx, http.MethodPost, "auth/"+strings.Trim(mount, "/")+"/login", body)
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault with Kubernetes auth role %s: %w", role, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault Kubernetes auth login for role %s returned no token", role)
+	}
+	c.token = resp.Auth.ClientToken
+	return nil
+}
+
+// do sends a request to the Vault API path, below /v1/
+func (c *vaultClient) do(ctx context.Context, method, path string, body []byte) (*vaultResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var decoded vaultResponse
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &decoded); err != nil && resp.StatusCode < 300 {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		if len(decoded.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(decoded.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+	return &decoded, nil
+}
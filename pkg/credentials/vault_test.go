@@ -0,0 +1,121 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+// vaultServer serves a KV version 2 secret at secret/data/ci/rancher to
+// requests carrying wantToken, and a Kubernetes auth login handing it out
+func vaultServer(t *testing.T, wantToken string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var login map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login["role"] != "ci" || login["jwt"] != "sa-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"` + wantToken + `"}}`))
+		case "/v1/secret/data/ci/rancher":
+			if r.Header.Get("X-Vault-Token") != wantToken {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"data":{"token":"token-abc:secret","api_token":"token-other:secret","ca":"PEM"},"metadata":{"version":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch_Vault(t *testing.T) {
+	server := vaultServer(t, "vault-token")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	creds, err := Fetch(context.Background(), "vault:secret/data/ci/rancher")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if creds.Token != "token-abc:secret" || creds.CACertData != "PEM" {
+		t.Errorf("Fetch() = %+v", creds)
+	}
+
+	creds, err = Fetch(context.Background(), "vault:secret/data/ci/rancher?token=api_token")
+	if err != nil || creds.Token != "token-other:secret" {
+		t.Errorf("Fetch() with a token field = %+v, %v", creds, err)
+	}
+
+	if _, err := Fetch(context.Background(), "vault:secret/data/ci/missing"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+	if _, err := Fetch(context.Background(), "vault:secret/data/ci/rancher?token=nope"); err == nil {
+		t.Error("expected an error for a missing token field")
+	}
+}
+
+func TestFetch_VaultKubernetesAuth(t *testing.T) {
+	server := vaultServer(t, "k8s-issued")
+	jwt := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwt, []byte("sa-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("RANCHER_VAULT_K8S_ROLE", "ci")
+	t.Setenv("RANCHER_VAULT_K8S_TOKEN_PATH", jwt)
+
+	creds, err := Fetch(context.Background(), "vault:secret/data/ci/rancher")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if creds.Token != "token-abc:secret" {
+		t.Errorf("Token = %q", creds.Token)
+	}
+
+	t.Setenv("RANCHER_VAULT_K8S_ROLE", "other")
+	if _, err := Fetch(context.Background(), "vault:secret/data/ci/rancher"); err == nil {
+		t.Error("expected an error for a rejected login")
+	}
+}
+
+func TestFetch_InvalidSource(t *testing.T) {
+	for _, source := range []string{"vault", "vault:", "keepass:rancher"} {
+		if _, err := Fetch(context.Background(), source); err == nil {
+			t.Errorf("Fetch(%q): expected an error", source)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	server := vaultServer(t, "vault-token")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	cfg := &config.Config{CredentialSource: "vault:secret/data/ci/rancher", CACertData: "OWN"}
+	if err := Apply(context.Background(), cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if cfg.Token != "token-abc:secret" || cfg.CACertData != "OWN\nPEM" {
+		t.Errorf("Token = %q, CACertData = %q", cfg.Token, cfg.CACertData)
+	}
+
+	cfg = &config.Config{CredentialSource: "vault:secret/data/ci/missing", Token: "token-given:secret"}
+	if err := Apply(context.Background(), cfg); err != nil || cfg.Token != "token-given:secret" {
+		t.Errorf("Apply() with a token = %q, %v; want the source left unread", cfg.Token, err)
+	}
+}
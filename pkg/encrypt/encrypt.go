@@ -0,0 +1,215 @@
+// Package encrypt protects generated kubeconfigs at rest, so they can be
+// distributed through git: with age (https://age-encryption.org), or with SOPS
+// using age keys, which keeps the YAML structure readable
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+)
+
+// Format is how a kubeconfig is encrypted
+type Format string
+
+const (
+	// FormatAge encrypts the whole file with age, ASCII-armored (the default)
+	FormatAge Format = "age"
+	// FormatSOPS encrypts the values of the YAML with the sops command
+	FormatSOPS Format = "sops"
+)
+
+// sopsCommand is the SOPS executable run for FormatSOPS
+var sopsCommand = "sops"
+
+// ageHeader starts every binary age file
+const ageHeader = "age-encryption.org/v1"
+
+// ParseFormat parses an --encrypt-format value, treating empty as age
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case "":
+		return FormatAge, nil
+	case FormatAge, FormatSOPS:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid encryption format %q (must be age or sops)", value)
+	}
+}
+
+// Detect tells how data is encrypted, reporting false for plaintext
+func Detect(data []byte) (Format, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte(armor.Header)) || bytes.HasPrefix(trimmed, []byte(ageHeader)) {
+		return FormatAge, true
+	}
+	var document struct {
+		SOPS map[string]any `json:"sops"`
+	}
+	if yaml.Unmarshal(data, &document) == nil && document.SOPS != nil {
+		return FormatSOPS, true
+	}
+	return "", false
+}
+
+// Encrypt encrypts data for every recipient. A recipient is an age public key
+// ("age1...") or the path of a file listing public keys, one per line.
+func Encrypt(format Format, data []byte, recipients []string) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients given")
+	}
+	keys, err := resolveRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatSOPS {
+		return encryptSOPS(data, keys)
+	}
+
+	parsed := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", key, err)
+		}
+		parsed = append(parsed, recipient)
+	}
+
+	var out bytes.Buffer
+	armored := armor.NewWriter(&out)
+	w, err := age.Encrypt(armored, parsed...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := armored.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt in either format, with the age
+// secret keys in identityFile
+func Decrypt(data []byte, identityFile string) ([]byte, error) {
+	format, ok := Detect(data)
+	if !ok {
+		return nil, errors.New("not encrypted with age or SOPS")
+	}
+	if format == FormatSOPS {
+		return decryptSOPS(data, identityFile)
+	}
+
+	if identityFile == "" {
+		return nil, errors.New("no file with age secret keys given")
+	}
+	f, err := os.Open(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identities: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identities in %s: %w", identityFile, err)
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return out, nil
+}
+
+// DefaultIdentityFile returns where SOPS looks for age secret keys:
+// $SOPS_AGE_KEY_FILE, or sops/age/keys.txt in the user configuration directory
+func DefaultIdentityFile() string {
+	if path := os.Getenv("SOPS_AGE_KEY_FILE"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sops", "age", "keys.txt")
+}
+
+// resolveRecipients expands the recipient files among recipients into the
+// public keys they list
+func resolveRecipients(recipients []string) ([]string, error) {
+	var keys []string
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+		if strings.HasPrefix(recipient, "age1") {
+			keys = append(keys, recipient)
+			continue
+		}
+		data, err := os.ReadFile(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q is neither an age public key nor a readable file: %w", recipient, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				keys = append(keys, line)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// encryptSOPS encrypts YAML with the sops command for the age public keys
+func encryptSOPS(data []byte, keys []string) ([]byte, error) {
+	return runSOPS(data, nil, "--encrypt", "--age", strings.Join(keys, ","))
+}
+
+// decryptSOPS decrypts a SOPS document with the sops command
+func decryptSOPS(data []byte, identityFile string) ([]byte, error) {
+	var env []string
+	if identityFile != "" {
+		env = append(env, "SOPS_AGE_KEY_FILE="+identityFile)
+	}
+	return runSOPS(data, env, "--decrypt")
+}
+
+// runSOPS runs sops on data passed through stdin
+func runSOPS(data []byte, env []string, args ...string) ([]byte, error) {
+	if err := noexec.Check("running " + sopsCommand); err != nil {
+		return nil, err
+	}
+	args = append(args, "--input-type", "yaml", "--output-type", "yaml", "/dev/stdin")
+	cmd := exec.Command(sopsCommand, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", sopsCommand, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", sopsCommand, err)
+	}
+	return stdout.Bytes(), nil
+}
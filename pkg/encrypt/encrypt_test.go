@@ -0,0 +1,128 @@
+package encrypt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+const plainKubeconfig = "apiVersion: v1\nkind: Config\ncurrent-context: prod\n"
+
+// newIdentity writes a new age secret key to a file and returns the file and
+// the matching public key
+func newIdentity(t *testing.T) (string, string) {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(path, []byte("# test key\n"+identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, identity.Recipient().String()
+}
+
+func TestParseFormat(t *testing.T) {
+	for input, want := range map[string]Format{"": FormatAge, "age": FormatAge, "SOPS": FormatSOPS} {
+		got, err := ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("gpg"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestEncryptDecrypt_Age(t *testing.T) {
+	identityFile, recipient := newIdentity(t)
+	_, other := newIdentity(t)
+
+	// A recipients file works like a key given directly
+	recipientsFile := filepath.Join(t.TempDir(), "recipients.txt")
+	if err := os.WriteFile(recipientsFile, []byte("# team\n"+other+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := Encrypt(FormatAge, []byte(plainKubeconfig), []string{recipient, recipientsFile})
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !strings.HasPrefix(string(encrypted), "-----BEGIN AGE ENCRYPTED FILE-----") {
+		t.Errorf("encrypted output is not ASCII-armored:\n%s", encrypted)
+	}
+	if strings.Contains(string(encrypted), "current-context") {
+		t.Error("encrypted output contains plaintext")
+	}
+	if format, ok := Detect(encrypted); !ok || format != FormatAge {
+		t.Errorf("Detect() = %q, %v, want age", format, ok)
+	}
+
+	decrypted, err := Decrypt(encrypted, identityFile)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(decrypted) != plainKubeconfig {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, plainKubeconfig)
+	}
+
+	wrongIdentity, _ := newIdentity(t)
+	if _, err := Decrypt(encrypted, wrongIdentity); err == nil {
+		t.Error("expected an error decrypting with a key that is not a recipient")
+	}
+}
+
+func TestEncrypt_Invalid(t *testing.T) {
+	if _, err := Encrypt(FormatAge, []byte(plainKubeconfig), nil); err == nil {
+		t.Error("expected an error without recipients")
+	}
+	if _, err := Encrypt(FormatAge, []byte(plainKubeconfig), []string{"age1invalid"}); err == nil {
+		t.Error("expected an error for an invalid public key")
+	}
+	if _, err := Encrypt(FormatAge, []byte(plainKubeconfig), []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing recipients file")
+	}
+	if _, err := Decrypt([]byte(plainKubeconfig), ""); err == nil {
+		t.Error("expected an error decrypting plaintext")
+	}
+}
+
+func TestEncryptDecrypt_SOPS(t *testing.T) {
+	// A stand-in for sops recording its arguments and environment
+	dir := t.TempDir()
+	script := filepath.Join(dir, "sops")
+	log := filepath.Join(dir, "log")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@ key=$SOPS_AGE_KEY_FILE\" >> "+log+"\ncat\necho 'sops:'\necho '  version: 3.9.0'\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer func(command string) { sopsCommand = command }(sopsCommand)
+	sopsCommand = script
+
+	encrypted, err := Encrypt(FormatSOPS, []byte(plainKubeconfig), []string{"age1abc", "age1def"})
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if format, ok := Detect(encrypted); !ok || format != FormatSOPS {
+		t.Errorf("Detect() = %q, %v, want sops", format, ok)
+	}
+	if _, err := Decrypt(encrypted, "/keys.txt"); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--encrypt --age age1abc,age1def --input-type yaml --output-type yaml /dev/stdin",
+		"--decrypt --input-type yaml --output-type yaml /dev/stdin key=/keys.txt",
+	} {
+		if !strings.Contains(string(calls), want) {
+			t.Errorf("sops calls %q lack %q", calls, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// IsEncryptedValue reports whether value is an age-encrypted value, as
+// written by EncryptValue
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), armor.Header)
+}
+
+// EncryptValue encrypts a single secret, e.g. a token of the configuration
+// file, into ASCII-armored age. It is encrypted for the recipients, age public
+// keys or files listing them, or else with the passphrase.
+func EncryptValue(value string, recipients []string, passphrase string) (string, error) {
+	if len(recipients) > 0 {
+		out, err := Encrypt(FormatAge, []byte(value), recipients)
+		return string(out), err
+	}
+	if passphrase == "" {
+		return "", errors.New("no recipients or passphrase given")
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	var out bytes.Buffer
+	armored := armor.NewWriter(&out)
+	w, err := age.Encrypt(armored, recipient)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if _, err := io.WriteString(w, value); err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := armored.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return out.String(), nil
+}
+
+// ValueDecrypter decrypts the values written by EncryptValue. The identities
+// and the passphrase are only read once a value needs them, so that nobody is
+// asked for a passphrase their configuration does not use.
+type ValueDecrypter struct {
+	// IdentityFile holds the age secret keys (empty or missing for none)
+	IdentityFile string
+
+	// Passphrase returns the passphrase of passphrase-encrypted values (nil for none)
+	Passphrase func() (string, error)
+
+	identities []age.Identity
+	loaded     bool
+	passphrase string
+}
+
+// Decrypt decrypts value, returning it unchanged if it is not encrypted
+func (d *ValueDecrypter) Decrypt(value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return value, nil
+	}
+	src := armor.NewReader(strings.NewReader(strings.TrimSpace(value)))
+	data, err := io.ReadAll(io.LimitReader(src, 1<<16))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	identities, err := d.identitiesFor(data)
+	if err != nil {
+		return "", err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(out), nil
+}
+
+// identitiesFor returns the identities able to decrypt the age file data:
+// the passphrase for a passphrase-encrypted file, else the secret keys
+func (d *ValueDecrypter) identitiesFor(data []byte) ([]age.Identity, error) {
+	if bytes.Contains(data, []byte("\n-> scrypt ")) {
+		if d.passphrase == "" {
+			if d.Passphrase == nil {
+				return nil, errors.New("value is encrypted with a passphrase, but none is given")
+			}
+			passphrase, err := d.Passphrase()
+			if err != nil {
+				return nil, err
+			}
+			d.passphrase = passphrase
+		}
+		identity, err := age.NewScryptIdentity(d.passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt: %w", err)
+		}
+		return []age.Identity{identity}, nil
+	}
+
+	if !d.loaded {
+		if d.IdentityFile == "" {
+			return nil, errors.New("value is encrypted for age keys, but no file with age secret keys is given")
+		}
+		f, err := os.Open(d.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identities: %w", err)
+		}
+		defer f.Close()
+		identities, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identities in %s: %w", d.IdentityFile, err)
+		}
+		d.identities, d.loaded = identities, true
+	}
+	return d.identities, nil
+}
@@ -0,0 +1,62 @@
+package encrypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptValue_Recipients(t *testing.T) {
+	identityFile, recipient := newIdentity(t)
+	encrypted, err := EncryptValue("token-abc:secret", []string{recipient}, "")
+	if err != nil {
+		t.Fatalf("EncryptValue() error = %v", err)
+	}
+	if !IsEncryptedValue(encrypted) || strings.Contains(encrypted, "secret") {
+		t.Fatalf("EncryptValue() = %q", encrypted)
+	}
+
+	d := &ValueDecrypter{IdentityFile: identityFile}
+	if got, err := d.Decrypt(encrypted); err != nil || got != "token-abc:secret" {
+		t.Errorf("Decrypt() = %q, %v", got, err)
+	}
+	if got, err := d.Decrypt("token-plain:secret"); err != nil || got != "token-plain:secret" {
+		t.Errorf("Decrypt() of a plaintext value = %q, %v", got, err)
+	}
+	if _, err := (&ValueDecrypter{}).Decrypt(encrypted); err == nil {
+		t.Error("expected an error without identities")
+	}
+}
+
+func TestEncryptValue_Passphrase(t *testing.T) {
+	encrypted, err := EncryptValue("hunter2", nil, "correct horse")
+	if err != nil {
+		t.Fatalf("EncryptValue() error = %v", err)
+	}
+
+	asked := 0
+	d := &ValueDecrypter{Passphrase: func() (string, error) {
+		asked++
+		return "correct horse", nil
+	}}
+	for range 2 {
+		if got, err := d.Decrypt(encrypted); err != nil || got != "hunter2" {
+			t.Errorf("Decrypt() = %q, %v", got, err)
+		}
+	}
+	if asked != 1 {
+		t.Errorf("passphrase asked %d times, want once", asked)
+	}
+
+	wrong := &ValueDecrypter{Passphrase: func() (string, error) { return "wrong", nil }}
+	if _, err := wrong.Decrypt(encrypted); err == nil {
+		t.Error("expected an error with a wrong passphrase")
+	}
+	failing := &ValueDecrypter{Passphrase: func() (string, error) { return "", errors.New("no terminal") }}
+	if _, err := failing.Decrypt(encrypted); err == nil {
+		t.Error("expected the passphrase error")
+	}
+	if _, err := EncryptValue("hunter2", nil, ""); err == nil {
+		t.Error("expected an error without recipients or passphrase")
+	}
+}
@@ -0,0 +1,130 @@
+// Package events carries progress and problems noticed by library code to the
+// program embedding it, which decides how to show them: on a terminal, in
+// structured logs, or not at all
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Kind is the severity of an event
+type Kind int
+
+const (
+	// Progress reports work being done, e.g. waiting for Rancher to come back
+	Progress Kind = iota
+	// Warning reports something skipped or degraded that the operation survived
+	Warning
+	// Error reports a failure the operation could not recover from
+	Error
+)
+
+// String returns the name of the kind
+func (k Kind) String() string {
+	switch k {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "progress"
+	}
+}
+
+// Event is a single report
+type Event struct {
+	Kind    Kind
+	Message string
+
+	// Cluster is the name of the cluster the event is about, if any
+	Cluster string
+
+	// Err is the error behind the event, if any
+	Err error
+}
+
+// Text renders the event as one line, the message followed by the error
+func (e Event) Text() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Reporter receives events. Implementations must be safe for concurrent use.
+type Reporter interface {
+	Report(event Event)
+}
+
+// ReporterFunc adapts a function to a Reporter
+type ReporterFunc func(event Event)
+
+// Report calls f
+func (f ReporterFunc) Report(event Event) {
+	f(event)
+}
+
+// Discard drops every event
+var Discard Reporter = ReporterFunc(func(Event) {})
+
+// Warnf reports a warning about cluster (empty if none)
+func Warnf(r Reporter, cluster, format string, args ...any) {
+	r.Report(Event{Kind: Warning, Message: fmt.Sprintf(format, args...), Cluster: cluster})
+}
+
+// Progressf reports progress
+func Progressf(r Reporter, format string, args ...any) {
+	r.Report(Event{Kind: Progress, Message: fmt.Sprintf(format, args...)})
+}
+
+// terminal writes events as lines of text
+type terminal struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewTerminal returns a reporter writing one line per event to out, with
+// warnings and errors prefixed as such, the way the CLI shows them on stderr
+func NewTerminal(out io.Writer) Reporter {
+	return &terminal{out: out}
+}
+
+// Report writes the event
+func (t *terminal) Report(event Event) {
+	prefix := ""
+	switch event.Kind {
+	case Warning:
+		prefix = "Warning: "
+	case Error:
+		prefix = "Error: "
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.out, "%s%s\n", prefix, event.Text())
+}
+
+// NewLogger returns a reporter logging events to logger, progress at info
+// level, with the cluster and error as attributes
+func NewLogger(logger *slog.Logger) Reporter {
+	return ReporterFunc(func(event Event) {
+		level := slog.LevelInfo
+		switch event.Kind {
+		case Warning:
+			level = slog.LevelWarn
+		case Error:
+			level = slog.LevelError
+		}
+		var attrs []any
+		if event.Cluster != "" {
+			attrs = append(attrs, slog.String("cluster", event.Cluster))
+		}
+		if event.Err != nil {
+			attrs = append(attrs, slog.String("error", event.Err.Error()))
+		}
+		logger.Log(context.Background(), level, event.Message, attrs...)
+	})
+}
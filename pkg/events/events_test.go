@@ -0,0 +1,41 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestNewTerminal(t *testing.T) {
+	var out bytes.Buffer
+	r := NewTerminal(&out)
+	Progressf(r, "Waiting for Rancher at %s", "https://rancher.example.com")
+	Warnf(r, "prod", "skipping Harvester cluster %s", "prod")
+	r.Report(Event{Kind: Error, Message: "skipping cluster broken", Cluster: "broken", Err: errors.New("status 500")})
+
+	want := "Waiting for Rancher at https://rancher.example.com\n" +
+		"Warning: skipping Harvester cluster prod\n" +
+		"Error: skipping cluster broken: status 500\n"
+	if got := out.String(); got != want {
+		t.Errorf("terminal output = %q, want %q", got, want)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var out bytes.Buffer
+	r := NewLogger(slog.New(slog.NewJSONHandler(&out, nil)))
+	r.Report(Event{Kind: Warning, Message: "skipping cluster broken", Cluster: "broken", Err: errors.New("status 500")})
+
+	var record map[string]string
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("log line %q is not JSON: %v", out.String(), err)
+	}
+	want := map[string]string{"level": "WARN", "msg": "skipping cluster broken", "cluster": "broken", "error": "status 500"}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %q, want %q", key, record[key], value)
+		}
+	}
+}
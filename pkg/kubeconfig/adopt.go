@@ -0,0 +1,101 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Ways Adopt matched a context to a Rancher cluster
+const (
+	AdoptedByClusterID = "cluster ID"
+	AdoptedByServer    = "server URL"
+)
+
+// Adoption is a context Adopt marked as generated from a Rancher cluster
+type Adoption struct {
+	Context   string
+	ClusterID string
+	// MatchedBy is AdoptedByClusterID or AdoptedByServer
+	MatchedBy string
+}
+
+// Adopt marks the contexts of config that belong to a cluster of the Rancher
+// server at rancherURL, and their clusters, as generated from it, so that
+// generate --merge-into updates them and Prune removes them once the cluster
+// is deleted. A context belongs to a cluster when its server goes through the
+// Rancher proxy with the cluster's ID, or is one of the URLs in servers, which
+// lists the other servers of each cluster by ID. Contexts already generated
+// from a Rancher server are left alone.
+func Adopt(config *api.Config, rancherURL string, servers map[string][]string) ([]Adoption, error) {
+	rancherURL = strings.TrimSuffix(rancherURL, "/")
+	byServer := make(map[string]string)
+	for _, id := range sortedKeys(servers) {
+		for _, server := range servers[id] {
+			if _, taken := byServer[normalizeServer(server)]; !taken {
+				byServer[normalizeServer(server)] = id
+			}
+		}
+	}
+
+	var adopted []Adoption
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return nil, fmt.Errorf("context %s: %w", name, err)
+		}
+		cluster, ok := config.Clusters[context.Cluster]
+		if provenance.Source == generatedSource || !ok {
+			continue
+		}
+
+		adoption := Adoption{Context: name}
+		if id, ok := strings.CutPrefix(cluster.Server, rancherURL+rancherProxyPath); ok {
+			id = strings.Trim(id, "/")
+			if _, listed := servers[id]; listed {
+				adoption.ClusterID, adoption.MatchedBy = id, AdoptedByClusterID
+			}
+		} else if id, ok := byServer[normalizeServer(cluster.Server)]; ok {
+			adoption.ClusterID, adoption.MatchedBy = id, AdoptedByServer
+		}
+		if adoption.ClusterID == "" {
+			continue
+		}
+
+		provenance.Source, provenance.RancherURL, provenance.ClusterID = generatedSource, rancherURL, adoption.ClusterID
+		if err := SetProvenance(context, provenance); err != nil {
+			return nil, fmt.Errorf("context %s: %w", name, err)
+		}
+		clusterProvenance, _, err := GetClusterProvenance(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", context.Cluster, err)
+		}
+		clusterProvenance.Source, clusterProvenance.RancherURL, clusterProvenance.ClusterID = generatedSource, rancherURL, adoption.ClusterID
+		if err := SetClusterProvenance(cluster, clusterProvenance); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", context.Cluster, err)
+		}
+		adopted = append(adopted, adoption)
+	}
+	return adopted, nil
+}
+
+// normalizeServer reduces a server URL to its lowercase scheme, host and
+// port, with the default port filled in, and its path without trailing slash
+func normalizeServer(server string) string {
+	u, err := url.Parse(strings.TrimSpace(server))
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(server, "/")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if scheme == "http" {
+			port = "80"
+		}
+	}
+	return scheme + "://" + strings.ToLower(u.Hostname()) + ":" + port + strings.TrimSuffix(u.Path, "/")
+}
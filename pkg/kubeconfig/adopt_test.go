@@ -0,0 +1,65 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestAdopt(t *testing.T) {
+	config := api.NewConfig()
+	add := func(name, server string) {
+		config.Clusters[name] = &api.Cluster{Server: server}
+		config.AuthInfos[name] = &api.AuthInfo{Token: "t-" + name}
+		config.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name}
+	}
+	add("prod", "https://rancher.example.com/k8s/clusters/c-m-prod")
+	add("prod-direct", "https://K8S.prod.example.com/")
+	add("gone", "https://rancher.example.com/k8s/clusters/c-m-gone")
+	add("other-rancher", "https://rancher2.example.com/k8s/clusters/c-m-prod")
+	add("minikube", "https://192.168.49.2:8443")
+	MergeInto(config, rancherConfig(t, "stage"))
+
+	adopted, err := Adopt(config, "https://rancher.example.com/", map[string][]string{
+		"c-m-prod": {"https://10.0.0.10:6443", "https://k8s.prod.example.com:443"},
+		"stage":    nil,
+	})
+	if err != nil {
+		t.Fatalf("Adopt() error = %v", err)
+	}
+	want := []Adoption{
+		{Context: "prod", ClusterID: "c-m-prod", MatchedBy: AdoptedByClusterID},
+		{Context: "prod-direct", ClusterID: "c-m-prod", MatchedBy: AdoptedByServer},
+	}
+	if len(adopted) != len(want) {
+		t.Fatalf("Adopt() = %+v, want %+v", adopted, want)
+	}
+	for i := range want {
+		if adopted[i] != want[i] {
+			t.Errorf("adoption %d = %+v, want %+v", i, adopted[i], want[i])
+		}
+	}
+
+	for _, name := range []string{"prod", "prod-direct"} {
+		provenance, _, _ := GetProvenance(config.Contexts[name])
+		if provenance.Source != generatedSource || provenance.RancherURL != "https://rancher.example.com" || provenance.ClusterID != "c-m-prod" {
+			t.Errorf("provenance of %s = %+v", name, provenance)
+		}
+		clusterProvenance, _, _ := GetClusterProvenance(config.Clusters[name])
+		if clusterProvenance.ClusterID != "c-m-prod" {
+			t.Errorf("cluster provenance of %s = %+v", name, clusterProvenance)
+		}
+	}
+	for _, name := range []string{"gone", "other-rancher", "minikube"} {
+		if provenance, _, _ := GetProvenance(config.Contexts[name]); provenance.Source != "" {
+			t.Errorf("context %s was adopted: %+v", name, provenance)
+		}
+	}
+
+	// Adopted contexts are replaced by generate --merge-into instead of clashing
+	generated := rancherConfig(t, "c-m-prod")
+	generated.Contexts["prod-direct"] = generated.Contexts["c-m-prod"]
+	if _, err := UpdateManaged(config, generated); err != nil {
+		t.Errorf("UpdateManaged() after adoption error = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// GetProvenance returns the provenance recorded on a context, and whether there is any
+func GetProvenance(context *api.Context) (Provenance, bool, error) {
+	return decodeProvenance(context.Extensions)
+}
+
+// AddNote appends a note to a context, keeping the rest of its provenance
+func AddNote(context *api.Context, note string) error {
+	provenance, _, err := GetProvenance(context)
+	if err != nil {
+		return err
+	}
+	provenance.Notes = append(provenance.Notes, note)
+	return SetProvenance(context, provenance)
+}
+
+// ClearNotes removes the notes of a context, and the extension when nothing else is left in it
+func ClearNotes(context *api.Context) error {
+	provenance, ok, err := GetProvenance(context)
+	if err != nil || !ok {
+		return err
+	}
+	provenance.Notes = nil
+	if provenance.Source == "" && provenance.File == "" && provenance.RancherURL == "" && provenance.ClusterID == "" && provenance.ImportedAt.IsZero() && provenance.GeneratedAt.IsZero() {
+		delete(context.Extensions, ProvenanceExtension)
+		return nil
+	}
+	return SetProvenance(context, provenance)
+}
+
+// ContextNotes returns the notes of a context, ignoring an unreadable extension
+func ContextNotes(context *api.Context) []string {
+	provenance, _, _ := GetProvenance(context)
+	return provenance.Notes
+}
+
+// CarryOverNotes copies the notes of the contexts of previous to the contexts of
+// the same name in config that have none, so regenerating a kubeconfig keeps
+// the annotations made on the earlier one. It returns the contexts updated.
+func CarryOverNotes(config, previous *api.Config) ([]string, error) {
+	var updated []string
+	for _, name := range sortedKeys(config.Contexts) {
+		old, ok := previous.Contexts[name]
+		if !ok || len(ContextNotes(config.Contexts[name])) > 0 {
+			continue
+		}
+		notes := ContextNotes(old)
+		if len(notes) == 0 {
+			continue
+		}
+		for _, note := range notes {
+			if err := AddNote(config.Contexts[name], note); err != nil {
+				return updated, fmt.Errorf("context %s: %w", name, err)
+			}
+		}
+		updated = append(updated, name)
+	}
+	return updated, nil
+}
+
+// ClusterNotes collects the notes of the contexts going through the Rancher
+// proxy, keyed by Rancher cluster ID
+func ClusterNotes(config *api.Config) map[string][]string {
+	notes := make(map[string][]string)
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		contextNotes := ContextNotes(context)
+		cluster, ok := config.Clusters[context.Cluster]
+		if len(contextNotes) == 0 || !ok {
+			continue
+		}
+		idx := strings.Index(cluster.Server, rancherProxyPath)
+		if idx < 0 {
+			continue
+		}
+		id := strings.Trim(cluster.Server[idx+len(rancherProxyPath):], "/")
+		notes[id] = append(notes[id], contextNotes...)
+	}
+	return notes
+}
@@ -0,0 +1,86 @@
+package kubeconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestAddNote_RoundTrip(t *testing.T) {
+	config := api.NewConfig()
+	config.Clusters["prod"] = &api.Cluster{Server: "https://rancher.example.com/k8s/clusters/c-m-abc123"}
+	config.Contexts["prod"] = &api.Context{Cluster: "prod"}
+
+	if err := AddNote(config.Contexts["prod"], "owned by team-x"); err != nil {
+		t.Fatalf("AddNote() error = %v", err)
+	}
+	if err := AddNote(config.Contexts["prod"], "pager: #team-x-oncall"); err != nil {
+		t.Fatalf("AddNote() error = %v", err)
+	}
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	loaded, err := clientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	want := []string{"owned by team-x", "pager: #team-x-oncall"}
+	if got := ContextNotes(loaded.Contexts["prod"]); !reflect.DeepEqual(got, want) {
+		t.Errorf("ContextNotes() = %v, want %v", got, want)
+	}
+	if got := ClusterNotes(loaded)["c-m-abc123"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClusterNotes()[c-m-abc123] = %v, want %v", got, want)
+	}
+
+	if err := ClearNotes(loaded.Contexts["prod"]); err != nil {
+		t.Fatalf("ClearNotes() error = %v", err)
+	}
+	if _, ok := loaded.Contexts["prod"].Extensions[ProvenanceExtension]; ok {
+		t.Error("ClearNotes() left an empty extension behind")
+	}
+}
+
+func TestClearNotes_KeepsProvenance(t *testing.T) {
+	context := &api.Context{Cluster: "prod"}
+	if err := SetProvenance(context, Provenance{Source: "normalize", Notes: []string{"old"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClearNotes(context); err != nil {
+		t.Fatalf("ClearNotes() error = %v", err)
+	}
+	provenance, ok, err := GetProvenance(context)
+	if err != nil || !ok || provenance.Source != "normalize" || provenance.Notes != nil {
+		t.Errorf("GetProvenance() = %+v, %v, %v; want the source kept and no notes", provenance, ok, err)
+	}
+}
+
+func TestCarryOverNotes(t *testing.T) {
+	previous := api.NewConfig()
+	previous.Contexts["prod"] = &api.Context{Cluster: "prod"}
+	previous.Contexts["gone"] = &api.Context{Cluster: "gone"}
+	AddNote(previous.Contexts["prod"], "owned by team-x")
+	AddNote(previous.Contexts["gone"], "decommissioned")
+
+	config := api.NewConfig()
+	config.Contexts["prod"] = &api.Context{Cluster: "prod"}
+	config.Contexts["stage"] = &api.Context{Cluster: "stage"}
+
+	updated, err := CarryOverNotes(config, previous)
+	if err != nil {
+		t.Fatalf("CarryOverNotes() error = %v", err)
+	}
+	if !reflect.DeepEqual(updated, []string{"prod"}) {
+		t.Errorf("updated = %v, want [prod]", updated)
+	}
+	if got := ContextNotes(config.Contexts["prod"]); !reflect.DeepEqual(got, []string{"owned by team-x"}) {
+		t.Errorf("prod notes = %v", got)
+	}
+	if got := ContextNotes(config.Contexts["stage"]); got != nil {
+		t.Errorf("stage notes = %v, want none", got)
+	}
+}
@@ -0,0 +1,46 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Combine merges kubeconfigs that were generated separately, e.g. one per Rancher
+// instance. Each input is expected to be uniquely named already (typically via a
+// per-instance prefix); clashing cluster, context or user names are an error
+// rather than being silently overwritten.
+func Combine(configs ...*api.Config) (*api.Config, error) {
+	combined := api.NewConfig()
+	var clashes []string
+
+	for _, config := range configs {
+		for name, cluster := range config.Clusters {
+			if _, exists := combined.Clusters[name]; exists {
+				clashes = append(clashes, "cluster "+name)
+			}
+			combined.Clusters[name] = cluster
+		}
+		for name, context := range config.Contexts {
+			if _, exists := combined.Contexts[name]; exists {
+				clashes = append(clashes, "context "+name)
+			}
+			combined.Contexts[name] = context
+		}
+		for name, authInfo := range config.AuthInfos {
+			if _, exists := combined.AuthInfos[name]; exists {
+				clashes = append(clashes, "user "+name)
+			}
+			combined.AuthInfos[name] = authInfo
+		}
+	}
+
+	if len(clashes) > 0 {
+		sort.Strings(clashes)
+		return nil, fmt.Errorf("duplicate names across kubeconfigs (use distinct prefixes): %s", strings.Join(clashes, ", "))
+	}
+
+	return combined, nil
+}
@@ -0,0 +1,43 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	dev, err := NewGenerator("dev-").MergeConfigs(map[string]string{"app": sampleKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	prod, err := NewGenerator("prod-").MergeConfigs(map[string]string{"app": sampleKubeconfig2})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	combined, err := Combine(dev, prod)
+	if err != nil {
+		t.Fatalf("Combine() error = %v", err)
+	}
+	for _, name := range []string{"dev-app", "prod-app"} {
+		if _, ok := combined.Contexts[name]; !ok {
+			t.Errorf("expected context %q", name)
+		}
+		if _, ok := combined.Clusters[name]; !ok {
+			t.Errorf("expected cluster %q", name)
+		}
+	}
+}
+
+func TestCombine_Clash(t *testing.T) {
+	a, _ := NewGenerator("").MergeConfigs(map[string]string{"app": sampleKubeconfig})
+	b, _ := NewGenerator("").MergeConfigs(map[string]string{"app": sampleKubeconfig2})
+
+	_, err := Combine(a, b)
+	if err == nil {
+		t.Fatal("expected an error for clashing names")
+	}
+	if !strings.Contains(err.Error(), "context app") {
+		t.Errorf("error %q should name the clashing context", err)
+	}
+}
@@ -0,0 +1,110 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ConflictStrategy decides what happens when two clusters end up with the
+// same cluster, context or user name in the merged kubeconfig
+type ConflictStrategy string
+
+const (
+	// ConflictError fails the merge (the default)
+	ConflictError ConflictStrategy = "error"
+	// ConflictSkip keeps the cluster merged first and leaves out the other
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the entries of the cluster merged first
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictRename numbers the names of the cluster merged last, e.g. prod-2
+	ConflictRename ConflictStrategy = "rename"
+)
+
+// ParseConflictStrategy parses a --on-conflict value, treating empty as error
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(strings.ToLower(value)); strategy {
+	case "":
+		return ConflictError, nil
+	case ConflictError, ConflictSkip, ConflictOverwrite, ConflictRename:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid conflict strategy %q (must be error, skip, overwrite or rename)", value)
+	}
+}
+
+// Conflict is a name clash resolved while merging
+type Conflict struct {
+	// Name is the name both clusters produced
+	Name string
+	// Existing is the cluster merged first
+	Existing string
+	// Cluster is the cluster merged last, whose entries were skipped,
+	// overwrote those of Existing or were renamed
+	Cluster string
+	// Resolution is the strategy applied
+	Resolution ConflictStrategy
+	// RenamedTo is the name Cluster got instead, with ConflictRename
+	RenamedTo string
+}
+
+// String describes the conflict and how it was resolved
+func (c Conflict) String() string {
+	switch c.Resolution {
+	case ConflictSkip:
+		return fmt.Sprintf("clusters %s and %s are both named %q: left out %s", c.Existing, c.Cluster, c.Name, c.Cluster)
+	case ConflictOverwrite:
+		return fmt.Sprintf("clusters %s and %s are both named %q: %s replaced %s", c.Existing, c.Cluster, c.Name, c.Cluster, c.Existing)
+	case ConflictRename:
+		return fmt.Sprintf("clusters %s and %s are both named %q: renamed %s to %q", c.Existing, c.Cluster, c.Name, c.Cluster, c.RenamedTo)
+	default:
+		return fmt.Sprintf("clusters %s and %s are both named %q", c.Existing, c.Cluster, c.Name)
+	}
+}
+
+// SetConflictStrategy sets how name clashes between clusters are resolved
+// when merging; empty fails the merge
+func (g *Generator) SetConflictStrategy(strategy ConflictStrategy) {
+	g.conflictStrategy = strategy
+}
+
+// Conflicts returns the name clashes resolved by the last MergeConfigs
+func (g *Generator) Conflicts() []Conflict {
+	return g.conflicts
+}
+
+// clashingName returns the first cluster, context or user name of config that
+// is already taken in merged
+func clashingName(merged, config *api.Config) (string, bool) {
+	for _, name := range sortedKeys(config.Contexts) {
+		if _, ok := merged.Contexts[name]; ok {
+			return name, true
+		}
+	}
+	for _, name := range sortedKeys(config.Clusters) {
+		if _, ok := merged.Clusters[name]; ok {
+			return name, true
+		}
+	}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		if _, ok := merged.AuthInfos[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// removeOwnedBy removes the entries merged for clusterName, so that a cluster
+// overwriting it leaves none of them behind
+func removeOwnedBy(merged *api.Config, owners map[string]string, clusterName string) {
+	for name, owner := range owners {
+		if owner != clusterName {
+			continue
+		}
+		delete(merged.Clusters, name)
+		delete(merged.Contexts, name)
+		delete(merged.AuthInfos, name)
+		delete(owners, name)
+	}
+}
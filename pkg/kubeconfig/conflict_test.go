@@ -0,0 +1,107 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConflictStrategy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ConflictStrategy
+		wantErr bool
+	}{
+		{"", ConflictError, false},
+		{"error", ConflictError, false},
+		{"skip", ConflictSkip, false},
+		{"Overwrite", ConflictOverwrite, false},
+		{"rename", ConflictRename, false},
+		{"merge", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseConflictStrategy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseConflictStrategy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseConflictStrategy(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// clashingKubeconfigs gives cluster prod two contexts, prod and prod-1, and
+// another cluster the name prod-1
+var clashingKubeconfigs = map[string]string{"prod": aceKubeconfig, "prod-1": sampleKubeconfig}
+
+func TestGenerator_MergeConfigs_Conflicts(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		g := NewGenerator("")
+		_, err := g.MergeConfigs(clashingKubeconfigs)
+		if err == nil || !strings.Contains(err.Error(), `clusters prod and prod-1 are both named "prod-1"`) {
+			t.Errorf("MergeConfigs() error = %v, want a conflict", err)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		g := NewGenerator("")
+		g.SetConflictStrategy(ConflictSkip)
+		config, err := g.MergeConfigs(clashingKubeconfigs)
+		if err != nil {
+			t.Fatalf("MergeConfigs() error = %v", err)
+		}
+		if got := sortedKeys(config.Contexts); strings.Join(got, ",") != "prod,prod-1" {
+			t.Errorf("contexts = %v, want prod,prod-1", got)
+		}
+		if server := config.Clusters["prod-1"].Server; server != "https://10.0.0.10:6443" {
+			t.Errorf("prod-1 server = %s, want the endpoint of cluster prod", server)
+		}
+		conflicts := g.Conflicts()
+		if len(conflicts) != 1 || conflicts[0].Existing != "prod" || conflicts[0].Cluster != "prod-1" {
+			t.Errorf("Conflicts() = %+v", conflicts)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		g := NewGenerator("")
+		g.SetConflictStrategy(ConflictOverwrite)
+		config, err := g.MergeConfigs(clashingKubeconfigs)
+		if err != nil {
+			t.Fatalf("MergeConfigs() error = %v", err)
+		}
+		if got := sortedKeys(config.Contexts); strings.Join(got, ",") != "prod-1" {
+			t.Errorf("contexts = %v, want only prod-1", got)
+		}
+		if len(config.Clusters) != 1 || len(config.AuthInfos) != 1 {
+			t.Errorf("entries of cluster prod are left: clusters %v, users %v", sortedKeys(config.Clusters), sortedKeys(config.AuthInfos))
+		}
+		if server := config.Clusters["prod-1"].Server; server != "https://cluster1.example.com:6443" {
+			t.Errorf("prod-1 server = %s, want the server of cluster prod-1", server)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		g := NewGenerator("")
+		g.SetConflictStrategy(ConflictRename)
+		config, err := g.MergeConfigs(clashingKubeconfigs)
+		if err != nil {
+			t.Fatalf("MergeConfigs() error = %v", err)
+		}
+		if got := sortedKeys(config.Contexts); strings.Join(got, ",") != "prod,prod-1,prod-1-2" {
+			t.Errorf("contexts = %v, want prod,prod-1,prod-1-2", got)
+		}
+		context := config.Contexts["prod-1-2"]
+		if context.Cluster != "prod-1-2" || config.Clusters["prod-1-2"].Server != "https://cluster1.example.com:6443" {
+			t.Errorf("renamed context points at %s", context.Cluster)
+		}
+		if _, ok := config.AuthInfos[context.AuthInfo]; !ok {
+			t.Errorf("renamed context references missing user %s", context.AuthInfo)
+		}
+		conflicts := g.Conflicts()
+		if len(conflicts) != 1 || conflicts[0].RenamedTo != "prod-1-2" {
+			t.Errorf("Conflicts() = %+v", conflicts)
+		}
+		if want := `renamed prod-1 to "prod-1-2"`; !strings.Contains(conflicts[0].String(), want) {
+			t.Errorf("String() = %q, want it to contain %q", conflicts[0].String(), want)
+		}
+	})
+}
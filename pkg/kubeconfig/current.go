@@ -0,0 +1,90 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// SetCurrentContext makes the context named by pattern the current context:
+// the context of that exact name, or else the first context, in name order,
+// whose name matches pattern as a glob, e.g. "prod-*". It returns the name of
+// the context chosen.
+func SetCurrentContext(config *api.Config, pattern string) (string, error) {
+	if _, ok := config.Contexts[pattern]; ok {
+		config.CurrentContext = pattern
+		return pattern, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid context pattern %q: %w", pattern, err)
+	}
+	for _, name := range sortedKeys(config.Contexts) {
+		if ok, _ := path.Match(pattern, name); ok {
+			config.CurrentContext = name
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no context matches %q", pattern)
+}
+
+// MatchContext finds the context a query names among names, the way kubectx
+// users expect: the exact name, else the only name starting with the query,
+// else the only one containing it, else the only one holding its characters
+// in order, so "peu" finds "prod-eu". All but the exact match ignore case. A
+// query matching several names at the first step it matches at is an error
+// listing them.
+func MatchContext(names []string, query string) (string, error) {
+	if slices.Contains(names, query) {
+		return query, nil
+	}
+	lower := strings.ToLower(query)
+	steps := []func(name string) bool{
+		func(name string) bool { return strings.HasPrefix(name, lower) },
+		func(name string) bool { return strings.Contains(name, lower) },
+		func(name string) bool { return isSubsequence(lower, name) },
+	}
+	for _, matches := range steps {
+		var found []string
+		for _, name := range names {
+			if matches(strings.ToLower(name)) {
+				found = append(found, name)
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		}
+		return "", fmt.Errorf("%q matches several contexts: %s", query, strings.Join(found, ", "))
+	}
+	return "", fmt.Errorf("no context matches %q", query)
+}
+
+// isSubsequence reports whether the characters of sub appear in s in order
+func isSubsequence(sub, s string) bool {
+	for _, r := range s {
+		if sub == "" {
+			break
+		}
+		if first, size := utf8.DecodeRuneInString(sub); r == first {
+			sub = sub[size:]
+		}
+	}
+	return sub == ""
+}
+
+// CarryOverCurrentContext keeps the current context of the previous version
+// of a kubeconfig, e.g. one chosen with "kubectl config use-context", if the
+// context still exists. It reports whether it did.
+func CarryOverCurrentContext(config, previous *api.Config) bool {
+	if _, ok := config.Contexts[previous.CurrentContext]; !ok {
+		return false
+	}
+	config.CurrentContext = previous.CurrentContext
+	return true
+}
@@ -0,0 +1,83 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func contextsNamed(names ...string) *api.Config {
+	config := api.NewConfig()
+	for _, name := range names {
+		config.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name}
+	}
+	return config
+}
+
+func TestSetCurrentContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"exact name", "prod-eu", "prod-eu", false},
+		{"exact name that is also a glob", "prod-[eu]", "prod-[eu]", false},
+		{"first glob match in name order", "prod-*", "prod-[eu]", false},
+		{"glob", "*-us", "prod-us", false},
+		{"no match", "staging-*", "", true},
+		{"invalid glob", "prod-[", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := contextsNamed("prod-us", "prod-eu", "prod-[eu]", "dev")
+			got, err := SetCurrentContext(config, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetCurrentContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || (!tt.wantErr && config.CurrentContext != tt.want) {
+				t.Errorf("SetCurrentContext() = %q, current context %q, want %q", got, config.CurrentContext, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchContext(t *testing.T) {
+	names := []string{"prod-eu", "prod-us", "staging-eu", "Dev"}
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"prod-eu", "prod-eu", false},
+		{"stag", "staging-eu", false},
+		{"dev", "Dev", false},
+		{"-us", "prod-us", false},
+		{"peu", "prod-eu", false},
+		{"prod", "", true},
+		{"eu", "", true},
+		{"qa", "", true},
+	}
+	for _, tt := range tests {
+		got, err := MatchContext(names, tt.query)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("MatchContext(%q) = %q, %v; want %q, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCarryOverCurrentContext(t *testing.T) {
+	config := contextsNamed("prod", "dev")
+
+	previous := contextsNamed("prod", "dev")
+	previous.CurrentContext = "dev"
+	if !CarryOverCurrentContext(config, previous) || config.CurrentContext != "dev" {
+		t.Errorf("current context = %q, want dev", config.CurrentContext)
+	}
+
+	config.CurrentContext = ""
+	previous.CurrentContext = "removed"
+	if CarryOverCurrentContext(config, previous) || config.CurrentContext != "" {
+		t.Errorf("current context = %q, want none for a removed context", config.CurrentContext)
+	}
+}
@@ -0,0 +1,48 @@
+package kubeconfig
+
+import (
+	"reflect"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// DedupeAuthInfos collapses users with identical credentials, as Rancher often
+// returns the same token for several clusters, into the first of them by name
+// and repoints the contexts. It returns the number of users removed.
+func DedupeAuthInfos(config *api.Config) int {
+	var kept []string
+	replacement := make(map[string]string)
+	for _, name := range sortedKeys(config.AuthInfos) {
+		authInfo := config.AuthInfos[name]
+		for _, keptName := range kept {
+			if sameCredentials(config.AuthInfos[keptName], authInfo) {
+				replacement[name] = keptName
+				break
+			}
+		}
+		if _, duplicate := replacement[name]; !duplicate {
+			kept = append(kept, name)
+		}
+	}
+
+	for name := range replacement {
+		delete(config.AuthInfos, name)
+	}
+	for _, context := range config.Contexts {
+		if keptName, ok := replacement[context.AuthInfo]; ok {
+			context.AuthInfo = keptName
+		}
+	}
+	return len(replacement)
+}
+
+// sameCredentials reports whether two users authenticate identically, ignoring
+// the file they were loaded from
+func sameCredentials(a, b *api.AuthInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	left, right := *a, *b
+	left.LocationOfOrigin, right.LocationOfOrigin = "", ""
+	return reflect.DeepEqual(left, right)
+}
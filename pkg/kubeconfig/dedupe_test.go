@@ -0,0 +1,54 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDedupeAuthInfos(t *testing.T) {
+	config := &api.Config{
+		AuthInfos: map[string]*api.AuthInfo{
+			"b":     {Token: "shared", LocationOfOrigin: "/tmp/b"},
+			"a":     {Token: "shared"},
+			"c":     {Token: "shared"},
+			"other": {Token: "different"},
+		},
+		Contexts: map[string]*api.Context{
+			"a": {Cluster: "a", AuthInfo: "a"},
+			"b": {Cluster: "b", AuthInfo: "b"},
+			"c": {Cluster: "c", AuthInfo: "c"},
+			"d": {Cluster: "d", AuthInfo: "other"},
+		},
+	}
+
+	if removed := DedupeAuthInfos(config); removed != 2 {
+		t.Errorf("DedupeAuthInfos() removed %d users, want 2", removed)
+	}
+	if len(config.AuthInfos) != 2 || config.AuthInfos["a"] == nil || config.AuthInfos["other"] == nil {
+		t.Errorf("users = %v, want a and other", sortedKeys(config.AuthInfos))
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if got := config.Contexts[name].AuthInfo; got != "a" {
+			t.Errorf("context %s uses user %q, want a", name, got)
+		}
+	}
+	if got := config.Contexts["d"].AuthInfo; got != "other" {
+		t.Errorf("context d uses user %q, want other", got)
+	}
+}
+
+func TestMergeConfigs_SharedToken(t *testing.T) {
+	merged, err := NewGenerator("").MergeConfigs(map[string]string{"one": sampleKubeconfig, "two": sampleKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	if len(merged.Contexts) != 2 || len(merged.AuthInfos) != 1 {
+		t.Errorf("got %d contexts and %d users, want 2 contexts sharing 1 user", len(merged.Contexts), len(merged.AuthInfos))
+	}
+	for name, context := range merged.Contexts {
+		if _, ok := merged.AuthInfos[context.AuthInfo]; !ok {
+			t.Errorf("context %s refers to missing user %q", name, context.AuthInfo)
+		}
+	}
+}
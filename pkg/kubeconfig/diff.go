@@ -0,0 +1,165 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ContextChange lists what differs between two versions of a context
+type ContextChange struct {
+	Name string
+
+	// Changes describe each difference, e.g. "server: https://a -> https://b".
+	// Credentials are never shown, only that they changed.
+	Changes []string
+
+	// CredentialsOnly is true when nothing but the credentials changed, e.g.
+	// because Rancher issued a new token
+	CredentialsOnly bool
+}
+
+// ConfigDiff is the semantic difference between a kubeconfig and the one that
+// would replace it
+type ConfigDiff struct {
+	Added   []string
+	Removed []string
+	Changed []ContextChange
+}
+
+// Empty reports whether there is no difference; with ignoreCredentials,
+// contexts whose credentials alone changed do not count
+func (d *ConfigDiff) Empty(ignoreCredentials bool) bool {
+	if len(d.Added) > 0 || len(d.Removed) > 0 {
+		return false
+	}
+	for _, change := range d.Changed {
+		if !ignoreCredentials || !change.CredentialsOnly {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffManaged compares the contexts of current that were generated from the
+// Rancher servers of generated, or are named like a generated one, with the
+// contexts of generated. Other entries of current, e.g. of a kubeconfig
+// updated with --merge-into, are not compared. Generation times and notes are
+// ignored.
+func DiffManaged(current, generated *api.Config) *ConfigDiff {
+	servers := RancherServers(generated)
+	diff := &ConfigDiff{}
+	for _, name := range sortedKeys(current.Contexts) {
+		if _, ok := generated.Contexts[name]; ok {
+			continue
+		}
+		provenance, _, _ := GetProvenance(current.Contexts[name])
+		if provenance.Source == generatedSource && slices.Contains(servers, provenance.RancherURL) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	for _, name := range sortedKeys(generated.Contexts) {
+		if _, ok := current.Contexts[name]; !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		if change := diffContext(current, generated, name); len(change.Changes) > 0 {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	return diff
+}
+
+// diffContext compares context name, with its cluster and user, in both kubeconfigs
+func diffContext(current, generated *api.Config, name string) ContextChange {
+	change := ContextChange{Name: name, CredentialsOnly: true}
+	setting := func(what, old, new string) {
+		if old != new {
+			change.Changes = append(change.Changes, fmt.Sprintf("%s: %s -> %s", what, orNone(old), orNone(new)))
+			change.CredentialsOnly = false
+		}
+	}
+	secret := func(what string, old, new any) {
+		if !sameValue(old, new) {
+			change.Changes = append(change.Changes, what+" changed")
+		}
+	}
+
+	oldContext, newContext := current.Contexts[name], generated.Contexts[name]
+	setting("namespace", oldContext.Namespace, newContext.Namespace)
+
+	oldCluster, newCluster := current.Clusters[oldContext.Cluster], generated.Clusters[newContext.Cluster]
+	if oldCluster == nil {
+		oldCluster = &api.Cluster{}
+	}
+	if newCluster == nil {
+		newCluster = &api.Cluster{}
+	}
+	setting("server", oldCluster.Server, newCluster.Server)
+	setting("tls-server-name", oldCluster.TLSServerName, newCluster.TLSServerName)
+	setting("proxy-url", oldCluster.ProxyURL, newCluster.ProxyURL)
+	setting("insecure-skip-tls-verify", fmt.Sprint(oldCluster.InsecureSkipTLSVerify), fmt.Sprint(newCluster.InsecureSkipTLSVerify))
+	if !bytes.Equal(oldCluster.CertificateAuthorityData, newCluster.CertificateAuthorityData) || oldCluster.CertificateAuthority != newCluster.CertificateAuthority {
+		change.Changes = append(change.Changes, "certificate authority changed")
+		change.CredentialsOnly = false
+	}
+
+	oldUser, newUser := current.AuthInfos[oldContext.AuthInfo], generated.AuthInfos[newContext.AuthInfo]
+	if oldUser == nil {
+		oldUser = &api.AuthInfo{}
+	}
+	if newUser == nil {
+		newUser = &api.AuthInfo{}
+	}
+	setting("authentication", authMethod(oldUser), authMethod(newUser))
+	secret("token", oldUser.Token+oldUser.TokenFile, newUser.Token+newUser.TokenFile)
+	secret("client certificate", string(oldUser.ClientCertificateData)+oldUser.ClientCertificate, string(newUser.ClientCertificateData)+newUser.ClientCertificate)
+	secret("client key", string(oldUser.ClientKeyData)+oldUser.ClientKey, string(newUser.ClientKeyData)+newUser.ClientKey)
+	secret("password", oldUser.Password, newUser.Password)
+	secret("exec plugin", oldUser.Exec, newUser.Exec)
+	secret("auth provider", oldUser.AuthProvider, newUser.AuthProvider)
+
+	if len(change.Changes) == 0 {
+		change.CredentialsOnly = false
+	}
+	return change
+}
+
+// authMethod names how a user authenticates
+func authMethod(user *api.AuthInfo) string {
+	switch {
+	case user.Exec != nil:
+		return "exec " + user.Exec.Command
+	case user.AuthProvider != nil:
+		return "auth-provider " + user.AuthProvider.Name
+	case user.Token != "" || user.TokenFile != "":
+		return "token"
+	case len(user.ClientCertificateData) > 0 || user.ClientCertificate != "":
+		return "client certificate"
+	case user.Username != "":
+		return "basic"
+	default:
+		return ""
+	}
+}
+
+// sameValue compares two values by their JSON encoding
+func sameValue(a, b any) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	return err == nil && bytes.Equal(left, right)
+}
+
+// orNone returns value, or "(none)" when it is empty
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDiffManaged(t *testing.T) {
+	current := rancherConfig(t, "prod", "ace", "gone")
+	current.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	current.AuthInfos["minikube"] = &api.AuthInfo{Token: "t"}
+	current.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+
+	generated := rancherConfig(t, "prod", "ace", "new")
+	generated.Contexts["prod"].Namespace = "apps"
+	generated.AuthInfos["ace"].Token = "kubeconfig-u-1:rotated"
+	StampGenerated(generated, time.Now())
+
+	diff := DiffManaged(current, generated)
+	if strings.Join(diff.Added, ",") != "new" {
+		t.Errorf("Added = %v, want new", diff.Added)
+	}
+	if strings.Join(diff.Removed, ",") != "gone" {
+		t.Errorf("Removed = %v, want gone (minikube is not managed)", diff.Removed)
+	}
+	if len(diff.Changed) != 3 {
+		t.Fatalf("Changed = %+v, want ace, ace-fqdn and prod", diff.Changed)
+	}
+	for _, change := range diff.Changed[:2] {
+		if strings.Join(change.Changes, ",") != "token changed" || !change.CredentialsOnly {
+			t.Errorf("%s change = %+v, want only the shared token changed", change.Name, change)
+		}
+	}
+	prod := diff.Changed[2]
+	if prod.Name != "prod" || strings.Join(prod.Changes, ",") != "namespace: (none) -> apps" || prod.CredentialsOnly {
+		t.Errorf("prod change = %+v", prod)
+	}
+	for _, change := range diff.Changed {
+		for _, what := range change.Changes {
+			if strings.Contains(what, "rotated") || strings.Contains(what, "kubeconfig-u-1") {
+				t.Errorf("change %q reveals a token", what)
+			}
+		}
+	}
+	if diff.Empty(false) || diff.Empty(true) {
+		t.Error("Empty() = true for a diff with added and removed contexts")
+	}
+}
+
+func TestDiffManagedIgnoreCredentials(t *testing.T) {
+	current := rancherConfig(t, "prod")
+	generated := rancherConfig(t, "prod")
+	if diff := DiffManaged(current, generated); !diff.Empty(false) {
+		t.Errorf("DiffManaged() of identical kubeconfigs = %+v", diff)
+	}
+
+	generated.AuthInfos["prod"].Token = "kubeconfig-u-1:rotated"
+	diff := DiffManaged(current, generated)
+	if diff.Empty(false) {
+		t.Error("Empty(false) = true for a rotated token")
+	}
+	if !diff.Empty(true) {
+		t.Error("Empty(true) = false when only the token changed")
+	}
+
+	generated.Clusters["prod"].Server = "https://rancher2.example.com/k8s/clusters/prod"
+	if diff := DiffManaged(current, generated); diff.Empty(true) {
+		t.Error("Empty(true) = true for a changed server")
+	}
+}
@@ -0,0 +1,89 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// RancherEndpoint holds the Rancher server details recovered from a kubeconfig
+// that Rancher generated
+type RancherEndpoint struct {
+	// URL is the Rancher server URL, without the /k8s/clusters/<id> suffix
+	URL string
+
+	// Token is the Rancher API token the kubeconfig authenticates with
+	Token string
+
+	// InsecureSkipTLSVerify mirrors the cluster's insecure-skip-tls-verify setting
+	InsecureSkipTLSVerify bool
+
+	// CACert is the path of the cluster's certificate-authority file, if any
+	CACert string
+
+	// CACertData is the PEM of the cluster's inline certificate-authority-data, if any
+	CACertData string
+}
+
+// DiscoverRancher extracts the Rancher server URL and token from a context of a
+// Rancher-generated kubeconfig. An empty path uses the default kubeconfig loading
+// rules (KUBECONFIG, then ~/.kube/config); an empty contextName uses the current context.
+// The context must go through the Rancher proxy, since direct (ACE) endpoints do not
+// reveal the Rancher server.
+func DiscoverRancher(path, contextName string) (*RancherEndpoint, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+
+	config, err := rules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("kubeconfig has no current context, specify one")
+	}
+
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q referenced by context %q not found", context.Cluster, contextName)
+	}
+
+	idx := strings.Index(cluster.Server, rancherProxyPath)
+	if idx < 0 {
+		return nil, fmt.Errorf("context %q does not point at a Rancher server (server %s)", contextName, cluster.Server)
+	}
+
+	endpoint := &RancherEndpoint{
+		URL:                   cluster.Server[:idx],
+		InsecureSkipTLSVerify: cluster.InsecureSkipTLSVerify,
+		CACert:                cluster.CertificateAuthority,
+		CACertData:            string(cluster.CertificateAuthorityData),
+	}
+
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("user %q referenced by context %q not found", context.AuthInfo, contextName)
+	}
+	endpoint.Token = authInfo.Token
+	if endpoint.Token == "" && authInfo.TokenFile != "" {
+		data, err := os.ReadFile(authInfo.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		endpoint.Token = strings.TrimSpace(string(data))
+	}
+	if endpoint.Token == "" {
+		return nil, fmt.Errorf("user %q has no token; only token-based Rancher kubeconfigs are supported", context.AuthInfo)
+	}
+
+	return endpoint, nil
+}
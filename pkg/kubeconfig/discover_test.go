@@ -0,0 +1,75 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKubeconfig writes data to a temporary kubeconfig file and returns its path
+func writeKubeconfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestDiscoverRancher(t *testing.T) {
+	path := writeKubeconfig(t, aceKubeconfig)
+
+	endpoint, err := DiscoverRancher(path, "")
+	if err != nil {
+		t.Fatalf("DiscoverRancher() error = %v", err)
+	}
+	if endpoint.URL != "https://rancher.example.com" {
+		t.Errorf("URL = %q, want https://rancher.example.com", endpoint.URL)
+	}
+	if endpoint.Token != "kubeconfig-user-abc:secret" {
+		t.Errorf("Token = %q, want kubeconfig-user-abc:secret", endpoint.Token)
+	}
+}
+
+func TestDiscoverRancher_CACertData(t *testing.T) {
+	// The Rancher proxy entry with the CA inlined, as Rancher writes it for a
+	// server with a private CA
+	data := strings.Replace(aceKubeconfig,
+		"    server: https://rancher.example.com/k8s/clusters/c-abc12\n",
+		"    server: https://rancher.example.com/k8s/clusters/c-abc12\n    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCnJhbmNoZXItY2EKLS0tLS1FTkQgQ0VSVElGSUNBVEUtLS0tLQo=\n", 1)
+
+	endpoint, err := DiscoverRancher(writeKubeconfig(t, data), "")
+	if err != nil {
+		t.Fatalf("DiscoverRancher() error = %v", err)
+	}
+	want := "-----BEGIN CERTIFICATE-----\nrancher-ca\n-----END CERTIFICATE-----\n"
+	if endpoint.CACertData != want {
+		t.Errorf("CACertData = %q, want %q", endpoint.CACertData, want)
+	}
+	if endpoint.CACert != "" {
+		t.Errorf("CACert = %q, want none", endpoint.CACert)
+	}
+}
+
+func TestDiscoverRancher_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		kubeconfig string
+		context    string
+		wantErr    string
+	}{
+		{"direct endpoint", aceKubeconfig, "ace-cluster-node1", "does not point at a Rancher server"},
+		{"missing context", aceKubeconfig, "nope", "not found"},
+		{"not rancher", sampleKubeconfig, "", "does not point at a Rancher server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiscoverRancher(writeKubeconfig(t, tt.kubeconfig), tt.context)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DiscoverRancher() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
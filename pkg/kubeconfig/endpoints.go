@@ -0,0 +1,199 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/probe"
+)
+
+// EndpointMode controls which API endpoints of an ACE-enabled cluster end up
+// in the generated kubeconfig
+type EndpointMode string
+
+const (
+	// EndpointModeAll keeps every context Rancher returns (the default)
+	EndpointModeAll EndpointMode = "all"
+	// EndpointModeProxy keeps only the context that goes through the Rancher proxy
+	EndpointModeProxy EndpointMode = "proxy"
+	// EndpointModeDirect keeps only the authorized cluster endpoint contexts
+	EndpointModeDirect EndpointMode = "direct"
+	// EndpointModeKeepDirect keeps a single authorized cluster endpoint context:
+	// the FQDN one if there is any, otherwise the first control-plane node
+	EndpointModeKeepDirect EndpointMode = "keep-direct"
+	// EndpointModeAuto probes every endpoint and keeps the healthiest, fastest one
+	EndpointModeAuto EndpointMode = "auto"
+)
+
+// rancherProxyPath is the path prefix of API servers reached through Rancher
+const rancherProxyPath = "/k8s/clusters/"
+
+// maxConcurrentProbes bounds how many endpoints of a cluster are probed at
+// once in auto mode, so a cluster with many control-plane nodes does not open
+// a connection to each of them at the same time
+const maxConcurrentProbes = 8
+
+// fqdnContextSuffix ends the name Rancher gives the context of an authorized
+// cluster endpoint configured with an FQDN, e.g. behind a load balancer
+const fqdnContextSuffix = "-fqdn"
+
+// endpointModeAliases are the names of the endpoint modes that describe which
+// contexts of an ACE-enabled cluster are kept
+var endpointModeAliases = map[string]EndpointMode{
+	"keep-all":     EndpointModeAll,
+	"keep-proxied": EndpointModeProxy,
+}
+
+// Prober checks the health of a single context in a kubeconfig. It is called
+// concurrently for the contexts of a cluster.
+type Prober func(config *api.Config, contextName string) probe.Result
+
+// ParseEndpointMode parses an --endpoint-mode value, treating empty as all.
+// keep-all and keep-proxied are accepted for all and proxy.
+func ParseEndpointMode(value string) (EndpointMode, error) {
+	if mode, ok := endpointModeAliases[strings.ToLower(value)]; ok {
+		return mode, nil
+	}
+	switch mode := EndpointMode(strings.ToLower(value)); mode {
+	case "":
+		return EndpointModeAll, nil
+	case EndpointModeAll, EndpointModeProxy, EndpointModeDirect, EndpointModeKeepDirect, EndpointModeAuto:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid endpoint mode %q (must be all, proxy, direct, keep-direct or auto)", value)
+	}
+}
+
+// IsProxyServer reports whether a server URL points at the Rancher cluster proxy
+// rather than directly at the downstream API server
+func IsProxyServer(server string) bool {
+	return strings.Contains(server, rancherProxyPath)
+}
+
+// ClassifyContexts splits the contexts of a kubeconfig into those going through
+// the Rancher proxy and those using an authorized cluster endpoint. Both lists are sorted.
+func ClassifyContexts(config *api.Config) (proxy, direct []string) {
+	for name, context := range config.Contexts {
+		cluster, ok := config.Clusters[context.Cluster]
+		if !ok {
+			continue
+		}
+		if IsProxyServer(cluster.Server) {
+			proxy = append(proxy, name)
+		} else {
+			direct = append(direct, name)
+		}
+	}
+	sort.Strings(proxy)
+	sort.Strings(direct)
+	return proxy, direct
+}
+
+// SetEndpointMode configures endpoint selection for ACE-enabled clusters.
+// The prober is only used in auto mode; nil uses probe.Context with the default timeout.
+func (g *Generator) SetEndpointMode(mode EndpointMode, prober Prober) {
+	g.endpointMode = mode
+	g.prober = prober
+}
+
+// SelectEndpoints reduces a single cluster's kubeconfig to the contexts allowed by
+// the endpoint mode. Configs without both kinds of endpoint are returned unchanged,
+// as is any config where the requested kind is missing.
+func (g *Generator) SelectEndpoints(config *api.Config) *api.Config {
+	if g.endpointMode == "" || g.endpointMode == EndpointModeAll {
+		return config
+	}
+
+	proxy, direct := ClassifyContexts(config)
+	if len(proxy) == 0 || len(direct) == 0 {
+		return config
+	}
+
+	switch g.endpointMode {
+	case EndpointModeProxy:
+		return keepContexts(config, proxy)
+	case EndpointModeDirect:
+		return keepContexts(config, direct)
+	case EndpointModeKeepDirect:
+		return keepContexts(config, []string{preferredDirect(direct)})
+	case EndpointModeAuto:
+		prober := g.prober
+		if prober == nil {
+			prober = func(config *api.Config, contextName string) probe.Result {
+				return probe.Context(config, contextName, probe.DefaultTimeout)
+			}
+		}
+
+		candidates := append(append([]string{}, proxy...), direct...)
+		results := probeConcurrently(config, candidates, prober)
+		if best := probe.Best(results); best >= 0 {
+			return keepContexts(config, []string{candidates[best]})
+		}
+		// Nothing answered; the proxy is the safest bet once connectivity returns
+		return keepContexts(config, proxy[:1])
+	}
+
+	return config
+}
+
+// probeConcurrently probes the named contexts, at most maxConcurrentProbes at
+// a time, returning the results in the order given
+func probeConcurrently(config *api.Config, names []string, prober Prober) []probe.Result {
+	results := make([]probe.Result, len(names))
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = prober(config, name)
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+// preferredDirect picks the direct context to keep out of the sorted direct
+// contexts of a cluster: the FQDN one, which survives node replacements, or
+// the first control-plane node
+func preferredDirect(direct []string) string {
+	for _, name := range direct {
+		if strings.HasSuffix(name, fqdnContextSuffix) {
+			return name
+		}
+	}
+	return direct[0]
+}
+
+// keepContexts returns a copy of config containing only the named contexts and
+// the clusters and users they reference
+func keepContexts(config *api.Config, names []string) *api.Config {
+	result := api.NewConfig()
+	result.Kind = config.Kind
+	result.APIVersion = config.APIVersion
+	result.Preferences = config.Preferences
+	result.Extensions = config.Extensions
+
+	for _, name := range names {
+		context, ok := config.Contexts[name]
+		if !ok {
+			continue
+		}
+		result.Contexts[name] = context
+		if cluster, ok := config.Clusters[context.Cluster]; ok {
+			result.Clusters[context.Cluster] = cluster
+		}
+		if authInfo, ok := config.AuthInfos[context.AuthInfo]; ok {
+			result.AuthInfos[context.AuthInfo] = authInfo
+		}
+	}
+
+	result.CurrentContext = names[0]
+	return result
+}
@@ -0,0 +1,320 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/probe"
+)
+
+// Kubeconfig as returned by Rancher for a cluster with an authorized cluster endpoint
+const aceKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://rancher.example.com/k8s/clusters/c-abc12
+  name: ace-cluster
+- cluster:
+    server: https://10.0.0.10:6443
+    certificate-authority-data: dGVzdC1jYS1kYXRh
+  name: ace-cluster-node1
+contexts:
+- context:
+    cluster: ace-cluster
+    user: ace-cluster
+  name: ace-cluster
+- context:
+    cluster: ace-cluster-node1
+    user: ace-cluster
+  name: ace-cluster-node1
+current-context: ace-cluster
+users:
+- name: ace-cluster
+  user:
+    token: kubeconfig-user-abc:secret
+`
+
+func TestParseEndpointMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    EndpointMode
+		wantErr bool
+	}{
+		{"", EndpointModeAll, false},
+		{"all", EndpointModeAll, false},
+		{"proxy", EndpointModeProxy, false},
+		{"Direct", EndpointModeDirect, false},
+		{"auto", EndpointModeAuto, false},
+		{"keep-all", EndpointModeAll, false},
+		{"keep-proxied", EndpointModeProxy, false},
+		{"Keep-Direct", EndpointModeKeepDirect, false},
+		{"fastest", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseEndpointMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEndpointMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEndpointMode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyContexts(t *testing.T) {
+	g := NewGenerator("")
+	config, err := g.ParseKubeconfig(aceKubeconfig)
+	if err != nil {
+		t.Fatalf("ParseKubeconfig() error = %v", err)
+	}
+
+	proxy, direct := ClassifyContexts(config)
+	if len(proxy) != 1 || proxy[0] != "ace-cluster" {
+		t.Errorf("proxy contexts = %v, want [ace-cluster]", proxy)
+	}
+	if len(direct) != 1 || direct[0] != "ace-cluster-node1" {
+		t.Errorf("direct contexts = %v, want [ace-cluster-node1]", direct)
+	}
+}
+
+func TestGenerator_SelectEndpoints(t *testing.T) {
+	healthy := func(latency time.Duration) probe.Result {
+		return probe.Result{Status: probe.StatusReachable, Latency: latency}
+	}
+	down := probe.Result{Status: probe.StatusUnreachable}
+
+	tests := []struct {
+		name        string
+		mode        EndpointMode
+		results     map[string]probe.Result
+		wantContext string
+		wantCount   int
+	}{
+		{"all keeps everything", EndpointModeAll, nil, "", 2},
+		{"proxy", EndpointModeProxy, nil, "ace-cluster", 1},
+		{"direct", EndpointModeDirect, nil, "ace-cluster-node1", 1},
+		{
+			name: "auto picks faster direct",
+			mode: EndpointModeAuto,
+			results: map[string]probe.Result{
+				"ace-cluster":       healthy(80 * time.Millisecond),
+				"ace-cluster-node1": healthy(10 * time.Millisecond),
+			},
+			wantContext: "ace-cluster-node1",
+			wantCount:   1,
+		},
+		{
+			name: "auto skips unhealthy direct",
+			mode: EndpointModeAuto,
+			results: map[string]probe.Result{
+				"ace-cluster":       healthy(80 * time.Millisecond),
+				"ace-cluster-node1": down,
+			},
+			wantContext: "ace-cluster",
+			wantCount:   1,
+		},
+		{
+			name: "auto falls back to proxy",
+			mode: EndpointModeAuto,
+			results: map[string]probe.Result{
+				"ace-cluster":       down,
+				"ace-cluster-node1": down,
+			},
+			wantContext: "ace-cluster",
+			wantCount:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("")
+			g.SetEndpointMode(tt.mode, func(config *api.Config, contextName string) probe.Result {
+				return tt.results[contextName]
+			})
+
+			config, err := g.ParseKubeconfig(aceKubeconfig)
+			if err != nil {
+				t.Fatalf("ParseKubeconfig() error = %v", err)
+			}
+
+			selected := g.SelectEndpoints(config)
+			if len(selected.Contexts) != tt.wantCount {
+				t.Fatalf("got %d contexts, want %d", len(selected.Contexts), tt.wantCount)
+			}
+			if tt.wantContext == "" {
+				return
+			}
+			context, ok := selected.Contexts[tt.wantContext]
+			if !ok {
+				t.Fatalf("context %q not kept, got %v", tt.wantContext, selected.Contexts)
+			}
+			if _, ok := selected.Clusters[context.Cluster]; !ok {
+				t.Errorf("cluster %q referenced by kept context was dropped", context.Cluster)
+			}
+			if len(selected.Clusters) != 1 {
+				t.Errorf("got %d clusters, want 1", len(selected.Clusters))
+			}
+			if selected.CurrentContext != tt.wantContext {
+				t.Errorf("CurrentContext = %q, want %q", selected.CurrentContext, tt.wantContext)
+			}
+		})
+	}
+}
+
+func TestGenerator_SelectEndpoints_AutoConcurrent(t *testing.T) {
+	// A cluster reached through the proxy and 20 control-plane nodes
+	config := api.NewConfig()
+	config.AuthInfos["user"] = &api.AuthInfo{Token: "token"}
+	config.Clusters["proxy"] = &api.Cluster{Server: "https://rancher.example.com/k8s/clusters/c-abc12"}
+	config.Contexts["proxy"] = &api.Context{Cluster: "proxy", AuthInfo: "user"}
+	for i := range 20 {
+		name := fmt.Sprintf("node%02d", i)
+		config.Clusters[name] = &api.Cluster{Server: fmt.Sprintf("https://10.0.0.%d:6443", i)}
+		config.Contexts[name] = &api.Context{Cluster: name, AuthInfo: "user"}
+	}
+
+	var running, peak atomic.Int32
+	g := NewGenerator("")
+	g.SetEndpointMode(EndpointModeAuto, func(config *api.Config, contextName string) probe.Result {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		latency := 50 * time.Millisecond
+		if contextName == "node13" {
+			latency = 5 * time.Millisecond
+		}
+		return probe.Result{Status: probe.StatusReachable, Latency: latency}
+	})
+
+	selected := g.SelectEndpoints(config)
+	if selected.CurrentContext != "node13" {
+		t.Errorf("CurrentContext = %q, want the fastest node13", selected.CurrentContext)
+	}
+	if p := peak.Load(); p < 2 || p > maxConcurrentProbes {
+		t.Errorf("%d probes ran at once, want between 2 and %d", p, maxConcurrentProbes)
+	}
+}
+
+func TestGenerator_SelectEndpoints_NonACE(t *testing.T) {
+	g := NewGenerator("")
+	g.SetEndpointMode(EndpointModeProxy, nil)
+
+	config, err := g.ParseKubeconfig(sampleKubeconfig)
+	if err != nil {
+		t.Fatalf("ParseKubeconfig() error = %v", err)
+	}
+
+	if selected := g.SelectEndpoints(config); selected != config {
+		t.Error("SelectEndpoints() should leave kubeconfigs without both endpoint kinds unchanged")
+	}
+}
+
+func TestGenerator_MergeConfigs_EndpointMode(t *testing.T) {
+	g := NewGenerator("prod-")
+	g.SetEndpointMode(EndpointModeDirect, nil)
+
+	merged, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	if len(merged.Contexts) != 1 {
+		t.Fatalf("got %d contexts, want 1", len(merged.Contexts))
+	}
+	cluster, ok := merged.Clusters["prod-ace"]
+	if !ok {
+		t.Fatalf("expected cluster prod-ace, got %v", merged.Clusters)
+	}
+	if cluster.Server != "https://10.0.0.10:6443" {
+		t.Errorf("server = %q, want direct endpoint", cluster.Server)
+	}
+	provenance, ok, err := GetProvenance(merged.Contexts["prod-ace"])
+	if err != nil || !ok || provenance.ClusterID != "c-abc12" {
+		t.Errorf("provenance of the direct context = %+v, %v, %v; want owned by cluster c-abc12", provenance, ok, err)
+	}
+}
+
+// multiNodeKubeconfig is an ACE-enabled cluster with a proxy context, one
+// context per control-plane node and an FQDN context
+const multiNodeKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://rancher.example.com/k8s/clusters/c-m-ha
+  name: ha
+- cluster:
+    server: https://10.0.0.11:6443
+  name: ha-node1
+- cluster:
+    server: https://10.0.0.12:6443
+  name: ha-node2
+- cluster:
+    server: https://ha.example.com:6443
+  name: ha-fqdn
+contexts:
+- context:
+    cluster: ha
+    user: ha
+  name: ha
+- context:
+    cluster: ha-node1
+    user: ha
+  name: ha-node1
+- context:
+    cluster: ha-node2
+    user: ha
+  name: ha-node2
+- context:
+    cluster: ha-fqdn
+    user: ha
+  name: ha-fqdn
+current-context: ha
+users:
+- name: ha
+  user:
+    token: kubeconfig-user-ha:secret
+`
+
+func TestGenerator_SelectEndpoints_CollapsesDirect(t *testing.T) {
+	g := NewGenerator("")
+	g.SetEndpointMode(EndpointModeDirect, nil)
+
+	config, err := g.ParseKubeconfig(multiNodeKubeconfig)
+	if err != nil {
+		t.Fatalf("ParseKubeconfig() error = %v", err)
+	}
+	// direct keeps every direct context
+	selected := g.SelectEndpoints(config)
+	if len(selected.Contexts) != 3 || selected.Contexts["ha"] != nil {
+		t.Errorf("direct contexts = %v, want ha-fqdn, ha-node1 and ha-node2", sortedKeys(selected.Contexts))
+	}
+
+	g.SetEndpointMode(EndpointModeKeepDirect, nil)
+	selected = g.SelectEndpoints(config)
+	if len(selected.Contexts) != 1 || selected.Contexts["ha-fqdn"] == nil {
+		t.Errorf("contexts = %v, want only ha-fqdn", sortedKeys(selected.Contexts))
+	}
+
+	// Without an FQDN the first node is kept
+	delete(config.Contexts, "ha-fqdn")
+	delete(config.Clusters, "ha-fqdn")
+	selected = g.SelectEndpoints(config)
+	if len(selected.Contexts) != 1 || selected.Contexts["ha-node1"] == nil {
+		t.Errorf("contexts = %v, want only ha-node1", sortedKeys(selected.Contexts))
+	}
+	if len(selected.Clusters) != 1 || len(selected.AuthInfos) != 1 {
+		t.Errorf("clusters %v and users %v are left", sortedKeys(selected.Clusters), sortedKeys(selected.AuthInfos))
+	}
+}
@@ -0,0 +1,44 @@
+package kubeconfig
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ExecCredentialAPIVersion is the client.authentication.k8s.io version of the
+// ExecCredential the token command prints
+const ExecCredentialAPIVersion = "client.authentication.k8s.io/v1"
+
+// ExecCredentialArgs returns the arguments of the token command fetching the
+// credentials of a cluster from the Rancher server at rancherURL
+func ExecCredentialArgs(rancherURL, clusterID string) []string {
+	return []string{"token", "--server", rancherURL, "--cluster", clusterID}
+}
+
+// UseExecCredentials replaces the embedded tokens of the generated contexts,
+// marked by MarkOwned, with an exec credential plugin running command, so
+// kubectl fetches a fresh token when it needs one. Users authenticating
+// otherwise, e.g. with a client certificate, are left alone.
+func UseExecCredentials(config *api.Config, command string) error {
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		user, ok := config.AuthInfos[context.AuthInfo]
+		if provenance.Source != generatedSource || !ok || user.Token == "" {
+			continue
+		}
+		user.Token = ""
+		user.Exec = &api.ExecConfig{
+			APIVersion:      ExecCredentialAPIVersion,
+			Command:         command,
+			Args:            ExecCredentialArgs(provenance.RancherURL, provenance.ClusterID),
+			InteractiveMode: api.NeverExecInteractiveMode,
+			InstallHint:     fmt.Sprintf("%s fetches the credentials of this context; install it and run \"%s login\"", command, command),
+		}
+	}
+	return nil
+}
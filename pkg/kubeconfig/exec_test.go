@@ -0,0 +1,58 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestUseExecCredentials(t *testing.T) {
+	config := rancherConfig(t, "prod", "ace")
+	config.AuthInfos["minikube"] = &api.AuthInfo{Token: "static"}
+	config.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+
+	if err := UseExecCredentials(config, "/usr/local/bin/kubeconfig-wrangler"); err != nil {
+		t.Fatalf("UseExecCredentials() error = %v", err)
+	}
+
+	for _, id := range []string{"prod", "ace"} {
+		user := config.AuthInfos[id]
+		if user.Token != "" || user.Exec == nil {
+			t.Fatalf("user %s = %+v, want an exec plugin and no token", id, user)
+		}
+		if user.Exec.Command != "/usr/local/bin/kubeconfig-wrangler" || user.Exec.APIVersion != ExecCredentialAPIVersion {
+			t.Errorf("user %s exec = %+v", id, user.Exec)
+		}
+		want := "token --server https://rancher.example.com --cluster " + id
+		if got := strings.Join(user.Exec.Args, " "); got != want {
+			t.Errorf("user %s args = %q, want %q", id, got, want)
+		}
+	}
+	if user := config.AuthInfos["minikube"]; user.Token != "static" || user.Exec != nil {
+		t.Errorf("user not generated from Rancher was rewritten: %+v", user)
+	}
+}
+
+func TestGenerator_SetExecCommand(t *testing.T) {
+	proxy := strings.Replace(sampleKubeconfig, "https://cluster1.example.com:6443", "https://rancher.example.com/k8s/clusters/c-1", 1)
+	g := NewGenerator("")
+	g.SetExecCommand("kubeconfig-wrangler")
+	config, err := g.MergeConfigs(map[string]string{"app": proxy})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	user := config.AuthInfos[config.Contexts["app"].AuthInfo]
+	if user.Token != "" || user.Exec == nil || user.Exec.Args[len(user.Exec.Args)-1] != "c-1" {
+		t.Errorf("generated user = %+v, want an exec plugin for c-1", user)
+	}
+
+	data, err := g.Serialize(config)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if strings.Contains(string(data), "test-token-12345") {
+		t.Error("serialized kubeconfig still embeds the token")
+	}
+}
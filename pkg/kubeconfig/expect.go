@@ -0,0 +1,145 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+// Expectations declares the contexts a kubeconfig must contain, so that CI can
+// fail when the Rancher fleet drifts from what was agreed on
+type Expectations struct {
+	// Contexts lists every context expected in the kubeconfig
+	Contexts []ExpectedContext `json:"contexts"`
+	// Server is a regular expression all servers must match unless a context sets its own
+	Server string `json:"server,omitempty"`
+	// AllowExtra accepts contexts that are not listed
+	AllowExtra bool `json:"allowExtra,omitempty"`
+	// AllowInsecure accepts clusters skipping TLS verification or served over plain HTTP
+	AllowInsecure bool `json:"allowInsecure,omitempty"`
+
+	server *regexp.Regexp
+}
+
+// ExpectedContext is one context an expectations file requires
+type ExpectedContext struct {
+	Name string `json:"name"`
+	// Server is a regular expression the context's server must match
+	Server string `json:"server,omitempty"`
+
+	server *regexp.Regexp
+}
+
+// LoadExpectations reads and parses an expectations file
+func LoadExpectations(path string) (*Expectations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expectations: %w", err)
+	}
+	expectations, err := ParseExpectations(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return expectations, nil
+}
+
+// ParseExpectations parses YAML or JSON expectations, rejecting unknown fields,
+// duplicate context names and invalid server patterns
+func ParseExpectations(data []byte) (*Expectations, error) {
+	var expectations Expectations
+	if err := yaml.UnmarshalStrict(data, &expectations); err != nil {
+		return nil, fmt.Errorf("failed to parse expectations: %w", err)
+	}
+
+	var err error
+	if expectations.server, err = compileServer(expectations.Server); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(expectations.Contexts))
+	for i := range expectations.Contexts {
+		expected := &expectations.Contexts[i]
+		if expected.Name == "" {
+			return nil, fmt.Errorf("context %d has no name", i+1)
+		}
+		if seen[expected.Name] {
+			return nil, fmt.Errorf("context %s is listed twice", expected.Name)
+		}
+		seen[expected.Name] = true
+		if expected.server, err = compileServer(expected.Server); err != nil {
+			return nil, fmt.Errorf("context %s: %w", expected.Name, err)
+		}
+	}
+	return &expectations, nil
+}
+
+func compileServer(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// Verify checks config against the expectations and returns one message per
+// violation, sorted by context name, or nil when the kubeconfig conforms
+func (e *Expectations) Verify(config *api.Config) []string {
+	var violations []string
+	expected := make(map[string]bool, len(e.Contexts))
+	for _, want := range e.Contexts {
+		expected[want.Name] = true
+		context, ok := config.Contexts[want.Name]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("%s: missing", want.Name))
+			continue
+		}
+		pattern := want.server
+		if pattern == nil {
+			pattern = e.server
+		}
+		violations = append(violations, e.checkContext(config, want.Name, context, pattern)...)
+	}
+
+	for _, name := range sortedKeys(config.Contexts) {
+		if expected[name] {
+			continue
+		}
+		if !e.AllowExtra {
+			violations = append(violations, fmt.Sprintf("%s: not expected", name))
+			continue
+		}
+		violations = append(violations, e.checkContext(config, name, config.Contexts[name], e.server)...)
+	}
+
+	sort.Strings(violations)
+	return violations
+}
+
+// checkContext checks the server and TLS settings of one context's cluster
+func (e *Expectations) checkContext(config *api.Config, name string, context *api.Context, pattern *regexp.Regexp) []string {
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return []string{fmt.Sprintf("%s: cluster %s not found", name, context.Cluster)}
+	}
+
+	var violations []string
+	if pattern != nil && !pattern.MatchString(cluster.Server) {
+		violations = append(violations, fmt.Sprintf("%s: server %s does not match %s", name, cluster.Server, pattern))
+	}
+	if !e.AllowInsecure {
+		if cluster.InsecureSkipTLSVerify {
+			violations = append(violations, fmt.Sprintf("%s: skips TLS verification", name))
+		}
+		if strings.HasPrefix(strings.ToLower(cluster.Server), "http://") {
+			violations = append(violations, fmt.Sprintf("%s: server %s is not served over HTTPS", name, cluster.Server))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,74 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestParseExpectations_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"contexts:\n- name: a\n- name: a\n",
+		"contexts:\n- server: x\n",
+		"server: '('\n",
+		"contexts: []\nunknown: true\n",
+	} {
+		if _, err := ParseExpectations([]byte(data)); err == nil {
+			t.Errorf("ParseExpectations(%q) did not fail", data)
+		}
+	}
+}
+
+func TestExpectations_Verify(t *testing.T) {
+	config := api.NewConfig()
+	for name, cluster := range map[string]*api.Cluster{
+		"prod":    {Server: "https://rancher.example.com/k8s/clusters/c-1"},
+		"staging": {Server: "https://rancher.example.com/k8s/clusters/c-2", InsecureSkipTLSVerify: true},
+		"lab":     {Server: "http://10.0.0.1:6443"},
+	} {
+		config.Clusters[name] = cluster
+		config.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name}
+	}
+
+	expectations, err := ParseExpectations([]byte(`
+server: ^https://rancher\.example\.com/
+contexts:
+- name: prod
+- name: staging
+- name: lab
+  server: ^http://10\.
+- name: dev
+`))
+	if err != nil {
+		t.Fatalf("ParseExpectations() error = %v", err)
+	}
+	got := strings.Join(expectations.Verify(config), "\n")
+	want := strings.Join([]string{
+		"dev: missing",
+		"lab: server http://10.0.0.1:6443 is not served over HTTPS",
+		"staging: skips TLS verification",
+	}, "\n")
+	if got != want {
+		t.Errorf("Verify() =\n%s\nwant\n%s", got, want)
+	}
+
+	expectations, err = ParseExpectations([]byte("server: ^https://\nallowInsecure: true\ncontexts:\n- name: prod\n"))
+	if err != nil {
+		t.Fatalf("ParseExpectations() error = %v", err)
+	}
+	got = strings.Join(expectations.Verify(config), "\n")
+	want = strings.Join([]string{
+		"lab: not expected",
+		"staging: not expected",
+	}, "\n")
+	if got != want {
+		t.Errorf("Verify() =\n%s\nwant\n%s", got, want)
+	}
+
+	expectations.AllowExtra = true
+	got = strings.Join(expectations.Verify(config), "\n")
+	if got != "lab: server http://10.0.0.1:6443 does not match ^https://" {
+		t.Errorf("Verify() with extra contexts allowed = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+// Flatten inlines the certificates, keys and token files that the clusters
+// and users of config reference, resolving relative paths against the file
+// each entry was loaded from, so the kubeconfig no longer depends on them
+func Flatten(config *api.Config) error {
+	if err := api.FlattenConfig(config); err != nil {
+		return fmt.Errorf("failed to inline referenced files: %w", err)
+	}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		user := config.AuthInfos[name]
+		if user.TokenFile == "" || user.Token != "" {
+			continue
+		}
+		baseDir, err := api.MakeAbs(filepath.Dir(user.LocationOfOrigin), "")
+		if err != nil {
+			return fmt.Errorf("user %s: %w", name, err)
+		}
+		token, err := os.ReadFile(api.ResolvePath(user.TokenFile, baseDir))
+		if err != nil {
+			return fmt.Errorf("failed to inline the token file of user %s: %w", name, err)
+		}
+		user.Token, user.TokenFile = strings.TrimSpace(string(token)), ""
+	}
+	return nil
+}
+
+// PruneUnused removes the clusters and users no context refers to and returns
+// them as "cluster <name>" and "user <name>"
+func PruneUnused(config *api.Config) []string {
+	usedClusters := make(map[string]bool)
+	usedUsers := make(map[string]bool)
+	for _, context := range config.Contexts {
+		usedClusters[context.Cluster] = true
+		usedUsers[context.AuthInfo] = true
+	}
+
+	var removed []string
+	for _, name := range sortedKeys(config.Clusters) {
+		if !usedClusters[name] {
+			delete(config.Clusters, name)
+			removed = append(removed, "cluster "+name)
+		}
+	}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		if !usedUsers[name] {
+			delete(config.AuthInfos, name)
+			removed = append(removed, "user "+name)
+		}
+	}
+	return removed
+}
+
+// stripEmptyFields drops the top-level fields of a serialized kubeconfig that
+// hold nothing, such as "preferences: {}", which clientcmd always writes
+func stripEmptyFields(data []byte) ([]byte, error) {
+	var fields map[string]any
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		switch v := value.(type) {
+		case map[string]any:
+			if len(v) == 0 {
+				delete(fields, key)
+			}
+		case []any:
+			if len(v) == 0 {
+				delete(fields, key)
+			}
+		case nil:
+			delete(fields, key)
+		}
+	}
+	return yaml.Marshal(fields)
+}
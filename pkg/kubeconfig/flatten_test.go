@@ -0,0 +1,91 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestFlatten(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"ca.crt": "ca-data", "token": "file-token\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origin := filepath.Join(dir, "config")
+	config := api.NewConfig()
+	config.Clusters["c"] = &api.Cluster{Server: "https://c.example.com", CertificateAuthority: "ca.crt", LocationOfOrigin: origin}
+	config.AuthInfos["u"] = &api.AuthInfo{TokenFile: "token", LocationOfOrigin: origin}
+
+	if err := Flatten(config); err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	cluster := config.Clusters["c"]
+	if cluster.CertificateAuthority != "" || string(cluster.CertificateAuthorityData) != "ca-data" {
+		t.Errorf("cluster CA = %q / %q, want inlined", cluster.CertificateAuthority, cluster.CertificateAuthorityData)
+	}
+	user := config.AuthInfos["u"]
+	if user.TokenFile != "" || user.Token != "file-token" {
+		t.Errorf("user token = %q / %q, want inlined", user.TokenFile, user.Token)
+	}
+
+	config.AuthInfos["u"] = &api.AuthInfo{TokenFile: "missing", LocationOfOrigin: origin}
+	if err := Flatten(config); err == nil {
+		t.Error("expected an error for a missing token file")
+	}
+}
+
+func TestPruneUnused(t *testing.T) {
+	config, err := clientcmd.Load([]byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Clusters["orphan"] = &api.Cluster{Server: "https://orphan.example.com"}
+	config.AuthInfos["orphan"] = &api.AuthInfo{Token: "t"}
+
+	removed := PruneUnused(config)
+	if strings.Join(removed, ",") != "cluster orphan,user orphan" {
+		t.Errorf("PruneUnused() = %v", removed)
+	}
+	if _, ok := config.Clusters["my-cluster"]; !ok {
+		t.Error("the cluster in use was removed")
+	}
+	if _, ok := config.AuthInfos["my-user"]; !ok {
+		t.Error("the user in use was removed")
+	}
+}
+
+func TestGenerator_Serialize_Minify(t *testing.T) {
+	config, err := clientcmd.Load([]byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AuthInfos["orphan"] = &api.AuthInfo{Token: "t"}
+
+	g := NewGenerator("")
+	g.SetMinify(true)
+	data, err := g.Serialize(config)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	out := string(data)
+	if strings.Contains(out, "preferences") || strings.Contains(out, "orphan") {
+		t.Errorf("minified kubeconfig still holds empty or unused entries:\n%s", out)
+	}
+	if _, ok := config.AuthInfos["orphan"]; !ok {
+		t.Error("Serialize() modified its input")
+	}
+
+	loaded, err := clientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("minified kubeconfig does not load: %v", err)
+	}
+	if loaded.CurrentContext != "my-cluster" || loaded.AuthInfos["my-user"].Token != "test-token-12345" {
+		t.Errorf("minified kubeconfig lost entries:\n%s", out)
+	}
+}
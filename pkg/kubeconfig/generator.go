@@ -3,6 +3,10 @@ package kubeconfig
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd"
@@ -16,8 +20,30 @@ type AptakubeExtension struct {
 
 // Generator handles kubeconfig generation and merging
 type Generator struct {
-	prefix string
-	tags   map[string][]string // Map of context name to tags
+	prefix       string
+	suffix       string
+	separator    string
+	nameTemplate *template.Template
+	clusterInfo  map[string]NameData
+	tags         map[string][]string // Map of context name to tags
+	endpointMode EndpointMode
+	prober       Prober
+	execCommand  string
+	oidc         *OIDCConfig
+	sanitizer    *NameSanitizer
+	groupLabel   string
+	proxyRules   []ProxyRule
+	tlsOverrides []TLSOverride
+	namespaces   map[string][]string
+
+	conflictStrategy ConflictStrategy
+	conflicts        []Conflict
+
+	flatten bool
+	minify  bool
+	redact  bool
+
+	generatedAt time.Time
 }
 
 // NewGenerator creates a new kubeconfig generator with the specified cluster name prefix
@@ -28,6 +54,100 @@ func NewGenerator(prefix string) *Generator {
 	}
 }
 
+// SetExecCommand makes the generated users run command as an exec credential
+// plugin instead of embedding their token (empty embeds the token)
+func (g *Generator) SetExecCommand(command string) {
+	g.execCommand = command
+}
+
+// SetOIDC makes the generated users log in with OIDC instead of using their
+// Rancher token (nil keeps the token)
+func (g *Generator) SetOIDC(oidc *OIDCConfig) {
+	g.oidc = oidc
+}
+
+// SetGeneratedAt records at, and the tool version, on the generated contexts
+// and clusters (the zero time records neither)
+func (g *Generator) SetGeneratedAt(at time.Time) {
+	g.generatedAt = at
+}
+
+// SetFlatten makes Serialize inline the files the kubeconfig references, like
+// kubectl config view --flatten
+func (g *Generator) SetFlatten(flatten bool) {
+	g.flatten = flatten
+}
+
+// SetMinify makes Serialize leave out the clusters and users no context uses,
+// and empty fields such as preferences
+func (g *Generator) SetMinify(minify bool) {
+	g.minify = minify
+}
+
+// SetRedact makes Serialize replace credentials with placeholders, for a copy
+// that can be shared for review but not used
+func (g *Generator) SetRedact(redact bool) {
+	g.redact = redact
+}
+
+// SetGroupLabel records on the generated contexts the value of this cluster
+// label, by which SplitByGroup splits them (empty records none)
+func (g *Generator) SetGroupLabel(label string) {
+	g.groupLabel = label
+}
+
+// SetSuffix sets the suffix appended to cluster names, e.g. a region
+func (g *Generator) SetSuffix(suffix string) {
+	g.suffix = suffix
+}
+
+// SetSeparator sets the separator placed between the prefix, the cluster name
+// and the suffix. Without one they are concatenated as given.
+func (g *Generator) SetSeparator(separator string) {
+	g.separator = separator
+}
+
+// SetSanitizer sets the rules applied to every generated name (nil keeps
+// names as they are)
+func (g *Generator) SetSanitizer(sanitizer *NameSanitizer) {
+	g.sanitizer = sanitizer
+}
+
+// ContextName returns the name clusterName gets in the kubeconfig. The
+// clusters and users of the cluster are named after it too.
+func (g *Generator) ContextName(clusterName string) string {
+	name := g.unsanitizedName(clusterName)
+	if g.sanitizer != nil {
+		// A name with no valid character is better replaced by the cluster
+		// ID, when known, than by a placeholder
+		if id := g.clusterInfo[clusterName].ClusterID; id != "" && g.sanitizer.ReplaceInvalid && replaceInvalid(name) == "" {
+			name = id
+		}
+		name = g.sanitizer.Sanitize(name)
+	}
+	return name
+}
+
+// unsanitizedName returns the name of clusterName from the template or the
+// prefix, separator and suffix
+func (g *Generator) unsanitizedName(clusterName string) string {
+	if g.nameTemplate != nil {
+		if name, ok := g.renderName(clusterName); ok {
+			return name
+		}
+	}
+	if g.separator == "" {
+		return g.prefix + clusterName + g.suffix
+	}
+	parts := make([]string, 0, 3)
+	for _, part := range []string{g.prefix, clusterName, g.suffix} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, g.separator)
+}
+
 // SetTags sets the Aptakube tags for a specific context
 func (g *Generator) SetTags(contextName string, tags []string) {
 	g.tags[contextName] = tags
@@ -58,11 +178,22 @@ func (g *Generator) ParseKubeconfig(data string) (*api.Config, error) {
 	return config, nil
 }
 
-// ApplyPrefix applies the configured prefix to all cluster, context, and user names in the config
-// It renames all entries to use clusterName as the base to ensure uniqueness when merging
+// ApplyPrefix applies the configured prefix and suffix to all cluster, context, and user names in the config
+// It renames all entries to use clusterName as the base to ensure uniqueness when merging. The entries of
+// the current context get the base name and the others are numbered in name order, so the same input is
+// always renamed the same way.
 func (g *Generator) ApplyPrefix(config *api.Config, clusterName string) *api.Config {
-	// Always use clusterName as the base, with optional prefix
-	newName := fmt.Sprintf("%s%s", g.prefix, clusterName)
+	// Always use clusterName as the base, with optional prefix and suffix
+	return renameEntries(config, g.ContextName(clusterName))
+}
+
+// renameEntries names the entries of config after newName, numbering all but
+// those of the current context
+func renameEntries(config *api.Config, newName string) *api.Config {
+	var currentCluster, currentAuthInfo string
+	if current, ok := config.Contexts[config.CurrentContext]; ok {
+		currentCluster, currentAuthInfo = current.Cluster, current.AuthInfo
+	}
 
 	// Create new maps with renamed entries
 	newClusters := make(map[string]*api.Cluster)
@@ -75,7 +206,8 @@ func (g *Generator) ApplyPrefix(config *api.Config, clusterName string) *api.Con
 
 	// Rename clusters - use clusterName as base for the first/only cluster
 	i := 0
-	for oldName, cluster := range config.Clusters {
+	for _, oldName := range currentFirst(config.Clusters, currentCluster) {
+		cluster := config.Clusters[oldName]
 		var mappedName string
 		if i == 0 {
 			mappedName = newName
@@ -89,7 +221,8 @@ func (g *Generator) ApplyPrefix(config *api.Config, clusterName string) *api.Con
 
 	// Rename auth infos (users) - use clusterName as base
 	i = 0
-	for oldName, authInfo := range config.AuthInfos {
+	for _, oldName := range currentFirst(config.AuthInfos, currentAuthInfo) {
+		authInfo := config.AuthInfos[oldName]
 		var mappedName string
 		if i == 0 {
 			mappedName = newName
@@ -103,7 +236,8 @@ func (g *Generator) ApplyPrefix(config *api.Config, clusterName string) *api.Con
 
 	// Rename and update contexts
 	i = 0
-	for _, context := range config.Contexts {
+	for _, oldName := range currentFirst(config.Contexts, config.CurrentContext) {
+		context := config.Contexts[oldName]
 		var newContextName string
 		if i == 0 {
 			newContextName = newName
@@ -145,23 +279,97 @@ func (g *Generator) ApplyPrefix(config *api.Config, clusterName string) *api.Con
 	}
 }
 
-// MergeConfigs merges multiple kubeconfig strings into a single config
-// The clusterKubeconfigs map has cluster names as keys and kubeconfig YAML strings as values
+// currentFirst returns the keys of m in order, with current moved to the front
+func currentFirst[V any](m map[string]V, current string) []string {
+	keys := sortedKeys(m)
+	if i := slices.Index(keys, current); i > 0 {
+		copy(keys[1:i+1], keys[:i])
+		keys[0] = current
+	}
+	return keys
+}
+
+// MergeConfigs merges multiple kubeconfig strings into a single config, collapsing users with identical credentials
+// The clusterKubeconfigs map has cluster names as keys and kubeconfig YAML strings as values. Clusters are merged
+// in name order, and names clashing with those of a cluster merged before are resolved by the conflict strategy.
 func (g *Generator) MergeConfigs(clusterKubeconfigs map[string]string) (*api.Config, error) {
 	mergedConfig := api.NewConfig()
+	owners := make(map[string]string)
+	g.conflicts = nil
 
-	for clusterName, kubeconfigData := range clusterKubeconfigs {
+	for _, clusterName := range sortedKeys(clusterKubeconfigs) {
+		kubeconfigData := clusterKubeconfigs[clusterName]
 		config, err := g.ParseKubeconfig(kubeconfigData)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse kubeconfig for cluster %s: %w", clusterName, err)
 		}
 
-		// Apply prefix to this config
-		prefixedConfig := g.ApplyPrefix(config, clusterName)
+		// Mark the contexts while the proxy context still tells which Rancher
+		// cluster they belong to; direct mode drops it
+		if err := MarkOwned(config); err != nil {
+			return nil, fmt.Errorf("failed to mark kubeconfig for cluster %s: %w", clusterName, err)
+		}
+		if !g.generatedAt.IsZero() {
+			if err := StampGenerated(config, g.generatedAt); err != nil {
+				return nil, fmt.Errorf("failed to mark kubeconfig for cluster %s: %w", clusterName, err)
+			}
+		}
+
+		if g.groupLabel != "" {
+			if err := setGroup(config, g.clusterInfo[clusterName].Labels[g.groupLabel]); err != nil {
+				return nil, fmt.Errorf("failed to mark kubeconfig for cluster %s: %w", clusterName, err)
+			}
+		}
+
+		// Before the endpoints are probed, which goes through the proxy and
+		// checks the certificates
+		if proxyURL := g.proxyURL(clusterName); proxyURL != "" {
+			setProxyURL(config, proxyURL)
+		}
+		g.applyTLSOverrides(config, clusterName)
+
+		// Drop proxy or direct endpoints according to the endpoint mode
+		config = g.SelectEndpoints(config)
+
+		// Apply prefix to this config. Templates, sanitizing and the numbering
+		// of additional contexts can give different clusters the same name.
+		prefixedConfig := g.expandNamespaces(g.ApplyPrefix(config, clusterName), clusterName)
+		if name, clash := clashingName(mergedConfig, prefixedConfig); clash {
+			conflict := Conflict{Name: name, Existing: owners[name], Cluster: clusterName, Resolution: g.conflictStrategy}
+			switch g.conflictStrategy {
+			case ConflictSkip:
+				g.conflicts = append(g.conflicts, conflict)
+				continue
+			case ConflictOverwrite:
+				removeOwnedBy(mergedConfig, owners, conflict.Existing)
+			case ConflictRename:
+				base := g.ContextName(clusterName)
+				for n := 2; clash; n++ {
+					conflict.RenamedTo = fmt.Sprintf("%s-%d", base, n)
+					prefixedConfig = g.expandNamespaces(renameEntries(config, conflict.RenamedTo), clusterName)
+					_, clash = clashingName(mergedConfig, prefixedConfig)
+				}
+			default:
+				conflict.Resolution = ConflictError
+				return nil, fmt.Errorf("%s (rename the clusters or choose a conflict strategy)", conflict)
+			}
+			g.conflicts = append(g.conflicts, conflict)
+		}
+		if g.execCommand != "" {
+			if err := UseExecCredentials(prefixedConfig, g.execCommand); err != nil {
+				return nil, fmt.Errorf("failed to set exec credentials for cluster %s: %w", clusterName, err)
+			}
+		}
+		if g.oidc != nil {
+			if err := UseOIDC(prefixedConfig, *g.oidc); err != nil {
+				return nil, fmt.Errorf("failed to set OIDC login for cluster %s: %w", clusterName, err)
+			}
+		}
 
 		// Merge into the combined config
 		for name, cluster := range prefixedConfig.Clusters {
 			mergedConfig.Clusters[name] = cluster
+			owners[name] = clusterName
 		}
 
 		for name, context := range prefixedConfig.Contexts {
@@ -176,13 +384,16 @@ func (g *Generator) MergeConfigs(clusterKubeconfigs map[string]string) (*api.Con
 				}
 			}
 			mergedConfig.Contexts[name] = context
+			owners[name] = clusterName
 		}
 
 		for name, authInfo := range prefixedConfig.AuthInfos {
 			mergedConfig.AuthInfos[name] = authInfo
+			owners[name] = clusterName
 		}
 	}
 
+	DedupeAuthInfos(mergedConfig)
 	return mergedConfig, nil
 }
 
@@ -200,12 +411,33 @@ func (g *Generator) buildAptakubeExtensionJSON(tags []string) []byte {
 	return []byte(result)
 }
 
-// Serialize converts a kubeconfig to YAML format
+// Serialize converts a kubeconfig to YAML format, flattened, minified and
+// redacted if the generator is set to. config itself is left as it is.
 func (g *Generator) Serialize(config *api.Config) ([]byte, error) {
+	if g.flatten || g.minify || g.redact {
+		config = config.DeepCopy()
+	}
+	if g.flatten {
+		if err := Flatten(config); err != nil {
+			return nil, err
+		}
+	}
+	if g.minify {
+		PruneUnused(config)
+	}
+	if g.redact {
+		Redact(config)
+	}
+
 	data, err := clientcmd.Write(*config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize kubeconfig: %w", err)
 	}
+	if g.minify {
+		if data, err = stripEmptyFields(data); err != nil {
+			return nil, fmt.Errorf("failed to minify kubeconfig: %w", err)
+		}
+	}
 	return data, nil
 }
 
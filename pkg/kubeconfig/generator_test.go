@@ -222,6 +222,44 @@ func TestGenerator_ApplyPrefix(t *testing.T) {
 	})
 }
 
+func TestGenerator_ContextName(t *testing.T) {
+	tests := []struct {
+		prefix, suffix, separator string
+		want                      string
+	}{
+		{"prod-", "", "", "prod-cluster1"},
+		{"payments-", "-eu", "", "payments-cluster1-eu"},
+		{"payments", "eu", "-", "payments-cluster1-eu"},
+		{"", "eu", ".", "cluster1.eu"},
+		{"payments", "", "_", "payments_cluster1"},
+		{"", "", "-", "cluster1"},
+	}
+	for _, tt := range tests {
+		g := NewGenerator(tt.prefix)
+		g.SetSuffix(tt.suffix)
+		g.SetSeparator(tt.separator)
+		if got := g.ContextName("cluster1"); got != tt.want {
+			t.Errorf("ContextName() with prefix %q, suffix %q, separator %q = %q, want %q", tt.prefix, tt.suffix, tt.separator, got, tt.want)
+		}
+	}
+
+	g := NewGenerator("payments")
+	g.SetSuffix("eu")
+	g.SetSeparator("-")
+	renamed := g.ApplyPrefix(&api.Config{
+		Clusters:  map[string]*api.Cluster{"c": {Server: "https://server.example.com"}},
+		Contexts:  map[string]*api.Context{"c": {Cluster: "c", AuthInfo: "u"}},
+		AuthInfos: map[string]*api.AuthInfo{"u": {Token: "t"}},
+	}, "cluster1")
+	ctx, ok := renamed.Contexts["payments-cluster1-eu"]
+	if !ok {
+		t.Fatalf("contexts = %v, want payments-cluster1-eu", renamed.Contexts)
+	}
+	if ctx.Cluster != "payments-cluster1-eu" || ctx.AuthInfo != "payments-cluster1-eu" {
+		t.Errorf("context references %q/%q, want the renamed cluster and user", ctx.Cluster, ctx.AuthInfo)
+	}
+}
+
 func TestGenerator_MergeConfigs(t *testing.T) {
 	t.Run("merge multiple configs without prefix", func(t *testing.T) {
 		g := NewGenerator("")
@@ -436,4 +474,84 @@ func TestGenerator_Generate(t *testing.T) {
 			t.Errorf("expected 1 cluster, got %d", len(parsed.Clusters))
 		}
 	})
+
+	t.Run("output is identical across runs", func(t *testing.T) {
+		kubeconfigs := map[string]string{
+			"ace":        aceKubeconfig,
+			"my-cluster": sampleKubeconfig,
+			"another":    sampleKubeconfig2,
+		}
+
+		var first []byte
+		for run := 0; run < 20; run++ {
+			g := NewGenerator("prod-")
+			g.SetAllTags([]string{"prod", "eu"})
+			data, err := g.Generate(kubeconfigs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if run == 0 {
+				first = data
+			} else if string(data) != string(first) {
+				t.Fatalf("run %d differs from the first:\n%s\n---\n%s", run, first, data)
+			}
+		}
+
+		// The current (proxy) context of Rancher's kubeconfig keeps the base name
+		parsed, err := NewGenerator("").ParseKubeconfig(string(first))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		ace := parsed.Contexts["prod-ace"]
+		if ace == nil || parsed.Clusters[ace.Cluster].Server != "https://rancher.example.com/k8s/clusters/c-abc12" {
+			t.Errorf("prod-ace = %+v, want the proxy endpoint", ace)
+		}
+	})
+}
+
+func TestGenerator_NameTemplate(t *testing.T) {
+	g := NewGenerator("team-")
+	if err := g.SetNameTemplate("{{.Prefix}}{{.ClusterName}}-{{.Provider}}{{with .Labels.region}}-{{.}}{{end}}"); err != nil {
+		t.Fatalf("SetNameTemplate() error = %v", err)
+	}
+	g.SetClusterInfo("prod", NameData{Provider: "rke2", Labels: map[string]string{"region": "eu"}})
+	g.SetClusterInfo("dev", NameData{Provider: "k3s"})
+
+	if got := g.ContextName("prod"); got != "team-prod-rke2-eu" {
+		t.Errorf("ContextName(prod) = %q, want team-prod-rke2-eu", got)
+	}
+	if got := g.ContextName("dev"); got != "team-dev-k3s" {
+		t.Errorf("ContextName(dev) = %q, want team-dev-k3s", got)
+	}
+
+	if err := g.SetNameTemplate("{{.Unknown}}"); err == nil {
+		t.Error("expected an error for a template referring to an unknown field")
+	}
+
+	clash := NewGenerator("")
+	if err := clash.SetNameTemplate("{{.Provider}}"); err != nil {
+		t.Fatalf("SetNameTemplate() error = %v", err)
+	}
+	clash.SetClusterInfo("a", NameData{Provider: "rke2"})
+	clash.SetClusterInfo("b", NameData{Provider: "rke2"})
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster:
+    server: https://server.example.com
+contexts:
+- name: c
+  context:
+    cluster: c
+    user: u
+users:
+- name: u
+  user:
+    token: t
+current-context: c
+`
+	if _, err := clash.MergeConfigs(map[string]string{"a": kubeconfig, "b": kubeconfig}); err == nil {
+		t.Error("expected an error when the template gives two clusters the same name")
+	}
 }
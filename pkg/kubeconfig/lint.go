@@ -0,0 +1,179 @@
+package kubeconfig
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Severities of lint findings
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// certificateExpiryWarning is how long before it expires a client
+// certificate is reported
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// LintFinding is one problem Lint found in a kubeconfig
+type LintFinding struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	// Entry is the context, cluster or user concerned, e.g. "context prod"
+	Entry   string `json:"entry"`
+	Message string `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Entry, f.Message, f.Rule)
+}
+
+// Lint checks a kubeconfig for references to missing entries and files,
+// servers used with different credentials, missing or invalid certificate
+// authorities, expired client certificates and insecure settings. Findings
+// are sorted by entry; now is when certificates must still be valid.
+func Lint(config *api.Config, now time.Time) []LintFinding {
+	var findings []LintFinding
+	add := func(severity, rule, entry, format string, args ...any) {
+		findings = append(findings, LintFinding{Severity: severity, Rule: rule, Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if config.CurrentContext != "" {
+		if _, ok := config.Contexts[config.CurrentContext]; !ok {
+			add(LintError, "dangling-reference", "current-context", "context %s not found", config.CurrentContext)
+		}
+	}
+
+	credentialsByServer := make(map[string]map[string][]string)
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		entry := "context " + name
+		cluster, clusterOK := config.Clusters[context.Cluster]
+		if !clusterOK {
+			add(LintError, "dangling-reference", entry, "cluster %q not found", context.Cluster)
+		}
+		user, userOK := config.AuthInfos[context.AuthInfo]
+		if !userOK {
+			add(LintError, "dangling-reference", entry, "user %q not found", context.AuthInfo)
+		}
+		if clusterOK && userOK && cluster.Server != "" {
+			credentials := credentialsKey(user)
+			if credentialsByServer[cluster.Server] == nil {
+				credentialsByServer[cluster.Server] = make(map[string][]string)
+			}
+			credentialsByServer[cluster.Server][credentials] = append(credentialsByServer[cluster.Server][credentials], name)
+		}
+	}
+
+	for _, server := range sortedKeys(credentialsByServer) {
+		byCredentials := credentialsByServer[server]
+		if len(byCredentials) < 2 {
+			continue
+		}
+		var contexts []string
+		for _, names := range byCredentials {
+			contexts = append(contexts, names...)
+		}
+		sort.Strings(contexts)
+		add(LintWarning, "duplicate-server", "server "+server, "used with %d different credentials by contexts %s", len(byCredentials), strings.Join(contexts, ", "))
+	}
+
+	for _, name := range sortedKeys(config.Clusters) {
+		lintCluster(config.Clusters[name], "cluster "+name, add)
+	}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		lintUser(config.AuthInfos[name], "user "+name, now, add)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Entry < findings[j].Entry })
+	return findings
+}
+
+// lintCluster checks the server, certificate authority and TLS settings of a cluster
+func lintCluster(cluster *api.Cluster, entry string, add func(severity, rule, entry, format string, args ...any)) {
+	if cluster.Server == "" {
+		add(LintError, "missing-server", entry, "no server")
+	}
+	if strings.HasPrefix(strings.ToLower(cluster.Server), "http://") {
+		add(LintWarning, "insecure", entry, "server %s is not served over HTTPS", cluster.Server)
+	}
+	if cluster.InsecureSkipTLSVerify {
+		add(LintWarning, "insecure", entry, "skips TLS verification")
+	}
+
+	data := cluster.CertificateAuthorityData
+	if cluster.CertificateAuthority != "" {
+		var err error
+		if data, err = os.ReadFile(cluster.CertificateAuthority); err != nil {
+			add(LintError, "missing-file", entry, "certificate authority %s cannot be read", cluster.CertificateAuthority)
+			return
+		}
+	}
+	switch {
+	case len(data) == 0 && !cluster.InsecureSkipTLSVerify && strings.HasPrefix(strings.ToLower(cluster.Server), "https://"):
+		add(LintWarning, "empty-ca", entry, "no certificate authority; the server must present a certificate the system trusts")
+	case len(data) > 0 && !x509.NewCertPool().AppendCertsFromPEM(data):
+		add(LintError, "invalid-ca", entry, "certificate authority holds no PEM certificate")
+	}
+}
+
+// lintUser checks the client certificate and referenced files of a user
+func lintUser(user *api.AuthInfo, entry string, now time.Time, add func(severity, rule, entry, format string, args ...any)) {
+	for _, file := range []struct{ what, path string }{
+		{"client certificate", user.ClientCertificate},
+		{"client key", user.ClientKey},
+		{"token file", user.TokenFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			add(LintError, "missing-file", entry, "%s %s cannot be read", file.what, file.path)
+		}
+	}
+	if user.Username != "" || user.Password != "" {
+		add(LintWarning, "insecure", entry, "uses basic authentication")
+	}
+
+	data := user.ClientCertificateData
+	if len(data) == 0 && user.ClientCertificate != "" {
+		data, _ = os.ReadFile(user.ClientCertificate)
+	}
+	if len(data) == 0 {
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		add(LintError, "invalid-certificate", entry, "client certificate is not PEM encoded")
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		add(LintError, "invalid-certificate", entry, "client certificate cannot be parsed: %v", err)
+		return
+	}
+	switch {
+	case now.After(cert.NotAfter):
+		add(LintError, "expired-certificate", entry, "client certificate expired on %s", cert.NotAfter.UTC().Format(time.DateOnly))
+	case cert.NotAfter.Sub(now) < certificateExpiryWarning:
+		add(LintWarning, "expiring-certificate", entry, "client certificate expires on %s", cert.NotAfter.UTC().Format(time.DateOnly))
+	}
+}
+
+// credentialsKey identifies the credentials of a user, so that two users with
+// the same credentials under different names are not reported
+func credentialsKey(user *api.AuthInfo) string {
+	stripped := user.DeepCopy()
+	stripped.LocationOfOrigin = ""
+	stripped.Extensions = nil
+	data, _ := json.Marshal(stripped)
+	return string(data)
+}
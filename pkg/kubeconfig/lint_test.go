@@ -0,0 +1,105 @@
+package kubeconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// clientCertificate returns a PEM certificate valid until notAfter
+func clientCertificate(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "admin"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestLint(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, ca := writeCA(t)
+	config := api.NewConfig()
+	config.Clusters["prod"] = &api.Cluster{Server: "https://prod.example.com:6443", CertificateAuthorityData: ca}
+	config.Clusters["prod-copy"] = &api.Cluster{Server: "https://prod.example.com:6443", CertificateAuthorityData: ca}
+	config.Clusters["lab"] = &api.Cluster{Server: "http://lab.example.com:8080", InsecureSkipTLSVerify: true}
+	config.Clusters["public"] = &api.Cluster{Server: "https://rancher.example.com/k8s/clusters/c-1"}
+	config.Clusters["broken"] = &api.Cluster{Server: "https://broken.example.com", CertificateAuthorityData: []byte("garbage")}
+	config.AuthInfos["admin"] = &api.AuthInfo{ClientCertificateData: clientCertificate(t, now.Add(-time.Hour)), ClientKeyData: []byte("key")}
+	config.AuthInfos["ops"] = &api.AuthInfo{ClientCertificateData: clientCertificate(t, now.Add(7*24*time.Hour)), ClientKeyData: []byte("key")}
+	config.AuthInfos["token"] = &api.AuthInfo{Token: "t", TokenFile: "/does/not/exist"}
+	config.AuthInfos["basic"] = &api.AuthInfo{Username: "admin", Password: "secret"}
+	config.Contexts["prod"] = &api.Context{Cluster: "prod", AuthInfo: "admin"}
+	config.Contexts["prod-ops"] = &api.Context{Cluster: "prod-copy", AuthInfo: "ops"}
+	config.Contexts["lab"] = &api.Context{Cluster: "lab", AuthInfo: "basic"}
+	config.Contexts["public"] = &api.Context{Cluster: "public", AuthInfo: "token"}
+	config.Contexts["broken"] = &api.Context{Cluster: "broken", AuthInfo: "token"}
+	config.Contexts["dangling"] = &api.Context{Cluster: "gone", AuthInfo: "nobody"}
+	config.CurrentContext = "old"
+
+	var got []string
+	for _, finding := range Lint(config, now) {
+		got = append(got, finding.Severity+" "+finding.Rule+" "+finding.Entry)
+	}
+	want := []string{
+		"error invalid-ca cluster broken",
+		"warning insecure cluster lab",
+		"warning insecure cluster lab",
+		"warning empty-ca cluster public",
+		"error dangling-reference context dangling",
+		"error dangling-reference context dangling",
+		"error dangling-reference current-context",
+		"warning duplicate-server server https://prod.example.com:6443",
+		"error expired-certificate user admin",
+		"warning insecure user basic",
+		"warning expiring-certificate user ops",
+		"error missing-file user token",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Lint() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLintGenerated(t *testing.T) {
+	config, err := clientcmd.Load([]byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sample's CA data is not a real certificate
+	config.Clusters["my-cluster"].CertificateAuthorityData = nil
+	config.Clusters["my-cluster"].InsecureSkipTLSVerify = false
+	for _, finding := range Lint(config, time.Now()) {
+		if finding.Severity == LintError {
+			t.Errorf("unexpected error %s", finding)
+		}
+	}
+
+	// Users with the same credentials under different names are not reported
+	config.AuthInfos["copy"] = config.AuthInfos["my-user"].DeepCopy()
+	config.Contexts["copy"] = &api.Context{Cluster: "my-cluster", AuthInfo: "copy"}
+	for _, finding := range Lint(config, time.Now()) {
+		if finding.Rule == "duplicate-server" {
+			t.Errorf("unexpected %s", finding)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ToolVersion is recorded on every generated entry; the CLI sets it to its version
+var ToolVersion = "dev"
+
+// GetClusterProvenance returns the provenance recorded on a cluster entry, and whether there is any
+func GetClusterProvenance(cluster *api.Cluster) (Provenance, bool, error) {
+	return decodeProvenance(cluster.Extensions)
+}
+
+// SetClusterProvenance records provenance on a cluster entry
+func SetClusterProvenance(cluster *api.Cluster, provenance Provenance) error {
+	if cluster.Extensions == nil {
+		cluster.Extensions = make(map[string]runtime.Object)
+	}
+	return encodeProvenance(cluster.Extensions, provenance)
+}
+
+// decodeProvenance reads the provenance extension among extensions
+func decodeProvenance(extensions map[string]runtime.Object) (Provenance, bool, error) {
+	var provenance Provenance
+	ext, ok := extensions[ProvenanceExtension]
+	if !ok {
+		return provenance, false, nil
+	}
+	unknown, ok := ext.(*runtime.Unknown)
+	if !ok {
+		return provenance, false, fmt.Errorf("unexpected %s extension type %T", ProvenanceExtension, ext)
+	}
+	if err := json.Unmarshal(unknown.Raw, &provenance); err != nil {
+		return provenance, false, fmt.Errorf("failed to decode %s extension: %w", ProvenanceExtension, err)
+	}
+	return provenance, true, nil
+}
+
+// encodeProvenance stores provenance as the provenance extension in extensions
+func encodeProvenance(extensions map[string]runtime.Object, provenance Provenance) error {
+	raw, err := json.Marshal(provenance)
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	extensions[ProvenanceExtension] = &runtime.Unknown{Raw: raw}
+	return nil
+}
+
+// StampGenerated records when, and by which version of the tool, the entries
+// marked by MarkOwned were generated, on both their contexts and clusters
+func StampGenerated(config *api.Config, at time.Time) error {
+	at = at.UTC().Truncate(time.Second)
+	for _, name := range sortedKeys(config.Contexts) {
+		provenance, _, err := GetProvenance(config.Contexts[name])
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		if provenance.Source != generatedSource {
+			continue
+		}
+		provenance.GeneratedAt, provenance.ToolVersion = at, ToolVersion
+		if err := SetProvenance(config.Contexts[name], provenance); err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+	}
+	for _, name := range sortedKeys(config.Clusters) {
+		provenance, _, err := GetClusterProvenance(config.Clusters[name])
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", name, err)
+		}
+		if provenance.Source != generatedSource {
+			continue
+		}
+		provenance.GeneratedAt, provenance.ToolVersion = at, ToolVersion
+		if err := SetClusterProvenance(config.Clusters[name], provenance); err != nil {
+			return fmt.Errorf("cluster %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// CarryOverGeneratedAt keeps the generation time and tool version recorded in
+// previous for the generated contexts of config, and their clusters, that did
+// not change otherwise, so regenerating an unchanged kubeconfig gives the same
+// file. It returns the contexts whose stamp was kept.
+func CarryOverGeneratedAt(config, previous *api.Config) ([]string, error) {
+	var kept []string
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		old, ok := previous.Contexts[name]
+		if !ok {
+			continue
+		}
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return kept, fmt.Errorf("context %s: %w", name, err)
+		}
+		oldProvenance, _, err := GetProvenance(old)
+		if err != nil || provenance.Source != generatedSource || oldProvenance.GeneratedAt.IsZero() {
+			continue
+		}
+		if !sameEntries(config, name, previous, name) {
+			continue
+		}
+
+		provenance.GeneratedAt, provenance.ToolVersion = oldProvenance.GeneratedAt, oldProvenance.ToolVersion
+		if err := SetProvenance(context, provenance); err != nil {
+			return kept, fmt.Errorf("context %s: %w", name, err)
+		}
+		if cluster, ok := config.Clusters[context.Cluster]; ok {
+			clusterProvenance, _, err := GetClusterProvenance(cluster)
+			if err != nil {
+				return kept, fmt.Errorf("cluster %s: %w", context.Cluster, err)
+			}
+			if clusterProvenance.Source == generatedSource {
+				clusterProvenance.GeneratedAt, clusterProvenance.ToolVersion = oldProvenance.GeneratedAt, oldProvenance.ToolVersion
+				if err := SetClusterProvenance(cluster, clusterProvenance); err != nil {
+					return kept, fmt.Errorf("cluster %s: %w", context.Cluster, err)
+				}
+			}
+		}
+		kept = append(kept, name)
+	}
+	return kept, nil
+}
+
+// sameEntries reports whether context aName of a and context bName of b, with
+// their clusters and users, are the same apart from generation stamps and notes
+func sameEntries(a *api.Config, aName string, b *api.Config, bName string) bool {
+	left, err := unstampedEntries(a, aName)
+	if err != nil {
+		return false
+	}
+	right, err := unstampedEntries(b, bName)
+	return err == nil && bytes.Equal(left, right)
+}
+
+// unstampedEntries serializes a context with its cluster and user, leaving
+// out the generation stamps and notes
+func unstampedEntries(config *api.Config, name string) ([]byte, error) {
+	context, ok := config.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("context %s not found", name)
+	}
+	entries := api.NewConfig()
+	entries.Contexts["context"] = context.DeepCopy()
+	if cluster, ok := config.Clusters[context.Cluster]; ok {
+		entries.Clusters["cluster"] = cluster.DeepCopy()
+	}
+	if user, ok := config.AuthInfos[context.AuthInfo]; ok {
+		entries.AuthInfos["user"] = user.DeepCopy()
+	}
+	entries.Contexts["context"].Cluster, entries.Contexts["context"].AuthInfo = "cluster", "user"
+
+	for _, extensions := range []map[string]runtime.Object{entries.Contexts["context"].Extensions, clusterExtensions(entries)} {
+		provenance, ok, err := decodeProvenance(extensions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			provenance.GeneratedAt, provenance.ToolVersion, provenance.Notes = time.Time{}, "", nil
+			if err := encodeProvenance(extensions, provenance); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return clientcmd.Write(*entries)
+}
+
+// clusterExtensions returns the extensions of the single cluster of entries
+func clusterExtensions(entries *api.Config) map[string]runtime.Object {
+	if cluster, ok := entries.Clusters["cluster"]; ok {
+		return cluster.Extensions
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestMarkOwned_Clusters(t *testing.T) {
+	config := rancherConfig(t, "ace")
+	for _, name := range []string{"ace", "ace-fqdn"} {
+		provenance, ok, err := GetClusterProvenance(config.Clusters[name])
+		if err != nil || !ok {
+			t.Fatalf("GetClusterProvenance(%s) = %v, %v", name, ok, err)
+		}
+		if provenance.Source != generatedSource || provenance.RancherURL != "https://rancher.example.com" || provenance.ClusterID != "ace" {
+			t.Errorf("cluster %s provenance = %+v", name, provenance)
+		}
+	}
+}
+
+func TestStampGenerated(t *testing.T) {
+	config := rancherConfig(t, "prod")
+	config.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube"}
+
+	defer func(version string) { ToolVersion = version }(ToolVersion)
+	ToolVersion = "v1.2.3"
+	at := time.Date(2026, 10, 17, 12, 30, 45, 500, time.FixedZone("CEST", 2*60*60))
+	if err := StampGenerated(config, at); err != nil {
+		t.Fatalf("StampGenerated() error = %v", err)
+	}
+
+	want := time.Date(2026, 10, 17, 10, 30, 45, 0, time.UTC)
+	contextProvenance, _, _ := GetProvenance(config.Contexts["prod"])
+	clusterProvenance, _, _ := GetClusterProvenance(config.Clusters["prod"])
+	for _, provenance := range []Provenance{contextProvenance, clusterProvenance} {
+		if !provenance.GeneratedAt.Equal(want) || provenance.ToolVersion != "v1.2.3" {
+			t.Errorf("provenance = %+v, want generated at %s by v1.2.3", provenance, want)
+		}
+	}
+	if _, ok, _ := GetProvenance(config.Contexts["minikube"]); ok {
+		t.Error("an entry that was not generated was stamped")
+	}
+	if _, ok, _ := GetClusterProvenance(config.Clusters["minikube"]); ok {
+		t.Error("a cluster that was not generated was stamped")
+	}
+}
+
+func TestCarryOverGeneratedAt(t *testing.T) {
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := rancherConfig(t, "prod", "staging")
+	if err := StampGenerated(previous, earlier); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddNote(previous.Contexts["prod"], "owned by team-x"); err != nil {
+		t.Fatal(err)
+	}
+
+	config := rancherConfig(t, "prod", "staging", "new")
+	config.AuthInfos["staging"].Token = "kubeconfig-u-1:rotated"
+	if err := StampGenerated(config, earlier.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := CarryOverGeneratedAt(config, previous)
+	if err != nil {
+		t.Fatalf("CarryOverGeneratedAt() error = %v", err)
+	}
+	if strings.Join(kept, ",") != "prod" {
+		t.Errorf("CarryOverGeneratedAt() kept %v, want prod", kept)
+	}
+	for name, want := range map[string]time.Time{"prod": earlier, "staging": earlier.Add(time.Hour), "new": earlier.Add(time.Hour)} {
+		provenance, _, _ := GetProvenance(config.Contexts[name])
+		clusterProvenance, _, _ := GetClusterProvenance(config.Clusters[name])
+		if !provenance.GeneratedAt.Equal(want) || !clusterProvenance.GeneratedAt.Equal(want) {
+			t.Errorf("%s generated at %s (cluster %s), want %s", name, provenance.GeneratedAt, clusterProvenance.GeneratedAt, want)
+		}
+	}
+}
+
+func TestGenerator_SetGeneratedAt(t *testing.T) {
+	at := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	g := NewGenerator("prod-")
+	g.SetGeneratedAt(at)
+	config, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	for _, name := range []string{"prod-ace", "prod-ace-1"} {
+		provenance, _, _ := GetProvenance(config.Contexts[name])
+		if !provenance.GeneratedAt.Equal(at) || provenance.ClusterID != "c-abc12" {
+			t.Errorf("context %s provenance = %+v", name, provenance)
+		}
+		clusterProvenance, _, _ := GetClusterProvenance(config.Clusters[name])
+		if !clusterProvenance.GeneratedAt.Equal(at) || clusterProvenance.RancherURL != "https://rancher.example.com" {
+			t.Errorf("cluster %s provenance = %+v", name, clusterProvenance)
+		}
+	}
+
+	// Without a time nothing is stamped, so the output only depends on the input
+	config, err = NewGenerator("prod-").MergeConfigs(map[string]string{"ace": aceKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	if provenance, _, _ := GetProvenance(config.Contexts["prod-ace"]); !provenance.GeneratedAt.IsZero() {
+		t.Errorf("context stamped without a generation time: %+v", provenance)
+	}
+}
+
+func TestUpdateManaged_ClusterProvenance(t *testing.T) {
+	// A generated cluster no context uses any more is still recognized as managed
+	existing := rancherConfig(t, "prod")
+	delete(existing.Contexts, "prod")
+
+	if _, err := UpdateManaged(existing, rancherConfig(t, "prod")); err != nil {
+		t.Errorf("UpdateManaged() error = %v", err)
+	}
+}
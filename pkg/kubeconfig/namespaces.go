@@ -0,0 +1,53 @@
+package kubeconfig
+
+import (
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// SetNamespaces expands the contexts of a cluster into one context per
+// namespace, named <context>-<namespace>, for users who may only work in
+// those namespaces
+func (g *Generator) SetNamespaces(clusterName string, namespaces []string) {
+	if g.namespaces == nil {
+		g.namespaces = make(map[string][]string)
+	}
+	g.namespaces[clusterName] = namespaces
+}
+
+// SetAllNamespaces expands the contexts of every cluster without namespaces
+// of its own into one context per namespace
+func (g *Generator) SetAllNamespaces(namespaces []string) {
+	g.SetNamespaces("*", namespaces)
+}
+
+// namespacesOf returns the namespaces the contexts of a cluster are expanded into
+func (g *Generator) namespacesOf(clusterName string) []string {
+	if namespaces, ok := g.namespaces[clusterName]; ok {
+		return namespaces
+	}
+	return g.namespaces["*"]
+}
+
+// expandNamespaces replaces every context of a single cluster's kubeconfig
+// with one copy per namespace of the cluster. The current context becomes
+// the copy of the first namespace.
+func (g *Generator) expandNamespaces(config *api.Config, clusterName string) *api.Config {
+	namespaces := g.namespacesOf(clusterName)
+	if len(namespaces) == 0 {
+		return config
+	}
+
+	contexts := make(map[string]*api.Context, len(config.Contexts)*len(namespaces))
+	for _, name := range sortedKeys(config.Contexts) {
+		for _, namespace := range namespaces {
+			context := config.Contexts[name].DeepCopy()
+			context.Namespace = namespace
+			contexts[name+"-"+namespace] = context
+		}
+	}
+	if config.CurrentContext != "" {
+		config.CurrentContext = config.CurrentContext + "-" + namespaces[0]
+	}
+	config.Contexts = contexts
+	return config
+}
@@ -0,0 +1,38 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	g := NewGenerator("prod-")
+	g.SetAllNamespaces([]string{"web", "db"})
+	g.SetNamespaces("app", []string{"payments"})
+	g.SetNamespaces("admin", nil)
+	config, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig, "app": sampleKubeconfig, "admin": sampleKubeconfig2})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	want := "prod-ace-1-db,prod-ace-1-web,prod-ace-db,prod-ace-web,prod-admin,prod-app-payments"
+	if got := strings.Join(sortedKeys(config.Contexts), ","); got != want {
+		t.Fatalf("contexts = %s, want %s", got, want)
+	}
+	for name, namespace := range map[string]string{"prod-ace-web": "web", "prod-ace-1-db": "db", "prod-app-payments": "payments", "prod-admin": ""} {
+		if got := config.Contexts[name].Namespace; got != namespace {
+			t.Errorf("namespace of %s = %q, want %q", name, got, namespace)
+		}
+	}
+	if web, db := config.Contexts["prod-ace-web"], config.Contexts["prod-ace-db"]; web.Cluster != db.Cluster || web.AuthInfo != db.AuthInfo || web == db {
+		t.Error("the contexts of a cluster should be distinct copies sharing its cluster and user")
+	}
+	if len(config.Clusters) != 4 {
+		t.Errorf("clusters = %v, want one per endpoint", sortedKeys(config.Clusters))
+	}
+
+	provenance, _, err := GetProvenance(config.Contexts["prod-ace-db"])
+	if err != nil || provenance.ClusterID != "c-abc12" {
+		t.Errorf("provenance of an expanded context = %+v, %v", provenance, err)
+	}
+}
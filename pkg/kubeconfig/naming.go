@@ -0,0 +1,62 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// NameData is the data available to naming templates
+type NameData struct {
+	Prefix      string
+	Suffix      string
+	ClusterName string
+	ClusterID   string
+	Provider    string
+	Instance    string
+	Labels      map[string]string
+}
+
+// SetNameTemplate names clusters with a Go template evaluated against NameData,
+// e.g. "{{.Prefix}}{{.ClusterName}}-{{.Provider}}", instead of joining the
+// prefix, cluster name and suffix. An empty template restores the default.
+func (g *Generator) SetNameTemplate(text string) error {
+	if text == "" {
+		g.nameTemplate = nil
+		return nil
+	}
+	tmpl, err := template.New("name").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid name template %q: %w", text, err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, NameData{ClusterName: "cluster", Labels: map[string]string{}}); err != nil {
+		return fmt.Errorf("invalid name template %q: %w", text, err)
+	}
+	g.nameTemplate = tmpl
+	return nil
+}
+
+// SetClusterInfo records what the naming template knows about a cluster beyond its name
+func (g *Generator) SetClusterInfo(clusterName string, data NameData) {
+	if g.clusterInfo == nil {
+		g.clusterInfo = make(map[string]NameData)
+	}
+	g.clusterInfo[clusterName] = data
+}
+
+// renderName evaluates the naming template for a cluster. An empty result or
+// a failing template falls back to the default name.
+func (g *Generator) renderName(clusterName string) (string, bool) {
+	data := g.clusterInfo[clusterName]
+	data.Prefix, data.Suffix, data.ClusterName = g.prefix, g.suffix, clusterName
+	if data.Labels == nil {
+		data.Labels = map[string]string{}
+	}
+
+	var name strings.Builder
+	if err := g.nameTemplate.Execute(&name, data); err != nil {
+		return "", false
+	}
+	result := strings.TrimSpace(name.String())
+	return result, result != ""
+}
@@ -0,0 +1,176 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ProvenanceExtension is the name of the context extension that records where
+// an entry of a managed kubeconfig came from
+const ProvenanceExtension = "kubeconfig-wrangler"
+
+// Provenance describes where a context came from, along with the notes
+// attached to it by "annotate"
+type Provenance struct {
+	// Source is how the entry was added, e.g. "normalize"
+	Source string `json:"source,omitempty"`
+
+	// File is the kubeconfig the entry was imported from
+	File string `json:"file,omitempty"`
+
+	// RancherURL and ClusterID identify the Rancher cluster, when the entry goes
+	// through the Rancher proxy
+	RancherURL string `json:"rancherUrl,omitempty"`
+	ClusterID  string `json:"clusterId,omitempty"`
+
+	// ImportedAt is when the entry was added
+	ImportedAt time.Time `json:"importedAt,omitzero"`
+
+	// GeneratedAt is when a generated entry was last changed, and ToolVersion
+	// the version of kubeconfig-wrangler that generated it
+	GeneratedAt time.Time `json:"generatedAt,omitzero"`
+	ToolVersion string    `json:"toolVersion,omitempty"`
+
+	// Group is the value of the cluster label the output is split by, e.g.
+	// the cluster's environment
+	Group string `json:"group,omitempty"`
+
+	// Notes are free-form remarks about the context, e.g. its owner
+	Notes []string `json:"notes,omitempty"`
+}
+
+// SetProvenance records provenance on a context
+func SetProvenance(context *api.Context, provenance Provenance) error {
+	if context.Extensions == nil {
+		context.Extensions = make(map[string]runtime.Object)
+	}
+	return encodeProvenance(context.Extensions, provenance)
+}
+
+// Tidy removes what a kubeconfig cannot use: contexts referring to a missing
+// cluster or user, clusters and users no context refers to, and a current
+// context that does not exist. It returns a description of each fix.
+func Tidy(config *api.Config) []string {
+	var fixes []string
+
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		if _, ok := config.Clusters[context.Cluster]; !ok {
+			fixes = append(fixes, fmt.Sprintf("removed context %s: cluster %q not found", name, context.Cluster))
+			delete(config.Contexts, name)
+			continue
+		}
+		if _, ok := config.AuthInfos[context.AuthInfo]; !ok && context.AuthInfo != "" {
+			fixes = append(fixes, fmt.Sprintf("removed context %s: user %q not found", name, context.AuthInfo))
+			delete(config.Contexts, name)
+		}
+	}
+
+	usedClusters := make(map[string]bool)
+	usedAuthInfos := make(map[string]bool)
+	for _, context := range config.Contexts {
+		usedClusters[context.Cluster] = true
+		usedAuthInfos[context.AuthInfo] = true
+	}
+	for _, name := range sortedKeys(config.Clusters) {
+		if !usedClusters[name] {
+			fixes = append(fixes, fmt.Sprintf("removed cluster %s: not used by any context", name))
+			delete(config.Clusters, name)
+		}
+	}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		if !usedAuthInfos[name] {
+			fixes = append(fixes, fmt.Sprintf("removed user %s: not used by any context", name))
+			delete(config.AuthInfos, name)
+		}
+	}
+
+	if _, ok := config.Contexts[config.CurrentContext]; !ok && config.CurrentContext != "" {
+		fixes = append(fixes, fmt.Sprintf("cleared current context %s: context not found", config.CurrentContext))
+		config.CurrentContext = ""
+	}
+	return fixes
+}
+
+// Normalize brings a kubeconfig downloaded from the Rancher UI in line with the
+// generated ones: unusable entries are removed, names get the generator's
+// prefix like generated entries do, and every context records its provenance.
+// It returns the normalized config and the fixes applied.
+func (g *Generator) Normalize(data []byte, file string) (*api.Config, []string, error) {
+	config, err := g.ParseKubeconfig(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fixes := Tidy(config)
+	if len(config.Contexts) == 0 {
+		return nil, fixes, fmt.Errorf("no usable contexts")
+	}
+
+	clusterName := baseClusterName(config)
+	normalized := g.ApplyPrefix(config, clusterName)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, context := range normalized.Contexts {
+		provenance := Provenance{Source: "normalize", File: file, ImportedAt: now}
+		if cluster, ok := normalized.Clusters[context.Cluster]; ok {
+			if idx := strings.Index(cluster.Server, rancherProxyPath); idx >= 0 {
+				provenance.RancherURL = cluster.Server[:idx]
+				provenance.ClusterID = strings.Trim(cluster.Server[idx+len(rancherProxyPath):], "/")
+			}
+		}
+		if err := SetProvenance(context, provenance); err != nil {
+			return nil, fixes, err
+		}
+	}
+	return normalized, fixes, nil
+}
+
+// MergeInto adds every cluster, context and user of src to dst, replacing
+// entries of the same name. It returns the replaced entries.
+func MergeInto(dst, src *api.Config) []string {
+	var replaced []string
+	for _, name := range sortedKeys(src.Clusters) {
+		if _, exists := dst.Clusters[name]; exists {
+			replaced = append(replaced, "cluster "+name)
+		}
+		dst.Clusters[name] = src.Clusters[name]
+	}
+	for _, name := range sortedKeys(src.Contexts) {
+		if _, exists := dst.Contexts[name]; exists {
+			replaced = append(replaced, "context "+name)
+		}
+		dst.Contexts[name] = src.Contexts[name]
+	}
+	for _, name := range sortedKeys(src.AuthInfos) {
+		if _, exists := dst.AuthInfos[name]; exists {
+			replaced = append(replaced, "user "+name)
+		}
+		dst.AuthInfos[name] = src.AuthInfos[name]
+	}
+	return replaced
+}
+
+// baseClusterName picks the name a Rancher-generated kubeconfig is known by: the
+// cluster of its current context, or else the first cluster by name
+func baseClusterName(config *api.Config) string {
+	if context, ok := config.Contexts[config.CurrentContext]; ok {
+		return context.Cluster
+	}
+	return sortedKeys(config.Clusters)[0]
+}
+
+// sortedKeys returns the keys of a kubeconfig section in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package kubeconfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// rancherUIKubeconfig is a kubeconfig as downloaded from the Rancher UI, with a
+// stale context left behind by hand editing
+const rancherUIKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://rancher.example.com/k8s/clusters/c-m-abc123
+  name: prod
+- cluster:
+    server: https://old.example.com
+  name: unused
+contexts:
+- context:
+    cluster: prod
+    user: prod
+  name: prod
+- context:
+    cluster: deleted
+    user: prod
+  name: stale
+current-context: prod
+users:
+- name: prod
+  user:
+    token: kubeconfig-u-abc:secret
+`
+
+func TestTidy(t *testing.T) {
+	config := api.NewConfig()
+	config.Clusters["a"] = &api.Cluster{Server: "https://a"}
+	config.Clusters["orphan"] = &api.Cluster{Server: "https://orphan"}
+	config.AuthInfos["u"] = &api.AuthInfo{Token: "t"}
+	config.AuthInfos["orphan"] = &api.AuthInfo{Token: "t"}
+	config.Contexts["a"] = &api.Context{Cluster: "a", AuthInfo: "u"}
+	config.Contexts["no-user"] = &api.Context{Cluster: "a", AuthInfo: "missing"}
+	config.CurrentContext = "no-user"
+
+	fixes := Tidy(config)
+	if len(fixes) != 4 {
+		t.Errorf("Tidy() fixes = %v, want 4", fixes)
+	}
+	if len(config.Contexts) != 1 || len(config.Clusters) != 1 || len(config.AuthInfos) != 1 {
+		t.Errorf("unexpected entries after Tidy: %d contexts, %d clusters, %d users",
+			len(config.Contexts), len(config.Clusters), len(config.AuthInfos))
+	}
+	if config.CurrentContext != "" {
+		t.Errorf("CurrentContext = %q, want cleared", config.CurrentContext)
+	}
+}
+
+func TestGenerator_Normalize(t *testing.T) {
+	g := NewGenerator("rancher-")
+	config, fixes, err := g.Normalize([]byte(rancherUIKubeconfig), "/tmp/prod.yaml")
+	if err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if len(fixes) != 2 {
+		t.Errorf("fixes = %v, want the stale context and unused cluster removed", fixes)
+	}
+
+	context, ok := config.Contexts["rancher-prod"]
+	if !ok || len(config.Contexts) != 1 {
+		t.Fatalf("contexts = %v, want only rancher-prod", config.Contexts)
+	}
+	if context.Cluster != "rancher-prod" || context.AuthInfo != "rancher-prod" {
+		t.Errorf("context references = %s/%s, want rancher-prod", context.Cluster, context.AuthInfo)
+	}
+
+	ext, ok := context.Extensions[ProvenanceExtension].(*runtime.Unknown)
+	if !ok {
+		t.Fatalf("missing %s extension", ProvenanceExtension)
+	}
+	var provenance Provenance
+	if err := json.Unmarshal(ext.Raw, &provenance); err != nil {
+		t.Fatalf("invalid provenance: %v", err)
+	}
+	if provenance.Source != "normalize" || provenance.File != "/tmp/prod.yaml" ||
+		provenance.RancherURL != "https://rancher.example.com" || provenance.ClusterID != "c-m-abc123" {
+		t.Errorf("provenance = %+v", provenance)
+	}
+
+	data, err := g.Serialize(config)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if !strings.Contains(string(data), ProvenanceExtension) {
+		t.Error("provenance extension not serialized")
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	dst := api.NewConfig()
+	dst.Clusters["keep"] = &api.Cluster{Server: "https://keep"}
+	dst.Clusters["prod"] = &api.Cluster{Server: "https://old"}
+
+	src := api.NewConfig()
+	src.Clusters["prod"] = &api.Cluster{Server: "https://new"}
+	src.Contexts["prod"] = &api.Context{Cluster: "prod"}
+
+	replaced := MergeInto(dst, src)
+	if len(replaced) != 1 || replaced[0] != "cluster prod" {
+		t.Errorf("replaced = %v, want [cluster prod]", replaced)
+	}
+	if dst.Clusters["prod"].Server != "https://new" || dst.Clusters["keep"] == nil || dst.Contexts["prod"] == nil {
+		t.Errorf("unexpected merge result: %+v", dst)
+	}
+}
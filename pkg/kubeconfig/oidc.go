@@ -0,0 +1,80 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// oidcLoginAPIVersion is the ExecCredential version kubelogin speaks
+const oidcLoginAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+// OIDCConfig describes the OpenID Connect provider, e.g. Keycloak, that the
+// API servers of the clusters trust
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	ExtraScopes  []string
+
+	// AuthProvider writes the legacy oidc auth-provider, for tools that do not
+	// support exec plugins, instead of running kubelogin ("kubectl oidc-login")
+	AuthProvider bool
+}
+
+// UseOIDC replaces the credentials of the generated contexts, marked by
+// MarkOwned, with an OIDC login against the configured issuer
+func UseOIDC(config *api.Config, oidc OIDCConfig) error {
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		user, ok := config.AuthInfos[context.AuthInfo]
+		if provenance.Source != generatedSource || !ok {
+			continue
+		}
+		*user = api.AuthInfo{LocationOfOrigin: user.LocationOfOrigin}
+		if oidc.AuthProvider {
+			user.AuthProvider = oidc.authProvider()
+		} else {
+			user.Exec = oidc.exec()
+		}
+	}
+	return nil
+}
+
+// exec returns the kubelogin exec plugin logging in with the settings
+func (o OIDCConfig) exec() *api.ExecConfig {
+	args := []string{"oidc-login", "get-token", "--oidc-issuer-url=" + o.IssuerURL, "--oidc-client-id=" + o.ClientID}
+	if o.ClientSecret != "" {
+		args = append(args, "--oidc-client-secret="+o.ClientSecret)
+	}
+	for _, scope := range o.ExtraScopes {
+		args = append(args, "--oidc-extra-scope="+scope)
+	}
+	return &api.ExecConfig{
+		APIVersion:      oidcLoginAPIVersion,
+		Command:         "kubectl",
+		Args:            args,
+		InteractiveMode: api.IfAvailableExecInteractiveMode,
+		InstallHint:     "Install kubelogin (https://github.com/int128/kubelogin), e.g. with \"kubectl krew install oidc-login\"",
+	}
+}
+
+// authProvider returns the legacy oidc auth-provider configuration
+func (o OIDCConfig) authProvider() *api.AuthProviderConfig {
+	cfg := map[string]string{
+		"idp-issuer-url": o.IssuerURL,
+		"client-id":      o.ClientID,
+	}
+	if o.ClientSecret != "" {
+		cfg["client-secret"] = o.ClientSecret
+	}
+	if len(o.ExtraScopes) > 0 {
+		cfg["extra-scopes"] = strings.Join(o.ExtraScopes, ",")
+	}
+	return &api.AuthProviderConfig{Name: "oidc", Config: cfg}
+}
@@ -0,0 +1,101 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestUseOIDC_Exec(t *testing.T) {
+	config := rancherConfig(t, "prod")
+	config.AuthInfos["minikube"] = &api.AuthInfo{Token: "static"}
+	config.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+
+	oidc := OIDCConfig{IssuerURL: "https://keycloak.example.com/realms/k8s", ClientID: "kubernetes", ExtraScopes: []string{"groups", "email"}}
+	if err := UseOIDC(config, oidc); err != nil {
+		t.Fatalf("UseOIDC() error = %v", err)
+	}
+
+	user := config.AuthInfos["prod"]
+	if user.Token != "" || user.AuthProvider != nil || user.Exec == nil {
+		t.Fatalf("user = %+v, want an exec plugin and no token", user)
+	}
+	want := "oidc-login get-token --oidc-issuer-url=https://keycloak.example.com/realms/k8s --oidc-client-id=kubernetes --oidc-extra-scope=groups --oidc-extra-scope=email"
+	if got := strings.Join(user.Exec.Args, " "); user.Exec.Command != "kubectl" || got != want {
+		t.Errorf("exec = %s %s, want kubectl %s", user.Exec.Command, got, want)
+	}
+	if user := config.AuthInfos["minikube"]; user.Token != "static" || user.Exec != nil {
+		t.Errorf("user not generated from Rancher was rewritten: %+v", user)
+	}
+}
+
+func TestUseOIDC_AuthProvider(t *testing.T) {
+	config := rancherConfig(t, "prod")
+	oidc := OIDCConfig{
+		IssuerURL:    "https://keycloak.example.com/realms/k8s",
+		ClientID:     "kubernetes",
+		ClientSecret: "s3cret",
+		ExtraScopes:  []string{"groups", "email"},
+		AuthProvider: true,
+	}
+	if err := UseOIDC(config, oidc); err != nil {
+		t.Fatalf("UseOIDC() error = %v", err)
+	}
+
+	user := config.AuthInfos["prod"]
+	if user.Token != "" || user.Exec != nil || user.AuthProvider == nil || user.AuthProvider.Name != "oidc" {
+		t.Fatalf("user = %+v, want the oidc auth-provider", user)
+	}
+	want := map[string]string{
+		"idp-issuer-url": "https://keycloak.example.com/realms/k8s",
+		"client-id":      "kubernetes",
+		"client-secret":  "s3cret",
+		"extra-scopes":   "groups,email",
+	}
+	for key, value := range want {
+		if got := user.AuthProvider.Config[key]; got != value {
+			t.Errorf("auth-provider %s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestGenerator_SetOIDC(t *testing.T) {
+	proxy := strings.Replace(sampleKubeconfig, "https://cluster1.example.com:6443", "https://rancher.example.com/k8s/clusters/c-1", 1)
+	g := NewGenerator("")
+	g.SetOIDC(&OIDCConfig{IssuerURL: "https://keycloak.example.com/realms/k8s", ClientID: "kubernetes"})
+	config, err := g.MergeConfigs(map[string]string{"app": proxy})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	data, err := g.Serialize(config)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if strings.Contains(string(data), "test-token-12345") {
+		t.Error("serialized kubeconfig still embeds the token")
+	}
+	if !strings.Contains(string(data), "oidc-login") {
+		t.Error("serialized kubeconfig does not log in with kubelogin")
+	}
+}
+
+func TestGenerator_SetOIDC_DirectEndpoints(t *testing.T) {
+	g := NewGenerator("")
+	g.SetEndpointMode(EndpointModeDirect, nil)
+	g.SetOIDC(&OIDCConfig{IssuerURL: "https://keycloak.example.com/realms/k8s", ClientID: "kubernetes"})
+	config, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	context := config.Contexts["ace"]
+	if context == nil || config.Clusters[context.Cluster].Server != "https://10.0.0.10:6443" {
+		t.Fatalf("contexts = %v, want ace on the direct endpoint", config.Contexts)
+	}
+	if user := config.AuthInfos[context.AuthInfo]; user.Token != "" || user.Exec == nil {
+		t.Errorf("user of the direct endpoint = %+v, want a kubelogin exec plugin", user)
+	}
+}
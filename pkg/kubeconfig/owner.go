@@ -0,0 +1,145 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// generatedSource is the provenance source of the contexts generated from Rancher
+const generatedSource = "generate"
+
+// MarkOwned records on every context of a single cluster's kubeconfig, and on
+// the clusters they use, the Rancher server and cluster it was generated from,
+// so it can be recognized, and pruned once the cluster is deleted, in a file
+// also holding other entries. Contexts using an authorized cluster endpoint,
+// whose server is not the Rancher proxy, belong to the proxy context sharing
+// their user.
+func MarkOwned(config *api.Config) error {
+	type owner struct{ rancherURL, clusterID string }
+	byUser := make(map[string]owner)
+	byContext := make(map[string]owner)
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		cluster, ok := config.Clusters[context.Cluster]
+		if !ok {
+			continue
+		}
+		if idx := strings.Index(cluster.Server, rancherProxyPath); idx >= 0 {
+			o := owner{cluster.Server[:idx], strings.Trim(cluster.Server[idx+len(rancherProxyPath):], "/")}
+			byContext[name] = o
+			byUser[context.AuthInfo] = o
+		}
+	}
+
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		o, ok := byContext[name]
+		if !ok {
+			if o, ok = byUser[context.AuthInfo]; !ok {
+				continue
+			}
+		}
+
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		provenance.Source, provenance.RancherURL, provenance.ClusterID = generatedSource, o.rancherURL, o.clusterID
+		if err := SetProvenance(context, provenance); err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+
+		if cluster, ok := config.Clusters[context.Cluster]; ok {
+			clusterProvenance, _, err := GetClusterProvenance(cluster)
+			if err != nil {
+				return fmt.Errorf("cluster %s: %w", context.Cluster, err)
+			}
+			clusterProvenance.Source, clusterProvenance.RancherURL, clusterProvenance.ClusterID = generatedSource, o.rancherURL, o.clusterID
+			if err := SetClusterProvenance(cluster, clusterProvenance); err != nil {
+				return fmt.Errorf("cluster %s: %w", context.Cluster, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RancherServers returns the Rancher URLs the generated contexts of a
+// kubeconfig were generated from
+func RancherServers(config *api.Config) []string {
+	seen := make(map[string]bool)
+	var servers []string
+	for _, name := range sortedKeys(config.Contexts) {
+		provenance, _, _ := GetProvenance(config.Contexts[name])
+		if provenance.Source == generatedSource && provenance.RancherURL != "" && !seen[provenance.RancherURL] {
+			seen[provenance.RancherURL] = true
+			servers = append(servers, provenance.RancherURL)
+		}
+	}
+	return servers
+}
+
+// ManagedContexts returns the names of the contexts of a kubeconfig generated
+// from any Rancher server, in name order
+func ManagedContexts(config *api.Config) []string {
+	var names []string
+	for _, name := range sortedKeys(config.Contexts) {
+		if provenance, _, _ := GetProvenance(config.Contexts[name]); provenance.Source == generatedSource {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// OwnedContexts returns the contexts of a kubeconfig generated from the
+// Rancher server at rancherURL, by cluster ID and in name order
+func OwnedContexts(config *api.Config, rancherURL string) map[string][]string {
+	owned := make(map[string][]string)
+	for _, name := range sortedKeys(config.Contexts) {
+		provenance, _, _ := GetProvenance(config.Contexts[name])
+		if provenance.Source == generatedSource && provenance.RancherURL == rancherURL && provenance.ClusterID != "" {
+			owned[provenance.ClusterID] = append(owned[provenance.ClusterID], name)
+		}
+	}
+	return owned
+}
+
+// Prune removes the generated contexts whose cluster no longer exists, given
+// the IDs of the clusters that do by Rancher URL, along with the clusters and
+// users only they referred to. Contexts of Rancher servers missing from
+// existing are kept, as nothing is known about their clusters. It returns the
+// removed contexts.
+func Prune(config *api.Config, existing map[string][]string) []string {
+	var removed []string
+	referenced := make(map[string]bool)
+	referencedUsers := make(map[string]bool)
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		provenance, _, _ := GetProvenance(context)
+		clusterIDs, known := existing[provenance.RancherURL]
+		if provenance.Source != generatedSource || !known || provenance.ClusterID == "" || slices.Contains(clusterIDs, provenance.ClusterID) {
+			continue
+		}
+		delete(config.Contexts, name)
+		removed = append(removed, name)
+		referenced[context.Cluster] = true
+		referencedUsers[context.AuthInfo] = true
+	}
+
+	for _, context := range config.Contexts {
+		delete(referenced, context.Cluster)
+		delete(referencedUsers, context.AuthInfo)
+	}
+	for name := range referenced {
+		delete(config.Clusters, name)
+	}
+	for name := range referencedUsers {
+		delete(config.AuthInfos, name)
+	}
+	if _, ok := config.Contexts[config.CurrentContext]; !ok {
+		config.CurrentContext = ""
+	}
+	return removed
+}
@@ -0,0 +1,61 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestMarkOwned(t *testing.T) {
+	config := rancherConfig(t, "ace")
+	for _, name := range []string{"ace", "ace-fqdn"} {
+		provenance, ok, err := GetProvenance(config.Contexts[name])
+		if err != nil || !ok {
+			t.Fatalf("GetProvenance(%s) = %v, %v", name, ok, err)
+		}
+		if provenance.RancherURL != "https://rancher.example.com" || provenance.ClusterID != "ace" {
+			t.Errorf("context %s owned by %q cluster %q, want rancher.example.com cluster ace", name, provenance.RancherURL, provenance.ClusterID)
+		}
+	}
+	if servers := RancherServers(config); len(servers) != 1 || servers[0] != "https://rancher.example.com" {
+		t.Errorf("RancherServers() = %v", servers)
+	}
+	if owned := OwnedContexts(config, "https://rancher.example.com"); len(owned) != 1 || strings.Join(owned["ace"], ",") != "ace,ace-fqdn" {
+		t.Errorf("OwnedContexts() = %v", owned)
+	}
+	if owned := OwnedContexts(config, "https://other.example.com"); len(owned) != 0 {
+		t.Errorf("OwnedContexts() of another server = %v", owned)
+	}
+
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+	if managed := ManagedContexts(config); strings.Join(managed, ",") != "ace,ace-fqdn" {
+		t.Errorf("ManagedContexts() = %v, want ace and ace-fqdn", managed)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	config := rancherConfig(t, "prod", "ace", "gone")
+	config.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	config.AuthInfos["minikube"] = &api.AuthInfo{Token: "t"}
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+	config.CurrentContext = "gone"
+
+	if removed := Prune(config, map[string][]string{"https://other.example.com": {}}); len(removed) != 0 {
+		t.Errorf("Prune() removed %v for an unrelated Rancher server", removed)
+	}
+
+	removed := Prune(config, map[string][]string{"https://rancher.example.com": {"prod", "ace", "new"}})
+	if strings.Join(removed, ",") != "gone" {
+		t.Errorf("Prune() removed %v, want gone", removed)
+	}
+	if _, ok := config.Clusters["gone"]; ok {
+		t.Error("expected the cluster of the pruned context to be removed")
+	}
+	if _, ok := config.AuthInfos["gone"]; ok {
+		t.Error("expected the user of the pruned context to be removed")
+	}
+	if len(config.Contexts) != 4 || config.CurrentContext != "" {
+		t.Errorf("contexts = %v, current %q; want prod, ace, ace-fqdn and minikube with no current context", sortedKeys(config.Contexts), config.CurrentContext)
+	}
+}
@@ -0,0 +1,114 @@
+package kubeconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ProxyRule sends the API requests to the clusters it selects through a proxy
+type ProxyRule struct {
+	// Selector is a glob matched against the Rancher cluster name, e.g.
+	// "prod-*", or a cluster label as key:value, e.g. "network:isolated"
+	Selector string
+
+	// URL is the http, https or socks5 proxy written as the proxy-url of the
+	// selected clusters' entries
+	URL string
+}
+
+// ParseProxyRules parses selector=url rules, e.g.
+// "network:isolated=socks5://bastion:1080". fallback, if not empty, is the
+// proxy of the clusters no rule selects.
+func ParseProxyRules(rules []string, fallback string) ([]ProxyRule, error) {
+	var parsed []ProxyRule
+	for _, rule := range rules {
+		selector, proxyURL, ok := strings.Cut(rule, "=")
+		selector, proxyURL = strings.TrimSpace(selector), strings.TrimSpace(proxyURL)
+		if !ok || selector == "" {
+			return nil, fmt.Errorf("invalid proxy rule %q: must be <cluster glob or label:value>=<proxy URL>", rule)
+		}
+		if _, err := path.Match(selector, ""); err != nil {
+			return nil, fmt.Errorf("invalid proxy rule %q: %w", rule, err)
+		}
+		if err := validateProxyURL(proxyURL); err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, ProxyRule{Selector: selector, URL: proxyURL})
+	}
+	if fallback != "" {
+		if err := validateProxyURL(fallback); err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, ProxyRule{Selector: "*", URL: fallback})
+	}
+	return parsed, nil
+}
+
+// validateProxyURL checks that proxyURL is a proxy client-go can use
+func validateProxyURL(proxyURL string) error {
+	// Errors quote the URL without its password, which the proxy may require
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("invalid proxy URL %q: the scheme must be http, https or socks5", u.Redacted())
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q: no host", u.Redacted())
+	}
+	return nil
+}
+
+// Matches reports whether the rule selects the cluster named clusterName with labels
+func (r ProxyRule) Matches(clusterName string, labels map[string]string) bool {
+	if key, value, ok := strings.Cut(r.Selector, ":"); ok {
+		label, found := labels[key]
+		if !found {
+			return false
+		}
+		matched, _ := path.Match(value, label)
+		return matched
+	}
+	matched, _ := path.Match(r.Selector, clusterName)
+	return matched
+}
+
+// SetProxyRules sets the proxies of the generated cluster entries; the first
+// rule selecting a cluster applies to all of its direct entries
+func (g *Generator) SetProxyRules(rules []ProxyRule) {
+	g.proxyRules = rules
+}
+
+// proxyURL returns the proxy of a cluster, or "" when no rule selects it
+func (g *Generator) proxyURL(clusterName string) string {
+	labels := g.clusterInfo[clusterName].Labels
+	for _, rule := range g.proxyRules {
+		if rule.Matches(clusterName, labels) {
+			return rule.URL
+		}
+	}
+	return ""
+}
+
+// setProxyURL sets the proxy-url of the cluster entries of config that do not
+// go through the Rancher proxy, which is reached like Rancher itself
+func setProxyURL(config *api.Config, proxyURL string) {
+	for _, cluster := range config.Clusters {
+		if IsProxyServer(cluster.Server) {
+			continue
+		}
+		cluster.ProxyURL = proxyURL
+	}
+}
@@ -0,0 +1,90 @@
+package kubeconfig
+
+import (
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// redactedValue replaces secrets that are plain strings
+const redactedValue = "REDACTED"
+
+// secretNameParts mark the auth-provider settings, exec arguments and exec
+// environment variables holding a secret
+var secretNameParts = []string{"secret", "token", "password", "key"}
+
+// Redact replaces the credentials in config with placeholders, keeping its
+// structure: certificate and key data serialize as DATA+OMITTED like with
+// kubectl config view, and tokens, passwords, secret plugin settings and
+// proxy credentials become REDACTED
+func Redact(config *api.Config) {
+	api.ShortenConfig(config)
+	for _, cluster := range config.Clusters {
+		cluster.ProxyURL = redactProxyURL(cluster.ProxyURL)
+	}
+	for _, user := range config.AuthInfos {
+		if user.Password != "" {
+			user.Password = redactedValue
+		}
+		if user.AuthProvider != nil {
+			for name := range user.AuthProvider.Config {
+				if isSecretName(name) {
+					user.AuthProvider.Config[name] = redactedValue
+				}
+			}
+		}
+		if user.Exec != nil {
+			redactArgs(user.Exec.Args)
+			for i, env := range user.Exec.Env {
+				if isSecretName(env.Name) {
+					user.Exec.Env[i].Value = redactedValue
+				}
+			}
+		}
+	}
+}
+
+// redactProxyURL redacts the password of a proxy URL with credentials, or its
+// username when it is the only credential
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.User == nil {
+		return proxyURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	} else {
+		u.User = url.User(redactedValue)
+	}
+	return u.String()
+}
+
+// redactArgs redacts the values of the secret-named flags in args, given
+// either as --flag=value or as --flag value
+func redactArgs(args []string) {
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(args[i], "=")
+		if !strings.HasPrefix(name, "-") || !isSecretName(name) {
+			continue
+		}
+		switch {
+		case hasValue:
+			args[i] = name + "=" + redactedValue
+		case i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+			i++
+			args[i] = redactedValue
+		}
+	}
+}
+
+// isSecretName reports whether a setting called name holds a secret
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
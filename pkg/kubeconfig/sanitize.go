@@ -0,0 +1,121 @@
+package kubeconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Name sanitization rules, as given to ParseNameSanitizer
+const (
+	SanitizeLowercase      = "lowercase"
+	SanitizeReplaceInvalid = "replace-invalid"
+	SanitizeAll            = "all"
+)
+
+// MinSanitizedNameLength is the smallest maximum name length, leaving room
+// for the hash suffix of shortened names
+const MinSanitizedNameLength = 16
+
+// nameHashLength is the number of hex digits of the hash suffix of shortened names
+const nameHashLength = 8
+
+// invalidNamePlaceholder starts the names that have no valid character left,
+// followed by a hash of the original name
+const invalidNamePlaceholder = "cluster"
+
+// NameSanitizer turns generated names, e.g. "Team A/Prod EU", into plain
+// identifiers that are easy to type and valid wherever kubectl and other
+// tools accept a context name
+type NameSanitizer struct {
+	// Lowercase lowercases names
+	Lowercase bool
+
+	// ReplaceInvalid replaces every run of characters other than letters,
+	// digits, '.', '_' and '-' with a single '-'
+	ReplaceInvalid bool
+
+	// MaxLength shortens longer names, ending them with a hash of the full
+	// name so they stay unique (0 for no limit)
+	MaxLength int
+}
+
+// ParseNameSanitizer builds a sanitizer from rule names (lowercase,
+// replace-invalid or all) and a maximum length
+func ParseNameSanitizer(rules []string, maxLength int) (*NameSanitizer, error) {
+	s := &NameSanitizer{MaxLength: maxLength}
+	for _, rule := range rules {
+		switch strings.ToLower(strings.TrimSpace(rule)) {
+		case SanitizeLowercase:
+			s.Lowercase = true
+		case SanitizeReplaceInvalid:
+			s.ReplaceInvalid = true
+		case SanitizeAll:
+			s.Lowercase, s.ReplaceInvalid = true, true
+		default:
+			return nil, fmt.Errorf("invalid name sanitization rule %q: must be %s, %s or %s", rule, SanitizeLowercase, SanitizeReplaceInvalid, SanitizeAll)
+		}
+	}
+	if maxLength != 0 && maxLength < MinSanitizedNameLength {
+		return nil, fmt.Errorf("invalid maximum name length %d: must be at least %d", maxLength, MinSanitizedNameLength)
+	}
+	return s, nil
+}
+
+// Sanitize applies the rules to name. A name with no valid character, e.g.
+// one written in another script, becomes "cluster-" and a hash of it.
+func (s *NameSanitizer) Sanitize(name string) string {
+	if s.Lowercase {
+		name = strings.ToLower(name)
+	}
+	if s.ReplaceInvalid {
+		if replaced := replaceInvalid(name); replaced != "" {
+			name = replaced
+		} else {
+			name = invalidNamePlaceholder + "-" + nameHash(name)
+		}
+	}
+	if s.MaxLength > 0 && len(name) > s.MaxLength {
+		head := strings.TrimRight(truncate(name, s.MaxLength-nameHashLength-1), "-._")
+		name = head + "-" + nameHash(name)
+	}
+	return name
+}
+
+// nameHash returns the short hash telling apart names that were shortened or replaced
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// replaceInvalid replaces runs of characters that are not letters, digits,
+// '.', '_' or '-' with '-', trimming them at both ends
+func replaceInvalid(name string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range name {
+		if r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			if pending && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pending = false
+			b.WriteRune(r)
+			continue
+		}
+		pending = true
+	}
+	return b.String()
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
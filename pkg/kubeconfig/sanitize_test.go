@@ -0,0 +1,106 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameSanitizer_Sanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		max   int
+		input string
+		want  string
+	}{
+		{"no rules", nil, 0, "Team A/Prod EU", "Team A/Prod EU"},
+		{"lowercase", []string{"lowercase"}, 0, "Team A/Prod EU", "team a/prod eu"},
+		{"replace invalid", []string{"replace-invalid"}, 0, " Team A//Prod EU! ", "Team-A-Prod-EU"},
+		{"all", []string{"all"}, 0, "Team A/Prod_EU.v2", "team-a-prod_eu.v2"},
+		{"non-ASCII", []string{"all"}, 0, "Zürich Prod", "z-rich-prod"},
+		{"short enough", []string{"all"}, 16, "prod-eu", "prod-eu"},
+		{"no valid character", []string{"replace-invalid"}, 0, "東京", "cluster-" + nameHash("東京")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseNameSanitizer(tt.rules, tt.max)
+			if err != nil {
+				t.Fatalf("ParseNameSanitizer() error = %v", err)
+			}
+			if got := s.Sanitize(tt.input); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameSanitizer_MaxLength(t *testing.T) {
+	s, err := ParseNameSanitizer(nil, 20)
+	if err != nil {
+		t.Fatalf("ParseNameSanitizer() error = %v", err)
+	}
+	a := s.Sanitize("payments-production-eu-west-1")
+	b := s.Sanitize("payments-production-eu-west-2")
+	if len(a) > 20 || len(b) > 20 {
+		t.Errorf("shortened names %q and %q are longer than 20", a, b)
+	}
+	if a == b {
+		t.Errorf("names differing after the cut were both shortened to %q", a)
+	}
+	if !strings.HasPrefix(a, "payments-pr") {
+		t.Errorf("shortened name %q does not start with the original", a)
+	}
+	if s.Sanitize("payments-production-eu-west-1") != a {
+		t.Error("shortening is not stable")
+	}
+}
+
+func TestParseNameSanitizer_Invalid(t *testing.T) {
+	if _, err := ParseNameSanitizer([]string{"uppercase"}, 0); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+	if _, err := ParseNameSanitizer(nil, 8); err == nil {
+		t.Error("expected an error for a maximum length without room for the hash")
+	}
+}
+
+func TestGenerator_SetSanitizer(t *testing.T) {
+	s, err := ParseNameSanitizer([]string{"all"}, 0)
+	if err != nil {
+		t.Fatalf("ParseNameSanitizer() error = %v", err)
+	}
+	g := NewGenerator("Team A/")
+	g.SetSanitizer(s)
+	config, err := g.MergeConfigs(map[string]string{"Prod EU": sampleKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	context, ok := config.Contexts["team-a-prod-eu"]
+	if !ok {
+		t.Fatalf("contexts = %v, want team-a-prod-eu", sortedKeys(config.Contexts))
+	}
+	if context.Cluster != "team-a-prod-eu" || context.AuthInfo != "team-a-prod-eu" {
+		t.Errorf("context references %s and %s, want the sanitized name", context.Cluster, context.AuthInfo)
+	}
+
+	// Names that only differ in what sanitization removes collide
+	if _, err := g.MergeConfigs(map[string]string{"Prod EU": sampleKubeconfig, "prod/eu": sampleKubeconfig2}); err == nil {
+		t.Error("expected an error for clusters sanitized to the same name")
+	}
+}
+
+func TestGenerator_ContextName_NoValidCharacter(t *testing.T) {
+	s, err := ParseNameSanitizer([]string{"replace-invalid"}, 0)
+	if err != nil {
+		t.Fatalf("ParseNameSanitizer() error = %v", err)
+	}
+	g := NewGenerator("")
+	g.SetSanitizer(s)
+	g.SetClusterInfo("東京", NameData{ClusterID: "c-m-abc12"})
+	if got := g.ContextName("東京"); got != "c-m-abc12" {
+		t.Errorf("ContextName() = %q, want the cluster ID", got)
+	}
+	if got := g.ContextName("大阪"); got == "" || got == g.ContextName("札幌") {
+		t.Errorf("ContextName() = %q, want distinct placeholders without a cluster ID", got)
+	}
+}
@@ -0,0 +1,106 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// SplitByCluster splits a generated kubeconfig into one kubeconfig per Rancher
+// cluster, holding all of the cluster's contexts (e.g. its authorized cluster
+// endpoints) with the clusters and users they reference. Each is keyed and
+// has its current context set to the cluster's context name, which is the
+// shortest of its context names. Contexts not generated from Rancher are kept
+// on their own.
+func SplitByCluster(config *api.Config) (map[string]*api.Config, error) {
+	groups := make(map[string][]string)
+	for _, name := range sortedKeys(config.Contexts) {
+		provenance, _, err := GetProvenance(config.Contexts[name])
+		if err != nil {
+			return nil, err
+		}
+		key := "context\x00" + name
+		if provenance.RancherURL != "" {
+			key = provenance.RancherURL + "\x00" + provenance.ClusterID
+		}
+		groups[key] = append(groups[key], name)
+	}
+
+	split := make(map[string]*api.Config, len(groups))
+	for _, names := range groups {
+		primary := names[0]
+		for _, name := range names[1:] {
+			if len(name) < len(primary) {
+				primary = name
+			}
+		}
+		// keepContexts makes the first name the current context
+		ordered := append([]string{primary}, slices.DeleteFunc(slices.Clone(names), func(name string) bool { return name == primary })...)
+		split[primary] = keepContexts(config, ordered)
+	}
+	return split, nil
+}
+
+// SplitFileName returns the file name of a kubeconfig split off for the
+// context name, with path separators replaced so it stays in its directory
+func SplitFileName(name string) string {
+	return strings.NewReplacer("/", "-", "\\", "-").Replace(name) + ".yaml"
+}
+
+// SplitByGroup splits a generated kubeconfig into one kubeconfig per group
+// recorded by a generator with a group label, e.g. one per environment. It
+// also returns the contexts without a group, which are in none of them.
+func SplitByGroup(config *api.Config) (map[string]*api.Config, []string, error) {
+	groups := make(map[string][]string)
+	var ungrouped []string
+	for _, name := range sortedKeys(config.Contexts) {
+		provenance, _, err := GetProvenance(config.Contexts[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("context %s: %w", name, err)
+		}
+		if provenance.Group == "" {
+			ungrouped = append(ungrouped, name)
+			continue
+		}
+		groups[provenance.Group] = append(groups[provenance.Group], name)
+	}
+
+	split := make(map[string]*api.Config, len(groups))
+	for group, names := range groups {
+		split[group] = keepContexts(config, names)
+	}
+	return split, ungrouped, nil
+}
+
+// GroupFileName returns the name of the file holding a group's kubeconfig:
+// path with the group inserted before its extension, e.g. kubeconfig-prod.yaml
+// for kubeconfig.yaml. Path separators in the group are replaced.
+func GroupFileName(path, group string) string {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		ext = ".yaml"
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-" + strings.NewReplacer("/", "-", "\\", "-").Replace(group) + ext
+}
+
+// setGroup records group on the generated contexts of a single cluster's kubeconfig
+func setGroup(config *api.Config, group string) error {
+	for _, name := range sortedKeys(config.Contexts) {
+		context := config.Contexts[name]
+		provenance, _, err := GetProvenance(context)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		if provenance.Source != generatedSource {
+			continue
+		}
+		provenance.Group = group
+		if err := SetProvenance(context, provenance); err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+	}
+	return nil
+}
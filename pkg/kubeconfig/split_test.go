@@ -0,0 +1,100 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestSplitByCluster(t *testing.T) {
+	g := NewGenerator("prod-")
+	config, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig, "app": sampleKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+	config.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	config.AuthInfos["minikube"] = &api.AuthInfo{Token: "static"}
+	config.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+
+	split, err := SplitByCluster(config)
+	if err != nil {
+		t.Fatalf("SplitByCluster() error = %v", err)
+	}
+	if got := sortedKeys(split); len(got) != 3 || got[0] != "minikube" || got[1] != "prod-ace" || got[2] != "prod-app" {
+		t.Fatalf("split into %v, want minikube, prod-ace and prod-app", got)
+	}
+
+	ace := split["prod-ace"]
+	if len(ace.Contexts) != 2 || ace.CurrentContext != "prod-ace" {
+		t.Errorf("prod-ace has contexts %v and current context %q, want both endpoints and prod-ace", sortedKeys(ace.Contexts), ace.CurrentContext)
+	}
+	for name, context := range ace.Contexts {
+		if _, ok := ace.Clusters[context.Cluster]; !ok {
+			t.Errorf("context %s references cluster %s missing from the file", name, context.Cluster)
+		}
+		if _, ok := ace.AuthInfos[context.AuthInfo]; !ok {
+			t.Errorf("context %s references user %s missing from the file", name, context.AuthInfo)
+		}
+	}
+	if app := split["prod-app"]; len(app.Contexts) != 1 || len(app.Clusters) != 1 || len(app.AuthInfos) != 1 {
+		t.Errorf("prod-app = %d contexts, %d clusters, %d users, want one of each", len(app.Contexts), len(app.Clusters), len(app.AuthInfos))
+	}
+}
+
+func TestSplitFileName(t *testing.T) {
+	if got := SplitFileName("team/prod\\eu"); got != "team-prod-eu.yaml" {
+		t.Errorf("SplitFileName() = %q, want team-prod-eu.yaml", got)
+	}
+}
+
+func TestSplitByGroup(t *testing.T) {
+	proxy := func(id string) string {
+		return strings.Replace(sampleKubeconfig, "https://cluster1.example.com:6443", "https://rancher.example.com/k8s/clusters/"+id, 1)
+	}
+	g := NewGenerator("")
+	g.SetGroupLabel("environment")
+	g.SetClusterInfo("eu", NameData{Labels: map[string]string{"environment": "prod"}})
+	g.SetClusterInfo("us", NameData{Labels: map[string]string{"environment": "prod"}})
+	g.SetClusterInfo("dev", NameData{Labels: map[string]string{"environment": "staging"}})
+	config, err := g.MergeConfigs(map[string]string{"eu": proxy("c-1"), "us": proxy("c-2"), "dev": proxy("c-3"), "lab": proxy("c-4")})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	split, ungrouped, err := SplitByGroup(config)
+	if err != nil {
+		t.Fatalf("SplitByGroup() error = %v", err)
+	}
+	if got := sortedKeys(split); strings.Join(got, ",") != "prod,staging" {
+		t.Fatalf("split into %v, want prod and staging", got)
+	}
+	if got := sortedKeys(split["prod"].Contexts); strings.Join(got, ",") != "eu,us" {
+		t.Errorf("prod has contexts %v, want eu and us", got)
+	}
+	if prod := split["prod"]; len(prod.Clusters) != 2 || len(prod.AuthInfos) != 1 || prod.CurrentContext != "eu" {
+		t.Errorf("prod = %d clusters, %d users, current context %q", len(prod.Clusters), len(prod.AuthInfos), prod.CurrentContext)
+	}
+	if got := sortedKeys(split["staging"].Contexts); strings.Join(got, ",") != "dev" {
+		t.Errorf("staging has contexts %v, want dev", got)
+	}
+	if strings.Join(ungrouped, ",") != "lab" {
+		t.Errorf("ungrouped = %v, want lab", ungrouped)
+	}
+}
+
+func TestGroupFileName(t *testing.T) {
+	tests := map[string]string{
+		"kubeconfig.yaml":        "kubeconfig-prod.yaml",
+		"/home/me/.kube/rancher": "/home/me/.kube/rancher-prod.yaml",
+		"out/fleet.yml":          "out/fleet-prod.yml",
+	}
+	for path, want := range tests {
+		if got := GroupFileName(path, "prod"); got != want {
+			t.Errorf("GroupFileName(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if got := GroupFileName("kubeconfig.yaml", "eu/prod"); got != "kubeconfig-eu-prod.yaml" {
+		t.Errorf("GroupFileName() = %q, want the separator replaced", got)
+	}
+}
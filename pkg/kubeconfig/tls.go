@@ -0,0 +1,107 @@
+package kubeconfig
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// TLSOverride replaces the TLS settings Rancher reports for the direct
+// endpoints of some clusters, e.g. those behind a re-encrypting load balancer
+// presenting another certificate. Only the fields set are replaced.
+type TLSOverride struct {
+	// Pattern is a glob matched against the Rancher cluster name
+	Pattern string
+
+	CertificateAuthorityData []byte
+	TLSServerName            string
+	InsecureSkipTLSVerify    bool
+}
+
+// ParseTLSOverrides builds overrides from <glob>=<PEM file> certificate
+// authorities, <glob>=<name> TLS server names and the globs of the clusters
+// whose certificates are not verified
+func ParseTLSOverrides(certificateAuthorities, serverNames, insecure []string) ([]TLSOverride, error) {
+	var overrides []TLSOverride
+	for _, rule := range certificateAuthorities {
+		pattern, file, err := parseTLSRule(rule, "certificate authority")
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the certificate authority of %s: %w", pattern, err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("certificate authority %s of %s holds no PEM certificate", file, pattern)
+		}
+		overrides = append(overrides, TLSOverride{Pattern: pattern, CertificateAuthorityData: data})
+	}
+	for _, rule := range serverNames {
+		pattern, name, err := parseTLSRule(rule, "TLS server name")
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, TLSOverride{Pattern: pattern, TLSServerName: name})
+	}
+	for _, pattern := range insecure {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid cluster pattern %q: %w", pattern, err)
+		}
+		overrides = append(overrides, TLSOverride{Pattern: pattern, InsecureSkipTLSVerify: true})
+	}
+	return overrides, nil
+}
+
+// parseTLSRule splits a <glob>=<value> rule
+func parseTLSRule(rule, what string) (string, string, error) {
+	pattern, value, ok := strings.Cut(rule, "=")
+	pattern, value = strings.TrimSpace(pattern), strings.TrimSpace(value)
+	if !ok || pattern == "" || value == "" {
+		return "", "", fmt.Errorf("invalid %s %q: must be <cluster glob>=<value>", what, rule)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", "", fmt.Errorf("invalid %s %q: %w", what, rule, err)
+	}
+	return pattern, value, nil
+}
+
+// SetTLSOverrides sets the TLS settings replacing those of the clusters'
+// direct endpoints; every matching override applies, in order
+func (g *Generator) SetTLSOverrides(overrides []TLSOverride) {
+	g.tlsOverrides = overrides
+}
+
+// applyTLSOverrides applies the overrides matching clusterName to the cluster
+// entries of config that do not go through the Rancher proxy, whose
+// certificate is Rancher's own
+func (g *Generator) applyTLSOverrides(config *api.Config, clusterName string) {
+	for _, override := range g.tlsOverrides {
+		if matched, _ := path.Match(override.Pattern, clusterName); !matched {
+			continue
+		}
+		for _, cluster := range config.Clusters {
+			if strings.Contains(cluster.Server, rancherProxyPath) {
+				continue
+			}
+			if len(override.CertificateAuthorityData) > 0 {
+				cluster.CertificateAuthorityData = override.CertificateAuthorityData
+				cluster.CertificateAuthority = ""
+				cluster.InsecureSkipTLSVerify = false
+			}
+			if override.TLSServerName != "" {
+				cluster.TLSServerName = override.TLSServerName
+			}
+			// kubectl refuses a certificate authority together with insecure-skip-tls-verify
+			if override.InsecureSkipTLSVerify {
+				cluster.InsecureSkipTLSVerify = true
+				cluster.CertificateAuthorityData = nil
+				cluster.CertificateAuthority = ""
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCA writes the PEM certificate of a test TLS server to a file
+func writeCA(t *testing.T) (string, []byte) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	file := filepath.Join(t.TempDir(), "lb-ca.pem")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file, data
+}
+
+func TestParseTLSOverrides(t *testing.T) {
+	file, data := writeCA(t)
+	overrides, err := ParseTLSOverrides([]string{"prod-*=" + file}, []string{"edge = k8s.edge.example.com"}, []string{"lab-*"})
+	if err != nil {
+		t.Fatalf("ParseTLSOverrides() error = %v", err)
+	}
+	if len(overrides) != 3 {
+		t.Fatalf("ParseTLSOverrides() = %+v, want 3 overrides", overrides)
+	}
+	if overrides[0].Pattern != "prod-*" || !bytes.Equal(overrides[0].CertificateAuthorityData, data) {
+		t.Errorf("certificate authority override = %+v", overrides[0])
+	}
+	if overrides[1].Pattern != "edge" || overrides[1].TLSServerName != "k8s.edge.example.com" {
+		t.Errorf("TLS server name override = %+v", overrides[1])
+	}
+	if overrides[2].Pattern != "lab-*" || !overrides[2].InsecureSkipTLSVerify {
+		t.Errorf("insecure override = %+v", overrides[2])
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := [][3][]string{
+		{{"prod-*"}, nil, nil},
+		{{"prod-*=" + notPEM}, nil, nil},
+		{{"prod-*=/does/not/exist.pem"}, nil, nil},
+		{nil, {"=k8s.example.com"}, nil},
+		{nil, nil, {"["}},
+	}
+	for _, args := range invalid {
+		if _, err := ParseTLSOverrides(args[0], args[1], args[2]); err == nil {
+			t.Errorf("ParseTLSOverrides(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestTLSOverrides(t *testing.T) {
+	file, data := writeCA(t)
+	overrides, err := ParseTLSOverrides([]string{"ace*=" + file}, []string{"ace=k8s.ace.example.com"}, []string{"lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGenerator("")
+	g.SetTLSOverrides(overrides)
+	config, err := g.MergeConfigs(map[string]string{"ace": aceKubeconfig, "lab": aceKubeconfig, "other": aceKubeconfig})
+	if err != nil {
+		t.Fatalf("MergeConfigs() error = %v", err)
+	}
+
+	direct := config.Clusters[config.Contexts["ace-1"].Cluster]
+	if !bytes.Equal(direct.CertificateAuthorityData, data) || direct.TLSServerName != "k8s.ace.example.com" || direct.InsecureSkipTLSVerify {
+		t.Errorf("direct endpoint of ace = %+v, want the overridden CA and server name", direct)
+	}
+	if proxy := config.Clusters[config.Contexts["ace"].Cluster]; len(proxy.CertificateAuthorityData) > 0 || proxy.TLSServerName != "" {
+		t.Errorf("Rancher proxy endpoint of ace = %+v, want it unchanged", proxy)
+	}
+	if lab := config.Clusters[config.Contexts["lab-1"].Cluster]; !lab.InsecureSkipTLSVerify || len(lab.CertificateAuthorityData) > 0 {
+		t.Errorf("direct endpoint of lab = %+v, want insecure without a CA", lab)
+	}
+	if other := config.Clusters[config.Contexts["other-1"].Cluster]; string(other.CertificateAuthorityData) != "test-ca-data" || other.InsecureSkipTLSVerify {
+		t.Errorf("direct endpoint of other = %+v, want the CA Rancher reported", other)
+	}
+}
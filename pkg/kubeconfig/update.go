@@ -0,0 +1,91 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// UpdateReport lists the contexts UpdateManaged added and replaced
+type UpdateReport struct {
+	Added   []string
+	Updated []string
+}
+
+// UpdateManaged merges a generated kubeconfig into an existing one, such as
+// ~/.kube/config, adding or replacing the contexts of the Rancher servers it
+// was generated from and leaving every other entry as it is. A generated name
+// taken by an entry not managed by those servers is an error. Contexts of
+// deleted clusters are left for Prune.
+func UpdateManaged(existing, generated *api.Config) (*UpdateReport, error) {
+	servers := RancherServers(generated)
+
+	// A context is managed when it was generated from one of the Rancher
+	// servers, or goes through one of them
+	managedContext := func(context *api.Context) bool {
+		provenance, _, _ := GetProvenance(context)
+		if provenance.Source == generatedSource && slices.Contains(servers, provenance.RancherURL) {
+			return true
+		}
+		cluster, ok := existing.Clusters[context.Cluster]
+		return ok && slices.ContainsFunc(servers, func(server string) bool {
+			return strings.HasPrefix(cluster.Server, server+rancherProxyPath)
+		})
+	}
+
+	managedClusters := make(map[string]bool)
+	managedUsers := make(map[string]bool)
+	unmanagedUsers := make(map[string]bool)
+	for _, name := range sortedKeys(existing.Contexts) {
+		context := existing.Contexts[name]
+		if managedContext(context) {
+			managedClusters[context.Cluster] = true
+			managedUsers[context.AuthInfo] = true
+			continue
+		}
+		unmanagedUsers[context.AuthInfo] = true
+		if _, clash := generated.Contexts[name]; clash {
+			return nil, fmt.Errorf("context %q already exists and is not managed by Rancher; use a different prefix", name)
+		}
+	}
+	for _, name := range sortedKeys(existing.Clusters) {
+		provenance, _, _ := GetClusterProvenance(existing.Clusters[name])
+		if provenance.Source == generatedSource && slices.Contains(servers, provenance.RancherURL) {
+			managedClusters[name] = true
+		}
+	}
+	for _, name := range sortedKeys(generated.Clusters) {
+		if _, exists := existing.Clusters[name]; exists && !managedClusters[name] {
+			return nil, fmt.Errorf("cluster %q already exists and is not managed by Rancher; use a different prefix", name)
+		}
+	}
+	for _, name := range sortedKeys(generated.AuthInfos) {
+		if unmanagedUsers[name] {
+			return nil, fmt.Errorf("user %q already exists and is used by contexts not managed by Rancher; use a different prefix", name)
+		}
+	}
+
+	report := &UpdateReport{}
+	for _, name := range sortedKeys(generated.Contexts) {
+		if _, exists := existing.Contexts[name]; exists {
+			report.Updated = append(report.Updated, name)
+		} else {
+			report.Added = append(report.Added, name)
+		}
+	}
+	MergeInto(existing, generated)
+
+	// Drop the managed users the replaced contexts no longer refer to
+	usedUsers := make(map[string]bool)
+	for _, context := range existing.Contexts {
+		usedUsers[context.AuthInfo] = true
+	}
+	for name := range managedUsers {
+		if !usedUsers[name] {
+			delete(existing.AuthInfos, name)
+		}
+	}
+	return report, nil
+}
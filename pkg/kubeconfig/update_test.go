@@ -0,0 +1,68 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// rancherConfig builds a generated kubeconfig with one proxy context per
+// cluster ID, plus an authorized cluster endpoint context for "ace"
+func rancherConfig(t *testing.T, clusterIDs ...string) *api.Config {
+	t.Helper()
+	merged := api.NewConfig()
+	for _, id := range clusterIDs {
+		config := api.NewConfig()
+		config.AuthInfos[id] = &api.AuthInfo{Token: "kubeconfig-u-1:" + id}
+		config.Clusters[id] = &api.Cluster{Server: "https://rancher.example.com/k8s/clusters/" + id}
+		config.Contexts[id] = &api.Context{Cluster: id, AuthInfo: id}
+		if id == "ace" {
+			config.Clusters["ace-fqdn"] = &api.Cluster{Server: "https://ace.example.com:6443"}
+			config.Contexts["ace-fqdn"] = &api.Context{Cluster: "ace-fqdn", AuthInfo: id}
+		}
+		if err := MarkOwned(config); err != nil {
+			t.Fatalf("MarkOwned() error = %v", err)
+		}
+		MergeInto(merged, config)
+	}
+	return merged
+}
+
+func TestUpdateManaged(t *testing.T) {
+	existing := api.NewConfig()
+	existing.Clusters["minikube"] = &api.Cluster{Server: "https://192.168.49.2:8443"}
+	existing.AuthInfos["minikube"] = &api.AuthInfo{ClientCertificate: "/home/me/.minikube/client.crt"}
+	existing.Contexts["minikube"] = &api.Context{Cluster: "minikube", AuthInfo: "minikube"}
+	existing.CurrentContext = "minikube"
+
+	report, err := UpdateManaged(existing, rancherConfig(t, "prod", "ace"))
+	if err != nil {
+		t.Fatalf("UpdateManaged() error = %v", err)
+	}
+	if len(report.Added) != 3 || len(report.Updated) != 0 {
+		t.Errorf("first update report = %+v, want 3 added", report)
+	}
+
+	report, err = UpdateManaged(existing, rancherConfig(t, "prod", "ace"))
+	if err != nil {
+		t.Fatalf("UpdateManaged() error = %v", err)
+	}
+	if len(report.Updated) != 3 || len(report.Added) != 0 {
+		t.Errorf("second update report = %+v, want prod, ace and ace-fqdn updated", report)
+	}
+	if _, ok := existing.Contexts["minikube"]; !ok || existing.CurrentContext != "minikube" {
+		t.Error("expected the unmanaged minikube context to stay current")
+	}
+	if _, ok := existing.AuthInfos["minikube"]; !ok {
+		t.Error("expected the unmanaged minikube user to be kept")
+	}
+	if context, ok := existing.Contexts["ace-fqdn"]; !ok {
+		t.Error("expected the authorized cluster endpoint context to be kept")
+	} else if _, owned, _ := GetProvenance(context); !owned {
+		t.Error("expected the authorized cluster endpoint context to be kept as managed")
+	}
+
+	if _, err := UpdateManaged(existing, rancherConfig(t, "minikube")); err == nil {
+		t.Error("expected an error when a generated name is taken by an unmanaged context")
+	}
+}
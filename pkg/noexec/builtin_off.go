@@ -0,0 +1,6 @@
+//go:build !noexec
+
+package noexec
+
+// builtIn is true in binaries built with the noexec tag
+const builtIn = false
@@ -0,0 +1,42 @@
+// Package noexec decides whether kubeconfig-wrangler may start other
+// processes, for hardened environments whose seccomp or AppArmor policy
+// forbids it. The mode is on when the binary is built with the noexec tag, when
+// RANCHER_NO_EXEC is true, or once Enable has been called.
+package noexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// ErrDisabled is returned by features that would start another process
+var ErrDisabled = errors.New("starting other processes is disabled (no-exec mode)")
+
+var enabled atomic.Bool
+
+// Enable turns no-exec mode on for the rest of the process
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled reports whether no-exec mode is on
+func Enabled() bool {
+	return builtIn || enabled.Load() || os.Getenv("RANCHER_NO_EXEC") == "true"
+}
+
+// BuiltIn reports whether the binary was built with the noexec tag, in which
+// case the mode cannot be turned off
+func BuiltIn() bool {
+	return builtIn
+}
+
+// Check returns ErrDisabled, naming what would have been run, when no-exec
+// mode is on
+func Check(what string) error {
+	if Enabled() {
+		return fmt.Errorf("%s: %w", what, ErrDisabled)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package policy evaluates CEL expressions that decide which clusters are
+// included in a generated kubeconfig
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// Decision is the outcome of evaluating a policy for one cluster
+type Decision string
+
+const (
+	// Include adds the cluster to the kubeconfig
+	Include Decision = "include"
+	// Exclude leaves the cluster out
+	Exclude Decision = "exclude"
+	// RequireApproval leaves the cluster out unless it was explicitly approved
+	RequireApproval Decision = "require-approval"
+)
+
+// Cluster is the cluster data exposed to policies as the `cluster` variable
+type Cluster struct {
+	ID          string
+	Name        string
+	State       string
+	Provider    string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// Identity is the requesting user exposed to policies as the `identity` variable
+type Identity struct {
+	ID       string
+	Username string
+}
+
+// Policy is a compiled policy expression
+type Policy struct {
+	expr    string
+	program cel.Program
+}
+
+// Compile parses and type-checks a CEL expression. The expression sees two maps,
+// `cluster` (id, name, state, provider, labels, annotations) and `identity`
+// (id, username), and must evaluate to a bool (true includes, false excludes) or
+// to one of the strings "include", "exclude" or "require-approval".
+func Compile(expr string) (*Policy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("cluster", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("identity", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid policy expression: %w", issues.Err())
+	}
+
+	switch ast.OutputType() {
+	case cel.BoolType, cel.StringType, cel.DynType:
+	default:
+		return nil, fmt.Errorf("policy must evaluate to a bool or a string, not %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build policy program: %w", err)
+	}
+
+	return &Policy{expr: expr, program: program}, nil
+}
+
+// Load compiles the policy expression stored in a file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return Compile(strings.TrimSpace(string(data)))
+}
+
+// String returns the policy expression
+func (p *Policy) String() string {
+	return p.expr
+}
+
+// Evaluate decides what to do with a cluster for the given identity
+func (p *Policy) Evaluate(cluster Cluster, identity Identity) (Decision, error) {
+	out, _, err := p.program.Eval(map[string]any{
+		"cluster": map[string]any{
+			"id":          cluster.ID,
+			"name":        cluster.Name,
+			"state":       cluster.State,
+			"provider":    cluster.Provider,
+			"labels":      stringMap(cluster.Labels),
+			"annotations": stringMap(cluster.Annotations),
+		},
+		"identity": map[string]any{
+			"id":       identity.ID,
+			"username": identity.Username,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate policy for cluster %s: %w", cluster.Name, err)
+	}
+
+	switch value := out.(type) {
+	case types.Bool:
+		if value {
+			return Include, nil
+		}
+		return Exclude, nil
+	case types.String:
+		switch decision := Decision(value); decision {
+		case Include, Exclude, RequireApproval:
+			return decision, nil
+		}
+		return "", fmt.Errorf("policy returned unknown decision %q for cluster %s", string(value), cluster.Name)
+	default:
+		return "", fmt.Errorf("policy returned %s for cluster %s, want bool or string", out.Type(), cluster.Name)
+	}
+}
+
+// stringMap never returns nil, so policies can index labels without has() checks
+// failing on a missing map
+func stringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	prod := Cluster{
+		ID:       "c-prod",
+		Name:     "prod-eu",
+		State:    "active",
+		Provider: "rke2",
+		Labels:   map[string]string{"env": "prod"},
+	}
+	dev := Cluster{ID: "c-dev", Name: "dev", State: "active", Provider: "k3s"}
+	alice := Identity{ID: "u-1", Username: "alice"}
+
+	tests := []struct {
+		name    string
+		expr    string
+		cluster Cluster
+		want    Decision
+	}{
+		{"bool true", `cluster.provider == "rke2"`, prod, Include},
+		{"bool false", `cluster.provider == "rke2"`, dev, Exclude},
+		{"missing label", `"env" in cluster.labels && cluster.labels.env == "prod"`, dev, Exclude},
+		{
+			name:    "string decision",
+			expr:    `cluster.labels.env == "prod" ? "require-approval" : "include"`,
+			cluster: prod,
+			want:    RequireApproval,
+		},
+		{"identity", `identity.username == "alice" ? "include" : "exclude"`, dev, Include},
+		{"name match", `cluster.name.startsWith("prod-")`, prod, Include},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := p.Evaluate(tt.cluster, alice)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`cluster.name ==`,
+		`unknown.name == "x"`,
+		`42`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestPolicy_Evaluate_UnknownDecision(t *testing.T) {
+	p, err := Compile(`"maybe"`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if _, err := p.Evaluate(Cluster{Name: "x"}, Identity{}); err == nil {
+		t.Error("expected an error for an unknown decision")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.cel")
+	if err := os.WriteFile(path, []byte("cluster.state == \"active\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p.String() != `cluster.state == "active"` {
+		t.Errorf("String() = %q", p.String())
+	}
+}
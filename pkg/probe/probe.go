@@ -0,0 +1,151 @@
+// Package probe checks the health of kubeconfig contexts by calling the
+// Kubernetes API server's /version endpoint with the context's credentials
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+)
+
+// DefaultTimeout bounds a single probe
+const DefaultTimeout = 10 * time.Second
+
+// Status classifies the outcome of a probe
+type Status string
+
+const (
+	// StatusReachable means /version answered successfully
+	StatusReachable Status = "reachable"
+	// StatusAuthFailed means the server answered but rejected the credentials
+	StatusAuthFailed Status = "auth-failed"
+	// StatusUnreachable means the server could not be contacted or returned an error
+	StatusUnreachable Status = "unreachable"
+)
+
+// Result is the outcome of probing a single context
+type Result struct {
+	Context    string        `json:"context"`
+	Server     string        `json:"server"`
+	Status     Status        `json:"status"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	Version    string        `json:"version,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Healthy reports whether the probe succeeded
+func (r Result) Healthy() bool {
+	return r.Status == StatusReachable
+}
+
+// versionInfo is the subset of the /version response we care about
+type versionInfo struct {
+	GitVersion string `json:"gitVersion"`
+}
+
+// Context probes a single context of a kubeconfig
+func Context(cfg *api.Config, contextName string, timeout time.Duration) Result {
+	result := Result{Context: contextName, Status: StatusUnreachable}
+
+	ctx, ok := cfg.Contexts[contextName]
+	if !ok {
+		result.Error = fmt.Sprintf("context %q not found", contextName)
+		return result
+	}
+	if cluster, ok := cfg.Clusters[ctx.Cluster]; ok {
+		result.Server = cluster.Server
+	}
+	if user, ok := cfg.AuthInfos[ctx.AuthInfo]; ok && user.Exec != nil {
+		if err := noexec.Check(fmt.Sprintf("credential plugin %q of user %q", user.Exec.Command, ctx.AuthInfo)); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*cfg, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	restConfig.Timeout = timeout
+
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Get(strings.TrimSuffix(restConfig.Host, "/") + "/version")
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		result.Status = StatusAuthFailed
+		result.Error = fmt.Sprintf("credentials rejected (status %d)", resp.StatusCode)
+		return result
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		result.Error = fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return result
+	}
+
+	var info versionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		result.Error = fmt.Sprintf("failed to decode /version: %v", err)
+		return result
+	}
+
+	result.Status = StatusReachable
+	result.Version = info.GitVersion
+	return result
+}
+
+// Contexts probes several contexts concurrently, returning results in the order given
+func Contexts(cfg *api.Config, contextNames []string, timeout time.Duration) []Result {
+	results := make([]Result, len(contextNames))
+	var wg sync.WaitGroup
+	for i, name := range contextNames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = Context(cfg, name, timeout)
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+// Best returns the index of the healthiest, fastest result, or -1 if none is healthy
+func Best(results []Result) int {
+	best := -1
+	for i, r := range results {
+		if !r.Healthy() {
+			continue
+		}
+		if best == -1 || r.Latency < results[best].Latency {
+			best = i
+		}
+	}
+	return best
+}
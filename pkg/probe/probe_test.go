@@ -0,0 +1,109 @@
+package probe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// newConfig builds a single-context kubeconfig pointing at server. client-go only
+// sends credentials over TLS, so the test servers are TLS servers with verification off.
+func newConfig(server, token string) *api.Config {
+	config := api.NewConfig()
+	config.Clusters["test"] = &api.Cluster{Server: server, InsecureSkipTLSVerify: true}
+	config.AuthInfos["test"] = &api.AuthInfo{Token: token}
+	config.Contexts["test"] = &api.Context{Cluster: "test", AuthInfo: "test"}
+	config.CurrentContext = "test"
+	return config
+}
+
+func TestContext(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"gitVersion":"v1.30.2+rke2r1"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		token       string
+		wantStatus  Status
+		wantVersion string
+	}{
+		{"reachable", "good", StatusReachable, "v1.30.2+rke2r1"},
+		{"auth failed", "bad", StatusAuthFailed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Context(newConfig(server.URL, tt.token), "test", time.Second)
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q (error: %s)", result.Status, tt.wantStatus, result.Error)
+			}
+			if result.Version != tt.wantVersion {
+				t.Errorf("Version = %q, want %q", result.Version, tt.wantVersion)
+			}
+			if result.Server != server.URL {
+				t.Errorf("Server = %q, want %q", result.Server, server.URL)
+			}
+		})
+	}
+}
+
+func TestContext_Unreachable(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	result := Context(newConfig(url, "token"), "test", time.Second)
+	if result.Healthy() {
+		t.Error("expected closed server to be unhealthy")
+	}
+	if result.Error == "" {
+		t.Error("expected an error message")
+	}
+}
+
+func TestContext_MissingContext(t *testing.T) {
+	result := Context(api.NewConfig(), "missing", time.Second)
+	if result.Status != StatusUnreachable || result.Error == "" {
+		t.Errorf("expected unreachable with error, got %+v", result)
+	}
+}
+
+func TestBest(t *testing.T) {
+	results := []Result{
+		{Status: StatusReachable, Latency: 50 * time.Millisecond},
+		{Status: StatusUnreachable, Latency: time.Millisecond},
+		{Status: StatusReachable, Latency: 20 * time.Millisecond},
+	}
+	if got := Best(results); got != 2 {
+		t.Errorf("Best() = %d, want 2", got)
+	}
+	if got := Best([]Result{{Status: StatusAuthFailed}}); got != -1 {
+		t.Errorf("Best() = %d, want -1", got)
+	}
+}
+
+func TestContext_NoExec(t *testing.T) {
+	t.Setenv("RANCHER_NO_EXEC", "true")
+
+	config := newConfig("https://127.0.0.1:1", "")
+	config.AuthInfos["test"] = &api.AuthInfo{Exec: &api.ExecConfig{Command: "/bin/false", APIVersion: "client.authentication.k8s.io/v1"}}
+
+	result := Context(config, "test", time.Second)
+	if result.Healthy() || !strings.Contains(result.Error, "no-exec") {
+		t.Errorf("expected the credential plugin to be refused in no-exec mode, got %+v", result)
+	}
+}
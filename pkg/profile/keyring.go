@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringTokenUser returns the keychain account holding the Rancher token of a profile
+func keyringTokenUser(id string) string {
+	return "token-" + id
+}
+
+// storeKeyringToken writes the Rancher token of a profile to the OS keychain
+// (macOS Keychain, Windows Credential Manager or the Secret Service on Linux)
+func storeKeyringToken(p *Profile) error {
+	if err := keyring.Set(keychainService, keyringTokenUser(p.ID), p.Token); err != nil {
+		return fmt.Errorf("failed to store the token in the keychain: %w", err)
+	}
+	return nil
+}
+
+// loadKeyringToken reads the Rancher token of a profile from the OS keychain
+func loadKeyringToken(p *Profile) (string, error) {
+	token, err := keyring.Get(keychainService, keyringTokenUser(p.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token from the keychain: %w", err)
+	}
+	return token, nil
+}
+
+// deleteKeyringToken removes the Rancher token of a profile from the OS keychain
+func deleteKeyringToken(p *Profile) error {
+	err := keyring.Delete(keychainService, keyringTokenUser(p.ID))
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete the token from the keychain: %w", err)
+	}
+	return nil
+}
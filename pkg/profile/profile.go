@@ -42,6 +42,9 @@ type Profile struct {
 	// Token is the Rancher API token (access_key:secret_key format)
 	Token string `json:"token,omitempty"`
 
+	// TokenInKeyring keeps Token in the OS keychain instead of the profiles file
+	TokenInKeyring bool `json:"tokenInKeyring,omitempty"`
+
 	// Username for password-based authentication
 	Username string `json:"username,omitempty"`
 
@@ -54,6 +57,12 @@ type Profile struct {
 	// CACert is the path to a custom CA certificate
 	CACert string `json:"caCert,omitempty"`
 
+	// CACertData is PEM-encoded CA certificates trusted in addition to CACert
+	CACertData string `json:"caCertData,omitempty"`
+
+	// ExcludeSystemCAs trusts only the custom CAs instead of adding them to the system roots
+	ExcludeSystemCAs bool `json:"excludeSystemCas,omitempty"`
+
 	// EKS-specific fields
 
 	// AWSProfile is the name of the AWS CLI profile to use
@@ -187,6 +196,13 @@ type ProfileCreateRequest struct {
 	Password   string `json:"password,omitempty"`
 	SkipTLS    bool   `json:"skipTls,omitempty"`
 	CACert     string `json:"caCert,omitempty"`
+	CACertData string `json:"caCertData,omitempty"`
+
+	// ExcludeSystemCAs trusts only the custom CAs instead of adding them to the system roots
+	ExcludeSystemCAs bool `json:"excludeSystemCas,omitempty"`
+
+	// TokenInKeyring keeps the token in the OS keychain instead of the profiles file
+	TokenInKeyring bool `json:"tokenInKeyring,omitempty"`
 
 	// EKS fields
 	AWSProfile   string `json:"awsProfile,omitempty"`
@@ -206,23 +222,26 @@ type ProfileCreateRequest struct {
 func (r *ProfileCreateRequest) ToProfile(id string) *Profile {
 	now := time.Now()
 	return &Profile{
-		ID:             id,
-		Name:           r.Name,
-		Type:           r.Type,
-		CreatedAt:      now,
-		UpdatedAt:      now,
-		RancherURL:     r.RancherURL,
-		Token:          r.Token,
-		Username:       r.Username,
-		Password:       r.Password,
-		SkipTLS:        r.SkipTLS,
-		CACert:         r.CACert,
-		AWSProfile:     r.AWSProfile,
-		AWSRegion:      r.AWSRegion,
-		AccessKey:      r.AccessKey,
-		SecretKey:      r.SecretKey,
-		SessionToken:   r.SessionToken,
-		Kubeconfig:     r.Kubeconfig,
-		ClusterAliases: r.ClusterAliases,
+		ID:               id,
+		Name:             r.Name,
+		Type:             r.Type,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		RancherURL:       r.RancherURL,
+		Token:            r.Token,
+		TokenInKeyring:   r.TokenInKeyring,
+		Username:         r.Username,
+		Password:         r.Password,
+		SkipTLS:          r.SkipTLS,
+		CACert:           r.CACert,
+		CACertData:       r.CACertData,
+		ExcludeSystemCAs: r.ExcludeSystemCAs,
+		AWSProfile:       r.AWSProfile,
+		AWSRegion:        r.AWSRegion,
+		AccessKey:        r.AccessKey,
+		SecretKey:        r.SecretKey,
+		SessionToken:     r.SessionToken,
+		Kubeconfig:       r.Kubeconfig,
+		ClusterAliases:   r.ClusterAliases,
 	}
 }
@@ -5,18 +5,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-)
 
-const (
-	appName      = "kubeconfig-wrangler"
-	profilesFile = "profiles.json"
+	"github.com/kubeconfig-wrangler/pkg/config"
 )
 
+const profilesFile = "profiles.json"
+
 // Store handles persistent storage of profiles
 type Store struct {
 	mu        sync.RWMutex
@@ -76,37 +74,12 @@ func NewStoreWithPath(path string) (*Store, error) {
 	return store, nil
 }
 
-// getStorePath returns the platform-specific path for storing profiles
+// getStorePath returns the path of the profile store, in the configuration
+// directory of the tool
 func getStorePath() (string, error) {
-	var configDir string
-
-	switch runtime.GOOS {
-	case "darwin":
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get home directory: %w", err)
-		}
-		configDir = filepath.Join(home, "Library", "Application Support", appName)
-	case "linux":
-		// Use XDG_CONFIG_HOME if set, otherwise ~/.config
-		xdgConfig := os.Getenv("XDG_CONFIG_HOME")
-		if xdgConfig != "" {
-			configDir = filepath.Join(xdgConfig, appName)
-		} else {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				return "", fmt.Errorf("failed to get home directory: %w", err)
-			}
-			configDir = filepath.Join(home, ".config", appName)
-		}
-	case "windows":
-		appData := os.Getenv("APPDATA")
-		if appData == "" {
-			return "", fmt.Errorf("APPDATA environment variable not set")
-		}
-		configDir = filepath.Join(appData, appName)
-	default:
-		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	configDir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
 	}
 
 	// Ensure directory exists
@@ -141,6 +114,11 @@ func (s *Store) load() error {
 			fmt.Fprintf(os.Stderr, "Warning: failed to decrypt profile %s: %v\n", p.Name, err)
 			continue
 		}
+		if decrypted.TokenInKeyring {
+			if decrypted.Token, err = loadKeyringToken(decrypted); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: profile %s: %v\n", p.Name, err)
+			}
+		}
 		s.profiles[decrypted.ID] = decrypted
 	}
 
@@ -151,6 +129,12 @@ func (s *Store) load() error {
 func (s *Store) save() error {
 	profiles := make([]*Profile, 0, len(s.profiles))
 	for _, p := range s.profiles {
+		if p.TokenInKeyring {
+			// The token is written to the keychain by Create and Update
+			withoutToken := *p
+			withoutToken.Token = ""
+			p = &withoutToken
+		}
 		encrypted, err := s.encryptor.EncryptProfile(p)
 		if err != nil {
 			return fmt.Errorf("failed to encrypt profile %s: %w", p.Name, err)
@@ -206,6 +190,23 @@ func (s *Store) Get(id string) (*Profile, error) {
 	return p, nil
 }
 
+// FindByName returns the oldest profile with the given name, or nil if there is none
+func (s *Store) FindByName(name string) *Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *Profile
+	for _, p := range s.profiles {
+		if p.Name != name {
+			continue
+		}
+		if found == nil || p.CreatedAt.Before(found.CreatedAt) {
+			found = p
+		}
+	}
+	return found
+}
+
 // Create adds a new profile
 func (s *Store) Create(req *ProfileCreateRequest) (*Profile, error) {
 	s.mu.Lock()
@@ -217,6 +218,11 @@ func (s *Store) Create(req *ProfileCreateRequest) (*Profile, error) {
 	if err := profile.Validate(); err != nil {
 		return nil, err
 	}
+	if profile.TokenInKeyring {
+		if err := storeKeyringToken(profile); err != nil {
+			return nil, err
+		}
+	}
 
 	s.profiles[id] = profile
 
@@ -240,10 +246,17 @@ func (s *Store) Update(id string, req *ProfileCreateRequest) (*Profile, error) {
 
 	profile := req.ToProfile(id)
 	profile.CreatedAt = existing.CreatedAt
+	// A token once moved to the keychain stays there
+	profile.TokenInKeyring = profile.TokenInKeyring || existing.TokenInKeyring
 
 	if err := profile.Validate(); err != nil {
 		return nil, err
 	}
+	if profile.TokenInKeyring {
+		if err := storeKeyringToken(profile); err != nil {
+			return nil, err
+		}
+	}
 
 	s.profiles[id] = profile
 
@@ -271,6 +284,9 @@ func (s *Store) Delete(id string) error {
 		s.profiles[id] = existing
 		return err
 	}
+	if existing.TokenInKeyring {
+		return deleteKeyringToken(existing)
+	}
 
 	return nil
 }
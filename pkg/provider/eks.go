@@ -146,6 +146,9 @@ func (p *EKSProvider) ListClusters() ([]ClusterInfo, error) {
 			if describe.Cluster.Arn != nil {
 				cluster.Description = *describe.Cluster.Arn
 			}
+			if describe.Cluster.Version != nil {
+				cluster.Version = *describe.Cluster.Version
+			}
 
 			clusters = append(clusters, cluster)
 		}
@@ -21,6 +21,9 @@ type ClusterInfo struct {
 	// ProfileName is the name of the profile this cluster belongs to
 	ProfileName string `json:"profileName"`
 
+	// Version is the Kubernetes version of the cluster, if the provider reports it
+	Version string `json:"version,omitempty"`
+
 	// Region is the cloud region (primarily for EKS)
 	Region string `json:"region,omitempty"`
 
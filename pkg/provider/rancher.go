@@ -3,13 +3,13 @@ package provider
 import (
 	"bytes"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
 )
 
 // RancherConfig holds the configuration for a Rancher provider
@@ -35,8 +35,14 @@ type RancherConfig struct {
 	// SkipTLSVerify skips TLS certificate verification
 	SkipTLSVerify bool
 
-	// CACert is the path to a custom CA certificate
+	// CACert is the path to a custom CA certificate, or a directory of them
 	CACert string
+
+	// CACertData is PEM-encoded CA certificates trusted in addition to CACert
+	CACertData string
+
+	// ExcludeSystemCAs trusts only the custom CAs instead of adding them to the system roots
+	ExcludeSystemCAs bool
 }
 
 // RancherProvider implements ClusterProvider for Rancher
@@ -67,7 +73,10 @@ type rancherCluster struct {
 	Description string `json:"description"`
 	State       string `json:"state"`
 	Provider    string `json:"provider"`
-	Actions     struct {
+	Version     struct {
+		GitVersion string `json:"gitVersion"`
+	} `json:"version"`
+	Actions struct {
 		GenerateKubeconfig string `json:"generateKubeconfig"`
 	} `json:"actions"`
 }
@@ -88,15 +97,15 @@ func NewRancherProvider(config RancherConfig) (*RancherProvider, error) {
 		InsecureSkipVerify: config.SkipTLSVerify,
 	}
 
-	// Load custom CA certificate if provided
-	if config.CACert != "" {
-		caCert, err := os.ReadFile(config.CACert)
+	// Trust the custom CAs, in addition to the system roots unless excluded
+	if config.CACert != "" || config.CACertData != "" {
+		bundle, err := rancher.ReadCABundle(config.CACert, config.CACertData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			return nil, err
 		}
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, fmt.Errorf("failed to parse CA certificate")
+		caCertPool, err := rancher.CertPool(bundle, !config.ExcludeSystemCAs)
+		if err != nil {
+			return nil, err
 		}
 		tlsConfig.RootCAs = caCertPool
 	}
@@ -164,6 +173,7 @@ func (p *RancherProvider) login() error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	rancher.SetClientHeaders(req)
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -172,8 +182,7 @@ func (p *RancherProvider) login() error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("login failed: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return rancher.NewAPIError("log in", resp)
 	}
 
 	var loginResp loginResponse
@@ -205,6 +214,7 @@ func (p *RancherProvider) doRequest(method, url string, body io.Reader) (*http.R
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	rancher.SetClientHeaders(req)
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -240,8 +250,7 @@ func (p *RancherProvider) ListClusters() ([]ClusterInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list clusters: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, rancher.NewAPIError("list clusters", resp)
 	}
 
 	var collection clusterCollection
@@ -256,6 +265,7 @@ func (p *RancherProvider) ListClusters() ([]ClusterInfo, error) {
 			Name:        c.Name,
 			State:       c.State,
 			Provider:    "rancher",
+			Version:     c.Version.GitVersion,
 			ProfileID:   p.config.ProfileID,
 			ProfileName: p.config.ProfileName,
 			Description: c.Description,
@@ -276,9 +286,7 @@ func (p *RancherProvider) GetKubeconfig(clusterID string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get kubeconfig for cluster %s: status %d, body: %s",
-			clusterID, resp.StatusCode, string(bodyBytes))
+		return "", rancher.NewAPIError("get kubeconfig for cluster "+clusterID, resp)
 	}
 
 	var kubeconfigResp kubeconfigResponse
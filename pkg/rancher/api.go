@@ -0,0 +1,46 @@
+package rancher
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RawResponse is the undecoded response to a passthrough API request
+type RawResponse struct {
+	Status     string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Raw sends an authenticated request for path, relative to the Rancher URL
+// (e.g. "/v3/clusters?limit=10"), and returns the response whatever its status.
+// Absolute URLs are rejected so the credentials never leave the configured server.
+func (c *Client) Raw(method, path string, body io.Reader) (*RawResponse, error) {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "//") {
+		return nil, fmt.Errorf("path %q must be relative to the Rancher URL", path)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	resp, err := c.doRequest(strings.ToUpper(method), c.config.RancherURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &RawResponse{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package rancher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxErrorBody is how much of an error response is read for diagnostics
+	maxErrorBody = 64 << 10
+
+	// maxErrorSummary bounds the text quoted from an error response
+	maxErrorSummary = 200
+)
+
+// htmlTitle matches the title of an HTML error page
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// APIError is a non-successful response from the Rancher API
+type APIError struct {
+	// Op describes the failed operation, e.g. "list clusters"
+	Op string
+
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+
+	// Code, Message and FieldName come from Rancher's JSON error body, when present
+	Code      string
+	Message   string
+	FieldName string
+
+	// Body is a concise description of a body that is not a Rancher error
+	Body string
+}
+
+// NewAPIError reads an unsuccessful response into an APIError. Rancher's JSON
+// errors are parsed; HTML or plain text bodies, typically produced by a load
+// balancer or proxy in front of Rancher, are reduced to their title or first
+// line with a hint about where they likely came from.
+func NewAPIError(op string, resp *http.Response) *APIError {
+	apiErr := &APIError{Op: op, StatusCode: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		apiErr.Body = "(empty)"
+		return apiErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.Contains(mediaType, "json") || (mediaType == "" && body[0] == '{') {
+		var rancherErr struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			FieldName string `json:"fieldName"`
+		}
+		if err := json.Unmarshal(body, &rancherErr); err != nil || (rancherErr.Message == "" && rancherErr.Code == "") {
+			apiErr.Body = truncate(string(body), maxErrorSummary*2)
+			return apiErr
+		}
+		apiErr.Code = rancherErr.Code
+		apiErr.Message = rancherErr.Message
+		apiErr.FieldName = rancherErr.FieldName
+		return apiErr
+	}
+
+	summary := firstLine(body)
+	if match := htmlTitle.FindSubmatch(body); match != nil {
+		summary = strings.Join(strings.Fields(string(match[1])), " ")
+	} else if bytes.HasPrefix(body, []byte("<")) {
+		summary = "(HTML page)"
+	}
+	if mediaType == "" {
+		mediaType = "non-JSON"
+	}
+	apiErr.Body = fmt.Sprintf("%s response %q; a proxy or load balancer in front of Rancher may have answered instead of Rancher",
+		mediaType, truncate(summary, maxErrorSummary))
+	return apiErr
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.Details())
+	}
+	return fmt.Sprintf("failed to %s: status %d: %s", e.Op, e.StatusCode, e.Details())
+}
+
+// Details describes the error body: Rancher's code and message, or a summary of
+// a body that is not a Rancher error
+func (e *APIError) Details() string {
+	if e.Code == "" && e.Message == "" {
+		return e.Body
+	}
+
+	details := e.Message
+	if e.Code != "" && details != "" {
+		details = e.Code + ": " + details
+	} else if details == "" {
+		details = e.Code
+	}
+	if e.FieldName != "" {
+		details += fmt.Sprintf(" (field %s)", e.FieldName)
+	}
+	return details
+}
+
+// Hint suggests how to resolve common errors, or returns an empty string
+func (e *APIError) Hint() string {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return "the Rancher credentials were rejected; check the token or password, or run \"login\" again if it has expired"
+	case http.StatusForbidden:
+		return "your Rancher user is not permitted to perform this operation; ask a Rancher administrator for access"
+	case http.StatusNotFound:
+		return "the resource was not found; check the Rancher URL and that the cluster still exists"
+	}
+	return ""
+}
+
+// StatusCode returns the HTTP status of the Rancher API error wrapped by err, or
+// 0 if err does not wrap an APIError
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsUnauthorized reports whether err is a 401 response from Rancher
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err is a 403 response from Rancher
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsNotFound reports whether err is a 404 response from Rancher
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// isHTMLResponse reports whether a response declares an HTML body, such as a
+// proxy's error or login page served in place of the Rancher API
+func isHTMLResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// firstLine returns the first non-blank line of a body
+func firstLine(body []byte) string {
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// truncate shortens s to at most n bytes, marking the cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,119 @@
+package rancher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failed requests that
+	// opens the circuit when no threshold is configured
+	DefaultBreakerThreshold = 5
+
+	// breakerCooldown is how long an open circuit fails requests before letting
+	// one through to check whether Rancher has recovered
+	breakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting Rancher once too many
+// consecutive requests have failed
+var ErrCircuitOpen = errors.New("rancher is not responding; giving up on the remaining requests")
+
+// breaker stops sending requests to a Rancher server that keeps failing, so a
+// dead server produces one clear error instead of a timeout per request. A nil
+// breaker lets every request through.
+type breaker struct {
+	threshold int
+
+	mu        sync.Mutex
+	failures  int
+	lastErr   error
+	openUntil time.Time
+	probing   bool
+}
+
+// newBreaker returns a breaker for threshold, following the BreakerThreshold
+// convention: 0 uses the default and a negative value disables it (nil)
+func newBreaker(threshold int) *breaker {
+	switch {
+	case threshold < 0:
+		return nil
+	case threshold == 0:
+		threshold = DefaultBreakerThreshold
+	}
+	return &breaker{threshold: threshold}
+}
+
+// allow returns ErrCircuitOpen, wrapped with the last failure, while the
+// circuit is open. After the cooldown a single request is let through.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return b.openError()
+	}
+	b.probing = true
+	return nil
+}
+
+// open returns the error allow would return while the circuit is open and no
+// request may be let through yet, without claiming the trial request
+func (b *breaker) open() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold || (!b.probing && !time.Now().Before(b.openUntil)) {
+		return nil
+	}
+	return b.openError()
+}
+
+// openError describes the open circuit; b.mu must be held
+func (b *breaker) openError() error {
+	return fmt.Errorf("%w (%d consecutive failures, last: %v)", ErrCircuitOpen, b.failures, b.lastErr)
+}
+
+// record counts the outcome of a request that allow let through
+func (b *breaker) record(resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil && !breakerFailure(resp.StatusCode) {
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+
+	b.failures++
+	if err != nil {
+		b.lastErr = err
+	} else {
+		b.lastErr = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// breakerFailure reports whether a status means Rancher itself is unavailable,
+// as opposed to rejecting the request
+func breakerFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
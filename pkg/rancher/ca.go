@@ -0,0 +1,80 @@
+package rancher
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// caBundleExtensions are the files read from a CA bundle directory
+var caBundleExtensions = map[string]bool{".pem": true, ".crt": true, ".cer": true}
+
+// ReadCABundle returns the PEM certificates of path, which may be a file or a
+// directory of .pem, .crt and .cer files, followed by the inline PEM data
+func ReadCABundle(path, inline string) ([]byte, error) {
+	var bundle bytes.Buffer
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = caBundleFiles(path); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			}
+			bundle.Write(data)
+			bundle.WriteByte('\n')
+		}
+	}
+	if inline != "" {
+		bundle.WriteString(inline)
+		bundle.WriteByte('\n')
+	}
+	return bundle.Bytes(), nil
+}
+
+// caBundleFiles lists the certificate files of a directory in name order
+func caBundleFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !caBundleExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .pem, .crt or .cer files in CA directory %s", dir)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// CertPool returns the roots to verify Rancher with: the system roots plus
+// bundle, or bundle alone when includeSystem is false
+func CertPool(bundle []byte, includeSystem bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if includeSystem {
+		if system, err := x509.SystemCertPool(); err == nil {
+			pool = system
+		}
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("failed to parse CA certificate")
+	}
+	return pool, nil
+}
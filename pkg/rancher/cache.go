@@ -0,0 +1,180 @@
+package rancher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+// DefaultSharedCacheTTL is how long an entry stays in a shared list cache
+const DefaultSharedCacheTTL = 10 * time.Minute
+
+// sharedCacheTimeout bounds each round trip to a shared list cache
+const sharedCacheTimeout = 2 * time.Second
+
+// SharedCache stores list cache entries outside the process, so that several
+// servers behind a load balancer revalidate against the same entry instead of
+// each downloading the full collection
+type SharedCache interface {
+	// Get returns an entry, reporting false if it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores an entry that expires after ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ListCache remembers the last cluster collection returned by Rancher together
+// with its validators (ETag / Last-Modified), so repeated listings in long-running
+// modes cost a 304 Not Modified instead of the full payload.
+// A ListCache is safe for concurrent use and may be shared between clients.
+type ListCache struct {
+	mu      sync.Mutex
+	entries map[string]*listCacheEntry
+
+	shared    SharedCache
+	sharedTTL time.Duration
+}
+
+// listCacheEntry is a single cached collection and the validators it was served with
+type listCacheEntry struct {
+	etag         string
+	lastModified string
+	clusters     []Cluster
+}
+
+// sharedListEntry is the serialized form of a listCacheEntry in a SharedCache
+type sharedListEntry struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Clusters     []Cluster `json:"clusters"`
+}
+
+// NewListCache creates an empty cluster list cache
+func NewListCache() *ListCache {
+	return &ListCache{
+		entries: make(map[string]*listCacheEntry),
+	}
+}
+
+// SetShared backs the cache with a shared store. Entries are read from the
+// shared store first and written to both; if the shared store fails, the
+// in-memory entries are used. A ttl of 0 uses DefaultSharedCacheTTL.
+func (lc *ListCache) SetShared(shared SharedCache, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultSharedCacheTTL
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.shared = shared
+	lc.sharedTTL = ttl
+}
+
+// get returns the cached entry for the key, if any, reporting to reporter when
+// the shared store is unavailable
+func (lc *ListCache) get(key string, reporter events.Reporter) (*listCacheEntry, bool) {
+	lc.mu.Lock()
+	shared := lc.shared
+	lc.mu.Unlock()
+
+	if shared != nil {
+		if entry, ok := lc.getShared(shared, key, reporter); ok {
+			return entry, true
+		}
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	entry, ok := lc.entries[key]
+	return entry, ok
+}
+
+// getShared reads an entry from the shared store
+func (lc *ListCache) getShared(shared SharedCache, key string, reporter events.Reporter) (*listCacheEntry, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+
+	data, ok, err := shared.Get(ctx, sharedCacheKey(key))
+	if err != nil {
+		reporter.Report(events.Event{Kind: events.Warning, Message: "shared cluster cache unavailable, using local cache", Err: err})
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var stored sharedListEntry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, false
+	}
+	return &listCacheEntry{
+		etag:         stored.ETag,
+		lastModified: stored.LastModified,
+		clusters:     stored.Clusters,
+	}, true
+}
+
+// store records a freshly downloaded collection if the response carried validators
+func (lc *ListCache) store(key string, header http.Header, clusters []Cluster, reporter events.Reporter) {
+	etag := header.Get("ETag")
+	lastModified := header.Get("Last-Modified")
+
+	lc.mu.Lock()
+	if etag == "" && lastModified == "" {
+		// Nothing to revalidate against next time
+		delete(lc.entries, key)
+		lc.mu.Unlock()
+		return
+	}
+
+	lc.entries[key] = &listCacheEntry{
+		etag:         etag,
+		lastModified: lastModified,
+		clusters:     append([]Cluster(nil), clusters...),
+	}
+	shared, ttl := lc.shared, lc.sharedTTL
+	lc.mu.Unlock()
+
+	if shared == nil {
+		return
+	}
+	data, err := json.Marshal(sharedListEntry{ETag: etag, LastModified: lastModified, Clusters: clusters})
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+	if err := shared.Set(ctx, sharedCacheKey(key), data, ttl); err != nil {
+		reporter.Report(events.Event{Kind: events.Warning, Message: "failed to update shared cluster cache", Err: err})
+	}
+}
+
+// Invalidate drops every cached collection held in memory. Entries in a
+// shared store are left to expire, as other servers may still use them.
+func (lc *ListCache) Invalidate() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.entries = make(map[string]*listCacheEntry)
+}
+
+// sharedCacheKey derives the shared store key from a list cache key, hashing
+// it so user names and access keys are not written to the shared store
+func sharedCacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "rancher-clusters:" + hex.EncodeToString(sum[:])
+}
+
+// applyValidators adds conditional request headers for a cached entry
+func (e *listCacheEntry) applyValidators(req *http.Request) {
+	if e.etag != "" {
+		req.Header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		req.Header.Set("If-Modified-Since", e.lastModified)
+	}
+}
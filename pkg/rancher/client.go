@@ -3,23 +3,40 @@ package rancher
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
 )
 
+// stderrReporter reports the events of clients that were given no reporter
+var stderrReporter = events.NewTerminal(os.Stderr)
+
 // Client is a Rancher API client
 type Client struct {
 	config      *config.Config
 	httpClient  *http.Client
 	bearerToken string // Used for password auth after login
+	listCache   *ListCache
+	filter      ClusterFilter
+	schemaDrift *SchemaDrift
+	breaker     *breaker
+	reporter    events.Reporter
+
+	// waitDeadline bounds waiting for an unavailable Rancher (zero to fail at once)
+	waitDeadline time.Time
+
+	// startCtx cancels the wait for Rancher in NewClient
+	startCtx context.Context
 }
 
 // LoginRequest represents the request body for password authentication
@@ -40,20 +57,93 @@ type LoginResponse struct {
 
 // Cluster represents a Rancher managed cluster
 type Cluster struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	State       string `json:"state"`
-	Provider    string `json:"provider"`
-	Links       struct {
-		Self             string `json:"self"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	State       string            `json:"state"`
+	Provider    string            `json:"provider"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Version     struct {
+		GitVersion string `json:"gitVersion"`
+	} `json:"version"`
+	NodeCount int    `json:"nodeCount"`
+	Created   string `json:"created"`
+	CreatedTS int64  `json:"createdTS"`
+	Links     struct {
+		Self               string `json:"self"`
 		GenerateKubeconfig string `json:"generateKubeconfig"`
 	} `json:"links"`
 	Actions struct {
 		GenerateKubeconfig string `json:"generateKubeconfig"`
 	} `json:"actions"`
+
+	// APIEndpoint is the URL Rancher reaches the cluster's API server at
+	APIEndpoint string `json:"apiEndpoint"`
+	// LocalClusterAuthEndpoint is the cluster's authorized cluster endpoint
+	LocalClusterAuthEndpoint struct {
+		Enabled bool   `json:"enabled"`
+		FQDN    string `json:"fqdn"`
+	} `json:"localClusterAuthEndpoint"`
 }
 
+// CreatedAt returns when the cluster was created, from the created timestamp or
+// else createdTS (Unix milliseconds), or false if Rancher did not say
+func (c *Cluster) CreatedAt() (time.Time, bool) {
+	if created, err := time.Parse(time.RFC3339, c.Created); err == nil {
+		return created, true
+	}
+	if c.CreatedTS > 0 {
+		return time.UnixMilli(c.CreatedTS).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// Servers returns the URLs the cluster's API server is known by besides the
+// Rancher proxy: its API endpoint and the FQDN of its authorized cluster
+// endpoint, if enabled
+func (c *Cluster) Servers() []string {
+	var servers []string
+	if c.APIEndpoint != "" {
+		servers = append(servers, c.APIEndpoint)
+	}
+	if c.LocalClusterAuthEndpoint.Enabled && c.LocalClusterAuthEndpoint.FQDN != "" {
+		servers = append(servers, "https://"+c.LocalClusterAuthEndpoint.FQDN)
+	}
+	return servers
+}
+
+// acceptsAge applies the configured age filters to a cluster. A cluster of
+// unknown age is kept, with a warning, since it cannot be told apart.
+func (c *Client) acceptsAge(cluster *Cluster) bool {
+	if c.config.OlderThan == 0 && c.config.NewerThan == 0 {
+		return true
+	}
+	created, ok := cluster.CreatedAt()
+	if !ok {
+		events.Warnf(c.events(), cluster.Name, "cluster %s has no creation time, ignoring the age filters for it", cluster.Name)
+		return true
+	}
+	age := time.Since(created)
+	if !c.config.AcceptsClusterAge(age) {
+		events.Warnf(c.events(), cluster.Name, "skipping cluster %s created %s ago", cluster.Name, age.Round(time.Minute))
+		return false
+	}
+	return true
+}
+
+// harvesterProviderLabel is the label Rancher sets to the provider of imported clusters
+const harvesterProviderLabel = "provider.cattle.io"
+
+// IsHarvester reports whether the cluster is a Harvester HCI cluster. Their
+// kubeconfigs manage the virtualization platform rather than workloads.
+func (c *Cluster) IsHarvester() bool {
+	return strings.EqualFold(c.Provider, "harvester") || c.Labels[harvesterProviderLabel] == "harvester"
+}
+
+// ClusterFilter decides whether a cluster in an accepted state is included by GetAllKubeconfigs
+type ClusterFilter func(cluster Cluster) bool
+
 // ClusterCollection represents the response from the clusters endpoint
 type ClusterCollection struct {
 	Data []Cluster `json:"data"`
@@ -64,27 +154,54 @@ type KubeconfigResponse struct {
 	Config string `json:"config"`
 }
 
+// ClientOption configures a Client created by NewClient
+type ClientOption func(*Client)
+
+// WithReporter makes the client report progress and skipped clusters to
+// reporter from the start, including while NewClient waits for Rancher
+func WithReporter(reporter events.Reporter) ClientOption {
+	return func(c *Client) {
+		c.reporter = reporter
+	}
+}
+
+// WithTimeout limits each request of the client, 30 seconds by default
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithContext lets ctx cancel the wait for an unavailable Rancher in NewClient,
+// e.g. on an interrupt caught by the caller
+func WithContext(ctx context.Context) ClientOption {
+	return func(c *Client) {
+		c.startCtx = ctx
+	}
+}
+
 // NewClient creates a new Rancher API client
-func NewClient(cfg *config.Config) (*Client, error) {
+func NewClient(cfg *config.Config, opts ...ClientOption) (*Client, error) {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: cfg.InsecureSkipTLSVerify,
 	}
 
-	// Load custom CA certificate if provided
-	if cfg.CACert != "" {
-		caCert, err := os.ReadFile(cfg.CACert)
+	// Trust the custom CAs in addition to the system roots, unless excluded
+	if cfg.CACert != "" || cfg.CACertData != "" {
+		bundle, err := ReadCABundle(cfg.CACert, cfg.CACertData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			return nil, err
 		}
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, fmt.Errorf("failed to parse CA certificate")
+		pool, err := CertPool(bundle, !cfg.ExcludeSystemCAs)
+		if err != nil {
+			return nil, err
 		}
-		tlsConfig.RootCAs = caCertPool
+		tlsConfig.RootCAs = pool
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+	var transport http.RoundTripper = newTransport(cfg, tlsConfig)
+	if cfg.DebugHTTP {
+		transport = &debugTransport{next: transport, out: os.Stderr}
 	}
 
 	httpClient := &http.Client{
@@ -95,6 +212,20 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	client := &Client{
 		config:     cfg,
 		httpClient: httpClient,
+		listCache:  NewListCache(),
+		breaker:    newBreaker(cfg.BreakerThreshold),
+		startCtx:   context.Background(),
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	// Wait out a maintenance window before the first request
+	if cfg.WaitForRancher > 0 {
+		client.waitDeadline = time.Now().Add(cfg.WaitForRancher)
+		if err := client.waitForRancher(client.startCtx); err != nil {
+			return nil, err
+		}
 	}
 
 	// If using password auth, perform login to get a bearer token
@@ -120,8 +251,11 @@ func (c *Client) login() error {
 		return fmt.Errorf("failed to marshal login request: %w", err)
 	}
 
-	// Try local authentication first
-	url := fmt.Sprintf("%s/v3-public/localProviders/local?action=login", c.config.RancherURL)
+	providerPath, err := LoginProviderPath(c.config.AuthProvider)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v3-public/%s?action=login", c.config.RancherURL, providerPath)
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
@@ -129,6 +263,7 @@ func (c *Client) login() error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	SetClientHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -137,8 +272,7 @@ func (c *Client) login() error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("login failed: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return NewAPIError("log in", resp)
 	}
 
 	var loginResp LoginResponse
@@ -154,8 +288,8 @@ func (c *Client) login() error {
 	return nil
 }
 
-// doRequest performs an HTTP request with authentication
-func (c *Client) doRequest(method, url string, body io.Reader) (*http.Response, error) {
+// newRequest builds an authenticated request against the Rancher API
+func (c *Client) newRequest(method, url string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -171,36 +305,152 @@ func (c *Client) doRequest(method, url string, body io.Reader) (*http.Response,
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	SetClientHeaders(req)
+	if c.config.AsUser != "" {
+		req.Header.Set(impersonateUserHeader, c.config.AsUser)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	return req, nil
+}
+
+// do sends a prepared request, retrying while Rancher is throttling or
+// unavailable and waiting for it to come back within the wait deadline,
+// unless the circuit breaker is open. The response body fails with ErrResponseTooLarge when it grows
+// beyond the maximum response size.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.sendWithRetry(req)
+	resp, err = c.waitAndResend(req, resp, err)
+	c.breaker.record(resp, err)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
+	limit := c.maxResponseSize()
+	resp.Body = &limitedBody{ReadCloser: resp.Body, limit: limit, remaining: limit}
 	return resp, nil
 }
 
-// ListClusters retrieves all clusters from the Rancher API
+// doRequest performs an HTTP request with authentication
+func (c *Client) doRequest(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// SetReporter sets where the client reports progress and skipped clusters;
+// without one, they are written to stderr
+func (c *Client) SetReporter(reporter events.Reporter) {
+	c.reporter = reporter
+}
+
+// events returns the reporter of the client
+func (c *Client) events() events.Reporter {
+	if c.reporter == nil {
+		return stderrReporter
+	}
+	return c.reporter
+}
+
+// SetListCache replaces the client's cluster list cache, allowing several
+// clients (e.g. one per web request) to share validators. A nil cache
+// disables conditional requests.
+func (c *Client) SetListCache(cache *ListCache) {
+	c.listCache = cache
+}
+
+// SetClusterFilter restricts GetAllKubeconfigs to the clusters the filter accepts
+func (c *Client) SetClusterFilter(filter ClusterFilter) {
+	c.filter = filter
+}
+
+// SchemaDrift returns the schema differences detected in the last clusters
+// response, or nil if none has been received
+func (c *Client) SchemaDrift() *SchemaDrift {
+	return c.schemaDrift
+}
+
+// listCacheKey identifies a cached collection by URL and the identity used to fetch it,
+// so different users never see each other's cluster lists
+func (c *Client) listCacheKey(url string) string {
+	identity := c.config.AccessKey
+	if c.config.UsePasswordAuth() {
+		identity = c.config.Username
+	}
+	if c.config.AsUser != "" {
+		identity += " as " + c.config.AsUser
+	}
+	return identity + "@" + url
+}
+
+// ListClusters retrieves all clusters from the Rancher API.
+// When a list cache is configured the request is conditional, and an unchanged
+// collection is served from the cache after a 304 Not Modified.
 func (c *Client) ListClusters() ([]Cluster, error) {
 	url := fmt.Sprintf("%s/v3/clusters", c.config.RancherURL)
 
-	resp, err := c.doRequest("GET", url, nil)
+	req, err := c.newRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cached *listCacheEntry
+	cacheKey := c.listCacheKey(url)
+	if c.listCache != nil {
+		if entry, ok := c.listCache.get(cacheKey, c.events()); ok {
+			cached = entry
+			cached.applyValidators(req)
+		}
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return append([]Cluster(nil), cached.clusters...), nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list clusters: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, NewAPIError("list clusters", resp)
+	}
+
+	if isHTMLResponse(resp) {
+		return nil, NewAPIError("list clusters", resp)
 	}
 
-	var collection ClusterCollection
-	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+	// Decode cluster by cluster so large collections are never buffered whole
+	var clusters []Cluster
+	schema := newSchemaChecker()
+	schema.paths = fieldPaths(c.config)
+	err = decodeCollection(resp.Body, func(item json.RawMessage) error {
+		var cluster Cluster
+		if err := json.Unmarshal(item, &cluster); err != nil {
+			return err
+		}
+		if err := mapFields(item, schema.paths, &cluster); err != nil {
+			return err
+		}
+		clusters = append(clusters, cluster)
+		schema.check(item)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to decode clusters response: %w", err)
 	}
+	c.schemaDrift = schema.drift()
+
+	if c.listCache != nil {
+		c.listCache.store(cacheKey, resp.Header, clusters, c.events())
+	}
 
-	return collection.Data, nil
+	return clusters, nil
 }
 
 // GetClusterKubeconfig retrieves the kubeconfig for a specific cluster
@@ -219,9 +469,15 @@ func (c *Client) GetClusterKubeconfig(cluster *Cluster) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get kubeconfig for cluster %s: status %d, body: %s",
-			cluster.Name, resp.StatusCode, string(bodyBytes))
+		apiErr := NewAPIError("get kubeconfig for cluster "+cluster.Name, resp)
+		if kubeconfigActionUnavailable(resp.StatusCode) {
+			return "", fmt.Errorf("%w: %w", ErrGenerateKubeconfigUnavailable, apiErr)
+		}
+		return "", apiErr
+	}
+
+	if isHTMLResponse(resp) {
+		return "", NewAPIError("get kubeconfig for cluster "+cluster.Name, resp)
 	}
 
 	var kubeconfigResp KubeconfigResponse
@@ -232,29 +488,126 @@ func (c *Client) GetClusterKubeconfig(cluster *Cluster) (string, error) {
 	return kubeconfigResp.Config, nil
 }
 
-// GetAllKubeconfigs retrieves kubeconfigs for all active clusters
+// GetAllKubeconfigs retrieves kubeconfigs for all clusters in an accepted state
+// (see config.Config.AcceptsClusterState). Skipped and failed clusters are
+// reported as warnings; use FetchKubeconfigs to handle failures instead.
 func (c *Client) GetAllKubeconfigs() (map[string]string, error) {
+	result, err := c.FetchKubeconfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, failure := range result.Failures {
+		c.events().Report(events.Event{Kind: events.Warning, Message: "skipping cluster " + failure.Name, Cluster: failure.Name, Err: failure.Err})
+	}
+	return result.Kubeconfigs, nil
+}
+
+// FetchKubeconfigs retrieves kubeconfigs for all clusters in an accepted state,
+// returning the clusters that failed alongside those that succeeded. Clusters
+// skipped by the state, Harvester and age filters are reported as warnings, as
+// are the selected clusters (config.Config.Clusters) Rancher does not list. With
+// AsUser set, only kubeconfigs holding that user's tokens are returned. An
+// error is returned only when nothing could be fetched at all.
+func (c *Client) FetchKubeconfigs() (*KubeconfigResult, error) {
+	if err := c.VerifyImpersonation(); err != nil {
+		return nil, err
+	}
+
 	clusters, err := c.ListClusters()
 	if err != nil {
 		return nil, err
 	}
 
-	kubeconfigs := make(map[string]string)
+	result := &KubeconfigResult{Kubeconfigs: make(map[string]string), Clusters: make(map[string]Cluster)}
+	selected := make(map[string]bool)
 	for _, cluster := range clusters {
-		// Skip clusters that are not active
+		result.ListedIDs = append(result.ListedIDs, cluster.ID)
+		if !c.config.SelectsCluster(cluster.ID, cluster.Name) {
+			continue
+		}
+		selected[cluster.ID], selected[cluster.Name] = true, true
+
+		if !c.config.AcceptsClusterState(cluster.State) {
+			events.Warnf(c.events(), cluster.Name, "skipping cluster %s in state %q (accepted states: %s)",
+				cluster.Name, cluster.State, strings.Join(c.config.AcceptedClusterStates(), ", "))
+			continue
+		}
+		if !c.config.AcceptsHarvester(cluster.IsHarvester()) {
+			if cluster.IsHarvester() {
+				events.Warnf(c.events(), cluster.Name, "skipping Harvester cluster %s", cluster.Name)
+			}
+			continue
+		}
+		if !c.acceptsAge(&cluster) {
+			continue
+		}
+		if !c.config.AcceptsClusterName(cluster.Name) {
+			continue
+		}
+		if !c.config.IncludeEphemeral && c.config.IsEphemeral(cluster.Name, cluster.Labels) {
+			events.Warnf(c.events(), cluster.Name, "skipping ephemeral cluster %s (use --include-ephemeral to keep it)", cluster.Name)
+			continue
+		}
 		if cluster.State != "active" {
+			events.Warnf(c.events(), cluster.Name, "including cluster %s in state %q; its API may not be reachable", cluster.Name, cluster.State)
+		}
+		if c.filter != nil && !c.filter(cluster) {
 			continue
 		}
 
-		kubeconfig, err := c.GetClusterKubeconfig(&cluster)
+		// Once the circuit opens the remaining clusters fail without a
+		// request, keeping those already fetched
+		if err := c.breaker.open(); err != nil {
+			result.Failures = append(result.Failures, ClusterFailure{Name: cluster.Name, ID: cluster.ID, Err: err})
+			continue
+		}
+		kubeconfig, err := c.clusterKubeconfig(&cluster)
 		if err != nil {
-			// Log the error but continue with other clusters
-			fmt.Fprintf(os.Stderr, "Warning: failed to get kubeconfig for cluster %s: %v\n", cluster.Name, err)
+			result.Failures = append(result.Failures, ClusterFailure{Name: cluster.Name, ID: cluster.ID, Err: err})
 			continue
 		}
+		result.Kubeconfigs[cluster.Name] = kubeconfig
+		result.Clusters[cluster.Name] = cluster
+	}
+	for _, name := range c.config.Clusters {
+		if !selected[name] {
+			events.Warnf(c.events(), name, "cluster %s not found in %s", name, c.config.RancherURL)
+		}
+	}
+
+	return result, nil
+}
+
+// clusterKubeconfig produces the kubeconfig of one eligible cluster, falling
+// back to a proxy kubeconfig, scoping its token and checking impersonation as
+// configured
+func (c *Client) clusterKubeconfig(cluster *Cluster) (string, error) {
+	// The token is replaced by an exec credential plugin, so do not have
+	// Rancher mint one just to throw it away
+	if c.config.ExecCredentials {
+		return c.BuildProxyKubeconfig(cluster)
+	}
 
-		kubeconfigs[cluster.Name] = kubeconfig
+	kubeconfig, err := c.GetClusterKubeconfig(cluster)
+	generated := err == nil
+	if errors.Is(err, ErrGenerateKubeconfigUnavailable) {
+		events.Warnf(c.events(), cluster.Name, "cannot generate a kubeconfig for cluster %s, building a proxy kubeconfig with your own credentials", cluster.Name)
+		kubeconfig, err = c.BuildProxyKubeconfig(cluster)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if c.config.ScopedTokens {
+		if kubeconfig, err = c.scopeKubeconfig(cluster, kubeconfig, generated); err != nil {
+			return "", err
+		}
 	}
 
-	return kubeconfigs, nil
+	if c.config.AsUser != "" {
+		if err := c.checkImpersonatedKubeconfig(cluster, kubeconfig); err != nil {
+			return "", err
+		}
+	}
+	return kubeconfig, nil
 }
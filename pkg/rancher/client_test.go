@@ -1,12 +1,24 @@
 package rancher
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
 )
 
 // Sample kubeconfig for testing
@@ -465,6 +477,9 @@ func TestCluster_JSON(t *testing.T) {
 		"description": "A test cluster",
 		"state": "active",
 		"provider": "rke",
+		"version": {"gitVersion": "v1.27.16+rke2r1"},
+		"nodeCount": 3,
+		"created": "2024-03-01T10:00:00Z",
 		"links": {
 			"self": "https://rancher/v3/clusters/c-12345"
 		},
@@ -493,4 +508,2089 @@ func TestCluster_JSON(t *testing.T) {
 	if cluster.Actions.GenerateKubeconfig == "" {
 		t.Error("Actions.GenerateKubeconfig should not be empty")
 	}
+	if cluster.Version.GitVersion != "v1.27.16+rke2r1" || cluster.NodeCount != 3 {
+		t.Errorf("Version = %q, NodeCount = %d", cluster.Version.GitVersion, cluster.NodeCount)
+	}
+	if created, ok := cluster.CreatedAt(); !ok || created.Year() != 2024 {
+		t.Errorf("CreatedAt() = %v, %v", created, ok)
+	}
+	if created, ok := (&Cluster{CreatedTS: 1717200000000}).CreatedAt(); !ok || !created.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt() from createdTS = %v, %v", created, ok)
+	}
+	if _, ok := (&Cluster{}).CreatedAt(); ok {
+		t.Error("CreatedAt() of a cluster without a timestamp should report false")
+	}
+}
+
+func TestCluster_Servers(t *testing.T) {
+	var cluster Cluster
+	if err := json.Unmarshal([]byte(`{
+		"id": "c-m-abc123",
+		"apiEndpoint": "https://10.0.0.10:6443",
+		"localClusterAuthEndpoint": {"enabled": true, "fqdn": "k8s.example.com"}
+	}`), &cluster); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if got := strings.Join(cluster.Servers(), ","); got != "https://10.0.0.10:6443,https://k8s.example.com" {
+		t.Errorf("Servers() = %s", got)
+	}
+
+	cluster.LocalClusterAuthEndpoint.Enabled = false
+	if got := strings.Join(cluster.Servers(), ","); got != "https://10.0.0.10:6443" {
+		t.Errorf("Servers() with the authorized cluster endpoint disabled = %s", got)
+	}
+}
+
+func TestNewClient_TransportDefaults(t *testing.T) {
+	cfg := &config.Config{
+		RancherURL: "https://rancher.example.com",
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", client.httpClient.Transport)
+	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Errorf("IdleConnTimeout = %v, want %v", transport.IdleConnTimeout, DefaultIdleConnTimeout)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 should be attempted by default")
+	}
+	if transport.TLSNextProto != nil {
+		t.Error("TLSNextProto should be nil when HTTP/2 is enabled")
+	}
+}
+
+func TestNewClient_TransportTuning(t *testing.T) {
+	cfg := &config.Config{
+		RancherURL:          "https://rancher.example.com",
+		AccessKey:           "access123",
+		SecretKey:           "secret456",
+		AuthMethod:          config.AuthMethodToken,
+		MaxIdleConnsPerHost: 200,
+		MaxConnsPerHost:     50,
+		IdleConnTimeout:     5 * time.Minute,
+		DisableHTTP2:        true,
+		DisableKeepAlives:   true,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	transport := client.httpClient.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 200 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 200", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxConnsPerHost != 50 {
+		t.Errorf("MaxConnsPerHost = %d, want 50", transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 5*time.Minute {
+		t.Errorf("IdleConnTimeout = %v, want 5m", transport.IdleConnTimeout)
+	}
+	if transport.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 should not be attempted when disabled")
+	}
+	if transport.TLSNextProto == nil {
+		t.Error("TLSNextProto should be a non-nil empty map when HTTP/2 is disabled")
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("DisableKeepAlives should be true")
+	}
+}
+
+func TestClient_ListClusters_ConditionalRequest(t *testing.T) {
+	clusters := []Cluster{
+		{ID: "c-12345", Name: "dev-cluster", State: "active"},
+	}
+
+	var fullResponses, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/clusters" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: clusters})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := client.ListClusters()
+		if err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+		if len(result) != 1 || result[0].Name != "dev-cluster" {
+			t.Fatalf("call %d: unexpected clusters: %+v", i, result)
+		}
+	}
+
+	if fullResponses != 1 {
+		t.Errorf("expected 1 full response, got %d", fullResponses)
+	}
+	if notModified != 2 {
+		t.Errorf("expected 2 not-modified responses, got %d", notModified)
+	}
+}
+
+// memorySharedCache is an in-process SharedCache for tests
+type memorySharedCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (m *memorySharedCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.entries[key]
+	return value, ok, nil
+}
+
+func (m *memorySharedCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = value
+	return nil
+}
+
+func TestClient_ListClusters_SharedCache(t *testing.T) {
+	var fullResponses int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-1", Name: "prod", State: "active"}}})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+	shared := &memorySharedCache{entries: map[string][]byte{}}
+
+	// Two replicas, each with its own in-memory cache backed by the same shared store
+	for replica := 0; replica < 2; replica++ {
+		client, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		cache := NewListCache()
+		cache.SetShared(shared, 0)
+		client.SetListCache(cache)
+
+		result, err := client.ListClusters()
+		if err != nil {
+			t.Fatalf("replica %d: unexpected error: %v", replica, err)
+		}
+		if len(result) != 1 || result[0].Name != "prod" {
+			t.Fatalf("replica %d: unexpected clusters: %+v", replica, result)
+		}
+	}
+
+	if fullResponses != 1 {
+		t.Errorf("expected 1 full response across replicas, got %d", fullResponses)
+	}
+	for key := range shared.entries {
+		if strings.Contains(key, "access123") {
+			t.Errorf("shared cache key %q exposes the access key", key)
+		}
+	}
+}
+
+func TestClient_ListClusters_CacheDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Error("conditional header should not be sent when the cache is disabled")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{}})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetListCache(nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListClusters(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestClient_ListProjects_ExcludesSystem(t *testing.T) {
+	projects := []Project{
+		{ID: "c-12345:p-aaaaa", Name: "Default", ClusterID: "c-12345"},
+		{ID: "c-12345:p-bbbbb", Name: "System", ClusterID: "c-12345", Labels: map[string]string{systemProjectLabel: "true"}},
+		{ID: "c-12345:p-ccccc", Name: "payments", ClusterID: "c-12345"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/projects" && r.URL.Query().Get("clusterId") == "c-12345" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ProjectCollection{Data: projects})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	result, err := client.ListProjects("c-12345")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 projects without System, got %d", len(result))
+	}
+	for _, p := range result {
+		if p.IsSystem() {
+			t.Errorf("system project %s should have been excluded", p.Name)
+		}
+	}
+
+	cfg.IncludeSystemProjects = true
+	result, err = client.ListProjects("c-12345")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 3 {
+		t.Errorf("expected 3 projects with System included, got %d", len(result))
+	}
+}
+
+func TestClient_ListProjectNamespaces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/projects" && r.URL.Query().Get("clusterId") == "c-12345":
+			_ = json.NewEncoder(w).Encode(ProjectCollection{Data: []Project{
+				{ID: "c-12345:p-aaaaa", Name: "payments"},
+				{ID: "c-12345:p-bbbbb", Name: "System", Labels: map[string]string{systemProjectLabel: "true"}},
+			}})
+		case r.URL.Path == "/v3/cluster/c-12345/namespaces":
+			_ = json.NewEncoder(w).Encode(NamespaceCollection{Data: []Namespace{
+				{ID: "payments-api", Name: "payments-api", ProjectID: "c-12345:p-aaaaa"},
+				{ID: "kube-system", Name: "kube-system", ProjectID: "c-12345:p-bbbbb"},
+				{ID: "loose", Name: "loose"},
+				{ID: "payments-db", Name: "payments-db", ProjectID: "c-12345:p-aaaaa"},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	names, err := client.ListProjectNamespaces("c-12345")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(names, ",") != "payments-api,payments-db" {
+		t.Errorf("ListProjectNamespaces() = %v, want the namespaces of the payments project", names)
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3":
+			_, _ = w.Write([]byte(`{"type":"apiRoot"}`))
+		case r.URL.Path == "/v3/users" && r.URL.Query().Get("me") == "true":
+			_ = json.NewEncoder(w).Encode(userCollection{Data: []User{{ID: "u-abc", Username: "admin"}}})
+		case r.URL.Path == "/v3/tokens/token-xxxxx":
+			_ = json.NewEncoder(w).Encode(Token{Name: "token-xxxxx", ClusterID: "c-12345", ExpiresAt: "2030-01-01T00:00:00Z"})
+		case r.URL.Path == "/v3/settings/server-version":
+			_, _ = w.Write([]byte(`{"value":"v2.9.2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:            server.URL,
+		AccessKey:             "token-xxxxx",
+		SecretKey:             "secret456",
+		AuthMethod:            config.AuthMethodToken,
+		InsecureSkipTLSVerify: true,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	result, err := client.Ping()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Reachable || !result.Authenticated {
+		t.Errorf("expected reachable and authenticated, got %+v", result)
+	}
+	if result.TLS == nil || result.TLS.Verified {
+		t.Error("expected TLS info reporting verification disabled")
+	}
+	if timings := result.Timings; timings == nil || timings.Reused || timings.TLS <= 0 || timings.Total < timings.FirstByte {
+		t.Errorf("expected timings of a fresh TLS connection, got %+v", timings)
+	}
+	if result.User == nil || result.User.Username != "admin" {
+		t.Errorf("unexpected user: %+v", result.User)
+	}
+	if result.Token == nil || result.Token.Scope() != "cluster c-12345" {
+		t.Errorf("unexpected token: %+v", result.Token)
+	}
+	if result.ServerVersion != "v2.9.2" {
+		t.Errorf("ServerVersion = %q, want %q", result.ServerVersion, "v2.9.2")
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestClient_Ping_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "wrong",
+		SecretKey:  "credentials",
+		AuthMethod: config.AuthMethodToken,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	result, err := client.Ping()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Reachable {
+		t.Error("server should be reported as reachable")
+	}
+	if result.Authenticated {
+		t.Error("credentials should be reported as rejected")
+	}
+}
+
+func TestLoginProviderPath(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     string
+		wantErr  bool
+	}{
+		{"", "localProviders/local", false},
+		{"local", "localProviders/local", false},
+		{"ActiveDirectory", "activeDirectoryProviders/activedirectory", false},
+		{"openldap", "openLdapProviders/openldap", false},
+		{"github", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := LoginProviderPath(tt.provider)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LoginProviderPath(%q) error = %v, wantErr %v", tt.provider, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("LoginProviderPath(%q) = %q, want %q", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestClient_LoginCreateTokenLogout(t *testing.T) {
+	var loggedOut bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3-public/activeDirectoryProviders/activedirectory":
+			_, _ = w.Write([]byte(`{"token":"session-token"}`))
+		case r.URL.Path == "/v3/tokens" && r.URL.Query().Get("action") == "logout":
+			loggedOut = r.Header.Get("Authorization") == "Bearer session-token"
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v3/tokens" && r.Method == "POST":
+			var req createTokenRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.TTL != 3600000 || req.Description != "laptop" {
+				t.Errorf("unexpected token request: %+v", req)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"token-new:secret"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:   server.URL,
+		Username:     "alice",
+		Password:     "pw",
+		AuthMethod:   config.AuthMethodPassword,
+		AuthProvider: "activedirectory",
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	token, err := client.CreateAPIToken("laptop", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+	if token != "token-new:secret" {
+		t.Errorf("token = %q, want token-new:secret", token)
+	}
+
+	if err := client.Logout(); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if !loggedOut {
+		t.Error("expected the session token to be logged out")
+	}
+}
+
+func TestClient_GetAllKubeconfigs_ScopedTokens(t *testing.T) {
+	const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://rancher.example.com/k8s/clusters/c-12345
+  name: prod
+contexts:
+- context:
+    cluster: prod
+    user: prod
+  name: prod
+current-context: prod
+users:
+- name: prod
+  user:
+    token: kubeconfig-user-admin:global
+`
+	t.Setenv("RANCHER_STATE_DIR", t.TempDir())
+	var minted int
+	var deleted []string
+	tokens := make(map[string]Token)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		name := strings.TrimPrefix(r.URL.Path, "/v3/tokens/")
+		switch {
+		case r.URL.Path == "/v3/clusters" && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-12345", Name: "prod", State: "active"}}})
+		case r.URL.Path == "/v3/clusters/c-12345":
+			_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: kubeconfig})
+		case r.URL.Path == "/v3/tokens" && r.Method == "POST":
+			var req createTokenRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.ClusterID != "c-12345" {
+				t.Errorf("token request clusterId = %q, want c-12345", req.ClusterID)
+			}
+			if req.TTL != DefaultScopedTokenTTL.Milliseconds() {
+				t.Errorf("token request ttl = %d, want the default %d", req.TTL, DefaultScopedTokenTTL.Milliseconds())
+			}
+			minted++
+			name := fmt.Sprintf("token-scoped%d", minted)
+			tokens[name] = Token{Name: name, ClusterID: req.ClusterID, Description: req.Description}
+			_, _ = fmt.Fprintf(w, `{"token":"%s:secret"}`, name)
+		case r.Method == "GET" && name != r.URL.Path:
+			token, ok := tokens[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(token)
+		case r.Method == "DELETE" && name != r.URL.Path:
+			deleted = append(deleted, name)
+			delete(tokens, name)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:   server.URL,
+		AccessKey:    "token-xxxxx",
+		SecretKey:    "secret456",
+		AuthMethod:   config.AuthMethodToken,
+		ScopedTokens: true,
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	got := kubeconfigs["prod"]
+	if !strings.Contains(got, "token-scoped1:secret") {
+		t.Errorf("expected the scoped token in the kubeconfig, got:\n%s", got)
+	}
+	if strings.Contains(got, "kubeconfig-user-admin:global") {
+		t.Error("the original token must not remain in the kubeconfig")
+	}
+	if strings.Join(deleted, ",") != "kubeconfig-user-admin" {
+		t.Errorf("deleted tokens = %v, want the replaced kubeconfig token", deleted)
+	}
+
+	// The next run reuses the recorded token
+	kubeconfigs, err = client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if minted != 1 || !strings.Contains(kubeconfigs["prod"], "token-scoped1:secret") {
+		t.Errorf("minted %d tokens, kubeconfig:\n%s\nwant the first token reused", minted, kubeconfigs["prod"])
+	}
+
+	// A token Rancher no longer has is replaced
+	delete(tokens, "token-scoped1")
+	deleted = nil
+	kubeconfigs, err = client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if minted != 2 || !strings.Contains(kubeconfigs["prod"], "token-scoped2:secret") {
+		t.Errorf("minted %d tokens, kubeconfig:\n%s\nwant a new token", minted, kubeconfigs["prod"])
+	}
+	if strings.Join(deleted, ",") != "token-scoped1,kubeconfig-user-admin" {
+		t.Errorf("deleted tokens = %v, want the replaced scoped and kubeconfig tokens", deleted)
+	}
+}
+
+// impersonatingServer is a fake Rancher that acts as the Impersonate-User user
+// when honor is true, and ignores the header otherwise
+func impersonatingServer(t *testing.T, honor bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := "user-admin"
+		if honor && r.Header.Get("Impersonate-User") != "" {
+			userID = r.Header.Get("Impersonate-User")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/users/u-abc123":
+			_ = json.NewEncoder(w).Encode(User{ID: "u-abc123", Username: "alice"})
+		case r.URL.Path == "/v3/users" && r.URL.Query().Get("me") == "true":
+			_ = json.NewEncoder(w).Encode(userCollection{Data: []User{{ID: userID}}})
+		case r.URL.Path == "/v3/clusters" && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-12345", Name: "prod", State: "active"}}})
+		case r.URL.Path == "/v3/clusters/c-12345":
+			kubeconfig := strings.Replace(testKubeconfig, "test-token-12345", "kubeconfig-"+userID+":secret", 1)
+			_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: kubeconfig})
+		case strings.HasPrefix(r.URL.Path, "/v3/tokens/kubeconfig-"):
+			owner := strings.TrimPrefix(r.URL.Path, "/v3/tokens/kubeconfig-")
+			_ = json.NewEncoder(w).Encode(Token{Name: "kubeconfig-" + owner, UserID: owner})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestClient_GetAllKubeconfigs_AsUser(t *testing.T) {
+	server := impersonatingServer(t, true)
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+		AsUser:     "u-abc123",
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if got := kubeconfigs["prod"]; !strings.Contains(got, "kubeconfig-u-abc123:secret") {
+		t.Errorf("expected the impersonated user's token, got:\n%s", got)
+	}
+}
+
+func TestClient_GetAllKubeconfigs_AsUserNotHonored(t *testing.T) {
+	server := impersonatingServer(t, false)
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+		AsUser:     "u-abc123",
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	_, err := client.GetAllKubeconfigs()
+	if err == nil || !strings.Contains(err.Error(), "did not impersonate") {
+		t.Fatalf("GetAllKubeconfigs() error = %v, want an impersonation error", err)
+	}
+}
+
+func TestCheckClusterSchema(t *testing.T) {
+	body := []byte(`{"data":[
+		{"id":"c-1","name":"ok","state":"active","actions":{"generateKubeconfig":"https://x"}},
+		{"id":"c-2","name":"restricted","state":"active","actions":{}},
+		{"id":"c-3","name":"renamed","state":"active","actions":{"generateKubeconfigV2":"https://x"}}
+	]}`)
+
+	drift, err := CheckClusterSchema(body)
+	if err != nil {
+		t.Fatalf("CheckClusterSchema() error = %v", err)
+	}
+
+	missing := drift.Missing["actions.generateKubeconfig"]
+	if len(missing) != 2 || missing[0] != "restricted" || missing[1] != "renamed" {
+		t.Errorf("missing generateKubeconfig = %v, want [restricted renamed]", missing)
+	}
+	if _, ok := drift.Missing["state"]; ok {
+		t.Error("state should not be reported missing")
+	}
+	if len(drift.Unexpected) != 1 || drift.Unexpected[0] != "generateKubeconfigV2" {
+		t.Errorf("Unexpected = %v, want [generateKubeconfigV2]", drift.Unexpected)
+	}
+	if len(drift.Warnings()) != 2 {
+		t.Errorf("expected 2 warnings, got %v", drift.Warnings())
+	}
+}
+
+func TestSchemaDrift_Empty(t *testing.T) {
+	var nilDrift *SchemaDrift
+	if !nilDrift.Empty() || nilDrift.Warnings() != nil {
+		t.Error("nil drift should be empty")
+	}
+
+	drift, err := CheckClusterSchema([]byte(`{"data":[{"id":"c-1","name":"a","state":"active","actions":{"generateKubeconfig":"u"}}]}`))
+	if err != nil {
+		t.Fatalf("CheckClusterSchema() error = %v", err)
+	}
+	if !drift.Empty() {
+		t.Errorf("expected no drift, got %+v", drift)
+	}
+}
+
+func TestClient_GetAllKubeconfigs_FallbackWithoutAction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/clusters" && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-12345", Name: "restricted", State: "active"}}})
+		case r.URL.Path == "/v3/clusters/c-12345":
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/v3/settings/cacerts":
+			_, _ = w.Write([]byte(`{"value":""}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	got, ok := kubeconfigs["restricted"]
+	if !ok {
+		t.Fatal("expected a fallback kubeconfig for the restricted cluster")
+	}
+	if !strings.Contains(got, server.URL+"/k8s/clusters/c-12345") {
+		t.Errorf("expected a proxy-routed server, got:\n%s", got)
+	}
+	if !strings.Contains(got, "token-xxxxx:secret456") {
+		t.Errorf("expected the client's own token, got:\n%s", got)
+	}
+}
+
+func TestClient_GetAllKubeconfigs_ExecCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/clusters" && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-12345", Name: "prod", State: "active"}}})
+		case r.URL.Path == "/v3/settings/cacerts":
+			_, _ = w.Write([]byte(`{"value":""}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:      server.URL,
+		AccessKey:       "token-xxxxx",
+		SecretKey:       "secret456",
+		AuthMethod:      config.AuthMethodToken,
+		ExecCredentials: true,
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if !strings.Contains(kubeconfigs["prod"], server.URL+"/k8s/clusters/c-12345") {
+		t.Errorf("expected a proxy kubeconfig built without generateKubeconfig, got:\n%s", kubeconfigs["prod"])
+	}
+}
+
+func TestCredentialCache(t *testing.T) {
+	cache := NewCredentialCache(filepath.Join(t.TempDir(), "tokens"))
+	if _, ok := cache.Get("https://rancher.example.com", "c-1"); ok {
+		t.Fatal("expected an empty cache")
+	}
+
+	if err := cache.Put(CachedCredential{Token: "kubeconfig-u-1:abc", ExpiresAt: time.Now().Add(time.Hour)}, "https://rancher.example.com", "c-1"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	cred, ok := cache.Get("https://rancher.example.com", "c-1")
+	if !ok || cred.Token != "kubeconfig-u-1:abc" {
+		t.Errorf("Get() = %+v, %v", cred, ok)
+	}
+	if _, ok := cache.Get("https://rancher.example.com", "c-2"); ok {
+		t.Error("expected no credential for another cluster")
+	}
+
+	if err := cache.Put(CachedCredential{Token: "kubeconfig-u-1:old", ExpiresAt: time.Now().Add(time.Minute)}, "https://rancher.example.com", "c-1"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := cache.Get("https://rancher.example.com", "c-1"); ok {
+		t.Error("expected a credential about to expire to be renewed")
+	}
+}
+
+func TestClient_GetAllKubeconfigs_ClusterStates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "active-cluster", State: "active"},
+				{ID: "c-2", Name: "updating-cluster", State: "updating"},
+				{ID: "c-3", Name: "provisioning-cluster", State: "provisioning"},
+			}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{"configured states", config.Config{ClusterStates: []string{"active", "updating"}}, []string{"active-cluster", "updating-cluster"}},
+		{"all states", config.Config{IncludeAllStates: true}, []string{"active-cluster", "updating-cluster", "provisioning-cluster"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.RancherURL = server.URL
+			cfg.AccessKey = "access123"
+			cfg.SecretKey = "secret456"
+			client := &Client{config: &cfg, httpClient: server.Client()}
+
+			kubeconfigs, err := client.GetAllKubeconfigs()
+			if err != nil {
+				t.Fatalf("GetAllKubeconfigs() error = %v", err)
+			}
+			if len(kubeconfigs) != len(tt.want) {
+				t.Errorf("got %d kubeconfigs, want %d", len(kubeconfigs), len(tt.want))
+			}
+			for _, name := range tt.want {
+				if _, ok := kubeconfigs[name]; !ok {
+					t.Errorf("expected kubeconfig for %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_GetAllKubeconfigs_Harvester(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "workload", State: "active", Provider: "rke2"},
+				{ID: "c-2", Name: "hci", State: "active", Provider: "harvester"},
+				{ID: "c-3", Name: "hci-labelled", State: "active", Provider: "imported", Labels: map[string]string{"provider.cattle.io": "harvester"}},
+			}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		mode config.HarvesterMode
+		want []string
+	}{
+		{"", []string{"workload", "hci", "hci-labelled"}},
+		{config.HarvesterExclude, []string{"workload"}},
+		{config.HarvesterOnly, []string{"hci", "hci-labelled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			cfg := &config.Config{RancherURL: server.URL, AccessKey: "a", SecretKey: "b", Harvester: tt.mode}
+			client := &Client{config: cfg, httpClient: server.Client()}
+
+			kubeconfigs, err := client.GetAllKubeconfigs()
+			if err != nil {
+				t.Fatalf("GetAllKubeconfigs() error = %v", err)
+			}
+			if len(kubeconfigs) != len(tt.want) {
+				t.Errorf("got %d kubeconfigs, want %v", len(kubeconfigs), tt.want)
+			}
+			for _, name := range tt.want {
+				if _, ok := kubeconfigs[name]; !ok {
+					t.Errorf("expected kubeconfig for %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCollection(t *testing.T) {
+	body := `{"type":"collection","links":{"self":"x"},"data":[{"id":"c-1"},{"id":"c-2"}],"pagination":{"limit":1000}}`
+
+	var ids []string
+	err := decodeCollection(strings.NewReader(body), func(item json.RawMessage) error {
+		var cluster Cluster
+		if err := json.Unmarshal(item, &cluster); err != nil {
+			return err
+		}
+		ids = append(ids, cluster.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeCollection() error = %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "c-1" || ids[1] != "c-2" {
+		t.Errorf("decoded ids = %v, want [c-1 c-2]", ids)
+	}
+
+	err = decodeCollection(strings.NewReader("<html><body>Bad Gateway</body></html>"), func(json.RawMessage) error { return nil })
+	if err == nil {
+		t.Error("expected an error for an HTML body")
+	}
+}
+
+func TestClient_ListClusters_MaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		clusters := make([]Cluster, 100)
+		for i := range clusters {
+			clusters[i] = Cluster{ID: fmt.Sprintf("c-%d", i), Name: fmt.Sprintf("cluster-%d", i), State: "active"}
+		}
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: clusters})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:      server.URL,
+		AccessKey:       "access123",
+		SecretKey:       "secret456",
+		MaxResponseSize: 1024,
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	_, err := client.ListClusters()
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("ListClusters() error = %v, want ErrResponseTooLarge", err)
+	}
+
+	cfg.MaxResponseSize = 0
+	clusters, err := client.ListClusters()
+	if err != nil {
+		t.Fatalf("ListClusters() with default limit error = %v", err)
+	}
+	if len(clusters) != 100 {
+		t.Errorf("got %d clusters, want 100", len(clusters))
+	}
+}
+
+func TestNewAPIError_Details(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+		notWant     string
+	}{
+		{
+			name:        "rancher JSON error",
+			contentType: "application/json",
+			body:        `{"type":"error","status":"403","code":"Forbidden","message":"clusters.management.cattle.io is forbidden"}`,
+			want:        []string{"Forbidden: clusters.management.cattle.io is forbidden"},
+		},
+		{
+			name:        "HTML error page",
+			contentType: "text/html; charset=utf-8",
+			body:        "<html>\n<head><title>502 Bad Gateway</title></head>\n<body><center><h1>502 Bad Gateway</h1></center><hr><center>nginx</center></body>\n</html>",
+			want:        []string{"text/html", `"502 Bad Gateway"`, "proxy or load balancer"},
+			notWant:     "<center>",
+		},
+		{
+			name:        "plain text",
+			contentType: "text/plain",
+			body:        "\nupstream connect error or disconnect/reset before headers\nmore details\n",
+			want:        []string{`"upstream connect error or disconnect/reset before headers"`},
+			notWant:     "more details",
+		},
+		{
+			name: "empty",
+			want: []string{"(empty)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header: http.Header{},
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			got := NewAPIError("list clusters", resp).Details()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Details() = %q, want it to contain %q", got, want)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(got, tt.notWant) {
+				t.Errorf("Details() = %q, should not contain %q", got, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestClient_ListClusters_HTMLResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Sign in - Corporate SSO</title></head><body>...</body></html>"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	_, err := client.ListClusters()
+	if err == nil {
+		t.Fatal("expected an error for an HTML response")
+	}
+	if !strings.Contains(err.Error(), "Sign in - Corporate SSO") || strings.Contains(err.Error(), "<body>") {
+		t.Errorf("expected a concise diagnostic, got %q", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-1", Name: "one", State: "active"}}})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	clusters, err := client.ListClusters()
+	if err != nil {
+		t.Fatalf("ListClusters() error = %v", err)
+	}
+	if len(clusters) != 1 || attempts != 3 {
+		t.Errorf("got %d clusters after %d attempts, want 1 after 3", len(clusters), attempts)
+	}
+}
+
+func TestClient_RetryAfter_ExceedsBudget(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456", RetryMaxWait: time.Second}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	start := time.Now()
+	_, err := client.ListClusters()
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("ListClusters() error = %v, want a 503 error", err)
+	}
+	if attempts != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("expected an immediate failure, got %d attempts in %s", attempts, time.Since(start))
+	}
+}
+
+func TestClient_RetryAfter_NotIdempotent(t *testing.T) {
+	var attempts int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+	cluster := &Cluster{ID: "c-1", Name: "one"}
+
+	// A 503 may come after Rancher minted the token, so the POST is not resent
+	if _, err := client.GetClusterKubeconfig(cluster); err == nil || attempts != 1 {
+		t.Errorf("GetClusterKubeconfig() error = %v after %d attempts, want a 503 error after 1", err, attempts)
+	}
+
+	attempts, status = 0, http.StatusTooManyRequests
+	if _, err := client.GetClusterKubeconfig(cluster); err != nil || attempts != 2 {
+		t.Errorf("GetClusterKubeconfig() error = %v after %d attempts, want success after 2", err, attempts)
+	}
+}
+
+func TestClient_RetryAfter_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/v3/clusters", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := client.sendWithRetry(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sendWithRetry() error = %v, want the context's error", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("sendWithRetry() kept waiting %s after the context was done", time.Since(start))
+	}
+}
+
+func TestClient_ListClusters_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"type":"error","status":403,"code":"Forbidden","message":"clusters is forbidden","fieldName":"id"}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	_, err := client.ListClusters()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ListClusters() error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Code != "Forbidden" || apiErr.Message != "clusters is forbidden" || apiErr.FieldName != "id" {
+		t.Errorf("unexpected APIError %+v", apiErr)
+	}
+	if !IsForbidden(err) || IsUnauthorized(err) || IsNotFound(err) {
+		t.Error("expected IsForbidden only")
+	}
+	if got, want := err.Error(), "failed to list clusters: status 403: Forbidden: clusters is forbidden (field id)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if apiErr.Hint() == "" {
+		t.Error("expected a hint for a 403")
+	}
+}
+
+func TestDebugTransport_RedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "R_SESS", Value: "session-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ClusterCollection{})
+	}))
+	defer server.Close()
+
+	var log strings.Builder
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{
+		config:     cfg,
+		httpClient: &http.Client{Transport: &debugTransport{next: http.DefaultTransport, out: &log}},
+	}
+
+	if _, err := client.doRequest("GET", server.URL+"/v3/clusters?token=abc&limit=5", nil); err != nil {
+		t.Fatalf("doRequest() error = %v", err)
+	}
+
+	got := log.String()
+	for _, want := range []string{"--> GET " + server.URL + "/v3/clusters", "<-- HTTP/1.1 200 OK", "Authorization: [REDACTED]", "Set-Cookie: [REDACTED]", "token=REDACTED", "limit=5"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("debug log missing %q:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"secret456", "session-secret", "abc"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("debug log leaks %q:\n%s", secret, got)
+		}
+	}
+}
+
+func TestClient_GetRegistrationToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/clusters":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "prod"},
+				{ID: "c-2", Name: "dup"},
+				{ID: "c-3", Name: "dup"},
+			}})
+		case r.URL.Path == "/v3/clusterregistrationtokens" && r.Method == "GET":
+			if r.URL.Query().Get("clusterId") != "c-1" {
+				_ = json.NewEncoder(w).Encode(registrationTokenCollection{})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(registrationTokenCollection{Data: []RegistrationToken{
+				{ID: "c-1:old", Created: "2025-01-01T00:00:00Z", Command: "kubectl apply -f old.yaml"},
+				{ID: "c-1:new", Created: "2025-06-01T00:00:00Z", Command: "kubectl apply -f new.yaml"},
+				{ID: "c-1:pending", Created: "2025-07-01T00:00:00Z"},
+			}})
+		case r.URL.Path == "/v3/clusterregistrationtokens" && r.Method == "POST":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"c-2:created","clusterId":"c-2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "a", SecretKey: "b", AuthMethod: config.AuthMethodToken}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	cluster, err := client.FindCluster("prod")
+	if err != nil || cluster.ID != "c-1" {
+		t.Fatalf("FindCluster(prod) = %v, %v", cluster, err)
+	}
+	if _, err := client.FindCluster("dup"); err == nil || !strings.Contains(err.Error(), "c-2, c-3") {
+		t.Errorf("FindCluster(dup) error = %v, want ambiguity error", err)
+	}
+	if cluster, err := client.FindCluster("c-3"); err != nil || cluster.ID != "c-3" {
+		t.Errorf("FindCluster(c-3) = %v, %v", cluster, err)
+	}
+
+	token, err := client.GetRegistrationToken("c-1", false)
+	if err != nil || token.ID != "c-1:new" {
+		t.Fatalf("GetRegistrationToken(c-1) = %+v, %v, want the newest token with a command", token, err)
+	}
+
+	if _, err := client.GetRegistrationToken("c-2", false); err == nil {
+		t.Error("expected an error when the cluster has no token and create is false")
+	}
+	token, err = client.GetRegistrationToken("c-2", true)
+	if err != nil || token.ID != "c-2:created" {
+		t.Errorf("GetRegistrationToken(c-2, create) = %+v, %v", token, err)
+	}
+}
+
+func TestPlan_GetAllKubeconfigs(t *testing.T) {
+	cfg := &config.Config{
+		RancherURL:   "https://rancher.example.com",
+		AuthMethod:   config.AuthMethodPassword,
+		AuthProvider: "local",
+		ScopedTokens: true,
+		AsUser:       "u-abc",
+	}
+	plan := NewPlan("generate", cfg)
+	plan.GetAllKubeconfigs()
+
+	var paths []string
+	for _, call := range plan.Calls {
+		paths = append(paths, call.Method+" "+call.Path)
+	}
+	want := []string{
+		"POST /v3-public/localProviders/local?action=login",
+		"GET /v3/users/u-abc",
+		"GET /v3/users?me=true",
+		"GET /v3/clusters",
+		"POST /v3/clusters/<id>?action=generateKubeconfig",
+		"GET /v3/settings/cacerts",
+		"GET /v3/tokens/<name>",
+		"POST /v3/tokens",
+		"DELETE /v3/tokens/<name>",
+		"GET /v3/tokens/<name>",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls = %v, want %v", paths, want)
+	}
+	if created := plan.Creates(); len(created) != 3 {
+		t.Errorf("Creates() = %v, want the session, kubeconfig and scoped tokens", created)
+	}
+
+	var out strings.Builder
+	if err := plan.Write(&out); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, s := range []string{"generateKubeconfig", "a cluster-scoped API token", "Nothing was sent to Rancher."} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Write() output is missing %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestPlan_ReadOnly(t *testing.T) {
+	plan := NewPlan("list", &config.Config{RancherURL: "https://rancher.example.com", AuthMethod: config.AuthMethodToken})
+	plan.ListClusters()
+
+	if len(plan.Calls) != 1 || plan.Creates() != nil {
+		t.Fatalf("plan = %+v, want a single read-only call", plan.Calls)
+	}
+	var out strings.Builder
+	plan.Write(&out)
+	if !strings.Contains(out.String(), "read-only") {
+		t.Errorf("Write() output does not say the plan is read-only:\n%s", out.String())
+	}
+}
+
+func TestClient_FetchKubeconfigs_CircuitBreaker(t *testing.T) {
+	var generateCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "one", State: "active"},
+				{ID: "c-2", Name: "two", State: "active"},
+				{ID: "c-3", Name: "three", State: "active"},
+				{ID: "c-4", Name: "four", State: "active"},
+			}})
+			return
+		}
+		generateCalls++
+		if r.URL.Path == "/v3/clusters/c-1" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: "apiVersion: v1\nkind: Config\n"})
+			return
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client(), breaker: newBreaker(2)}
+
+	result, err := client.FetchKubeconfigs()
+	if err != nil {
+		t.Fatalf("FetchKubeconfigs() error = %v, want the partial result", err)
+	}
+	if _, ok := result.Kubeconfigs["one"]; !ok || len(result.Kubeconfigs) != 1 {
+		t.Errorf("Kubeconfigs = %v, want the cluster fetched before the circuit opened", result.Kubeconfigs)
+	}
+	if len(result.Failures) != 3 {
+		t.Fatalf("got %d failures (%+v), want one per remaining cluster", len(result.Failures), result.Failures)
+	}
+	last := result.Failures[2]
+	if last.Name != "four" || !errors.Is(last.Err, ErrCircuitOpen) {
+		t.Errorf("failure of %s = %v, want ErrCircuitOpen", last.Name, last.Err)
+	}
+	if !strings.Contains(last.Err.Error(), "2 consecutive failures") || !strings.Contains(last.Err.Error(), "504") {
+		t.Errorf("error %q does not describe the failures", last.Err)
+	}
+	if generateCalls != 3 {
+		t.Errorf("Rancher received %d generateKubeconfig calls, want 3 before the circuit opened", generateCalls)
+	}
+
+	// A success after the cooldown closes the circuit again
+	client.breaker.openUntil = time.Now()
+	client.breaker.record(&http.Response{StatusCode: http.StatusOK}, nil)
+	if err := client.breaker.allow(); err != nil {
+		t.Errorf("allow() after a success = %v, want nil", err)
+	}
+}
+
+func TestNewBreaker(t *testing.T) {
+	if b := newBreaker(-1); b != nil || b.allow() != nil {
+		t.Error("a negative threshold must disable the breaker")
+	}
+	if b := newBreaker(0); b.threshold != DefaultBreakerThreshold {
+		t.Errorf("threshold = %d, want the default %d", b.threshold, DefaultBreakerThreshold)
+	}
+}
+
+func TestNewClient_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{}})
+	}))
+	defer server.Close()
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "server.pem"), []byte(serverCA), 0600)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0600)
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{"directory", config.Config{CACert: dir}},
+		{"inline", config.Config{CACertData: serverCA}},
+		{"inline without system CAs", config.Config{CACertData: serverCA, ExcludeSystemCAs: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.RancherURL = server.URL
+			cfg.AccessKey, cfg.SecretKey = "access123", "secret456"
+			client, err := NewClient(&cfg)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.ListClusters(); err != nil {
+				t.Errorf("ListClusters() error = %v, want the custom CA to be trusted", err)
+			}
+		})
+	}
+}
+
+func TestReadCABundle_Errors(t *testing.T) {
+	if _, err := ReadCABundle(t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "no .pem") {
+		t.Errorf("ReadCABundle(empty dir) error = %v, want a missing files error", err)
+	}
+	if _, err := CertPool([]byte("garbage"), true); err == nil {
+		t.Error("CertPool(garbage) returned no error")
+	}
+}
+
+func TestClient_GetAllKubeconfigs_AgeFilters(t *testing.T) {
+	now := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-ci", Name: "ci", State: "active", Created: now.Add(-10 * time.Minute).Format(time.RFC3339)},
+				{ID: "c-prod", Name: "prod", State: "active", CreatedTS: now.Add(-90 * 24 * time.Hour).UnixMilli()},
+				{ID: "c-unknown", Name: "unknown", State: "active"},
+			}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456", OlderThan: time.Hour}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if _, ok := kubeconfigs["ci"]; ok || len(kubeconfigs) != 2 {
+		t.Errorf("got kubeconfigs for %v, want prod and unknown only", mapKeys(kubeconfigs))
+	}
+
+	cfg.OlderThan, cfg.NewerThan = 0, time.Hour
+	kubeconfigs, err = client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if _, ok := kubeconfigs["prod"]; ok || len(kubeconfigs) != 2 {
+		t.Errorf("got kubeconfigs for %v, want ci and unknown only", mapKeys(kubeconfigs))
+	}
+}
+
+func TestClient_GetAllKubeconfigs_Ephemeral(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-ci", Name: "ci-4711", State: "active"},
+				{ID: "c-review", Name: "review", State: "active", Labels: map[string]string{"lifecycle": "ephemeral"}},
+				{ID: "c-prod", Name: "prod", State: "active"},
+			}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:           server.URL,
+		AccessKey:            "access123",
+		SecretKey:            "secret456",
+		EphemeralLabel:       "lifecycle=ephemeral",
+		EphemeralNamePattern: "^ci-",
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if got := mapKeys(kubeconfigs); len(got) != 1 || got[0] != "prod" {
+		t.Errorf("got kubeconfigs for %v, want prod only", got)
+	}
+
+	cfg.IncludeEphemeral = true
+	kubeconfigs, err = client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if len(kubeconfigs) != 3 {
+		t.Errorf("got kubeconfigs for %v, want all three clusters", mapKeys(kubeconfigs))
+	}
+}
+
+func TestClient_GetAllKubeconfigs_NameFilters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "prod-eu", State: "active"},
+				{ID: "c-2", Name: "prod-sandbox", State: "active"},
+				{ID: "c-3", Name: "staging", State: "active"},
+			}})
+			return
+		}
+		if r.URL.Path != "/v3/clusters/c-1" {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:      server.URL,
+		AccessKey:       "access123",
+		SecretKey:       "secret456",
+		IncludeClusters: []string{"prod-*"},
+		ExcludeClusters: []string{"*-sandbox"},
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	kubeconfigs, err := client.GetAllKubeconfigs()
+	if err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if got := mapKeys(kubeconfigs); len(got) != 1 || got[0] != "prod-eu" {
+		t.Errorf("got kubeconfigs for %v, want prod-eu only", got)
+	}
+}
+
+func TestClient_FetchKubeconfigs_SelectedClusters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "prod-a", State: "active"},
+				{ID: "c-2", Name: "prod-b", State: "active"},
+				{ID: "c-3", Name: "staging", State: "provisioning"},
+			}})
+			return
+		}
+		if r.URL.Path == "/v3/clusters/c-3" {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "access123",
+		SecretKey:  "secret456",
+		Clusters:   []string{"prod-a", "c-2", "prod-c"},
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+	var reported []events.Event
+	client.SetReporter(events.ReporterFunc(func(event events.Event) {
+		reported = append(reported, event)
+	}))
+
+	result, err := client.FetchKubeconfigs()
+	if err != nil {
+		t.Fatalf("FetchKubeconfigs() error = %v", err)
+	}
+	if got := mapKeys(result.Kubeconfigs); len(got) != 2 || got[0] != "prod-a" || got[1] != "prod-b" {
+		t.Errorf("got kubeconfigs for %v, want prod-a and prod-b", got)
+	}
+	// The unselected staging cluster is not warned about for its state
+	if len(reported) != 1 || reported[0].Cluster != "prod-c" {
+		t.Errorf("reported %+v, want a single warning about prod-c", reported)
+	}
+}
+
+func TestSelectClusters(t *testing.T) {
+	clusters := []Cluster{
+		{Name: "prod-app", Labels: map[string]string{"env": "prod", "team": "app"}},
+		{Name: "prod-infra", Labels: map[string]string{"env": "prod", "team": "infra"}},
+		{Name: "prod-bare", Labels: map[string]string{"env": "prod"}},
+		{Name: "dev", Labels: map[string]string{"env": "dev"}},
+	}
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"", "prod-app,prod-infra,prod-bare,dev"},
+		{"env=prod,team!=infra", "prod-app,prod-bare"},
+		{"env in (dev,staging)", "dev"},
+		{"team", "prod-app,prod-infra"},
+		{"!team", "prod-bare,dev"},
+	}
+	for _, tt := range tests {
+		selector, err := ParseClusterSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseClusterSelector(%q) error = %v", tt.selector, err)
+		}
+		var names []string
+		for _, cluster := range SelectClusters(clusters, selector) {
+			names = append(names, cluster.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("SelectClusters(%q) = %s, want %s", tt.selector, got, tt.want)
+		}
+	}
+
+	if _, err := ParseClusterSelector("env=(prod"); err == nil {
+		t.Error("expected an invalid selector to be rejected")
+	}
+}
+
+func TestClient_SetReporter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v3/clusters" && r.Method == "GET" {
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "prod", State: "active"},
+				{ID: "c-2", Name: "new", State: "provisioning"},
+				{ID: "c-3", Name: "broken", State: "active"},
+			}})
+			return
+		}
+		if r.URL.Path == "/v3/clusters/c-3" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+	var reported []events.Event
+	client.SetReporter(events.ReporterFunc(func(event events.Event) {
+		reported = append(reported, event)
+	}))
+
+	if _, err := client.GetAllKubeconfigs(); err != nil {
+		t.Fatalf("GetAllKubeconfigs() error = %v", err)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("reported %d events, want 2: %+v", len(reported), reported)
+	}
+	for i, cluster := range []string{"new", "broken"} {
+		if reported[i].Kind != events.Warning || reported[i].Cluster != cluster {
+			t.Errorf("event %d = %+v, want a warning about %s", i, reported[i], cluster)
+		}
+	}
+	if reported[1].Err == nil {
+		t.Error("failure was reported without its error")
+	}
+}
+
+func TestClient_ListTokens_Pagination(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/v3/tokens" {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+		var page tokenCollection
+		if r.URL.Query().Get("marker") == "" {
+			page.Data = []Token{{Name: "token-1"}, {Name: "token-2"}}
+			page.Pagination.Next = server.URL + "/v3/tokens?marker=token-2"
+		} else {
+			page.Data = []Token{{Name: "token-3", Labels: map[string]string{"created-by": "rkp"}}}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	tokens, err := client.ListTokens()
+	if err != nil {
+		t.Fatalf("ListTokens() error = %v", err)
+	}
+	if len(tokens) != 3 || tokens[2].Name != "token-3" || tokens[2].Labels["created-by"] != "rkp" {
+		t.Errorf("ListTokens() = %+v, want the tokens of both pages", tokens)
+	}
+}
+
+func TestClient_DeleteToken(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("method = %s, want DELETE", r.Method)
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/v3/tokens/")
+		switch name {
+		case "gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			deleted = append(deleted, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	if err := client.DeleteToken("token-1"); err != nil {
+		t.Errorf("DeleteToken() error = %v", err)
+	}
+	if err := client.DeleteToken("gone"); err != nil {
+		t.Errorf("DeleteToken() of a missing token error = %v, want nil", err)
+	}
+	if err := client.DeleteToken("forbidden"); err == nil {
+		t.Error("expected an error when Rancher refuses")
+	}
+	if len(deleted) != 1 || deleted[0] != "token-1" {
+		t.Errorf("deleted %v, want token-1", deleted)
+	}
+}
+
+func TestTokenSelector_Matches(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := Token{Name: "old", UserID: "u-1", Created: "2026-04-01T00:00:00Z", Labels: map[string]string{"created-by": "rkp"}}
+	recent := Token{Name: "recent", UserID: "u-1", Created: "2026-05-30T00:00:00Z", Labels: map[string]string{"created-by": "rkp"}}
+	unlabeled := Token{Name: "unlabeled", UserID: "u-2", CreatedTS: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), Expired: true}
+	undated := Token{Name: "undated", UserID: "u-1"}
+
+	tests := []struct {
+		name     string
+		selector TokenSelector
+		want     []string
+	}{
+		{"age", TokenSelector{OlderThan: 30 * 24 * time.Hour}, []string{"old", "unlabeled"}},
+		{"age and label", TokenSelector{OlderThan: 30 * 24 * time.Hour, Labels: map[string]string{"created-by": "rkp"}}, []string{"old"}},
+		{"label value", TokenSelector{Labels: map[string]string{"created-by": "ci"}}, nil},
+		{"user", TokenSelector{UserID: "u-1"}, []string{"old", "recent", "undated"}},
+		{"expired", TokenSelector{ExpiredOnly: true}, []string{"unlabeled"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, token := range []Token{old, recent, unlabeled, undated} {
+				if tt.selector.Matches(&token, now) {
+					got = append(got, token.Name)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mapKeys returns the keys of m in order
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestClient_FetchKubeconfigs_Failures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/clusters" && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-ok", Name: "ok", State: "active"},
+				{ID: "c-broken", Name: "broken", State: "active"},
+			}})
+		case r.URL.Path == "/v3/clusters/c-broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"type":"error","status":500,"code":"ServerError","message":"agent disconnected"}`))
+		default:
+			_ = json.NewEncoder(w).Encode(KubeconfigResponse{Config: testKubeconfig})
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	result, err := client.FetchKubeconfigs()
+	if err != nil {
+		t.Fatalf("FetchKubeconfigs() error = %v", err)
+	}
+	if _, ok := result.Kubeconfigs["ok"]; !ok || len(result.Kubeconfigs) != 1 {
+		t.Errorf("Kubeconfigs = %v, want ok only", mapKeys(result.Kubeconfigs))
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Name != "broken" || result.Failures[0].ID != "c-broken" {
+		t.Fatalf("Failures = %+v, want broken", result.Failures)
+	}
+	if !strings.Contains(result.Err().Error(), "agent disconnected") {
+		t.Errorf("Err() = %v, want the Rancher message", result.Err())
+	}
+	var apiErr *APIError
+	if !errors.As(result.Err(), &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Err() does not wrap the *APIError: %v", result.Err())
+	}
+
+	if (&KubeconfigResult{}).Err() != nil {
+		t.Error("Err() of a result without failures should be nil")
+	}
+}
+
+func TestClient_SendsClientHeaders(t *testing.T) {
+	var userAgent, correlationID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		correlationID = r.Header.Get("X-Correlation-ID")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{}})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+	if _, err := client.ListClusters(); err != nil {
+		t.Fatalf("ListClusters() error = %v", err)
+	}
+
+	if !strings.HasPrefix(userAgent, "kubeconfig-wrangler/") {
+		t.Errorf("User-Agent = %q, want kubeconfig-wrangler/<version>", userAgent)
+	}
+	if correlationID == "" || correlationID != CorrelationID {
+		t.Errorf("X-Correlation-ID = %q, want the run's ID %q", correlationID, CorrelationID)
+	}
+}
+
+func TestClient_Raw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Error("expected an authenticated request")
+		}
+		if r.URL.Path != "/v3/clusters" || r.URL.Query().Get("limit") != "10" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"type":"error","status":404}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456"}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	resp, err := client.Raw("get", "v3/clusters?limit=10", nil)
+	if err != nil {
+		t.Fatalf("Raw() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != `{"data":[]}` {
+		t.Errorf("Raw() = %d %q, want 200 with the body as received", resp.StatusCode, resp.Body)
+	}
+
+	resp, err = client.Raw("GET", "/v3/missing", nil)
+	if err != nil {
+		t.Fatalf("Raw() error = %v, want the error status returned as a response", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Raw() status = %d, want 404", resp.StatusCode)
+	}
+
+	if _, err := client.Raw("GET", "https://elsewhere.example.com/v3", nil); err == nil {
+		t.Error("expected an absolute URL to be rejected")
+	}
+}
+
+func TestClient_ListClusters_FieldMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{
+			"id": "c-1",
+			"name": "c-1",
+			"spec": {"displayName": "payments"},
+			"status": {"phase": "active"},
+			"actions": {"kubeconfig": "https://rancher.example.com/v3/clusters/c-1?action=kubeconfig"}
+		}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:            server.URL,
+		AccessKey:             "access123",
+		SecretKey:             "secret456",
+		StateField:            "status.phase",
+		NameField:             "spec.displayName",
+		KubeconfigActionField: "actions.kubeconfig",
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	clusters, err := client.ListClusters()
+	if err != nil {
+		t.Fatalf("ListClusters() error = %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("got %d clusters, want 1", len(clusters))
+	}
+	cluster := clusters[0]
+	if cluster.Name != "payments" || cluster.State != "active" {
+		t.Errorf("got name %q, state %q, want payments and active", cluster.Name, cluster.State)
+	}
+	if cluster.Actions.GenerateKubeconfig != "https://rancher.example.com/v3/clusters/c-1?action=kubeconfig" {
+		t.Errorf("GenerateKubeconfig = %q, want the mapped action", cluster.Actions.GenerateKubeconfig)
+	}
+	if drift := client.SchemaDrift(); !drift.Empty() {
+		t.Errorf("expected no schema drift with the mapped fields, got %v", drift.Warnings())
+	}
+}
+
+func TestClient_WaitForRancher(t *testing.T) {
+	defer func(initial, max time.Duration) { waitInitialDelay, waitMaxDelay = initial, max }(waitInitialDelay, waitMaxDelay)
+	waitInitialDelay, waitMaxDelay = 10*time.Millisecond, 20*time.Millisecond
+
+	var pings, lists int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ping":
+			pings++
+			if pings < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("pong"))
+		case "/v3/clusters":
+			lists++
+			if lists == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{{ID: "c-1", Name: "one", State: "active"}}})
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL:     server.URL,
+		AccessKey:      "access123",
+		SecretKey:      "secret456",
+		RetryMaxWait:   -1,
+		WaitForRancher: 5 * time.Second,
+	}
+	var progress int
+	reporter := events.ReporterFunc(func(event events.Event) {
+		if event.Kind == events.Progress {
+			progress++
+		}
+	})
+	client, err := NewClient(cfg, WithReporter(reporter))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if pings != 3 {
+		t.Errorf("pinged %d times before starting, want 3", pings)
+	}
+	if progress == 0 {
+		t.Error("expected the wait to be reported to the reporter given to NewClient")
+	}
+
+	clusters, err := client.ListClusters()
+	if err != nil {
+		t.Fatalf("ListClusters() error = %v", err)
+	}
+	if len(clusters) != 1 || lists != 2 {
+		t.Errorf("got %d clusters after %d list requests, want 1 after 2", len(clusters), lists)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	cfg.RancherURL, cfg.WaitForRancher = down.URL, 50*time.Millisecond
+	if _, err := NewClient(cfg); err == nil || !strings.Contains(err.Error(), "did not come back") {
+		t.Errorf("NewClient() error = %v, want a timeout waiting for Rancher", err)
+	}
+}
+
+func TestClient_WaitForRancher_Cancel(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	cfg := &config.Config{
+		RancherURL:     down.URL,
+		AccessKey:      "access123",
+		SecretKey:      "secret456",
+		WaitForRancher: time.Hour,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := NewClient(cfg, WithContext(ctx), WithReporter(events.ReporterFunc(func(events.Event) {})))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("NewClient() error = %v, want the wait cancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > waitInitialDelay {
+		t.Errorf("NewClient() returned after %s, want right after the cancellation", elapsed)
+	}
+}
+
+func TestClient_WaitForRancher_NotIdempotent(t *testing.T) {
+	var pings, generated int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			pings++
+			return
+		}
+		generated++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RancherURL: server.URL, AccessKey: "access123", SecretKey: "secret456", RetryMaxWait: -1}
+	client := &Client{config: cfg, httpClient: server.Client(), waitDeadline: time.Now().Add(5 * time.Second)}
+
+	// The 503 may come after Rancher minted the token, so the POST is not resent
+	if _, err := client.GetClusterKubeconfig(&Cluster{ID: "c-1", Name: "one"}); err == nil {
+		t.Fatal("GetClusterKubeconfig() succeeded, want a 503 error")
+	}
+	if generated != 1 || pings != 0 {
+		t.Errorf("sent %d generateKubeconfig requests and %d pings, want 1 and none", generated, pings)
+	}
 }
@@ -0,0 +1,79 @@
+package rancher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// credentialRenewBefore is how long before its expiry a cached token is
+// replaced, so that kubectl never starts a command with a token about to expire
+const credentialRenewBefore = 5 * time.Minute
+
+// CachedCredential is a cluster token handed out by the token command
+type CachedCredential struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CredentialCache keeps the tokens handed to kubectl by the exec credential
+// plugin on disk, one file per cluster and identity, so that kubectl, which
+// runs the plugin in every new process, does not mint a token per command
+type CredentialCache struct {
+	dir string
+}
+
+// NewCredentialCache returns a cache storing its files in dir
+func NewCredentialCache(dir string) *CredentialCache {
+	return &CredentialCache{dir: dir}
+}
+
+// path returns the file caching the credential identified by key. The key
+// parts are hashed, so the file name reveals neither server nor identity.
+func (c *CredentialCache) path(key []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// Get returns the credential cached under key, unless it is missing, unreadable
+// or expires within credentialRenewBefore
+func (c *CredentialCache) Get(key ...string) (*CachedCredential, bool) {
+	cred, ok := c.load(key)
+	if !ok || time.Until(cred.ExpiresAt) < credentialRenewBefore {
+		return nil, false
+	}
+	return cred, true
+}
+
+// load returns the credential cached under key, even if it has expired
+func (c *CredentialCache) load(key []string) (*CachedCredential, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var cred CachedCredential
+	if err := json.Unmarshal(data, &cred); err != nil || cred.Token == "" {
+		return nil, false
+	}
+	return &cred, true
+}
+
+// Put caches a credential under key, readable by the current user only
+func (c *CredentialCache) Put(cred CachedCredential, key ...string) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create credential cache: %w", err)
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return fmt.Errorf("failed to encode credential: %w", err)
+	}
+	if err := os.WriteFile(c.path(key), data, 0600); err != nil {
+		return fmt.Errorf("failed to write credential cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package rancher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedHeaders are never written to the debug log
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Auth-Header":   true,
+	"X-Api-Set-Cookie":    true,
+}
+
+// redactedQueryParams are masked in logged URLs
+var redactedQueryParams = []string{"token", "password", "secret"}
+
+// debugTransport logs every request and response made through it, with
+// credentials redacted
+type debugTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var timing requestTiming
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace()))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %s %s\n", req.Method, redactURL(req.URL))
+	if base, ok := t.next.(*http.Transport); ok && base.Proxy != nil {
+		if proxy, err := base.Proxy(req); err == nil && proxy != nil {
+			fmt.Fprintf(&b, "    via proxy %s\n", redactURL(proxy))
+		}
+	}
+	writeHeaders(&b, req.Header)
+	fmt.Fprint(t.out, b.String())
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	total := time.Since(start)
+
+	b.Reset()
+	if err != nil {
+		fmt.Fprintf(&b, "<-- %s %s failed after %s: %v\n", req.Method, redactURL(req.URL), total.Round(time.Millisecond), err)
+		fmt.Fprintf(&b, "    %s\n", timing.summary(start))
+		fmt.Fprint(t.out, b.String())
+		return nil, err
+	}
+
+	fmt.Fprintf(&b, "<-- %s %s %s (%s)\n", resp.Proto, resp.Status, redactURL(req.URL), total.Round(time.Millisecond))
+	fmt.Fprintf(&b, "    %s\n", timing.summary(start))
+	if resp.TLS != nil {
+		fmt.Fprintf(&b, "    %s\n", formatTLS(resp.TLS))
+	}
+	writeHeaders(&b, resp.Header)
+	fmt.Fprint(t.out, b.String())
+	return resp, nil
+}
+
+// requestTiming records the phases of a request
+type requestTiming struct {
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+	reused                    bool
+}
+
+func (t *requestTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		GotConn:              func(info httptrace.GotConnInfo) { t.reused = info.Reused },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+}
+
+// summary renders the recorded phases
+func (t *requestTiming) summary(start time.Time) string {
+	if t.reused {
+		return fmt.Sprintf("reused connection, ttfb=%s", phase(start, t.firstByte))
+	}
+	return fmt.Sprintf("dns=%s connect=%s tls=%s ttfb=%s",
+		phase(t.dnsStart, t.dnsDone), phase(t.connectStart, t.connectDone),
+		phase(t.tlsStart, t.tlsDone), phase(start, t.firstByte))
+}
+
+// phase formats the duration between two instants, or "-" if it did not happen
+func phase(from, to time.Time) string {
+	if from.IsZero() || to.IsZero() {
+		return "-"
+	}
+	return to.Sub(from).Round(time.Millisecond).String()
+}
+
+// formatTLS summarizes the negotiated TLS session and server certificate
+func formatTLS(state *tls.ConnectionState) string {
+	info := describeTLS(state, false)
+	desc := fmt.Sprintf("%s %s", info.Version, tls.CipherSuiteName(state.CipherSuite))
+	if info.Subject != "" {
+		desc += fmt.Sprintf(", certificate %q issued by %q, expires %s",
+			info.Subject, info.Issuer, info.NotAfter.Format("2006-01-02"))
+	}
+	return desc
+}
+
+// writeHeaders writes headers in a stable order, redacting credentials
+func writeHeaders(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(b, "    %s: %s\n", name, value)
+	}
+}
+
+// redactURL renders a URL without user info or secret query parameters
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("REDACTED")
+	}
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for _, param := range redactedQueryParams {
+			if query.Has(param) {
+				query.Set(param, "REDACTED")
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+// baseTransport returns the *http.Transport underneath any debug wrapper
+func baseTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	if debug, ok := rt.(*debugTransport); ok {
+		rt = debug.next
+	}
+	transport, ok := rt.(*http.Transport)
+	return transport, ok
+}
@@ -0,0 +1,101 @@
+package rancher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxResponseSize bounds Rancher API response bodies when no limit is configured
+const DefaultMaxResponseSize int64 = 64 << 20
+
+// ErrResponseTooLarge is returned when reading a response body beyond the maximum size
+var ErrResponseTooLarge = errors.New("response exceeds maximum size")
+
+// limitedBody fails reads once more than limit bytes have been consumed, unlike
+// io.LimitReader which silently truncates and lets a decoder report garbage
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Only fail if there actually is more data
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, b.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// maxResponseSize returns the configured response size limit or the default
+func (c *Client) maxResponseSize() int64 {
+	if c.config.MaxResponseSize > 0 {
+		return c.config.MaxResponseSize
+	}
+	return DefaultMaxResponseSize
+}
+
+// decodeCollection streams the "data" array of a Rancher collection, passing each
+// element to onItem without holding the whole collection in memory
+func decodeCollection(r io.Reader, onItem func(json.RawMessage) error) error {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "data" {
+			// Skip links, actions, pagination and other collection metadata
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			var item json.RawMessage
+			if err := dec.Decode(&item); err != nil {
+				return err
+			}
+			if err := onItem(item); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token and checks it is the given delimiter
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected JSON token %v, expected %v", tok, want)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package rancher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ErrGenerateKubeconfigUnavailable is returned by GetClusterKubeconfig when the
+// generateKubeconfig action is not available to the user, typically because
+// their role does not grant it
+var ErrGenerateKubeconfigUnavailable = errors.New("generateKubeconfig action unavailable")
+
+// kubeconfigActionUnavailable reports whether a status code from the
+// generateKubeconfig action means the action is not permitted or not present
+func kubeconfigActionUnavailable(status int) bool {
+	return status == http.StatusForbidden || status == http.StatusNotFound || status == http.StatusMethodNotAllowed
+}
+
+// BuildProxyKubeconfig constructs a kubeconfig for a cluster without calling the
+// generateKubeconfig action. The context is routed through the Rancher proxy
+// (/k8s/clusters/<id>) and authenticates with the client's own credentials, so it
+// works for users whose role can reach the cluster but not generate kubeconfigs.
+func (c *Client) BuildProxyKubeconfig(cluster *Cluster) (string, error) {
+	token := c.bearerToken
+	if token == "" {
+		token = c.config.AccessKey + ":" + c.config.SecretKey
+	}
+
+	apiCluster := &api.Cluster{
+		Server:                fmt.Sprintf("%s/k8s/clusters/%s", c.config.RancherURL, cluster.ID),
+		InsecureSkipTLSVerify: c.config.InsecureSkipTLSVerify,
+	}
+	if !c.config.InsecureSkipTLSVerify {
+		apiCluster.CertificateAuthorityData = c.rancherCAData()
+	}
+
+	config := api.NewConfig()
+	config.Clusters[cluster.Name] = apiCluster
+	config.AuthInfos[cluster.Name] = &api.AuthInfo{Token: token}
+	config.Contexts[cluster.Name] = &api.Context{Cluster: cluster.Name, AuthInfo: cluster.Name}
+	config.CurrentContext = cluster.Name
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize kubeconfig for cluster %s: %w", cluster.Name, err)
+	}
+	return string(data), nil
+}
+
+// rancherCAData returns the CA that signs the Rancher server certificate: the
+// configured CA bundle if any, otherwise the server's cacerts setting. It returns
+// nil when neither is available, leaving verification to the system roots.
+func (c *Client) rancherCAData() []byte {
+	if c.config.CACert != "" || c.config.CACertData != "" {
+		if data, err := ReadCABundle(c.config.CACert, c.config.CACertData); err == nil {
+			return data
+		}
+	}
+
+	resp, err := c.doRequest("GET", c.config.RancherURL+"/v3/settings/cacerts", nil)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var setting struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&setting); err != nil {
+		return nil
+	}
+	if value := strings.TrimSpace(setting.Value); value != "" {
+		return []byte(value + "\n")
+	}
+	return nil
+}
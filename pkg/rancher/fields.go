@@ -0,0 +1,52 @@
+package rancher
+
+import (
+	"encoding/json"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+// fieldPaths maps the standard cluster field paths to the paths configured for
+// Rancher derivatives that name them differently. Unmapped fields are absent.
+func fieldPaths(cfg *config.Config) map[string]string {
+	paths := make(map[string]string)
+	for field, path := range map[string]string{
+		"state":                      cfg.StateField,
+		"name":                       cfg.NameField,
+		"actions.generateKubeconfig": cfg.KubeconfigActionField,
+	} {
+		if path != "" && path != field {
+			paths[field] = path
+		}
+	}
+	return paths
+}
+
+// mapFields fills the cluster fields configured with a custom path from the
+// raw cluster object, leaving the standard values when the path is absent
+func mapFields(item json.RawMessage, paths map[string]string, cluster *Cluster) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(item, &object); err != nil {
+		return err
+	}
+
+	for field, path := range paths {
+		value, ok := fieldValue(object, path).(string)
+		if !ok || value == "" {
+			continue
+		}
+		switch field {
+		case "state":
+			cluster.State = value
+		case "name":
+			cluster.Name = value
+		case "actions.generateKubeconfig":
+			cluster.Actions.GenerateKubeconfig = value
+		}
+	}
+	return nil
+}
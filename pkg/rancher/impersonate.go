@@ -0,0 +1,83 @@
+package rancher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// impersonateUserHeader asks Rancher to process a request as another user. The
+// caller needs the impersonate permission for that user, which admins have.
+const impersonateUserHeader = "Impersonate-User"
+
+// GetUser retrieves a user by ID
+func (c *Client) GetUser(id string) (*User, error) {
+	endpoint := fmt.Sprintf("%s/v3/users/%s", c.config.RancherURL, url.PathEscape(id))
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("get user "+id, resp)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode user response: %w", err)
+	}
+	return &user, nil
+}
+
+// VerifyImpersonation checks that Rancher acts as the configured AsUser: the
+// user must exist and Rancher must report it as the current user. Servers that
+// ignore impersonation headers would otherwise hand out the caller's own tokens.
+func (c *Client) VerifyImpersonation() error {
+	if c.config.AsUser == "" {
+		return nil
+	}
+
+	user, err := c.GetUser(c.config.AsUser)
+	if err != nil {
+		return fmt.Errorf("failed to look up user to impersonate: %w", err)
+	}
+	current, err := c.GetCurrentUser()
+	if err != nil {
+		return fmt.Errorf("failed to verify impersonation of %s: %w", c.config.AsUser, err)
+	}
+	if current.ID != user.ID {
+		return fmt.Errorf("rancher did not impersonate %s (requests run as %s); impersonation needs a Rancher version that honors %s and the impersonate permission for that user",
+			user.ID, current.ID, impersonateUserHeader)
+	}
+	return nil
+}
+
+// checkImpersonatedKubeconfig makes sure every token in a kubeconfig belongs to
+// the impersonated user, so the caller's own credentials are never handed out
+func (c *Client) checkImpersonatedKubeconfig(cluster *Cluster, kubeconfig string) error {
+	config, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return fmt.Errorf("failed to parse kubeconfig for cluster %s: %w", cluster.Name, err)
+	}
+
+	for name, authInfo := range config.AuthInfos {
+		if authInfo.Token == "" {
+			return fmt.Errorf("kubeconfig for cluster %s has no token for user %s", cluster.Name, name)
+		}
+		tokenName, _, _ := strings.Cut(authInfo.Token, ":")
+		token, err := c.GetToken(tokenName)
+		if err != nil {
+			return fmt.Errorf("failed to check token owner for cluster %s: %w", cluster.Name, err)
+		}
+		if token.UserID != c.config.AsUser {
+			return fmt.Errorf("kubeconfig for cluster %s carries a token of %s, not of %s", cluster.Name, token.UserID, c.config.AsUser)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package rancher
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ParseClusterSelector parses a label selector in kubectl's syntax, e.g.
+// "env=prod,team!=infra", "tier in (web,api)" or "!ephemeral". An empty
+// selector selects every cluster.
+func ParseClusterSelector(selector string) (labels.Selector, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %w", selector, err)
+	}
+	return parsed, nil
+}
+
+// MatchesSelector reports whether the cluster's labels satisfy selector
+func (c *Cluster) MatchesSelector(selector labels.Selector) bool {
+	return selector.Matches(labels.Set(c.Labels))
+}
+
+// SelectClusters returns the clusters whose labels satisfy selector, in order
+func SelectClusters(clusters []Cluster, selector labels.Selector) []Cluster {
+	if selector.Empty() {
+		return clusters
+	}
+	var selected []Cluster
+	for i := range clusters {
+		if clusters[i].MatchesSelector(selector) {
+			selected = append(selected, clusters[i])
+		}
+	}
+	return selected
+}
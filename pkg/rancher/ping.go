@@ -0,0 +1,163 @@
+package rancher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PingResult summarizes connectivity and credential checks against a Rancher server
+type PingResult struct {
+	// URL is the Rancher server that was checked
+	URL string
+
+	// Reachable is true when the /v3 endpoint answered
+	Reachable bool
+
+	// StatusCode is the HTTP status returned by /v3
+	StatusCode int
+
+	// Latency is the round-trip time of the /v3 request
+	Latency time.Duration
+
+	// Timings breaks the latency of the /v3 request down into DNS, connect, TLS and first byte
+	Timings *Timings
+
+	// TLS describes the server certificate, nil for plain HTTP
+	TLS *TLSInfo
+
+	// Authenticated is true when the credentials were accepted
+	Authenticated bool
+
+	// User is the authenticated user, if it could be determined
+	User *User
+
+	// Token is the API token in use, if it could be looked up
+	Token *Token
+
+	// ServerVersion is the Rancher server version, if readable
+	ServerVersion string
+
+	// Errors lists the non-fatal problems encountered during the checks
+	Errors []string
+}
+
+// TLSInfo describes the TLS connection to the Rancher server
+type TLSInfo struct {
+	Version  string
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	Verified bool
+}
+
+// serverVersionSetting represents the server-version setting resource
+type serverVersionSetting struct {
+	Value string `json:"value"`
+}
+
+// Ping checks reachability, TLS, authentication, token scope and server version.
+// An error is returned only if the server could not be reached at all.
+func (c *Client) Ping() (*PingResult, error) {
+	result := &PingResult{URL: c.config.RancherURL}
+
+	req, err := c.newRequest("GET", c.config.RancherURL+"/v3", nil)
+	if err != nil {
+		return result, err
+	}
+	// Time a fresh connection rather than one left over from the login
+	c.httpClient.CloseIdleConnections()
+	req, result.Timings = traceTimings(req)
+
+	start := time.Now()
+	resp, err := c.do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) {
+			return result, fmt.Errorf("TLS verification failed (use --ca-cert or --insecure-skip-tls-verify): %w", err)
+		}
+		return result, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	result.Reachable = true
+	result.StatusCode = resp.StatusCode
+	if resp.TLS != nil {
+		result.TLS = describeTLS(resp.TLS, !c.config.InsecureSkipTLSVerify)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Authenticated = true
+	case http.StatusUnauthorized, http.StatusForbidden:
+		result.Errors = append(result.Errors, fmt.Sprintf("credentials rejected (status %d)", resp.StatusCode))
+		return result, nil
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("unexpected status %d from /v3", resp.StatusCode))
+	}
+
+	if user, err := c.GetCurrentUser(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.User = user
+	}
+
+	if name := c.TokenName(); name != "" {
+		if tok, err := c.GetToken(name); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		} else {
+			result.Token = tok
+		}
+	}
+
+	if version, err := c.GetServerVersion(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.ServerVersion = version
+	}
+
+	return result, nil
+}
+
+// GetServerVersion returns the Rancher server version from the server-version setting
+func (c *Client) GetServerVersion() (string, error) {
+	resp, err := c.doRequest("GET", c.config.RancherURL+"/v3/settings/server-version", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewAPIError("get server version", resp)
+	}
+
+	var setting serverVersionSetting
+	if err := json.NewDecoder(resp.Body).Decode(&setting); err != nil {
+		return "", fmt.Errorf("failed to decode server version: %w", err)
+	}
+	return setting.Value, nil
+}
+
+// describeTLS extracts the interesting parts of a TLS connection state
+func describeTLS(state *tls.ConnectionState, verified bool) *TLSInfo {
+	info := &TLSInfo{
+		Version:  tls.VersionName(state.Version),
+		Verified: verified,
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		info.Subject = leaf.Subject.String()
+		info.Issuer = leaf.Issuer.String()
+		info.NotAfter = leaf.NotAfter
+	}
+	return info
+}
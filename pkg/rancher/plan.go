@@ -0,0 +1,202 @@
+package rancher
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+// PlannedCall is a Rancher API call a command is expected to make
+type PlannedCall struct {
+	Method string
+	Path   string
+
+	// Count describes how often the call is made, e.g. "once" or "per cluster"
+	Count string
+
+	// Purpose explains why the call is made
+	Purpose string
+
+	// Creates names what the call creates on the Rancher server, if anything
+	Creates string
+}
+
+// Plan is the sequence of Rancher API calls a command is expected to make with
+// a given configuration. It is built without contacting Rancher, so counts that
+// depend on the clusters found are given per cluster.
+type Plan struct {
+	Command string
+	URL     string
+	Calls   []PlannedCall
+
+	// Notes describe work that does not go to the Rancher API
+	Notes []string
+
+	cfg *config.Config
+}
+
+// NewPlan starts the plan of a command, including the login that password
+// authentication and the checks that impersonation perform first
+func NewPlan(command string, cfg *config.Config) *Plan {
+	p := &Plan{Command: command, URL: cfg.RancherURL, cfg: cfg}
+	if cfg.WaitForRancher > 0 {
+		p.Add(PlannedCall{Method: "GET", Path: "/ping", Count: "until Rancher answers, up to " + cfg.WaitForRancher.String(), Purpose: "wait for Rancher to come back"})
+	}
+	if cfg.UsePasswordAuth() {
+		path, err := LoginProviderPath(cfg.AuthProvider)
+		if err != nil {
+			path = "<provider>"
+		}
+		p.Add(PlannedCall{
+			Method:  "POST",
+			Path:    "/v3-public/" + path + "?action=login",
+			Count:   "once",
+			Purpose: "log in with username and password",
+			Creates: "a session token",
+		})
+	}
+	return p
+}
+
+// Add appends a call to the plan
+func (p *Plan) Add(call PlannedCall) {
+	p.Calls = append(p.Calls, call)
+}
+
+// Note appends a remark about work that does not involve the Rancher API
+func (p *Plan) Note(format string, args ...any) {
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+// ListClusters adds the cluster listing
+func (p *Plan) ListClusters() {
+	p.Add(PlannedCall{Method: "GET", Path: "/v3/clusters", Count: "once", Purpose: "list clusters"})
+}
+
+// GetAllKubeconfigs adds the calls GetAllKubeconfigs makes with the plan's configuration
+func (p *Plan) GetAllKubeconfigs() {
+	cfg := p.cfg
+	if cfg.AsUser != "" {
+		p.Add(PlannedCall{Method: "GET", Path: "/v3/users/" + cfg.AsUser, Count: "once", Purpose: "look up the user to impersonate"})
+		p.Add(PlannedCall{Method: "GET", Path: "/v3/users?me=true", Count: "once", Purpose: "check that Rancher honors impersonation"})
+	}
+	p.ListClusters()
+
+	eligible := fmt.Sprintf("per cluster in state %s", strings.Join(cfg.AcceptedClusterStates(), "/"))
+	if cfg.IncludeAllStates {
+		eligible = "per cluster"
+	}
+	if mode, _ := config.ParseHarvesterMode(string(cfg.Harvester)); mode != config.HarvesterInclude {
+		eligible += fmt.Sprintf(" (Harvester: %s)", mode)
+	}
+	if cfg.OlderThan > 0 {
+		eligible += fmt.Sprintf(" older than %s", cfg.OlderThan)
+	}
+	if cfg.NewerThan > 0 {
+		eligible += fmt.Sprintf(" newer than %s", cfg.NewerThan)
+	}
+	if !cfg.IncludeEphemeral && (cfg.EphemeralLabel != "" || cfg.EphemeralNamePattern != "") {
+		eligible += " not ephemeral"
+	}
+	if len(cfg.IncludeClusters) > 0 {
+		eligible += fmt.Sprintf(" named %s", strings.Join(cfg.IncludeClusters, "/"))
+	}
+	if len(cfg.ExcludeClusters) > 0 {
+		eligible += fmt.Sprintf(" not named %s", strings.Join(cfg.ExcludeClusters, "/"))
+	}
+	if len(cfg.Clusters) > 0 {
+		eligible += fmt.Sprintf(" among %s", strings.Join(cfg.Clusters, ", "))
+	}
+
+	if cfg.ExecCredentials {
+		p.Add(PlannedCall{
+			Method:  "GET",
+			Path:    "/v3/settings/cacerts",
+			Count:   eligible,
+			Purpose: "build a proxy kubeconfig whose credentials come from the exec plugin",
+		})
+		p.Note("kubectl later runs %q, which mints a cluster-scoped token when its cached one expires", cfg.ExecCommandOrDefault()+" token")
+		return
+	}
+
+	p.Add(PlannedCall{
+		Method:  "POST",
+		Path:    "/v3/clusters/<id>?action=generateKubeconfig",
+		Count:   eligible,
+		Purpose: "generate the cluster's kubeconfig",
+		Creates: "a kubeconfig token, unless Rancher reuses one",
+	})
+	p.Add(PlannedCall{
+		Method:  "GET",
+		Path:    "/v3/settings/cacerts",
+		Count:   "per cluster without generateKubeconfig",
+		Purpose: "build a proxy kubeconfig with your own token instead",
+	})
+	if cfg.ScopedTokens {
+		p.Add(PlannedCall{
+			Method:  "GET",
+			Path:    "/v3/tokens/<name>",
+			Count:   "per cluster with a recorded scoped token",
+			Purpose: "check the scoped token of an earlier run can be reused",
+		})
+		p.Add(PlannedCall{
+			Method:  "POST",
+			Path:    "/v3/tokens",
+			Count:   "per cluster without a reusable scoped token",
+			Purpose: "mint a cluster-scoped token",
+			Creates: "a cluster-scoped API token",
+		})
+		p.Add(PlannedCall{
+			Method:  "DELETE",
+			Path:    "/v3/tokens/<name>",
+			Count:   eligible,
+			Purpose: "revoke the kubeconfig token and any scoped token replaced",
+		})
+	}
+	if cfg.AsUser != "" {
+		p.Add(PlannedCall{Method: "GET", Path: "/v3/tokens/<name>", Count: eligible, Purpose: "check the kubeconfig token belongs to " + cfg.AsUser})
+	}
+}
+
+// Creates lists what the planned calls create on the Rancher server
+func (p *Plan) Creates() []string {
+	var created []string
+	for _, call := range p.Calls {
+		if call.Creates != "" {
+			created = append(created, fmt.Sprintf("%s (%s)", call.Creates, call.Count))
+		}
+	}
+	return created
+}
+
+// Write renders the plan for humans
+func (p *Plan) Write(out io.Writer) error {
+	fmt.Fprintf(out, "Plan for %q against %s:\n\n", p.Command, p.URL)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  #\tMETHOD\tENDPOINT\tCALLS\tPURPOSE")
+	for i, call := range p.Calls {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\n", i+1, call.Method, call.Path, call.Count, call.Purpose)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	if created := p.Creates(); len(created) > 0 {
+		fmt.Fprintln(out, "Creates on the Rancher server:")
+		for _, item := range created {
+			fmt.Fprintf(out, "  - %s\n", item)
+		}
+	} else {
+		fmt.Fprintln(out, "Creates nothing on the Rancher server (read-only).")
+	}
+	for _, note := range p.Notes {
+		fmt.Fprintf(out, "Note: %s\n", note)
+	}
+	fmt.Fprintln(out, "\nNothing was sent to Rancher.")
+	return nil
+}
@@ -0,0 +1,140 @@
+package rancher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+const (
+	// systemProjectLabel marks the project Rancher creates for its own workloads
+	systemProjectLabel = "authz.management.cattle.io/system-project"
+
+	// systemProjectName is the display name Rancher gives the system project
+	systemProjectName = "System"
+)
+
+// Project represents a Rancher project within a downstream cluster
+type Project struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	ClusterID   string            `json:"clusterId"`
+	Description string            `json:"description"`
+	State       string            `json:"state"`
+	Labels      map[string]string `json:"labels"`
+}
+
+// ProjectCollection represents the response from the projects endpoint
+type ProjectCollection struct {
+	Data []Project `json:"data"`
+}
+
+// IsSystem reports whether this is Rancher's System project, which holds
+// cattle-system, kube-system and other namespaces users rarely want as a default
+func (p *Project) IsSystem() bool {
+	if p.Labels[systemProjectLabel] == "true" {
+		return true
+	}
+	return p.Name == systemProjectName
+}
+
+// ListProjects retrieves the projects of a cluster. The System project is
+// excluded unless IncludeSystemProjects is set in the configuration.
+func (c *Client) ListProjects(clusterID string) ([]Project, error) {
+	endpoint := fmt.Sprintf("%s/v3/projects?clusterId=%s", c.config.RancherURL, url.QueryEscape(clusterID))
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("list projects for cluster "+clusterID, resp)
+	}
+
+	var collection ProjectCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("failed to decode projects response: %w", err)
+	}
+
+	return FilterProjects(collection.Data, c.config.IncludeSystemProjects), nil
+}
+
+// FilterProjects drops the System project unless includeSystem is true
+func FilterProjects(projects []Project, includeSystem bool) []Project {
+	if includeSystem {
+		return projects
+	}
+	filtered := make([]Project, 0, len(projects))
+	for _, p := range projects {
+		if p.IsSystem() {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// Namespace represents a namespace of a downstream cluster
+type Namespace struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ProjectID string `json:"projectId"`
+	State     string `json:"state"`
+}
+
+// NamespaceCollection represents the response from the namespaces endpoint
+type NamespaceCollection struct {
+	Data []Namespace `json:"data"`
+}
+
+// ListNamespaces retrieves the namespaces of a cluster
+func (c *Client) ListNamespaces(clusterID string) ([]Namespace, error) {
+	endpoint := fmt.Sprintf("%s/v3/cluster/%s/namespaces", c.config.RancherURL, url.PathEscape(clusterID))
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("list namespaces for cluster "+clusterID, resp)
+	}
+
+	var collection NamespaceCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("failed to decode namespaces response: %w", err)
+	}
+	return collection.Data, nil
+}
+
+// ListProjectNamespaces returns the sorted names of the namespaces that belong
+// to a project of the cluster, leaving out the System project's unless
+// IncludeSystemProjects is set
+func (c *Client) ListProjectNamespaces(clusterID string) ([]string, error) {
+	projects, err := c.ListProjects(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	inProject := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		inProject[project.ID] = true
+	}
+
+	namespaces, err := c.ListNamespaces(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, namespace := range namespaces {
+		if inProject[namespace.ProjectID] {
+			names = append(names, namespace.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,141 @@
+package rancher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RegistrationToken is a cluster registration token, holding the commands that
+// install or re-install the Rancher agents on a cluster
+type RegistrationToken struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	ClusterID           string `json:"clusterId"`
+	State               string `json:"state"`
+	Token               string `json:"token"`
+	Command             string `json:"command"`
+	InsecureCommand     string `json:"insecureCommand"`
+	ManifestURL         string `json:"manifestUrl"`
+	NodeCommand         string `json:"nodeCommand"`
+	InsecureNodeCommand string `json:"insecureNodeCommand"`
+	WindowsNodeCommand  string `json:"windowsNodeCommand"`
+	Created             string `json:"created"`
+}
+
+// registrationTokenCollection represents the response from the clusterregistrationtokens endpoint
+type registrationTokenCollection struct {
+	Data []RegistrationToken `json:"data"`
+}
+
+// FindCluster returns the cluster whose ID or name matches nameOrID. IDs are
+// unique; a name shared by several clusters is an error.
+func (c *Client) FindCluster(nameOrID string) (*Cluster, error) {
+	clusters, err := c.ListClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Cluster
+	for _, cluster := range clusters {
+		if cluster.ID == nameOrID {
+			return &cluster, nil
+		}
+		if cluster.Name == nameOrID {
+			matches = append(matches, cluster)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("cluster %q not found", nameOrID)
+	case 1:
+		return &matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	return nil, fmt.Errorf("several clusters are named %q (%s); use the cluster ID", nameOrID, strings.Join(ids, ", "))
+}
+
+// ListRegistrationTokens retrieves the registration tokens of a cluster
+func (c *Client) ListRegistrationTokens(clusterID string) ([]RegistrationToken, error) {
+	endpoint := fmt.Sprintf("%s/v3/clusterregistrationtokens?clusterId=%s", c.config.RancherURL, url.QueryEscape(clusterID))
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("list registration tokens for cluster "+clusterID, resp)
+	}
+
+	var collection registrationTokenCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("failed to decode registration tokens response: %w", err)
+	}
+	return collection.Data, nil
+}
+
+// CreateRegistrationToken asks Rancher for a new registration token for a cluster
+func (c *Client) CreateRegistrationToken(clusterID string) (*RegistrationToken, error) {
+	body, err := json.Marshal(map[string]string{
+		"type":      "clusterRegistrationToken",
+		"clusterId": clusterID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal registration token request: %w", err)
+	}
+
+	resp, err := c.doRequest("POST", c.config.RancherURL+"/v3/clusterregistrationtokens", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, NewAPIError("create registration token for cluster "+clusterID, resp)
+	}
+
+	var token RegistrationToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode registration token response: %w", err)
+	}
+	return &token, nil
+}
+
+// GetRegistrationToken returns the most recent registration token of a cluster
+// that carries an import command. If there is none and create is true, a new
+// token is requested; Rancher fills in its commands asynchronously, so the
+// returned token may still lack them.
+func (c *Client) GetRegistrationToken(clusterID string, create bool) (*RegistrationToken, error) {
+	tokens, err := c.ListRegistrationTokens(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *RegistrationToken
+	for i := range tokens {
+		if tokens[i].Command == "" && tokens[i].ManifestURL == "" {
+			continue
+		}
+		// Rancher timestamps are RFC 3339, so they order lexically
+		if latest == nil || tokens[i].Created > latest.Created {
+			latest = &tokens[i]
+		}
+	}
+	if latest != nil {
+		return latest, nil
+	}
+
+	if !create {
+		return nil, fmt.Errorf("cluster %s has no registration token (use --create to request one)", clusterID)
+	}
+	return c.CreateRegistrationToken(clusterID)
+}
@@ -0,0 +1,48 @@
+package rancher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ClusterFailure is a cluster whose kubeconfig could not be produced
+type ClusterFailure struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Err  error  `json:"-"`
+}
+
+// Error describes the failure with the cluster name
+func (f ClusterFailure) Error() string {
+	return fmt.Sprintf("%s: %v", f.Name, f.Err)
+}
+
+// Unwrap returns the underlying error
+func (f ClusterFailure) Unwrap() error {
+	return f.Err
+}
+
+// KubeconfigResult is the outcome of fetching the kubeconfigs of every eligible
+// cluster: the clusters that succeeded and those that failed
+type KubeconfigResult struct {
+	// Kubeconfigs holds the kubeconfig of every cluster that succeeded, by cluster name
+	Kubeconfigs map[string]string
+
+	// Clusters holds the clusters that succeeded, by cluster name
+	Clusters map[string]Cluster
+
+	// ListedIDs holds the ID of every cluster Rancher listed, including those filtered out
+	ListedIDs []string
+
+	// Failures lists the eligible clusters that failed, in the order Rancher listed them
+	Failures []ClusterFailure
+}
+
+// Err joins the failures, or returns nil when every cluster succeeded
+func (r *KubeconfigResult) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, failure := range r.Failures {
+		errs[i] = failure
+	}
+	return errors.Join(errs...)
+}
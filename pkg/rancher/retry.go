@@ -0,0 +1,132 @@
+package rancher
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+const (
+	// DefaultRetryMaxWait is the total time spent waiting on throttled or
+	// unavailable responses before giving up, when no budget is configured
+	DefaultRetryMaxWait = time.Minute
+
+	// maxRetries bounds the attempts for a single request regardless of the budget
+	maxRetries = 5
+
+	// retryInitialDelay is the first delay when the server gives no Retry-After
+	retryInitialDelay = time.Second
+)
+
+// retryable reports whether a response asks to come back later and the
+// request is safe to send again. A 503 from a proxy may come after Rancher
+// processed the request, so only idempotent requests are retried on it; a
+// 429 means the request was turned away before being processed.
+func retryable(req *http.Request, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return idempotent(req.Method)
+	}
+	return false
+}
+
+// idempotent reports whether sending a request with this method twice has
+// the same effect as sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay seconds or
+// as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryMaxWait returns the configured wait budget, the default, or 0 when
+// retries are disabled with a negative budget
+func (c *Client) retryMaxWait() time.Duration {
+	switch {
+	case c.config.RetryMaxWait < 0:
+		return 0
+	case c.config.RetryMaxWait == 0:
+		return DefaultRetryMaxWait
+	}
+	return c.config.RetryMaxWait
+}
+
+// sendWithRetry sends a request, waiting and retrying while Rancher answers 429
+// or, for idempotent requests, 503. The server's Retry-After is honored,
+// falling back to exponential backoff, as long as the total wait stays within
+// the budget and the request's context is not done. The last response is
+// returned when the budget is exhausted, so callers report the throttling
+// status as usual.
+func (c *Client) sendWithRetry(req *http.Request) (*http.Response, error) {
+	budget := c.retryMaxWait()
+	delay := retryInitialDelay
+	var waited time.Duration
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		if err != nil || !retryable(req, resp.StatusCode) || attempt >= maxRetries {
+			return resp, err
+		}
+		// A request body that cannot be replayed makes the request unsafe to retry
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			wait = delay
+			delay *= 2
+		}
+		if waited+wait > budget {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+
+		events.Warnf(c.events(), "", "Rancher answered %d for %s, retrying in %s", resp.StatusCode, req.URL.Path, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		waited += wait
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
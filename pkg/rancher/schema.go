@@ -0,0 +1,159 @@
+package rancher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// expectedClusterFields are the cluster fields the client relies on, with a hint
+// explaining what their absence most likely means
+var expectedClusterFields = map[string]string{
+	"id":                         "this does not look like a Rancher v3 clusters collection",
+	"name":                       "this does not look like a Rancher v3 clusters collection",
+	"state":                      "clusters cannot be filtered by state; this Rancher version may be unsupported",
+	"actions.generateKubeconfig": "your role may not allow generating kubeconfigs, or this Rancher version renamed the action",
+}
+
+// SchemaDrift describes differences between the cluster objects Rancher returned
+// and the fields this client expects
+type SchemaDrift struct {
+	// Missing maps each expected field path to the clusters that lacked it
+	Missing map[string][]string
+
+	// Unexpected lists kubeconfig-related actions the client does not know about
+	Unexpected []string
+}
+
+// Empty reports whether no drift was detected
+func (d *SchemaDrift) Empty() bool {
+	return d == nil || (len(d.Missing) == 0 && len(d.Unexpected) == 0)
+}
+
+// Warnings renders the drift as human readable messages
+func (d *SchemaDrift) Warnings() []string {
+	if d.Empty() {
+		return nil
+	}
+
+	fields := make([]string, 0, len(d.Missing))
+	for field := range d.Missing {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var warnings []string
+	for _, field := range fields {
+		clusters := d.Missing[field]
+		warnings = append(warnings, fmt.Sprintf("Rancher response lacks %q for %d cluster(s) (%s): %s",
+			field, len(clusters), strings.Join(clusters, ", "), expectedClusterFields[field]))
+	}
+	for _, action := range d.Unexpected {
+		warnings = append(warnings, fmt.Sprintf("Rancher offers unknown kubeconfig action %q; this Rancher version may not be fully supported", action))
+	}
+	return warnings
+}
+
+// CheckClusterSchema inspects a raw clusters collection for missing expected
+// fields and unknown kubeconfig-related actions
+func CheckClusterSchema(body []byte) (*SchemaDrift, error) {
+	schema := newSchemaChecker()
+	err := decodeCollection(bytes.NewReader(body), func(item json.RawMessage) error {
+		schema.check(item)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode clusters response: %w", err)
+	}
+	return schema.drift(), nil
+}
+
+// schemaChecker accumulates schema drift over the clusters of a collection
+type schemaChecker struct {
+	count      int
+	missing    map[string][]string
+	unexpected map[string]bool
+
+	// paths overrides where expected fields are looked up (see fieldPaths)
+	paths map[string]string
+}
+
+func newSchemaChecker() *schemaChecker {
+	return &schemaChecker{
+		missing:    make(map[string][]string),
+		unexpected: make(map[string]bool),
+	}
+}
+
+// check records the drift of one raw cluster object
+func (s *schemaChecker) check(item json.RawMessage) {
+	index := s.count
+	s.count++
+
+	var cluster map[string]any
+	if err := json.Unmarshal(item, &cluster); err != nil {
+		return
+	}
+
+	name, _ := cluster["name"].(string)
+	if name == "" {
+		name, _ = cluster["id"].(string)
+	}
+	if name == "" {
+		name = fmt.Sprintf("#%d", index)
+	}
+
+	for field := range expectedClusterFields {
+		path := field
+		if custom, ok := s.paths[field]; ok {
+			path = custom
+		}
+		if !hasField(cluster, path) {
+			s.missing[field] = append(s.missing[field], name)
+		}
+	}
+
+	mapped := strings.TrimPrefix(s.paths["actions.generateKubeconfig"], "actions.")
+	if actions, ok := cluster["actions"].(map[string]any); ok {
+		for action := range actions {
+			if action != "generateKubeconfig" && action != mapped && strings.Contains(strings.ToLower(action), "kubeconfig") {
+				s.unexpected[action] = true
+			}
+		}
+	}
+}
+
+// drift returns the accumulated drift
+func (s *schemaChecker) drift() *SchemaDrift {
+	drift := &SchemaDrift{Missing: s.missing}
+	for action := range s.unexpected {
+		drift.Unexpected = append(drift.Unexpected, action)
+	}
+	sort.Strings(drift.Unexpected)
+	return drift
+}
+
+// hasField reports whether a dotted field path is present and non-empty
+func hasField(object map[string]any, path string) bool {
+	value := fieldValue(object, path)
+	if s, ok := value.(string); ok {
+		return s != ""
+	}
+	return value != nil
+}
+
+// fieldValue returns the value at a dotted field path, or nil if it is absent
+func fieldValue(object map[string]any, path string) any {
+	head, rest, nested := strings.Cut(path, ".")
+	value := object[head]
+	if !nested || value == nil {
+		return value
+	}
+	child, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	return fieldValue(child, rest)
+}
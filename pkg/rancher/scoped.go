@@ -0,0 +1,150 @@
+package rancher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+// DefaultScopedTokenTTL is the lifetime of the scoped tokens when none is configured
+const DefaultScopedTokenTTL = 30 * 24 * time.Hour
+
+// scopedTokenDescription is the description given to tokens minted per cluster,
+// naming the cluster; the run that minted it follows (scopedTokenRun)
+const (
+	scopedTokenDescription = "kubeconfig-wrangler scoped token for %s"
+	scopedTokenRun         = " (run %s)"
+)
+
+// scopedTokenStateDir is the subdirectory of the state directory recording
+// the scoped tokens minted, so that later runs reuse or revoke them
+const scopedTokenStateDir = "scoped-tokens"
+
+// scopeKubeconfig replaces the credentials of a cluster's kubeconfig with a token
+// that only works for that cluster, so a leaked kubeconfig cannot be used
+// against any other cluster the generating user can reach. The token minted by
+// an earlier run is reused while it has at least half of its lifetime left, and
+// revoked once replaced. generated tells that the kubeconfig holds a token
+// generateKubeconfig created, which is revoked too.
+func (c *Client) scopeKubeconfig(cluster *Cluster, kubeconfig string, generated bool) (string, error) {
+	config, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kubeconfig for cluster %s: %w", cluster.Name, err)
+	}
+
+	token, err := c.scopedToken(cluster)
+	if err != nil {
+		return "", fmt.Errorf("failed to create scoped token for cluster %s: %w", cluster.Name, err)
+	}
+
+	replaced := make(map[string]bool)
+	for _, authInfo := range config.AuthInfos {
+		if generated && authInfo.Token != "" {
+			name, _, _ := strings.Cut(authInfo.Token, ":")
+			replaced[name] = true
+		}
+		authInfo.Token = token
+		authInfo.TokenFile = ""
+		authInfo.Username = ""
+		authInfo.Password = ""
+	}
+	// Never revoke the token the client itself authenticates with
+	delete(replaced, c.TokenName())
+	for name := range replaced {
+		if err := c.DeleteToken(name); err != nil {
+			events.Warnf(c.events(), cluster.Name, "failed to revoke the kubeconfig token %s of cluster %s replaced by a scoped token: %v", name, cluster.Name, err)
+		}
+	}
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize kubeconfig for cluster %s: %w", cluster.Name, err)
+	}
+	return string(data), nil
+}
+
+// scopedToken returns the scoped token of cluster: the one recorded in the
+// state directory while Rancher still has it and half of its lifetime is
+// left, else a new one replacing it
+func (c *Client) scopedToken(cluster *Cluster) (string, error) {
+	ttl := c.config.ScopedTokenTTL
+	if ttl == 0 {
+		ttl = DefaultScopedTokenTTL
+	}
+	description := fmt.Sprintf(scopedTokenDescription, cluster.Name)
+	key := []string{c.config.RancherURL, cluster.ID, c.config.AccessKey + c.config.Username}
+
+	cache, err := scopedTokenCache()
+	if err != nil {
+		events.Warnf(c.events(), cluster.Name, "scoped tokens are not recorded, so none is reused or revoked: %v", err)
+	}
+	var previous *CachedCredential
+	if cache != nil {
+		previous, _ = cache.load(key)
+	}
+	if previous != nil && time.Until(previous.ExpiresAt) > ttl/2 && c.scopedTokenValid(previous.Token, cluster.ID, description) {
+		return previous.Token, nil
+	}
+
+	cred, err := c.MintClusterCredential(cluster.ID, description+fmt.Sprintf(scopedTokenRun, CorrelationID), ttl)
+	if err != nil {
+		return "", err
+	}
+	if cache != nil {
+		if err := cache.Put(*cred, key...); err != nil {
+			events.Warnf(c.events(), cluster.Name, "%v", err)
+		}
+	}
+	if previous != nil {
+		name, _, _ := strings.Cut(previous.Token, ":")
+		if err := c.DeleteToken(name); err != nil {
+			events.Warnf(c.events(), cluster.Name, "failed to revoke the replaced scoped token %s of cluster %s: %v", name, cluster.Name, err)
+		}
+	}
+	return cred.Token, nil
+}
+
+// scopedTokenValid reports whether Rancher still has token as an enabled,
+// unexpired scoped token of clusterID with the given description
+func (c *Client) scopedTokenValid(token, clusterID, description string) bool {
+	name, _, _ := strings.Cut(token, ":")
+	info, err := c.GetToken(name)
+	if err != nil || info.Expired || (info.Enabled != nil && !*info.Enabled) || info.ClusterID != clusterID {
+		return false
+	}
+	return info.Description == description || strings.HasPrefix(info.Description, description+" (run ")
+}
+
+// scopedTokenCache returns the record of the scoped tokens minted, in the
+// state directory
+func scopedTokenCache() (*CredentialCache, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewCredentialCache(filepath.Join(dir, scopedTokenStateDir)), nil
+}
+
+// MintClusterCredential creates a token scoped to one cluster and reads back
+// when it expires, since Rancher may shorten the requested lifetime
+func (c *Client) MintClusterCredential(clusterID, description string, ttl time.Duration) (*CachedCredential, error) {
+	token, err := c.CreateClusterToken(clusterID, description, ttl)
+	if err != nil {
+		return nil, err
+	}
+	cred := &CachedCredential{Token: token, ExpiresAt: time.Now().Add(ttl)}
+
+	name, _, _ := strings.Cut(token, ":")
+	if info, err := c.GetToken(name); err == nil && info.ExpiresAt != "" {
+		if expires, err := time.Parse(time.RFC3339, info.ExpiresAt); err == nil && expires.Before(cred.ExpiresAt) {
+			cred.ExpiresAt = expires
+		}
+	}
+	return cred, nil
+}
@@ -0,0 +1,206 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+// ClusterEventType describes what happened to a cluster
+type ClusterEventType string
+
+const (
+	// ClusterCreated is emitted for a cluster that was not known before
+	ClusterCreated ClusterEventType = "created"
+	// ClusterRemoved is emitted when a cluster is deleted
+	ClusterRemoved ClusterEventType = "removed"
+	// ClusterStateChanged is emitted when a cluster's state changes
+	ClusterStateChanged ClusterEventType = "state-changed"
+)
+
+// ClusterEvent is a relevant change to a downstream cluster
+type ClusterEvent struct {
+	Type     ClusterEventType
+	Cluster  Cluster
+	OldState string
+}
+
+// subscribeMessage is a message received on the /v3/subscribe websocket
+type subscribeMessage struct {
+	Name         string          `json:"name"`
+	ResourceType string          `json:"resourceType"`
+	Data         json.RawMessage `json:"data"`
+}
+
+const (
+	// subscribeMinBackoff and subscribeMaxBackoff bound the reconnect delay
+	subscribeMinBackoff = time.Second
+	subscribeMaxBackoff = time.Minute
+)
+
+// WatchClusters subscribes to Rancher's /v3/subscribe websocket and calls onEvent
+// whenever a cluster is created, removed or changes state. Other changes (labels,
+// status conditions, heartbeats) are ignored. The subscription is re-established
+// with exponential backoff after errors, each reported through the client's
+// reporter, and the cluster list is re-read on every reconnect so changes missed
+// while disconnected are still reported. WatchClusters returns nil when ctx is
+// cancelled, and the error when Rancher rejects the credentials (401 or 403),
+// since retrying cannot help.
+func (c *Client) WatchClusters(ctx context.Context, onEvent func(ClusterEvent)) error {
+	known := make(map[string]Cluster)
+	initial := true
+	backoff := subscribeMinBackoff
+
+	for {
+		clusters, err := c.ListClusters()
+		if err == nil {
+			c.resync(known, clusters, !initial, onEvent)
+			initial = false
+			err = c.subscribeClusters(ctx, func(event subscribeMessage, cluster Cluster) {
+				backoff = subscribeMinBackoff
+				c.applyClusterMessage(known, event, cluster, onEvent)
+			})
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if IsUnauthorized(err) || IsForbidden(err) {
+			return err
+		}
+		events.Warnf(c.events(), "", "cluster events of %s: %v; reconnecting in %s", c.config.RancherURL, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > subscribeMaxBackoff {
+			backoff = subscribeMaxBackoff
+		}
+	}
+}
+
+// resync reconciles the known clusters with a fresh list, emitting events for
+// differences unless this is the initial load
+func (c *Client) resync(known map[string]Cluster, clusters []Cluster, emit bool, onEvent func(ClusterEvent)) {
+	seen := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		seen[cluster.ID] = true
+		previous, existed := known[cluster.ID]
+		known[cluster.ID] = cluster
+		if !emit {
+			continue
+		}
+		switch {
+		case !existed:
+			onEvent(ClusterEvent{Type: ClusterCreated, Cluster: cluster})
+		case previous.State != cluster.State:
+			onEvent(ClusterEvent{Type: ClusterStateChanged, Cluster: cluster, OldState: previous.State})
+		}
+	}
+	for id, cluster := range known {
+		if !seen[id] {
+			delete(known, id)
+			if emit {
+				onEvent(ClusterEvent{Type: ClusterRemoved, Cluster: cluster})
+			}
+		}
+	}
+}
+
+// applyClusterMessage turns a websocket message into a cluster event, if relevant
+func (c *Client) applyClusterMessage(known map[string]Cluster, msg subscribeMessage, cluster Cluster, onEvent func(ClusterEvent)) {
+	previous, existed := known[cluster.ID]
+
+	if msg.Name == "resource.remove" {
+		if existed {
+			delete(known, cluster.ID)
+			onEvent(ClusterEvent{Type: ClusterRemoved, Cluster: cluster})
+		}
+		return
+	}
+
+	known[cluster.ID] = cluster
+	switch {
+	case !existed:
+		onEvent(ClusterEvent{Type: ClusterCreated, Cluster: cluster})
+	case previous.State != cluster.State:
+		onEvent(ClusterEvent{Type: ClusterStateChanged, Cluster: cluster, OldState: previous.State})
+	}
+}
+
+// subscribeClusters opens a single websocket subscription and delivers cluster
+// messages until the connection fails or ctx is cancelled
+func (c *Client) subscribeClusters(ctx context.Context, onMessage func(subscribeMessage, Cluster)) error {
+	endpoint := c.config.RancherURL + "/v3/subscribe?eventNames=resource.create&eventNames=resource.change&eventNames=resource.remove"
+	endpoint = "ws" + strings.TrimPrefix(endpoint, "http")
+
+	req, err := c.newRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	// The handshake carries the same credentials, User-Agent, correlation ID
+	// and impersonation as the API requests
+	header := req.Header.Clone()
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+	}
+	if transport, ok := baseTransport(c.httpClient.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+		dialer.Proxy = transport.Proxy
+	}
+
+	if c.config.DebugHTTP {
+		fmt.Fprintf(os.Stderr, "--> websocket %s\n", endpoint)
+	}
+	conn, resp, err := dialer.DialContext(ctx, endpoint, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return NewAPIError("subscribe to cluster events", resp)
+		}
+		return fmt.Errorf("failed to subscribe to cluster events: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage when the caller cancels
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	paths := fieldPaths(c.config)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("cluster event subscription closed: %w", err)
+		}
+
+		var msg subscribeMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if msg.ResourceType != "cluster" || len(msg.Data) == 0 {
+			continue
+		}
+
+		var cluster Cluster
+		if err := json.Unmarshal(msg.Data, &cluster); err != nil || cluster.ID == "" {
+			continue
+		}
+		// Apply the same custom field paths as ListClusters so watched
+		// clusters are named and stated consistently
+		if err := mapFields(msg.Data, paths, &cluster); err != nil {
+			continue
+		}
+		onMessage(msg, cluster)
+	}
+}
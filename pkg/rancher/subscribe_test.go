@@ -0,0 +1,220 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+func TestClient_WatchClusters(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/clusters":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ClusterCollection{Data: []Cluster{
+				{ID: "c-1", Name: "prod", State: "active"},
+				{ID: "c-2", Name: "old", State: "active"},
+			}})
+		case "/v3/subscribe":
+			if r.Header.Get("Authorization") == "" {
+				t.Error("expected the subscription to be authenticated")
+			}
+			if r.Header.Get("User-Agent") != UserAgent || r.Header.Get(correlationIDHeader) != CorrelationID {
+				t.Errorf("handshake headers = %q, %q, want the client's User-Agent and correlation ID",
+					r.Header.Get("User-Agent"), r.Header.Get(correlationIDHeader))
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("upgrade failed: %v", err)
+				return
+			}
+			defer conn.Close()
+			for _, msg := range []string{
+				`{"name":"ping"}`,
+				`{"name":"resource.change","resourceType":"cluster","data":{"id":"c-1","name":"prod","state":"active","labels":{"x":"y"}}}`,
+				`{"name":"resource.change","resourceType":"cluster","data":{"id":"c-1","name":"prod","state":"updating"}}`,
+				`{"name":"resource.change","resourceType":"project","data":{"id":"p-1","name":"Default","state":"active"}}`,
+				`{"name":"resource.create","resourceType":"cluster","data":{"id":"c-3","name":"new","state":"provisioning"}}`,
+				`{"name":"resource.remove","resourceType":"cluster","data":{"id":"c-2","name":"old","state":"removing"}}`,
+			} {
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(msg))
+			}
+			// Keep the connection open until the client goes away
+			_, _, _ = conn.ReadMessage()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []ClusterEvent
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WatchClusters(ctx, func(event ClusterEvent) {
+			events = append(events, event)
+			if len(events) == 3 {
+				cancel()
+			}
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WatchClusters() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("WatchClusters() did not return after cancellation")
+	}
+
+	want := []struct {
+		typ     ClusterEventType
+		cluster string
+	}{
+		{ClusterStateChanged, "prod"},
+		{ClusterCreated, "new"},
+		{ClusterRemoved, "old"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events (%+v), want %d", len(events), events, len(want))
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Cluster.Name != w.cluster {
+			t.Errorf("event %d = %s %s, want %s %s", i, events[i].Type, events[i].Cluster.Name, w.typ, w.cluster)
+		}
+	}
+	if events[0].OldState != "active" {
+		t.Errorf("OldState = %q, want active", events[0].OldState)
+	}
+}
+
+func TestClient_WatchClusters_FieldPaths(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/clusters":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"id":"c-1","spec":{"displayName":"prod"},"status":{"phase":"active"}}]}`))
+		case "/v3/subscribe":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("upgrade failed: %v", err)
+				return
+			}
+			defer conn.Close()
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(
+				`{"name":"resource.change","resourceType":"cluster","data":{"id":"c-1","spec":{"displayName":"prod"},"status":{"phase":"updating"}}}`))
+			_, _, _ = conn.ReadMessage()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+		StateField: "status.phase",
+		NameField:  "spec.displayName",
+	}
+	client := &Client{config: cfg, httpClient: server.Client()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []ClusterEvent
+	err := client.WatchClusters(ctx, func(event ClusterEvent) {
+		events = append(events, event)
+		cancel()
+	})
+	if err != nil {
+		t.Fatalf("WatchClusters() error = %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events (%+v), want 1", len(events), events)
+	}
+	event := events[0]
+	if event.Type != ClusterStateChanged || event.Cluster.Name != "prod" || event.Cluster.State != "updating" || event.OldState != "active" {
+		t.Errorf("event = %s %s %s (from %s), want ClusterStateChanged prod updating (from active)",
+			event.Type, event.Cluster.Name, event.Cluster.State, event.OldState)
+	}
+}
+
+func TestClient_WatchClusters_Errors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/clusters":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		case "/v3/subscribe":
+			// Unavailable first, then the token is revoked
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","code":"Unauthorized","message":"must authenticate"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		RancherURL: server.URL,
+		AccessKey:  "token-xxxxx",
+		SecretKey:  "secret456",
+		AuthMethod: config.AuthMethodToken,
+	}
+	var warnings []string
+	client := &Client{config: cfg, httpClient: server.Client()}
+	client.SetReporter(events.ReporterFunc(func(event events.Event) {
+		if event.Kind == events.Warning {
+			warnings = append(warnings, event.Message)
+		}
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := client.WatchClusters(ctx, func(ClusterEvent) {})
+	if !IsUnauthorized(err) {
+		t.Fatalf("WatchClusters() error = %v, want the 401 instead of retrying", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("WatchClusters() kept retrying after the 401")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("subscribe attempts = %d, want 2", n)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "status 503") {
+		t.Errorf("warnings = %q, want the 503 reported once", warnings)
+	}
+}
@@ -0,0 +1,102 @@
+package rancher
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings breaks the latency of a request down into its phases. Phases that did
+// not happen, such as DNS for an IP address or TLS for plain HTTP, are zero.
+type Timings struct {
+	// DNS is the time spent resolving the host name
+	DNS time.Duration
+
+	// Addrs are the addresses the host name resolved to
+	Addrs []string
+
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+
+	// TLS is the time spent on the TLS handshake
+	TLS time.Duration
+
+	// FirstByte is the time from sending the request to the first response byte,
+	// i.e. how long Rancher took to answer
+	FirstByte time.Duration
+
+	// Total is the time from starting the request to the first response byte
+	Total time.Duration
+
+	// Reused is true when an idle connection was reused, so no DNS, connect or TLS took place
+	Reused bool
+}
+
+// traceTimings attaches a trace to req that fills the returned Timings while
+// the request is sent
+func traceTimings(req *http.Request) (*http.Request, *Timings) {
+	timings := &Timings{}
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart, wrote time.Time
+	start := time.Now()
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.DNS = time.Since(dnsStart)
+			timings.Addrs = nil
+			for _, addr := range info.Addrs {
+				timings.Addrs = append(timings.Addrs, addr.String())
+			}
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				timings.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				timings.TLS = time.Since(tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.Reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.FirstByte = time.Since(wrote)
+			timings.Total = time.Since(start)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), timings
+}
@@ -0,0 +1,209 @@
+package rancher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token represents a Rancher API token
+type Token struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	UserID      string `json:"userId"`
+	Description string `json:"description"`
+	ClusterID   string `json:"clusterId"`
+	TTL         int64  `json:"ttl"`
+	ExpiresAt   string `json:"expiresAt"`
+	Expired     bool   `json:"expired"`
+	Enabled     *bool  `json:"enabled,omitempty"`
+	Current     bool   `json:"current"`
+	Created     string `json:"created"`
+	CreatedTS   int64  `json:"createdTS"`
+	IsDerived   bool   `json:"isDerived"`
+
+	Labels map[string]string `json:"labels"`
+}
+
+// tokenCollection represents a page of the tokens endpoint
+type tokenCollection struct {
+	Data       []Token `json:"data"`
+	Pagination struct {
+		Next string `json:"next"`
+	} `json:"pagination"`
+}
+
+// CreatedAt returns when the token was created, or false if Rancher did not say
+func (t *Token) CreatedAt() (time.Time, bool) {
+	if created, err := time.Parse(time.RFC3339, t.Created); err == nil {
+		return created, true
+	}
+	if t.CreatedTS > 0 {
+		return time.UnixMilli(t.CreatedTS).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// TokenSelector selects API tokens, e.g. for revocation. Every criterion set
+// must match; the zero value selects every token.
+type TokenSelector struct {
+	// OlderThan selects tokens created at least this long ago (0 for any age)
+	OlderThan time.Duration
+
+	// Labels selects tokens carrying all of these labels
+	Labels map[string]string
+
+	// UserID selects the tokens of one user (empty for every user)
+	UserID string
+
+	// ExpiredOnly selects expired tokens only
+	ExpiredOnly bool
+}
+
+// Matches reports whether the token is selected at time now. Tokens of unknown
+// age never match an age criterion.
+func (s TokenSelector) Matches(t *Token, now time.Time) bool {
+	if s.UserID != "" && t.UserID != s.UserID {
+		return false
+	}
+	if s.ExpiredOnly && !t.Expired {
+		return false
+	}
+	for key, value := range s.Labels {
+		if label, ok := t.Labels[key]; !ok || label != value {
+			return false
+		}
+	}
+	if s.OlderThan > 0 {
+		created, ok := t.CreatedAt()
+		if !ok || now.Sub(created) < s.OlderThan {
+			return false
+		}
+	}
+	return true
+}
+
+// Scope returns a human readable description of what the token can reach
+func (t *Token) Scope() string {
+	if t.ClusterID != "" {
+		return "cluster " + t.ClusterID
+	}
+	return "global"
+}
+
+// User represents a Rancher user
+type User struct {
+	ID          string   `json:"id"`
+	Username    string   `json:"username"`
+	Name        string   `json:"name"`
+	PrincipalID []string `json:"principalIds"`
+}
+
+// userCollection represents the response from the users endpoint
+type userCollection struct {
+	Data []User `json:"data"`
+}
+
+// TokenName returns the name of the API token the client authenticates with,
+// i.e. the access key part of an "access_key:secret_key" pair
+func (c *Client) TokenName() string {
+	if c.bearerToken != "" {
+		name, _, _ := strings.Cut(c.bearerToken, ":")
+		return name
+	}
+	return c.config.AccessKey
+}
+
+// GetToken retrieves a token by name
+func (c *Client) GetToken(name string) (*Token, error) {
+	endpoint := fmt.Sprintf("%s/v3/tokens/%s", c.config.RancherURL, url.PathEscape(name))
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("get token "+name, resp)
+	}
+
+	var tok Token
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	return &tok, nil
+}
+
+// ListTokens retrieves every API token the client can see, following pagination
+func (c *Client) ListTokens() ([]Token, error) {
+	var tokens []Token
+	endpoint := c.config.RancherURL + "/v3/tokens"
+	for endpoint != "" {
+		resp, err := c.doRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := NewAPIError("list tokens", resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var collection tokenCollection
+		err = json.NewDecoder(resp.Body).Decode(&collection)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tokens response: %w", err)
+		}
+		tokens = append(tokens, collection.Data...)
+		endpoint = collection.Pagination.Next
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes a token by name
+func (c *Client) DeleteToken(name string) error {
+	endpoint := fmt.Sprintf("%s/v3/tokens/%s", c.config.RancherURL, url.PathEscape(name))
+
+	resp, err := c.doRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		// Already gone is as good as revoked
+		return nil
+	}
+	return NewAPIError("delete token "+name, resp)
+}
+
+// GetCurrentUser retrieves the user the client is authenticated as
+func (c *Client) GetCurrentUser() (*User, error) {
+	endpoint := fmt.Sprintf("%s/v3/users?me=true", c.config.RancherURL)
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("get current user", resp)
+	}
+
+	var collection userCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("failed to decode users response: %w", err)
+	}
+	if len(collection.Data) == 0 {
+		return nil, fmt.Errorf("rancher returned no current user")
+	}
+	return &collection.Data[0], nil
+}
@@ -0,0 +1,58 @@
+package rancher
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost keeps enough idle connections around that concurrent
+	// fetches against a single Rancher server reuse TLS sessions instead of redialing
+	DefaultMaxIdleConnsPerHost = 64
+
+	// DefaultIdleConnTimeout is how long an idle connection is kept in the pool
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// newTransport builds the HTTP transport used for all Rancher API calls,
+// applying the connection pooling and HTTP/2 settings from the configuration
+func newTransport(cfg *config.Config, tlsConfig *tls.Config) *http.Transport {
+	maxIdlePerHost := cfg.MaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	idleTimeout := cfg.IdleConnTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleConnTimeout
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          maxIdlePerHost * 2,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       idleTimeout,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		// A custom TLSClientConfig disables HTTP/2 unless explicitly requested
+		ForceAttemptHTTP2: !cfg.DisableHTTP2,
+	}
+
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map prevents the transport from upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
+}
@@ -0,0 +1,33 @@
+package rancher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// correlationIDHeader carries the run's correlation ID on every Rancher request,
+// so the Rancher audit log can tie requests, and the tokens they create, to a run
+const correlationIDHeader = "X-Correlation-ID"
+
+// UserAgent is sent with every Rancher request; the CLI sets it to include its version
+var UserAgent = "kubeconfig-wrangler/dev"
+
+// CorrelationID identifies this run of the program in Rancher requests and in
+// the descriptions of the tokens it creates
+var CorrelationID = newCorrelationID()
+
+// newCorrelationID returns a random 16 hex character ID
+func newCorrelationID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// SetClientHeaders sets the User-Agent and correlation ID headers on a request to Rancher
+func SetClientHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set(correlationIDHeader, CorrelationID)
+}
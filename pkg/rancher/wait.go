@@ -0,0 +1,110 @@
+package rancher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/events"
+)
+
+// Backoff while waiting for Rancher, variables so tests can shorten it
+var (
+	// waitInitialDelay is the first pause while waiting for Rancher to come back
+	waitInitialDelay = 2 * time.Second
+
+	// waitMaxDelay caps the pause between two checks
+	waitMaxDelay = 30 * time.Second
+)
+
+// unavailable reports whether a request failed because Rancher is down, e.g.
+// refusing connections or answering 503 during an upgrade
+func unavailable(resp *http.Response, err error) bool {
+	if err != nil {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	return breakerFailure(resp.StatusCode)
+}
+
+// waitForRancher polls the Rancher /ping endpoint with backoff until Rancher
+// answers, giving up at the client's wait deadline or when ctx is done
+func (c *Client) waitForRancher(ctx context.Context) error {
+	delay := waitInitialDelay
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.config.RancherURL+"/ping", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		SetClientHeaders(req)
+
+		resp, err := c.httpClient.Do(req)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped waiting for rancher at %s: %w", c.config.RancherURL, ctx.Err())
+		}
+		if !unavailable(resp, err) {
+			return nil
+		}
+
+		reason := err
+		if reason == nil {
+			reason = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		remaining := time.Until(c.waitDeadline)
+		if remaining <= 0 {
+			return fmt.Errorf("rancher at %s did not come back in time: %w", c.config.RancherURL, reason)
+		}
+		wait := min(delay, remaining)
+		events.Progressf(c.events(), "Waiting for Rancher at %s (%v), checking again in %s", c.config.RancherURL, reason, wait.Round(time.Second))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("stopped waiting for rancher at %s: %w", c.config.RancherURL, ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(delay*2, waitMaxDelay)
+	}
+}
+
+// waitAndResend waits for Rancher to come back and sends the request again
+// when it failed because Rancher is down and the wait deadline has not passed.
+// A request that was answered may have been processed, so it is only sent
+// again when idempotent; a refused connection means it never left. Otherwise
+// the original outcome is returned.
+func (c *Client) waitAndResend(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if c.waitDeadline.IsZero() || !time.Now().Before(c.waitDeadline) || !unavailable(resp, err) {
+		return resp, err
+	}
+	if err == nil && !idempotent(req.Method) {
+		return resp, err
+	}
+	// A request body that cannot be replayed makes the request unsafe to resend
+	if req.Body != nil && req.GetBody == nil {
+		return resp, err
+	}
+
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+	}
+	if waitErr := c.waitForRancher(req.Context()); waitErr != nil {
+		return nil, waitErr
+	}
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		req.Body = body
+	}
+	return c.sendWithRetry(req)
+}
@@ -0,0 +1,249 @@
+// Package share hands a file to another machine once, over HTTPS, behind an
+// unguessable URL that stops working after the first download or a timeout
+package share
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a share stays available when no TTL is given
+const DefaultTTL = 10 * time.Minute
+
+// ErrExpired is returned by Wait when the share expired before it was downloaded
+var ErrExpired = errors.New("share expired before it was downloaded")
+
+// Options configures a share
+type Options struct {
+	// Addr is the address to listen on; the port is chosen at random when it is 0
+	// or missing (default 0.0.0.0:0)
+	Addr string
+
+	// Host is the host name or IP address put in the URL and the certificate
+	// (default: the first non-loopback IPv4 address of this machine)
+	Host string
+
+	// TTL is how long the share stays available (0 uses DefaultTTL)
+	TTL time.Duration
+
+	// FileName is the name suggested to the downloading client
+	FileName string
+}
+
+// Share serves a file once
+type Share struct {
+	// URL is the one-time download URL, including its secret token
+	URL string
+
+	// PublicKeyPin is the base64 SHA-256 of the certificate's public key, as
+	// used by curl --pinnedpubkey sha256//<pin>
+	PublicKeyPin string
+
+	// Fingerprint is the hex SHA-256 fingerprint of the certificate
+	Fingerprint string
+
+	// ExpiresAt is when the share stops being served
+	ExpiresAt time.Time
+
+	data     []byte
+	path     string
+	fileName string
+	srv      *http.Server
+
+	mu         sync.Mutex
+	downloaded bool
+	done       chan string
+}
+
+// Start begins serving data according to opts. The share stops after the
+// first download, when the TTL passes, or when Close is called.
+func Start(data []byte, opts Options) (*Share, error) {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	addr := opts.Addr
+	if addr == "" {
+		addr = "0.0.0.0:0"
+	} else if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+	host := opts.Host
+	if host == "" {
+		host = advertisedHost()
+	}
+
+	cert, leaf, err := selfSignedCertificate(host, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	pin := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	fingerprint := sha256.Sum256(leaf.Raw)
+	s := &Share{
+		PublicKeyPin: base64.StdEncoding.EncodeToString(pin[:]),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		ExpiresAt:    time.Now().Add(ttl),
+		data:         data,
+		path:         "/" + base64.RawURLEncoding.EncodeToString(token),
+		fileName:     opts.FileName,
+		done:         make(chan string, 1),
+	}
+	s.URL = fmt.Sprintf("https://%s%s", net.JoinHostPort(host, fmt.Sprint(port)), s.path)
+
+	s.srv = &http.Server{
+		Handler:           http.HandlerFunc(s.handle),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+	go s.srv.ServeTLS(ln, "", "")
+	return s, nil
+}
+
+// Wait blocks until the share has been downloaded, it expires or ctx is done.
+// It returns the address of the client that downloaded the file.
+func (s *Share) Wait(ctx context.Context) (string, error) {
+	timer := time.NewTimer(time.Until(s.ExpiresAt))
+	defer timer.Stop()
+
+	select {
+	case client := <-s.done:
+		return client, nil
+	case <-timer.C:
+		return "", ErrExpired
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close stops serving, letting a download in progress finish for a few seconds
+func (s *Share) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// handle serves the file to the first request presenting the token
+func (s *Share) handle(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.URL.Path), []byte(s.path)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	if s.downloaded || time.Now().After(s.ExpiresAt) {
+		s.mu.Unlock()
+		http.Error(w, "This share has already been used or has expired", http.StatusGone)
+		return
+	}
+	s.downloaded = true
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Cache-Control", "no-store")
+	if s.fileName != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.fileName))
+	}
+	w.Write(s.data)
+	s.done <- r.RemoteAddr
+}
+
+// selfSignedCertificate creates a short-lived certificate for host
+func selfSignedCertificate(host string, ttl time.Duration) (tls.Certificate, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host, Organization: []string{"kubeconfig-wrangler share"}},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(ttl + time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf, nil
+}
+
+// advertisedHost returns the first non-loopback IPv4 address of this machine,
+// falling back to 127.0.0.1
+func advertisedHost() string {
+	addrs, err := net.InterfaceAddrs()
+	if err == nil {
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if ip := ipNet.IP.To4(); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return "127.0.0.1"
+}
+
+// CurlCommand returns a curl command that downloads the share while pinning the
+// certificate's public key, so the self-signed certificate is still verified
+func (s *Share) CurlCommand(output string) string {
+	parts := []string{"curl", "-fsS", "-k", "--pinnedpubkey", "'sha256//" + s.PublicKeyPin + "'", "-o", output, "'" + s.URL + "'"}
+	return strings.Join(parts, " ")
+}
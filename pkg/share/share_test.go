@@ -0,0 +1,97 @@
+package share
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pinnedClient trusts only the certificate whose public key matches pin
+func pinnedClient(pin string) *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if base64.StdEncoding.EncodeToString(sum[:]) != pin {
+				return errors.New("public key pin mismatch")
+			}
+			return nil
+		},
+	}}}
+}
+
+func TestShare_ServesOnce(t *testing.T) {
+	s, err := Start([]byte("apiVersion: v1\n"), Options{Addr: "127.0.0.1", Host: "127.0.0.1", TTL: time.Minute, FileName: "config"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Close()
+	client := pinnedClient(s.PublicKeyPin)
+
+	resp, err := client.Get(s.URL + "x")
+	if err != nil {
+		t.Fatalf("GET with wrong token: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("wrong token status = %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = client.Get(s.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "apiVersion: v1\n" {
+		t.Fatalf("GET = %d %q, want the shared file", resp.StatusCode, body)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Disposition"), `filename="config"`) {
+		t.Errorf("Content-Disposition = %q", resp.Header.Get("Content-Disposition"))
+	}
+
+	if _, err := s.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+
+	resp, err = client.Get(s.URL)
+	if err != nil {
+		t.Fatalf("second GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("second GET status = %d, want 410", resp.StatusCode)
+	}
+}
+
+func TestShare_Expires(t *testing.T) {
+	s, err := Start([]byte("data"), Options{Addr: "127.0.0.1", Host: "127.0.0.1", TTL: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Wait(context.Background()); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Wait() error = %v, want ErrExpired", err)
+	}
+	resp, err := pinnedClient(s.PublicKeyPin).Get(s.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("GET after expiry = %d, want 410", resp.StatusCode)
+	}
+}
@@ -0,0 +1,337 @@
+// Package sink renders generated kubeconfigs into destinations other than a plain file,
+// such as Kubernetes Secret manifests consumed by GitOps tooling
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// Convention selects the Secret layout expected by a consumer
+type Convention string
+
+const (
+	// ConventionNone writes a single Opaque Secret holding the merged kubeconfig
+	ConventionNone Convention = ""
+	// ConventionFlux writes the kubeconfig under the "value" key read by Flux kubeConfig.secretRef
+	ConventionFlux Convention = "flux"
+	// ConventionArgoCD writes one declarative cluster Secret per context, labelled for Argo CD
+	ConventionArgoCD Convention = "argocd"
+)
+
+const (
+	// DefaultSecretNameTemplate is used when no name template is configured
+	DefaultSecretNameTemplate = "kubeconfig-{{ .Profile }}"
+
+	// DefaultSecretKey is the data key holding the kubeconfig for plain Secrets
+	DefaultSecretKey = "config"
+
+	fluxSecretKey       = "value"
+	argoCDSecretTypeKey = "argocd.argoproj.io/secret-type"
+)
+
+// SecretOptions configures how kubeconfig Secrets are named and labelled.
+// Name, label and annotation values are Go templates evaluated against SecretTemplateData.
+type SecretOptions struct {
+	// NameTemplate is the template for the Secret name (default: kubeconfig-{{ .Profile }})
+	NameTemplate string
+
+	// Namespace is the namespace written into the Secret metadata (optional)
+	Namespace string
+
+	// Key is the data key holding the kubeconfig (default: "config", or "value" for Flux)
+	Key string
+
+	// Labels are added to every Secret; values may be templates
+	Labels map[string]string
+
+	// Annotations are added to every Secret; values may be templates
+	Annotations map[string]string
+
+	// Convention selects a consumer-specific layout (flux, argocd)
+	Convention Convention
+}
+
+// SecretTemplateData is the data available to name, label and annotation templates
+type SecretTemplateData struct {
+	// Profile is the name of the configuration profile that produced the kubeconfig
+	Profile string
+
+	// Prefix is the cluster name prefix in effect
+	Prefix string
+
+	// Cluster is the context name (only set for per-cluster Secrets)
+	Cluster string
+
+	// Namespace is the target namespace
+	Namespace string
+}
+
+// secretManifest is the minimal v1 Secret representation written by the sink
+type secretManifest struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   secretMetadata    `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+type secretMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// argoCDClusterConfig is the "config" payload of an Argo CD cluster Secret
+type argoCDClusterConfig struct {
+	BearerToken     string                `json:"bearerToken,omitempty"`
+	Username        string                `json:"username,omitempty"`
+	Password        string                `json:"password,omitempty"`
+	TLSClientConfig argoCDTLSClientConfig `json:"tlsClientConfig"`
+}
+
+type argoCDTLSClientConfig struct {
+	Insecure   bool   `json:"insecure"`
+	ServerName string `json:"serverName,omitempty"`
+	CAData     []byte `json:"caData,omitempty"`
+	CertData   []byte `json:"certData,omitempty"`
+	KeyData    []byte `json:"keyData,omitempty"`
+}
+
+// RenderSecrets renders the kubeconfig as one or more Secret manifests,
+// returned as a multi-document YAML stream
+func RenderSecrets(opts SecretOptions, data SecretTemplateData, kubeconfig []byte) ([]byte, error) {
+	if data.Namespace == "" {
+		data.Namespace = opts.Namespace
+	}
+
+	var secrets []secretManifest
+	switch opts.Convention {
+	case ConventionNone, ConventionFlux:
+		secret, err := buildKubeconfigSecret(opts, data, kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	case ConventionArgoCD:
+		var err error
+		secrets, err = buildArgoCDSecrets(opts, data, kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown secret convention %q (expected flux or argocd)", opts.Convention)
+	}
+
+	var out bytes.Buffer
+	for i, secret := range secrets {
+		doc, err := yaml.Marshal(secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize secret %s: %w", secret.Metadata.Name, err)
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(doc)
+	}
+	return out.Bytes(), nil
+}
+
+// buildKubeconfigSecret builds a single Secret holding the whole kubeconfig
+func buildKubeconfigSecret(opts SecretOptions, data SecretTemplateData, kubeconfig []byte) (secretManifest, error) {
+	key := opts.Key
+	if key == "" {
+		key = DefaultSecretKey
+		if opts.Convention == ConventionFlux {
+			key = fluxSecretKey
+		}
+	}
+
+	meta, err := buildMetadata(opts, data, nil)
+	if err != nil {
+		return secretManifest{}, err
+	}
+
+	return secretManifest{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   meta,
+		Type:       "Opaque",
+		Data:       map[string][]byte{key: kubeconfig},
+	}, nil
+}
+
+// buildArgoCDSecrets builds one declarative cluster Secret per context
+func buildArgoCDSecrets(opts SecretOptions, data SecretTemplateData, kubeconfig []byte) ([]secretManifest, error) {
+	cfg, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secrets := make([]secretManifest, 0, len(names))
+	for _, contextName := range names {
+		ctx := cfg.Contexts[contextName]
+		cluster, ok := cfg.Clusters[ctx.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("context %s references unknown cluster %s", contextName, ctx.Cluster)
+		}
+
+		clusterConfig := argoCDClusterConfig{
+			TLSClientConfig: argoCDTLSClientConfig{
+				Insecure:   cluster.InsecureSkipTLSVerify,
+				ServerName: cluster.TLSServerName,
+				CAData:     cluster.CertificateAuthorityData,
+			},
+		}
+		if auth, ok := cfg.AuthInfos[ctx.AuthInfo]; ok {
+			clusterConfig.BearerToken = auth.Token
+			clusterConfig.Username = auth.Username
+			clusterConfig.Password = auth.Password
+			clusterConfig.TLSClientConfig.CertData = auth.ClientCertificateData
+			clusterConfig.TLSClientConfig.KeyData = auth.ClientKeyData
+		}
+		configJSON, err := json.Marshal(clusterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cluster config for %s: %w", contextName, err)
+		}
+
+		perCluster := data
+		perCluster.Cluster = contextName
+		meta, err := buildMetadata(opts, perCluster, map[string]string{argoCDSecretTypeKey: "cluster"})
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, secretManifest{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   meta,
+			Type:       "Opaque",
+			Data: map[string][]byte{
+				"name":   []byte(contextName),
+				"server": []byte(cluster.Server),
+				"config": configJSON,
+			},
+		})
+	}
+
+	return secrets, nil
+}
+
+// buildMetadata evaluates the name, label and annotation templates
+func buildMetadata(opts SecretOptions, data SecretTemplateData, extraLabels map[string]string) (secretMetadata, error) {
+	nameTemplate := opts.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = DefaultSecretNameTemplate
+		if opts.Convention == ConventionArgoCD {
+			nameTemplate = "cluster-{{ .Cluster }}"
+		}
+	}
+
+	name, err := renderTemplate("name", nameTemplate, data)
+	if err != nil {
+		return secretMetadata{}, err
+	}
+	name = SanitizeName(name)
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return secretMetadata{}, fmt.Errorf("invalid secret name %q: %s", name, strings.Join(errs, "; "))
+	}
+
+	meta := secretMetadata{
+		Name:      name,
+		Namespace: data.Namespace,
+	}
+
+	if len(opts.Labels) > 0 || len(extraLabels) > 0 {
+		meta.Labels = make(map[string]string)
+		for k, v := range extraLabels {
+			meta.Labels[k] = v
+		}
+		for k, v := range opts.Labels {
+			value, err := renderTemplate("label "+k, v, data)
+			if err != nil {
+				return secretMetadata{}, err
+			}
+			meta.Labels[k] = value
+		}
+	}
+
+	if len(opts.Annotations) > 0 {
+		meta.Annotations = make(map[string]string)
+		for k, v := range opts.Annotations {
+			value, err := renderTemplate("annotation "+k, v, data)
+			if err != nil {
+				return secretMetadata{}, err
+			}
+			meta.Annotations[k] = value
+		}
+	}
+
+	return meta, nil
+}
+
+// renderTemplate executes a single template string against the template data
+func renderTemplate(what, text string, data SecretTemplateData) (string, error) {
+	tmpl, err := template.New(what).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template %q: %w", what, text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template %q: %w", what, text, err)
+	}
+	return buf.String(), nil
+}
+
+// SanitizeName lowercases a rendered name and replaces characters that are not
+// allowed in Kubernetes object names with dashes
+func SanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	result := b.String()
+	for strings.Contains(result, "--") {
+		result = strings.ReplaceAll(result, "--", "-")
+	}
+	result = strings.Trim(result, "-.")
+	if len(result) > validation.DNS1123SubdomainMaxLength {
+		result = strings.TrimRight(result[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+	return result
+}
+
+// ParseKeyValues parses "key=value" pairs as supplied on the command line
+func ParseKeyValues(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
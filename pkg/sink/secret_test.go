@@ -0,0 +1,153 @@
+package sink
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const sampleKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://cluster1.example.com:6443
+    certificate-authority-data: dGVzdC1jYS1kYXRh
+  name: prod-east
+- cluster:
+    server: https://cluster2.example.com:6443
+  name: prod-west
+contexts:
+- context:
+    cluster: prod-east
+    user: prod-east
+  name: prod-east
+- context:
+    cluster: prod-west
+    user: prod-west
+  name: prod-west
+current-context: prod-east
+users:
+- name: prod-east
+  user:
+    token: token-east
+- name: prod-west
+  user:
+    token: token-west
+`
+
+func decodeSecrets(t *testing.T, data []byte) []secretManifest {
+	t.Helper()
+	var secrets []secretManifest
+	for _, doc := range strings.Split(string(data), "---\n") {
+		var s secretManifest
+		if err := yaml.Unmarshal([]byte(doc), &s); err != nil {
+			t.Fatalf("failed to parse rendered secret: %v", err)
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets
+}
+
+func TestRenderSecrets_DefaultName(t *testing.T) {
+	out, err := RenderSecrets(SecretOptions{}, SecretTemplateData{Profile: "Prod Rancher"}, []byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secrets := decodeSecrets(t, out)
+	if len(secrets) != 1 {
+		t.Fatalf("expected 1 secret, got %d", len(secrets))
+	}
+	if secrets[0].Metadata.Name != "kubeconfig-prod-rancher" {
+		t.Errorf("name = %q, want %q", secrets[0].Metadata.Name, "kubeconfig-prod-rancher")
+	}
+	if string(secrets[0].Data[DefaultSecretKey]) != sampleKubeconfig {
+		t.Error("secret data should contain the kubeconfig under the default key")
+	}
+}
+
+func TestRenderSecrets_TemplatedLabels(t *testing.T) {
+	opts := SecretOptions{
+		NameTemplate: "{{ .Prefix }}kubeconfig",
+		Namespace:    "flux-system",
+		Labels:       map[string]string{"app.kubernetes.io/managed-by": "kubeconfig-wrangler", "profile": "{{ .Profile }}"},
+		Annotations:  map[string]string{"note": "generated for {{ .Namespace }}"},
+		Convention:   ConventionFlux,
+	}
+	out, err := RenderSecrets(opts, SecretTemplateData{Profile: "lab", Prefix: "team-a-"}, []byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := decodeSecrets(t, out)[0]
+	if s.Metadata.Name != "team-a-kubeconfig" {
+		t.Errorf("name = %q, want %q", s.Metadata.Name, "team-a-kubeconfig")
+	}
+	if s.Metadata.Namespace != "flux-system" {
+		t.Errorf("namespace = %q, want %q", s.Metadata.Namespace, "flux-system")
+	}
+	if s.Metadata.Labels["profile"] != "lab" {
+		t.Errorf("profile label = %q, want %q", s.Metadata.Labels["profile"], "lab")
+	}
+	if s.Metadata.Annotations["note"] != "generated for flux-system" {
+		t.Errorf("note annotation = %q", s.Metadata.Annotations["note"])
+	}
+	if _, ok := s.Data["value"]; !ok {
+		t.Error("flux convention should store the kubeconfig under the 'value' key")
+	}
+}
+
+func TestRenderSecrets_ArgoCD(t *testing.T) {
+	out, err := RenderSecrets(SecretOptions{Convention: ConventionArgoCD, Namespace: "argocd"}, SecretTemplateData{Profile: "prod"}, []byte(sampleKubeconfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secrets := decodeSecrets(t, out)
+	if len(secrets) != 2 {
+		t.Fatalf("expected one secret per context, got %d", len(secrets))
+	}
+	if secrets[0].Metadata.Name != "cluster-prod-east" {
+		t.Errorf("name = %q, want %q", secrets[0].Metadata.Name, "cluster-prod-east")
+	}
+	if secrets[0].Metadata.Labels[argoCDSecretTypeKey] != "cluster" {
+		t.Error("argocd secrets must carry the cluster secret-type label")
+	}
+	if string(secrets[0].Data["server"]) != "https://cluster1.example.com:6443" {
+		t.Errorf("server = %q", secrets[0].Data["server"])
+	}
+	if !strings.Contains(string(secrets[0].Data["config"]), `"bearerToken":"token-east"`) {
+		t.Errorf("config should carry the bearer token, got %s", secrets[0].Data["config"])
+	}
+	ca := base64.StdEncoding.EncodeToString([]byte("test-ca-data"))
+	if !strings.Contains(string(secrets[0].Data["config"]), ca) {
+		t.Error("config should carry the CA data")
+	}
+}
+
+func TestRenderSecrets_Errors(t *testing.T) {
+	if _, err := RenderSecrets(SecretOptions{Convention: "bogus"}, SecretTemplateData{Profile: "x"}, []byte(sampleKubeconfig)); err == nil {
+		t.Error("expected error for unknown convention")
+	}
+	if _, err := RenderSecrets(SecretOptions{NameTemplate: "{{ .Missing }}"}, SecretTemplateData{}, []byte(sampleKubeconfig)); err == nil {
+		t.Error("expected error for unknown template field")
+	}
+	if _, err := RenderSecrets(SecretOptions{NameTemplate: "!!!"}, SecretTemplateData{}, []byte(sampleKubeconfig)); err == nil {
+		t.Error("expected error for a name that sanitizes to empty")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := ParseKeyValues([]string{"a=1", "b=x=y"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["a"] != "1" || got["b"] != "x=y" {
+		t.Errorf("unexpected result: %v", got)
+	}
+	if _, err := ParseKeyValues([]string{"novalue"}); err == nil {
+		t.Error("expected error for missing '='")
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	// Database drivers for the supported backends
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// dialect holds what differs between the SQL backends
+type dialect struct {
+	driver string
+	schema []string
+}
+
+var sqliteDialect = dialect{
+	driver: "sqlite",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TIMESTAMP NOT NULL,
+			kind TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL,
+			detail TEXT NOT NULL,
+			success BOOLEAN NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS cache (
+			key TEXT PRIMARY KEY,
+			value BLOB NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS counters (
+			key TEXT PRIMARY KEY,
+			count INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+	},
+}
+
+var postgresDialect = dialect{
+	driver: "postgres",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS events (
+			id BIGSERIAL PRIMARY KEY,
+			time TIMESTAMPTZ NOT NULL,
+			kind TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL,
+			detail TEXT NOT NULL,
+			success BOOLEAN NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS cache (
+			key TEXT PRIMARY KEY,
+			value BYTEA NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS counters (
+			key TEXT PRIMARY KEY,
+			count BIGINT NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+	},
+}
+
+// sqlStore is a Store backed by database/sql
+type sqlStore struct {
+	db *sql.DB
+}
+
+// openSQL connects to a database and makes sure the schema exists
+func openSQL(d dialect, dsn string) (*sqlStore, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", d.driver, err)
+	}
+	if d.driver == "sqlite" {
+		// SQLite allows a single writer; serialize access instead of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	statements := append(d.schema, "CREATE INDEX IF NOT EXISTS events_kind_time ON events (kind, time)")
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize %s storage: %w", d.driver, err)
+		}
+	}
+	return &sqlStore{db: db}, nil
+}
+
+// Record implements Store
+func (s *sqlStore) Record(ctx context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO events (time, kind, actor, action, target, detail, success) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		event.Time.UTC(), string(event.Kind), event.Actor, event.Action, event.Target, event.Detail, event.Success)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	return nil
+}
+
+// List implements Store
+func (s *sqlStore) List(ctx context.Context, query Query) ([]Event, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	var rows *sql.Rows
+	var err error
+	if query.Kind != "" {
+		rows, err = s.db.QueryContext(ctx,
+			"SELECT id, time, kind, actor, action, target, detail, success FROM events WHERE kind = $1 ORDER BY time DESC, id DESC LIMIT $2",
+			string(query.Kind), limit)
+	} else {
+		rows, err = s.db.QueryContext(ctx,
+			"SELECT id, time, kind, actor, action, target, detail, success FROM events ORDER BY time DESC, id DESC LIMIT $1",
+			limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var kind string
+		if err := rows.Scan(&event.ID, &event.Time, &kind, &event.Actor, &event.Action, &event.Target, &event.Detail, &event.Success); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		event.Kind = Kind(kind)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	return events, nil
+}
+
+// Prune implements Store
+func (s *sqlStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE time < $1", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Get implements Store
+func (s *sqlStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx,
+		"SELECT value FROM cache WHERE key = $1 AND expires_at > $2",
+		key, time.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set implements Store
+func (s *sqlStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	// Expired entries are only ever skipped by Get; drop them here so the table stays small
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM cache WHERE expires_at <= $1", now.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to expire cache entries: %w", err)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO cache (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, now.Add(ttl).UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Increment implements Store
+func (s *sqlStore) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now().UnixMilli()
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM counters WHERE expires_at <= $1", now); err != nil {
+		return 0, fmt.Errorf("failed to expire counters: %w", err)
+	}
+
+	// A single upsert keeps concurrent replicas from losing increments
+	var count int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO counters (key, count, expires_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET count = counters.count + 1
+		RETURNING count`,
+		key, now+window.Milliseconds()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	return count, nil
+}
+
+// Close implements Store
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_RecordAndList(t *testing.T) {
+	store, err := Open("sqlite://" + filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: base, Kind: KindAudit, Actor: "127.0.0.1", Action: "create-profile", Target: "prod", Success: true},
+		{Time: base.Add(time.Minute), Kind: KindJob, Actor: "127.0.0.1", Action: "generate", Detail: "3 clusters", Success: true},
+		{Time: base.Add(2 * time.Minute), Kind: KindAudit, Actor: "10.0.0.1", Action: "delete-profile", Target: "dev", Success: false},
+	}
+	for _, event := range events {
+		if err := store.Record(ctx, event); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	all, err := store.List(ctx, Query{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(all) != 3 || all[0].Action != "delete-profile" || all[2].Action != "create-profile" {
+		t.Fatalf("List() = %+v, want all events newest first", all)
+	}
+	if !all[2].Time.Equal(base) || all[2].Target != "prod" || !all[2].Success || all[2].ID == 0 {
+		t.Errorf("event not round-tripped: %+v", all[2])
+	}
+
+	audit, err := store.List(ctx, Query{Kind: KindAudit, Limit: 1})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(audit) != 1 || audit[0].Action != "delete-profile" || audit[0].Success {
+		t.Errorf("List(audit, 1) = %+v", audit)
+	}
+}
+
+func TestSQLiteStore_Prune(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, age := range []time.Duration{100 * 24 * time.Hour, 91 * 24 * time.Hour, time.Hour} {
+		if err := store.Record(ctx, Event{Time: now.Add(-age), Kind: KindAudit, Actor: "a", Action: "generate"}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	n, err := store.Prune(ctx, now.Add(-90*24*time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("Prune() = %d, %v, want 2 events deleted", n, err)
+	}
+	events, err := store.List(ctx, Query{})
+	if err != nil || len(events) != 1 || !events[0].Time.Equal(now.Add(-time.Hour)) {
+		t.Errorf("List() after Prune() = %+v, %v, want only the recent event", events, err)
+	}
+}
+
+func TestSQLiteStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := store.Record(context.Background(), Event{Kind: KindJob, Actor: "a", Action: "generate"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	store.Close()
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer store.Close()
+	events, err := store.List(context.Background(), Query{})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("List() after reopen = %v, %v, want 1 event", events, err)
+	}
+}
+
+func TestOpen_UnsupportedScheme(t *testing.T) {
+	_, err := Open("mysql://root:hunter2@db/kw")
+	if err == nil {
+		t.Fatal("expected an error for an unsupported scheme")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error leaks the password: %v", err)
+	}
+}
+
+func TestSQLiteStore_Cache(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	if _, ok, err := store.Get(ctx, "clusters"); err != nil || ok {
+		t.Fatalf("Get() on empty cache = %v, %v, want miss", ok, err)
+	}
+	if err := store.Set(ctx, "clusters", []byte("v1"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set(ctx, "clusters", []byte("v2"), time.Minute); err != nil {
+		t.Fatalf("Set() overwrite error = %v", err)
+	}
+	value, ok, err := store.Get(ctx, "clusters")
+	if err != nil || !ok || string(value) != "v2" {
+		t.Fatalf("Get() = %q, %v, %v, want v2", value, ok, err)
+	}
+
+	if err := store.Set(ctx, "stale", []byte("x"), -time.Second); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, ok, _ := store.Get(ctx, "stale"); ok {
+		t.Error("Get() returned an expired entry")
+	}
+}
+
+func TestSQLiteStore_Increment(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := store.Increment(ctx, "client-a", time.Minute)
+		if err != nil || count != want {
+			t.Fatalf("Increment() = %d, %v, want %d", count, err, want)
+		}
+	}
+	if count, _ := store.Increment(ctx, "client-b", time.Minute); count != 1 {
+		t.Errorf("Increment() of another key = %d, want 1", count)
+	}
+
+	// A counter whose window has passed starts over
+	if _, err := store.Increment(ctx, "short", time.Millisecond); err != nil {
+		t.Fatalf("Increment() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if count, _ := store.Increment(ctx, "short", time.Millisecond); count != 1 {
+		t.Errorf("Increment() after the window = %d, want 1", count)
+	}
+}
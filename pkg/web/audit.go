@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/storage"
+)
+
+// SetStorage persists the audit log and job history in store and exposes them
+// at /api/audit. The store also holds the cluster list cache, the public
+// catalog and rate-limit counters, so replicas sharing one database present
+// the same view and share their load on Rancher.
+func (s *Server) SetStorage(store storage.Store) {
+	s.storage = store
+	s.clusterCache.SetShared(store, 0)
+	s.mux.HandleFunc("/api/audit", s.handleAudit)
+}
+
+// DefaultStorageRetention is how long audit and job events are kept by default
+const DefaultStorageRetention = 90 * 24 * time.Hour
+
+// pruneInterval is how often events older than the retention are deleted
+const pruneInterval = time.Hour
+
+// SetStorageRetention sets how long the audit log and job history are kept in
+// storage; 0 keeps them forever
+func (s *Server) SetStorageRetention(retention time.Duration) {
+	s.storageRetention = retention
+}
+
+// pruneEvents deletes the events older than the retention every
+// pruneInterval, starting right away, until ctx is done. Every replica
+// prunes; deleting rows another one already deleted is harmless.
+func (s *Server) pruneEvents(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		pruneCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		n, err := s.storage.Prune(pruneCtx, time.Now().Add(-s.storageRetention))
+		cancel()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Warning: %v", err)
+		case n > 0:
+			log.Printf("Deleted %d audit and job event(s) older than %s", n, s.storageRetention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordEvent stores an audit or job event. Storage failures are logged but
+// never fail the request being recorded.
+func (s *Server) recordEvent(r *http.Request, kind storage.Kind, action, target, detail string, success bool) {
+	if s.storage == nil {
+		return
+	}
+
+	event := storage.Event{
+		Kind:    kind,
+		Actor:   s.requestActor(r),
+		Action:  action,
+		Target:  target,
+		Detail:  detail,
+		Success: success,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.storage.Record(ctx, event); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// recordJob stores a completed kubeconfig generation in the job history
+func (s *Server) recordJob(r *http.Request, action, target string, clusters int) {
+	s.recordEvent(r, storage.KindJob, action, target, fmt.Sprintf("%d cluster(s)", clusters), true)
+}
+
+// SetTrustedProxies sets the load balancers and proxies in front of the
+// server. Requests coming through them are attributed to the client address
+// they report in X-Forwarded-For, so that each client gets its own rate limit
+// and audit entries rather than sharing the load balancer's.
+func (s *Server) SetTrustedProxies(proxies []netip.Prefix) {
+	s.trustedProxies = proxies
+}
+
+// ParseTrustedProxies parses addresses and CIDR ranges of trusted proxies
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	proxies := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: expected an IP address or CIDR range", value)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// isTrustedProxy reports whether addr is one of the trusted proxies
+func (s *Server) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestActor identifies who made a request: the client address, as the web
+// server has no user accounts. Behind trusted proxies it is the last address
+// of X-Forwarded-For that is not itself a trusted proxy, since clients can
+// put anything before it.
+func (s *Server) requestActor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err != nil || !s.isTrustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		host = addr.Unmap().String()
+		if !s.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return host
+}
+
+// handleAudit lists stored events, newest first, optionally filtered by ?kind= and capped by ?limit=
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, APIResponse{
+			Success: false,
+			Error:   "Method not allowed",
+		})
+		return
+	}
+
+	query := storage.Query{Kind: storage.Kind(r.URL.Query().Get("kind"))}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			s.writeJSON(w, http.StatusBadRequest, APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid limit: %q", value),
+			})
+			return
+		}
+		query.Limit = limit
+	}
+
+	events, err := s.storage.List(r.Context(), query)
+	if err != nil {
+		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/profile"
+)
+
+// catalogTTL is how long the public catalog is served from memory before the
+// sources are queried again, so anonymous traffic cannot hammer Rancher or AWS
+const catalogTTL = time.Minute
+
+// catalogCacheKey is where the catalog is cached when storage is configured
+const catalogCacheKey = "catalog"
+
+// CatalogEntry is the credential-free view of a cluster shown in the public catalog
+type CatalogEntry struct {
+	Name     string `json:"name"`
+	Source   string `json:"source"`
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Version  string `json:"version,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// catalog caches the public cluster catalog
+type catalog struct {
+	mu        sync.Mutex
+	entries   []CatalogEntry
+	fetchedAt time.Time
+}
+
+// EnableCatalog exposes a read-only cluster catalog at /catalog and /api/catalog
+// that does not require the auth token. It lists cluster names, states and
+// versions only; kubeconfig generation stays behind authentication.
+func (s *Server) EnableCatalog() {
+	s.catalog = &catalog{}
+	s.mux.HandleFunc("/catalog", s.handleCatalogPage)
+	s.mux.HandleFunc("/api/catalog", s.handleCatalog)
+}
+
+// isPublicPath reports whether a path is served without token and origin checks
+func (s *Server) isPublicPath(path string) bool {
+	return s.catalog != nil && (path == "/catalog" || path == "/api/catalog")
+}
+
+// catalogEntries returns the cached catalog, refreshing it from every saved profile once stale
+func (s *Server) catalogEntries() []CatalogEntry {
+	s.catalog.mu.Lock()
+	defer s.catalog.mu.Unlock()
+
+	if s.catalog.entries != nil && time.Since(s.catalog.fetchedAt) < catalogTTL {
+		return s.catalog.entries
+	}
+	if entries, ok := s.sharedCatalog(); ok {
+		s.catalog.entries = entries
+		s.catalog.fetchedAt = time.Now()
+		return entries
+	}
+
+	entries := []CatalogEntry{}
+	if s.profileStore != nil {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, p := range s.profileStore.List() {
+			wg.Add(1)
+			go func(prof *profile.Profile) {
+				defer wg.Done()
+				clusters, err := s.getClustersForProfile(prof)
+				if err != nil {
+					log.Printf("catalog: failed to list clusters for profile %s: %v", prof.Name, err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, c := range clusters {
+					name := c.Name
+					if c.Alias != "" {
+						name = c.Alias
+					}
+					entries = append(entries, CatalogEntry{
+						Name:     name,
+						Source:   prof.Name,
+						Provider: c.Provider,
+						State:    c.State,
+						Version:  c.Version,
+						Region:   c.Region,
+					})
+				}
+			}(p)
+		}
+		wg.Wait()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Source != entries[j].Source {
+			return entries[i].Source < entries[j].Source
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	s.catalog.entries = entries
+	s.catalog.fetchedAt = time.Now()
+	s.storeSharedCatalog(entries)
+	return entries
+}
+
+// sharedCatalog returns the catalog another replica cached in storage, if fresh
+func (s *Server) sharedCatalog() ([]CatalogEntry, bool) {
+	if s.storage == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	data, ok, err := s.storage.Get(ctx, catalogCacheKey)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return nil, false
+	}
+	var entries []CatalogEntry
+	if !ok || json.Unmarshal(data, &entries) != nil {
+		return nil, false
+	}
+	return entries, true
+}
+
+// expireCatalog makes the next request list the clusters again, on every
+// replica sharing the storage
+func (s *Server) expireCatalog() {
+	if s.catalog == nil {
+		return
+	}
+	s.catalog.mu.Lock()
+	s.catalog.entries = nil
+	s.catalog.mu.Unlock()
+
+	if s.storage == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// An empty entry is not a valid catalog, so sharedCatalog ignores it
+	if err := s.storage.Set(ctx, catalogCacheKey, nil, catalogTTL); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// storeSharedCatalog caches the catalog in storage for the other replicas
+func (s *Server) storeSharedCatalog(entries []CatalogEntry) {
+	if s.storage == nil {
+		return
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.storage.Set(ctx, catalogCacheKey, data, catalogTTL); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// handleCatalog returns the public cluster catalog as JSON
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, APIResponse{
+			Success: false,
+			Error:   "Method not allowed",
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.catalogEntries(),
+	})
+}
+
+// handleCatalogPage renders the public cluster catalog as HTML
+func (s *Server) handleCatalogPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := template.New("catalog").Parse(catalogHTML)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, s.catalogEntries()); err != nil {
+		log.Printf("Error executing catalog template: %v", err)
+	}
+}
+
+// catalogHTML is the template for the public cluster catalog page
+const catalogHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Cluster Catalog - Kubeconfig Wrangler</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 2rem; color: #1f2937; }
+  h1 { font-size: 1.5rem; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: 0.5rem 0.75rem; border-bottom: 1px solid #e5e7eb; }
+  th { background: #f9fafb; font-weight: 600; }
+  .state-active, .state-ACTIVE { color: #047857; }
+  .empty { color: #6b7280; }
+</style>
+</head>
+<body>
+<h1>Cluster Catalog</h1>
+{{if .}}
+<table>
+  <thead><tr><th>Cluster</th><th>Source</th><th>Provider</th><th>State</th><th>Version</th><th>Region</th></tr></thead>
+  <tbody>
+  {{range .}}
+    <tr><td>{{.Name}}</td><td>{{.Source}}</td><td>{{.Provider}}</td><td class="state-{{.State}}">{{.State}}</td><td>{{.Version}}</td><td>{{.Region}}</td></tr>
+  {{end}}
+  </tbody>
+</table>
+{{else}}
+<p class="empty">No clusters available.</p>
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/config"
+	"github.com/kubeconfig-wrangler/pkg/profile"
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// profileWatchInterval is how often the saved profiles are checked for Rancher
+// instances to start or stop watching
+const profileWatchInterval = time.Minute
+
+// EnableClusterEvents subscribes to the cluster events of every saved Rancher
+// profile while the server runs, dropping the cached kubeconfigs and catalog
+// as soon as a cluster is created, removed or changes state instead of
+// serving them until they expire
+func (s *Server) EnableClusterEvents() {
+	s.clusterEvents = true
+}
+
+// watchClusterEvents watches the cluster events of the saved Rancher profiles
+// until ctx is done. Profiles saved, changed or deleted later are picked up
+// within profileWatchInterval.
+func (s *Server) watchClusterEvents(ctx context.Context) {
+	if s.profileStore == nil {
+		return
+	}
+	// Keyed by profile ID and update time, so an edited profile reconnects
+	// with its new credentials, while one whose credentials were rejected
+	// is not retried until it is edited
+	watches := make(map[string]context.CancelFunc)
+	ticker := time.NewTicker(profileWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		current := make(map[string]bool)
+		for _, p := range s.profileStore.List() {
+			if p.Type != profile.ProfileTypeRancher {
+				continue
+			}
+			key := p.ID + "@" + p.UpdatedAt.String()
+			current[key] = true
+			if _, ok := watches[key]; !ok {
+				watchCtx, cancel := context.WithCancel(ctx)
+				watches[key] = cancel
+				go s.watchProfileClusters(watchCtx, p)
+			}
+		}
+		for key, cancel := range watches {
+			if !current[key] {
+				cancel()
+				delete(watches, key)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchProfileClusters watches the cluster events of one Rancher profile
+func (s *Server) watchProfileClusters(ctx context.Context, p *profile.Profile) {
+	cfg := &config.Config{
+		RancherURL:            p.RancherURL,
+		Token:                 p.Token,
+		Username:              p.Username,
+		Password:              p.Password,
+		InsecureSkipTLSVerify: p.SkipTLS,
+		CACert:                p.CACert,
+		CACertData:            p.CACertData,
+		ExcludeSystemCAs:      p.ExcludeSystemCAs,
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Printf("Warning: not watching the clusters of profile %s: %v", p.Name, err)
+		return
+	}
+	client, err := rancher.NewClient(cfg, rancher.WithReporter(s.reporter))
+	if err != nil {
+		log.Printf("Warning: not watching the clusters of profile %s: %v", p.Name, err)
+		return
+	}
+
+	err = client.WatchClusters(ctx, func(event rancher.ClusterEvent) {
+		log.Printf("Cluster %s of profile %s: %s, dropping cached kubeconfigs", event.Cluster.Name, p.Name, event.Type)
+		s.kubeconfigCache.clear()
+		s.expireCatalog()
+	})
+	if err != nil {
+		log.Printf("Warning: stopped watching the clusters of profile %s until it is updated: %v", p.Name, err)
+	}
+}
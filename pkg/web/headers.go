@@ -0,0 +1,67 @@
+package web
+
+import (
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// hstsHeader is only sent over HTTPS, where browsers honor it
+const hstsHeader = "Strict-Transport-Security"
+
+// DefaultSecurityHeaders returns the headers sent with every response. The
+// page is a single document with inline script and style and talks to its own
+// API only; responses carry credentials, so nothing may be cached or framed.
+func DefaultSecurityHeaders() map[string]string {
+	return map[string]string{
+		"Content-Security-Policy": "default-src 'none'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+			"connect-src 'self'; img-src 'self'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'",
+		"X-Content-Type-Options":       "nosniff",
+		"X-Frame-Options":              "DENY",
+		"Referrer-Policy":              "no-referrer",
+		"Cross-Origin-Opener-Policy":   "same-origin",
+		"Cross-Origin-Resource-Policy": "same-origin",
+		"Permissions-Policy":           "camera=(), microphone=(), geolocation=()",
+		"Cache-Control":                "no-store",
+		hstsHeader:                     "max-age=31536000; includeSubDomains",
+	}
+}
+
+// SetSecurityHeaders overrides the default security headers: each value
+// replaces the default of its header, or adds a header, and an empty value
+// drops the header
+func (s *Server) SetSecurityHeaders(overrides map[string]string) {
+	headers := DefaultSecurityHeaders()
+	for name, value := range overrides {
+		name = http.CanonicalHeaderKey(name)
+		if value == "" {
+			delete(headers, name)
+			continue
+		}
+		headers[name] = value
+	}
+	s.securityHeaders = headers
+}
+
+// headersMiddleware sets the security headers on every response, including
+// errors. HSTS is only sent when the request came over HTTPS, directly or
+// through a TLS-terminating proxy.
+func (s *Server) headersMiddleware(next http.Handler) http.Handler {
+	headers := s.securityHeaders
+	if headers == nil {
+		headers = DefaultSecurityHeaders()
+	}
+	headers = maps.Clone(headers)
+	hsts, hasHSTS := headers[hstsHeader]
+	delete(headers, hstsHeader)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		if hasHSTS && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+			w.Header().Set(hstsHeader, hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/rancher"
+)
+
+// DefaultKubeconfigCacheTTL is how long the kubeconfigs fetched for a cluster
+// selection are reused by default
+const DefaultKubeconfigCacheTTL = time.Minute
+
+// kubeconfigCache keeps the per-cluster kubeconfigs fetched for a selection
+// for a short while. Regenerating the same selection with only another
+// prefix, tags or file layout then reuses them, instead of asking Rancher to
+// mint a new token per cluster on every click.
+type kubeconfigCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]kubeconfigCacheEntry
+}
+
+type kubeconfigCacheEntry struct {
+	kubeconfigs map[string]string
+	expiresAt   time.Time
+}
+
+// SetKubeconfigCacheTTL sets how long the kubeconfigs fetched for a cluster
+// selection are reused by the same client; 0 disables the cache
+func (s *Server) SetKubeconfigCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		s.kubeconfigCache = nil
+		return
+	}
+	s.kubeconfigCache = &kubeconfigCache{ttl: ttl, entries: make(map[string]kubeconfigCacheEntry)}
+}
+
+// get returns a copy of the kubeconfigs cached under key, if they are still fresh
+func (c *kubeconfigCache) get(key string) (map[string]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return maps.Clone(entry.kubeconfigs), true
+}
+
+// put caches kubeconfigs under key, dropping the entries that have expired
+func (c *kubeconfigCache) put(key string, kubeconfigs map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = kubeconfigCacheEntry{kubeconfigs: maps.Clone(kubeconfigs), expiresAt: now.Add(c.ttl)}
+}
+
+// clear drops every cached kubeconfig, e.g. after a cluster changed
+func (c *kubeconfigCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// fetchCached returns the kubeconfigs cached under key, or fetches them. Only
+// complete results are cached, so that clusters which failed are retried on
+// the next request.
+func (c *kubeconfigCache) fetchCached(key string, fetch func() (map[string]string, []rancher.ClusterFailure, error)) (map[string]string, []rancher.ClusterFailure, error) {
+	if kubeconfigs, ok := c.get(key); ok {
+		log.Printf("Reusing %d kubeconfig(s) fetched for the same selection", len(kubeconfigs))
+		return kubeconfigs, nil, nil
+	}
+	kubeconfigs, failures, err := fetch()
+	if err == nil && len(failures) == 0 && len(kubeconfigs) > 0 {
+		c.put(key, kubeconfigs)
+	}
+	return kubeconfigs, failures, err
+}
+
+// selectionKey identifies a cluster selection made by one client with one set
+// of credentials. The parts are hashed so that no credential is kept in the
+// key; clusters are sorted so that the order of the selection does not matter.
+func selectionKey(actor string, source []string, clusters []string) string {
+	clusters = append([]string(nil), clusters...)
+	sort.Strings(clusters)
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		actor,
+		strings.Join(source, "\x00"),
+		strings.Join(clusters, "\x00"),
+	}, "\x01")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow is the fixed window over which requests are counted
+const rateLimitWindow = time.Minute
+
+// rateLimitedPrefixes are the API paths that reach Rancher or other providers
+var rateLimitedPrefixes = []string{"/api/clusters", "/api/generate"}
+
+// rateLimiter counts requests per client in fixed one-minute windows. With
+// storage configured the counters live in the database, so the limit holds
+// across every replica behind a load balancer.
+type rateLimiter struct {
+	limit int
+
+	mu      sync.Mutex
+	counts  map[string]int
+	resetAt time.Time
+}
+
+// SetRateLimit caps how many cluster listing and generation requests each
+// client may make per minute; 0 disables the limit
+func (s *Server) SetRateLimit(perMinute int) {
+	if perMinute <= 0 {
+		s.rateLimit = nil
+		return
+	}
+	s.rateLimit = &rateLimiter{limit: perMinute, counts: make(map[string]int)}
+}
+
+// rateLimitMiddleware rejects requests over the limit with 429 Too Many Requests
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.rateLimit == nil || !isRateLimitedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		actor := s.requestActor(r)
+		count, err := s.countRequest(r.Context(), actor)
+		if err != nil {
+			// Fail open: an unavailable database must not take the server down with it
+			log.Printf("Warning: rate limit check failed: %v", err)
+		} else if count > s.rateLimit.limit {
+			log.Printf("Rate limit exceeded for %s", actor)
+			w.Header().Set("Retry-After", strconv.Itoa(int(rateLimitWindow.Seconds())))
+			s.writeJSON(w, http.StatusTooManyRequests, APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Rate limit of %d requests per minute exceeded", s.rateLimit.limit),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countRequest records a request from actor and returns its count in the current window
+func (s *Server) countRequest(ctx context.Context, actor string) (int, error) {
+	if s.storage != nil {
+		count, err := s.storage.Increment(ctx, "ratelimit:"+actor, rateLimitWindow)
+		return int(count), err
+	}
+
+	rl := s.rateLimit
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if now := time.Now(); now.After(rl.resetAt) {
+		rl.counts = make(map[string]int)
+		rl.resetAt = now.Add(rateLimitWindow)
+	}
+	rl.counts[actor]++
+	return rl.counts[actor], nil
+}
+
+// isRateLimitedPath reports whether a request path is subject to the rate limit
+func isRateLimitedPath(path string) bool {
+	for _, prefix := range rateLimitedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
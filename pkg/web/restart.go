@@ -0,0 +1,227 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kubeconfig-wrangler/pkg/noexec"
+)
+
+const (
+	// DefaultShutdownTimeout is how long in-flight requests may take to finish
+	// when the server stops or hands over to a new process
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// listenFDEnv tells a re-executed server which inherited file descriptor is
+	// the listening socket
+	listenFDEnv = "KUBECONFIG_WRANGLER_LISTEN_FD"
+
+	// readyFDEnv tells a re-executed server which inherited file descriptor to
+	// write to once it serves on the inherited socket
+	readyFDEnv = "KUBECONFIG_WRANGLER_READY_FD"
+
+	// handOffTimeout is how long the new server process may take to report
+	// that it serves before the old one gives up on it and keeps serving
+	handOffTimeout = 30 * time.Second
+)
+
+// SetReusePort binds the listening socket with SO_REUSEPORT, so a new server
+// process can start on the same address before the old one stops
+func (s *Server) SetReusePort(enabled bool) {
+	s.reusePort = enabled
+}
+
+// SetShutdownTimeout sets how long in-flight requests may take to finish on shutdown
+func (s *Server) SetShutdownTimeout(timeout time.Duration) {
+	s.shutdownTimeout = timeout
+}
+
+// listen returns the socket inherited from the previous server process, if any,
+// or binds a new one
+func (s *Server) listen() (net.Listener, error) {
+	if value := os.Getenv(listenFDEnv); value != "" {
+		os.Unsetenv(listenFDEnv)
+		fd, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", listenFDEnv, err)
+		}
+		ln, err := net.FileListener(os.NewFile(uintptr(fd), "listener"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited listener: %w", err)
+		}
+		log.Printf("Took over listening socket from the previous server process")
+		return ln, nil
+	}
+
+	lc := net.ListenConfig{}
+	if s.reusePort {
+		if err := setReusePort(&lc); err != nil {
+			return nil, err
+		}
+	}
+	return lc.Listen(context.Background(), "tcp", s.addr)
+}
+
+// serve runs the HTTP server until it is stopped by a signal. SIGINT and SIGTERM
+// stop accepting connections and drain in-flight requests. Where supported,
+// SIGHUP first starts the executable on disk (typically an upgraded binary)
+// with the same arguments, handing it the listening socket, so no connection
+// is refused during the upgrade. This process keeps serving until the new one
+// reports that it serves too, and carries on if it never does. Only the
+// listening socket is handed over: the in-memory kubeconfig cache, cluster
+// list validators, catalog and, without storage, rate-limit counters start
+// empty in the new process.
+func (s *Server) serve(handler http.Handler) error {
+	ln, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	srv := &http.Server{Handler: handler}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+	signalReady()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, restartSignals...)...)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case sig := <-signals:
+			if isRestartSignal(sig) {
+				pid, err := handOff(ln)
+				if err != nil {
+					log.Printf("Restart failed, continuing to serve: %v", err)
+					continue
+				}
+				log.Printf("Handed the listening socket to process %d", pid)
+			}
+			return s.drain(srv)
+		}
+	}
+}
+
+// drain stops accepting connections and waits for in-flight requests
+func (s *Server) drain(srv *http.Server) error {
+	timeout := s.shutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down cleanly: %w", err)
+	}
+	return nil
+}
+
+// isRestartSignal reports whether sig requests a restart with socket handoff
+func isRestartSignal(sig os.Signal) bool {
+	for _, restart := range restartSignals {
+		if sig == restart {
+			return true
+		}
+	}
+	return false
+}
+
+// signalReady tells the previous server process, if it started this one, that
+// this one now serves on the inherited socket
+func signalReady() {
+	value := os.Getenv(readyFDEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(readyFDEnv)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s: %v", readyFDEnv, err)
+		return
+	}
+	ready := os.NewFile(uintptr(fd), "ready")
+	defer ready.Close()
+	if _, err := ready.Write([]byte{1}); err != nil {
+		log.Printf("Warning: failed to tell the previous server process that this one is ready: %v", err)
+	}
+}
+
+// handOff starts a new server process that inherits the listening socket and
+// waits until it reports that it serves. A process that exits or does not
+// report within handOffTimeout is killed, so the caller keeps serving.
+func handOff(ln net.Listener) (int, error) {
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return 0, errors.New("listener does not expose its file descriptor")
+	}
+	file, err := filer.File()
+	if err != nil {
+		return 0, fmt.Errorf("failed to duplicate listening socket: %w", err)
+	}
+	defer file.Close()
+
+	if err := noexec.Check("restart with socket handoff"); err != nil {
+		return 0, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer readyRead.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{file, readyWrite}
+	// ExtraFiles[i] becomes file descriptor 3+i in the child
+	cmd.Env = append(os.Environ(), listenFDEnv+"=3", readyFDEnv+"=4")
+	err = cmd.Start()
+	// Only the child holds the write end now, so the read below fails as
+	// soon as the child exits
+	readyWrite.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start %s: %w", executable, err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyRead.Read(make([]byte, 1))
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+		if err != nil {
+			err = fmt.Errorf("process %d exited before serving", cmd.Process.Pid)
+		}
+	case <-time.After(handOffTimeout):
+		err = fmt.Errorf("process %d did not start serving within %s", cmd.Process.Pid, handOffTimeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return 0, err
+	}
+	return cmd.Process.Pid, nil
+}
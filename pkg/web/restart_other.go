@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package web
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// restartSignals is empty: socket handoff relies on SIGHUP
+var restartSignals []os.Signal
+
+// setReusePort reports that SO_REUSEPORT is unavailable on this platform
+func setReusePort(lc *net.ListenConfig) error {
+	return errors.New("--reuse-port is not supported on this platform")
+}
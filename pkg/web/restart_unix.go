@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package web
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// restartSignals trigger a restart with socket handoff
+var restartSignals = []os.Signal{syscall.SIGHUP}
+
+// setReusePort makes the listener bind with SO_REUSEPORT
+func setReusePort(lc *net.ListenConfig) error {
+	lc.Control = func(network, address string, conn syscall.RawConn) error {
+		var sockErr error
+		err := conn.Control(func(fd uintptr) {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+	return nil
+}
@@ -4,20 +4,27 @@ package web
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/kubeconfig-wrangler/pkg/config"
 	kctx "github.com/kubeconfig-wrangler/pkg/context"
+	"github.com/kubeconfig-wrangler/pkg/events"
 	"github.com/kubeconfig-wrangler/pkg/kubeconfig"
 	"github.com/kubeconfig-wrangler/pkg/profile"
 	"github.com/kubeconfig-wrangler/pkg/provider"
 	"github.com/kubeconfig-wrangler/pkg/rancher"
+	"github.com/kubeconfig-wrangler/pkg/storage"
 )
 
 // Server represents the web server
@@ -28,6 +35,23 @@ type Server struct {
 	profileStore *profile.Store
 	registry     *provider.Registry
 	ctxSwitcher  *kctx.Switcher
+	clusterCache *rancher.ListCache
+	reporter     events.Reporter
+	catalog      *catalog
+
+	clusterEvents bool
+
+	securityHeaders map[string]string
+
+	kubeconfigCache *kubeconfigCache
+
+	reusePort       bool
+	shutdownTimeout time.Duration
+
+	storage          storage.Store
+	storageRetention time.Duration
+	rateLimit        *rateLimiter
+	trustedProxies   []netip.Prefix
 }
 
 // ClusterInfo holds cluster information for the API
@@ -88,11 +112,21 @@ func NewServer(addr string, token string) *Server {
 		profileStore: store,
 		registry:     provider.NewRegistry(),
 		ctxSwitcher:  kctx.NewSwitcher(),
+		clusterCache: rancher.NewListCache(),
+		reporter:     events.NewLogger(slog.Default()),
 	}
+	s.SetKubeconfigCacheTTL(DefaultKubeconfigCacheTTL)
+	s.SetStorageRetention(DefaultStorageRetention)
 	s.setupRoutes()
 	return s
 }
 
+// SetReporter sets where the Rancher clients of the server report progress and
+// skipped clusters; by default they are logged with the default slog logger
+func (s *Server) SetReporter(reporter events.Reporter) {
+	s.reporter = reporter
+}
+
 // setupRoutes configures the HTTP routes
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/", s.handleIndex)
@@ -135,6 +169,12 @@ func (s *Server) securityMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// The public catalog is read-only and credential-free
+		if s.isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Only apply security to API endpoints
 		if !strings.HasPrefix(r.URL.Path, "/api/") {
 			next.ServeHTTP(w, r)
@@ -173,11 +213,25 @@ func (s *Server) Start() error {
 	if s.token != "" {
 		log.Printf("Security: token authentication enabled")
 	}
+	if s.catalog != nil {
+		log.Printf("Public read-only cluster catalog at http://%s/catalog", s.addr)
+	}
 	log.Printf("Open http://%s in your browser", s.addr)
 
-	// Wrap the mux with security middleware
-	handler := s.securityMiddleware(s.mux)
-	return http.ListenAndServe(s.addr, handler)
+	// Wrap the mux with security middleware; rate limiting applies to
+	// authenticated requests only, response headers to all of them
+	handler := s.headersMiddleware(s.securityMiddleware(s.rateLimitMiddleware(s.mux)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if s.storage != nil && s.storageRetention > 0 {
+		go s.pruneEvents(ctx)
+	}
+	if s.clusterEvents {
+		go s.watchClusterEvents(ctx)
+		log.Printf("Watching the clusters of saved Rancher profiles for changes")
+	}
+	return s.serve(handler)
 }
 
 // templateData holds data passed to the HTML template
@@ -243,18 +297,19 @@ func (s *Server) handleListClusters(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := rancher.NewClient(cfg)
+	client, err := rancher.NewClient(cfg, rancher.WithReporter(s.reporter))
 	if err != nil {
-		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+		s.writeJSON(w, rancherErrorStatus(err), APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create Rancher client: %v", err),
 		})
 		return
 	}
+	client.SetListCache(s.clusterCache)
 
 	clusters, err := client.ListClusters()
 	if err != nil {
-		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+		s.writeJSON(w, rancherErrorStatus(err), APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list clusters: %v", err),
 		})
@@ -314,19 +369,20 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := rancher.NewClient(cfg)
+	client, err := rancher.NewClient(cfg, rancher.WithReporter(s.reporter))
 	if err != nil {
-		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+		s.writeJSON(w, rancherErrorStatus(err), APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create Rancher client: %v", err),
 		})
 		return
 	}
+	client.SetListCache(s.clusterCache)
 
 	// Get list of clusters to determine which to include
 	clusters, err := client.ListClusters()
 	if err != nil {
-		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+		s.writeJSON(w, rancherErrorStatus(err), APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list clusters: %v", err),
 		})
@@ -339,57 +395,65 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		selectedSet[name] = true
 	}
 
-	// Fetch kubeconfigs for selected clusters in parallel
-	kubeconfigs := make(map[string]string)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	errors := make([]string, 0)
-
+	var selected []rancher.Cluster
+	var selectedNames []string
 	for _, cluster := range clusters {
 		// Skip if not selected (when selection is provided)
 		if len(selectedSet) > 0 && !selectedSet[cluster.Name] {
 			continue
 		}
 
-		// Skip inactive clusters
-		if cluster.State != "active" {
+		// Skip clusters that are not in an accepted state
+		if !cfg.AcceptsClusterState(cluster.State) {
 			continue
 		}
+		selected = append(selected, cluster)
+		selectedNames = append(selectedNames, cluster.ID+"/"+cluster.Name)
+	}
+	key := selectionKey(s.requestActor(r), []string{req.RancherURL, req.Token, req.Username, req.Password}, selectedNames)
 
-		wg.Add(1)
-		go func(c rancher.Cluster) {
-			defer wg.Done()
+	// Fetch kubeconfigs for selected clusters in parallel
+	kubeconfigs, failures, _ := s.kubeconfigCache.fetchCached(key, func() (map[string]string, []rancher.ClusterFailure, error) {
+		kubeconfigs := make(map[string]string)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var failures []rancher.ClusterFailure
+
+		for _, cluster := range selected {
+			wg.Add(1)
+			go func(c rancher.Cluster) {
+				defer wg.Done()
+
+				kubeconfig, err := client.GetClusterKubeconfig(&c)
+				if err != nil {
+					mu.Lock()
+					failures = append(failures, rancher.ClusterFailure{Name: c.Name, ID: c.ID, Err: err})
+					mu.Unlock()
+					return
+				}
 
-			kubeconfig, err := client.GetClusterKubeconfig(&c)
-			if err != nil {
 				mu.Lock()
-				errors = append(errors, fmt.Sprintf("%s: %v", c.Name, err))
+				kubeconfigs[c.Name] = kubeconfig
 				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			kubeconfigs[c.Name] = kubeconfig
-			mu.Unlock()
-		}(cluster)
-	}
+			}(cluster)
+		}
 
-	wg.Wait()
+		wg.Wait()
+		return kubeconfigs, failures, nil
+	})
 
 	if len(kubeconfigs) == 0 {
-		errMsg := "No kubeconfigs retrieved"
-		if len(errors) > 0 {
-			errMsg = fmt.Sprintf("Failed to get kubeconfigs: %v", errors)
-		}
 		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
 			Success: false,
-			Error:   errMsg,
+			Error:   noKubeconfigsError(failures),
 		})
 		return
 	}
+	setFailedClustersHeader(w, failures)
 
 	// Generate merged kubeconfig
 	generator := kubeconfig.NewGenerator(cfg.ClusterPrefix)
+	generator.SetGeneratedAt(time.Now())
 	// Add Aptakube tags if specified
 	if len(req.AptakubeTags) > 0 {
 		generator.SetAllTags(req.AptakubeTags)
@@ -404,6 +468,7 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return as downloadable file
+	s.recordJob(r, "generate", req.RancherURL, len(kubeconfigs))
 	w.Header().Set("Content-Type", "application/x-yaml")
 	w.Header().Set("Content-Disposition", "attachment; filename=kubeconfig.yaml")
 	if _, err := w.Write(kubeconfigData); err != nil {
@@ -411,6 +476,47 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// failedClustersHeader lists, on a successful generation, the clusters whose
+// kubeconfig could not be fetched, as comma-separated URL-escaped names
+const failedClustersHeader = "X-Failed-Clusters"
+
+// setFailedClustersHeader reports partially failed generations to the browser
+func setFailedClustersHeader(w http.ResponseWriter, failures []rancher.ClusterFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	names := make([]string, len(failures))
+	for i, failure := range failures {
+		names[i] = url.QueryEscape(failure.Name)
+	}
+	w.Header().Set(failedClustersHeader, strings.Join(names, ","))
+}
+
+// noKubeconfigsError describes a generation where no kubeconfig could be fetched
+func noKubeconfigsError(failures []rancher.ClusterFailure) string {
+	if len(failures) == 0 {
+		return "No kubeconfigs retrieved for selected clusters"
+	}
+	messages := make([]string, len(failures))
+	for i, failure := range failures {
+		messages[i] = failure.Error()
+	}
+	return "Failed to get kubeconfigs: " + strings.Join(messages, "; ")
+}
+
+// rancherErrorStatus maps an error from a Rancher call to the status returned to
+// the browser: rejected credentials and permissions are passed through, other
+// Rancher API errors are reported as a bad gateway
+func rancherErrorStatus(err error) int {
+	switch status := rancher.StatusCode(err); {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return status
+	case status != 0:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
 // writeJSON writes a JSON response
 func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -502,6 +608,7 @@ func (s *Server) createProfile(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	s.recordEvent(r, storage.KindAudit, "create-profile", p.Name, string(p.Type), true)
 
 	s.writeJSON(w, http.StatusCreated, APIResponse{
 		Success: true,
@@ -562,6 +669,7 @@ func (s *Server) handleProfileByID(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		s.recordEvent(r, storage.KindAudit, "update-profile", p.Name, string(p.Type), true)
 		s.writeJSON(w, http.StatusOK, APIResponse{
 			Success: true,
 			Data:    p,
@@ -577,6 +685,7 @@ func (s *Server) handleProfileByID(w http.ResponseWriter, r *http.Request) {
 		}
 		// Also unregister the provider if it exists
 		s.registry.Unregister(id)
+		s.recordEvent(r, storage.KindAudit, "delete-profile", id, "", true)
 		s.writeJSON(w, http.StatusOK, APIResponse{
 			Success: true,
 		})
@@ -666,7 +775,7 @@ func (s *Server) handleListClustersForProfile(w http.ResponseWriter, r *http.Req
 	clusters, err := s.getClustersForProfile(p)
 	if err != nil {
 		log.Printf("handleListClustersForProfile: error=%v", err)
-		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
+		s.writeJSON(w, rancherErrorStatus(err), APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list clusters: %v", err),
 		})
@@ -696,14 +805,16 @@ func (s *Server) getClustersForProfile(p *profile.Profile) ([]provider.ClusterIn
 	switch p.Type {
 	case profile.ProfileTypeRancher:
 		cfg := provider.RancherConfig{
-			ProfileID:     p.ID,
-			ProfileName:   p.Name,
-			URL:           p.RancherURL,
-			Token:         p.Token,
-			Username:      p.Username,
-			Password:      p.Password,
-			SkipTLSVerify: p.SkipTLS,
-			CACert:        p.CACert,
+			ProfileID:        p.ID,
+			ProfileName:      p.Name,
+			URL:              p.RancherURL,
+			Token:            p.Token,
+			Username:         p.Username,
+			Password:         p.Password,
+			SkipTLSVerify:    p.SkipTLS,
+			CACert:           p.CACert,
+			CACertData:       p.CACertData,
+			ExcludeSystemCAs: p.ExcludeSystemCAs,
 		}
 		prov, err := provider.NewRancherProvider(cfg)
 		if err != nil {
@@ -858,6 +969,7 @@ func (s *Server) handleGenerateForAllSources(w http.ResponseWriter, r *http.Requ
 
 	// Get kubeconfigs for each profile
 	kubeconfigs := make(map[string]string)
+	var failures []rancher.ClusterFailure
 	for profileID, clusters := range clustersByProfile {
 		p, err := s.profileStore.Get(profileID)
 		if err != nil {
@@ -865,11 +977,12 @@ func (s *Server) handleGenerateForAllSources(w http.ResponseWriter, r *http.Requ
 			continue
 		}
 
-		profileKubeconfigs, err := s.getKubeconfigsForProfile(p, clusters)
+		profileKubeconfigs, profileFailures, err := s.cachedKubeconfigsForProfile(r, p, clusters)
 		if err != nil {
 			log.Printf("Error getting kubeconfigs for profile %s: %v", p.Name, err)
 			continue
 		}
+		failures = append(failures, profileFailures...)
 
 		// Apply source name prefix if requested
 		sourcePrefix := ""
@@ -886,12 +999,14 @@ func (s *Server) handleGenerateForAllSources(w http.ResponseWriter, r *http.Requ
 	if len(kubeconfigs) == 0 {
 		s.writeJSON(w, http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "No kubeconfigs retrieved for selected clusters",
+			Error:   noKubeconfigsError(failures),
 		})
 		return
 	}
+	setFailedClustersHeader(w, failures)
 
 	generator := kubeconfig.NewGenerator(req.ClusterPrefix)
+	generator.SetGeneratedAt(time.Now())
 	if len(req.AptakubeTags) > 0 {
 		generator.SetAllTags(req.AptakubeTags)
 	}
@@ -931,6 +1046,7 @@ func (s *Server) handleGenerateForAllSources(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
+		s.recordJob(r, "generate", "all sources", len(kubeconfigs))
 		w.Header().Set("Content-Type", "application/zip")
 		w.Header().Set("Content-Disposition", "attachment; filename=kubeconfigs.zip")
 		if _, err := w.Write(zipBuffer.Bytes()); err != nil {
@@ -949,6 +1065,7 @@ func (s *Server) handleGenerateForAllSources(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	s.recordJob(r, "generate", "all sources", len(kubeconfigs))
 	w.Header().Set("Content-Type", "application/x-yaml")
 	w.Header().Set("Content-Disposition", "attachment; filename=kubeconfig.yaml")
 	if _, err := w.Write(kubeconfigData); err != nil {
@@ -1327,7 +1444,7 @@ func (s *Server) handleGenerateForProfile(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get kubeconfigs for selected clusters
-	rawKubeconfigs, err := s.getKubeconfigsForProfile(p, req.SelectedClusters)
+	rawKubeconfigs, failures, err := s.cachedKubeconfigsForProfile(r, p, req.SelectedClusters)
 	if err != nil {
 		s.writeJSON(w, http.StatusInternalServerError, APIResponse{
 			Success: false,
@@ -1350,12 +1467,14 @@ func (s *Server) handleGenerateForProfile(w http.ResponseWriter, r *http.Request
 	if len(kubeconfigs) == 0 {
 		s.writeJSON(w, http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "No kubeconfigs retrieved for selected clusters",
+			Error:   noKubeconfigsError(failures),
 		})
 		return
 	}
+	setFailedClustersHeader(w, failures)
 
 	generator := kubeconfig.NewGenerator(req.ClusterPrefix)
+	generator.SetGeneratedAt(time.Now())
 	if len(req.AptakubeTags) > 0 {
 		generator.SetAllTags(req.AptakubeTags)
 	}
@@ -1397,6 +1516,7 @@ func (s *Server) handleGenerateForProfile(w http.ResponseWriter, r *http.Request
 			return
 		}
 
+		s.recordJob(r, "generate", req.ProfileID, len(kubeconfigs))
 		w.Header().Set("Content-Type", "application/zip")
 		w.Header().Set("Content-Disposition", "attachment; filename=kubeconfigs.zip")
 		if _, err := w.Write(zipBuffer.Bytes()); err != nil {
@@ -1416,6 +1536,7 @@ func (s *Server) handleGenerateForProfile(w http.ResponseWriter, r *http.Request
 	}
 
 	// Return as downloadable file
+	s.recordJob(r, "generate", req.ProfileID, len(kubeconfigs))
 	w.Header().Set("Content-Type", "application/x-yaml")
 	w.Header().Set("Content-Disposition", "attachment; filename=kubeconfig.yaml")
 	if _, err := w.Write(kubeconfigData); err != nil {
@@ -1423,26 +1544,44 @@ func (s *Server) handleGenerateForProfile(w http.ResponseWriter, r *http.Request
 	}
 }
 
-// getKubeconfigsForProfile retrieves kubeconfigs for selected clusters from a profile
+// cachedKubeconfigsForProfile is getKubeconfigsForProfile, reusing what the
+// same client fetched for the same selection moments ago. Editing the profile
+// changes its UpdatedAt and so never reuses kubeconfigs of old credentials.
+func (s *Server) cachedKubeconfigsForProfile(r *http.Request, p *profile.Profile, selectedClusters []ClusterSelection) (map[string]string, []rancher.ClusterFailure, error) {
+	selected := make([]string, 0, len(selectedClusters))
+	for _, cluster := range selectedClusters {
+		selected = append(selected, cluster.ID+"/"+cluster.DisplayName())
+	}
+	key := selectionKey(s.requestActor(r), []string{p.ID, p.UpdatedAt.String()}, selected)
+	return s.kubeconfigCache.fetchCached(key, func() (map[string]string, []rancher.ClusterFailure, error) {
+		return s.getKubeconfigsForProfile(p, selectedClusters)
+	})
+}
+
+// getKubeconfigsForProfile retrieves kubeconfigs for selected clusters from a profile,
+// along with the clusters that failed.
 // Uses cluster.ID for API calls and cluster.Name as the key in the returned map for labeling
-func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters []ClusterSelection) (map[string]string, error) {
+func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters []ClusterSelection) (map[string]string, []rancher.ClusterFailure, error) {
 	kubeconfigs := make(map[string]string)
+	var failures []rancher.ClusterFailure
 
 	switch p.Type {
 	case profile.ProfileTypeRancher:
 		cfg := provider.RancherConfig{
-			ProfileID:     p.ID,
-			ProfileName:   p.Name,
-			URL:           p.RancherURL,
-			Token:         p.Token,
-			Username:      p.Username,
-			Password:      p.Password,
-			SkipTLSVerify: p.SkipTLS,
-			CACert:        p.CACert,
+			ProfileID:        p.ID,
+			ProfileName:      p.Name,
+			URL:              p.RancherURL,
+			Token:            p.Token,
+			Username:         p.Username,
+			Password:         p.Password,
+			SkipTLSVerify:    p.SkipTLS,
+			CACert:           p.CACert,
+			CACertData:       p.CACertData,
+			ExcludeSystemCAs: p.ExcludeSystemCAs,
 		}
 		prov, err := provider.NewRancherProvider(cfg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer prov.Close()
 
@@ -1451,6 +1590,7 @@ func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters [
 			kc, err := prov.GetKubeconfig(cluster.ID)
 			if err != nil {
 				log.Printf("Warning: failed to get kubeconfig for cluster %s (id: %s): %v", cluster.Name, cluster.ID, err)
+				failures = append(failures, rancher.ClusterFailure{Name: cluster.Name, ID: cluster.ID, Err: err})
 				continue
 			}
 			kubeconfigs[cluster.DisplayName()] = kc
@@ -1468,7 +1608,7 @@ func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters [
 		}
 		prov, err := provider.NewEKSProvider(cfg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer prov.Close()
 
@@ -1477,6 +1617,7 @@ func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters [
 			kc, err := prov.GetKubeconfig(cluster.ID)
 			if err != nil {
 				log.Printf("Warning: failed to get kubeconfig for cluster %s: %v", cluster.Name, err)
+				failures = append(failures, rancher.ClusterFailure{Name: cluster.Name, ID: cluster.ID, Err: err})
 				continue
 			}
 			kubeconfigs[cluster.DisplayName()] = kc
@@ -1490,7 +1631,7 @@ func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters [
 		}
 		prov, err := provider.NewStaticProvider(cfg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer prov.Close()
 
@@ -1499,16 +1640,17 @@ func (s *Server) getKubeconfigsForProfile(p *profile.Profile, selectedClusters [
 			kc, err := prov.GetKubeconfig(cluster.ID)
 			if err != nil {
 				log.Printf("Warning: failed to get kubeconfig for cluster %s: %v", cluster.Name, err)
+				failures = append(failures, rancher.ClusterFailure{Name: cluster.Name, ID: cluster.ID, Err: err})
 				continue
 			}
 			kubeconfigs[cluster.DisplayName()] = kc
 		}
 
 	default:
-		return nil, fmt.Errorf("unknown profile type: %s", p.Type)
+		return nil, nil, fmt.Errorf("unknown profile type: %s", p.Type)
 	}
 
-	return kubeconfigs, nil
+	return kubeconfigs, failures, nil
 }
 
 // indexHTML is the embedded HTML template for the web interface
@@ -2447,6 +2589,10 @@ const indexHTML = `<!DOCTYPE html>
             border-left: 3px solid var(--error);
         }
 
+        .toast.warning {
+            border-left: 3px solid var(--warning);
+        }
+
         .hidden {
             display: none !important;
         }
@@ -2783,6 +2929,18 @@ const indexHTML = `<!DOCTYPE html>
             setTimeout(() => toast.classList.remove('visible'), 3000);
         }
 
+        // Warn about the clusters left out of a generated kubeconfig, returning
+        // whether there were any
+        function warnFailedClusters(response) {
+            const failed = response.headers.get('X-Failed-Clusters');
+            if (!failed) {
+                return false;
+            }
+            const names = failed.split(',').map(name => decodeURIComponent(name.replace(/\+/g, ' ')));
+            showToast(names.length + ' cluster(s) could not be fetched and were left out: ' + names.join(', '), 'warning');
+            return true;
+        }
+
         function setLoading(loading) {
             document.getElementById('loadingOverlay').classList.toggle('visible', loading);
         }
@@ -3015,7 +3173,9 @@ const indexHTML = `<!DOCTYPE html>
                     });
                     const mergeResult = await mergeResponse.json();
                     if (mergeResult.success) {
-                        showToast('Contexts merged into ~/.kube/config successfully!', 'success');
+                        if (!warnFailedClusters(response)) {
+                            showToast('Contexts merged into ~/.kube/config successfully!', 'success');
+                        }
                         // Refresh cluster list to show updated badges
                         if (selectedProfileId === '__all__') {
                             loadAllClusters();
@@ -3045,7 +3205,9 @@ const indexHTML = `<!DOCTYPE html>
                 window.URL.revokeObjectURL(url);
                 a.remove();
 
-                showToast('Kubeconfig downloaded successfully!', 'success');
+                if (!warnFailedClusters(response)) {
+                    showToast('Kubeconfig downloaded successfully!', 'success');
+                }
 
             } catch (error) {
                 showToast('Failed to generate: ' + error.message, 'error');